@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/hdt3213/rdb/helper"
 )
@@ -12,11 +13,11 @@ import (
 const help = `
 This is a tool to parse Redis' RDB files
 Options:
-  -c command, including: json/memory/aof/bigkey/prefix/flamegraph
+  -c command, including: json/memory/aof/bigkey/prefix/flamegraph/pattern
   -o output file path
   -n number of result, using in command: bigkey/prefix
   -port listen port for flame graph web service
-  -sep separator for flamegraph, rdb will separate key by it, default value is ":". 
+  -sep separator for flamegraph and pattern, rdb will separate key by it, default value is ":". 
 		supporting multi separators: -sep sep1 -sep sep2 
   -regex using regex expression filter keys
   -expire filter keys by its expiration time
@@ -27,6 +28,12 @@ Options:
 		5. 'anyexpire' get all keys with expiration time
   -no-expired filter expired keys(deprecated, please use 'expire' option)
   -concurrent The number of concurrent json converters. 4 by default.
+  -expire-shift shift all expirations by a duration during aof export, e.g. '24h', '-1h30m'.
+		keys whose shifted expiration is in the past will be dropped
+  -strip-expirations remove expirations of all keys and hash fields during aof export
+  -no-values emit 'SET key ""' instead of real values during aof export, databases and expirations are preserved
+  -typed-json output json in the typed schema, whose value has a fixed shape for each type
+  -key-length output byte length and character count of keys in json
 
 Examples:
 parameters between '[' and ']' is optional
@@ -42,6 +49,8 @@ parameters between '[' and ']' is optional
   rdb -c prefix [-n 10] [-max-depth 3] [-o prefix-report.csv] dump.rdb
 6. draw flamegraph
   rdb -c flamegraph [-port 16379] [-sep :] dump.rdb
+7. get memory size by inferred key pattern
+  rdb -c pattern [-sep :] [-o pattern-report.csv] dump.rdb
 `
 
 type separators []string
@@ -67,6 +76,11 @@ func main() {
 	var expirationExpr string
 	var maxDepth int
 	var concurrent int
+	var expireShift time.Duration
+	var noValues bool
+	var stripExpirations bool
+	var typedJSON bool
+	var keyLength bool
 	var err error
 	flagSet.StringVar(&cmd, "c", "", "command for rdb: json")
 	flagSet.StringVar(&output, "o", "", "output file path")
@@ -78,6 +92,11 @@ func main() {
 	flagSet.StringVar(&regexExpr, "regex", "", "regex expression")
 	flagSet.StringVar(&expirationExpr, "expire", "", "expiration filter expression")
 	flagSet.BoolVar(&noExpired, "no-expired", false, "filter expired keys(deprecated, please use expire)")
+	flagSet.DurationVar(&expireShift, "expire-shift", 0, "shift expirations during aof export")
+	flagSet.BoolVar(&noValues, "no-values", false, "emit key skeleton without values during aof export")
+	flagSet.BoolVar(&stripExpirations, "strip-expirations", false, "remove all expirations during aof export")
+	flagSet.BoolVar(&typedJSON, "typed-json", false, "output json in the typed schema")
+	flagSet.BoolVar(&keyLength, "key-length", false, "output length of keys in json")
 	_ = flagSet.Parse(os.Args[1:]) // ExitOnError
 	src := flagSet.Arg(0)
 
@@ -103,6 +122,21 @@ func main() {
 	if expirationExpr != "" {
 		options = append(options, helper.WithExpirationOption(expirationExpr))
 	}
+	if expireShift != 0 {
+		options = append(options, helper.WithExpireShift(expireShift))
+	}
+	if noValues {
+		options = append(options, helper.WithSkeletonOnly())
+	}
+	if stripExpirations {
+		options = append(options, helper.WithStripExpirations())
+	}
+	if typedJSON {
+		options = append(options, helper.WithTypedJSON())
+	}
+	if keyLength {
+		options = append(options, helper.WithKeyLength())
+	}
 
 	var outputFile *os.File
 	if output == "" {
@@ -123,7 +157,7 @@ func main() {
 	case "memory":
 		err = helper.MemoryProfile(src, output, options...)
 	case "aof":
-		err = helper.ToAOF(src, output, options)
+		err = helper.ToAOF(src, output, options...)
 	case "bigkey":
 		err = helper.FindBiggestKeys(src, n, outputFile, options...)
 	case "prefix":
@@ -135,6 +169,20 @@ func main() {
 			return
 		}
 		<-make(chan struct{})
+	case "pattern":
+		var rdbFile *os.File
+		rdbFile, err = os.Open(src)
+		if err != nil {
+			fmt.Printf("open rdb %s failed, %v\n", src, err)
+			return
+		}
+		defer func() {
+			_ = rdbFile.Close()
+		}()
+		if len(seps) > 0 {
+			options = append(options, helper.WithPatternSeparators(seps...))
+		}
+		err = helper.InferredKeyPatterns(rdbFile, outputFile, options...)
 	default:
 		println("unknown command")
 		return
@@ -0,0 +1,38 @@
+package core
+
+import "github.com/hdt3213/rdb/model"
+
+// defaultBatchSize is the batch size of ParseBatches if WithBatching is not set
+const defaultBatchSize = 128
+
+// WithBatching sets max number of objects ParseBatches passes to callback at a time
+func (dec *Decoder) WithBatching(size int) *Decoder {
+	dec.batchSize = size
+	return dec
+}
+
+// ParseBatches parses rdb and passes objects to cb in batches of WithBatching size, the last batch may be smaller.
+// The batch slice is reused between calls, so cb must copy it to retain objects after it returns.
+// cb returns true to continue, returns false to stop. Objects decoded before an error are passed to cb
+// before the error is returned
+func (dec *Decoder) ParseBatches(cb func(batch []model.RedisObject) bool) error {
+	size := dec.batchSize
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	batch := make([]model.RedisObject, 0, size)
+	stopped := false
+	err := dec.Parse(func(object model.RedisObject) bool {
+		batch = append(batch, object)
+		if len(batch) < size {
+			return true
+		}
+		stopped = !cb(batch)
+		batch = batch[:0]
+		return !stopped
+	})
+	if !stopped && len(batch) > 0 {
+		cb(batch)
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestParseBatches(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 25, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 25; i++ {
+		if err := enc.WriteStringObject("k"+strconv.Itoa(i), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	var sizes []int
+	var keys []string
+	err := NewDecoder(bytes.NewReader(data)).WithBatching(10).ParseBatches(func(batch []model.RedisObject) bool {
+		sizes = append(sizes, len(batch))
+		for _, obj := range batch {
+			keys = append(keys, obj.GetKey())
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || sizes[0] != 10 || sizes[1] != 10 || sizes[2] != 5 {
+		t.Errorf("expect batches of 10, 10 and 5, actual %v", sizes)
+	}
+	for i, key := range keys {
+		if key != "k"+strconv.Itoa(i) {
+			t.Errorf("expect key k%d, actual %s", i, key)
+		}
+	}
+
+	batches := 0
+	err = NewDecoder(bytes.NewReader(data)).WithBatching(10).ParseBatches(func(batch []model.RedisObject) bool {
+		batches++
+		return false
+	})
+	if err != nil || batches != 1 {
+		t.Errorf("expect stop after the first batch, actual %d, %v", batches, err)
+	}
+
+	// objects before error are still passed
+	total := 0
+	err = NewDecoder(bytes.NewReader(data[:len(data)-20])).WithBatching(10).ParseBatches(func(batch []model.RedisObject) bool {
+		total += len(batch)
+		return true
+	})
+	if err == nil {
+		t.Error("expect error for truncated rdb")
+	}
+	if total == 0 || total >= 25 {
+		t.Errorf("expect objects before error, actual %d", total)
+	}
+}
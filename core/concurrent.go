@@ -0,0 +1,184 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hdt3213/rdb/lzf"
+	"github.com/hdt3213/rdb/memprofiler"
+	"github.com/hdt3213/rdb/model"
+)
+
+// decodeJob decompresses value of a LZF compressed string object in a worker
+type decodeJob struct {
+	obj        *model.StringObject
+	compressed []byte
+	outLen     int
+	elapsed    time.Duration
+	err        error
+	done       chan struct{}
+	offset     int64 // offset is position of the object in rdb file, it is reported by ParseError
+}
+
+func (job *decodeJob) run() {
+	defer func() {
+		if err := recover(); err != nil {
+			job.err = fmt.Errorf("decompress value of %s failed: %v", job.obj.Key, err)
+		}
+		close(job.done)
+	}()
+	start := time.Now()
+	out, err := lzf.Decompress(job.compressed, len(job.compressed), job.outLen)
+	job.elapsed = time.Since(start)
+	if err != nil {
+		job.err = fmt.Errorf("decompress value of %s failed: %v", job.obj.Key, err)
+	} else {
+		job.obj.Value = out
+		job.obj.Encoding = stringObjectEncoding(out)
+	}
+}
+
+// pendingObject is an object waiting to be passed to callback, job is nil if it has been decoded
+type pendingObject struct {
+	obj  model.RedisObject
+	base *model.BaseObject
+	job  *decodeJob
+}
+
+// decodePipeline decompresses string values in workers while objects are passed to callback in order
+type decodePipeline struct {
+	jobs    chan *decodeJob
+	pending []pendingObject
+	window  int
+	err     error
+}
+
+func newDecodePipeline(workers int) *decodePipeline {
+	p := &decodePipeline{
+		jobs:   make(chan *decodeJob, workers*4),
+		window: workers * 4,
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range p.jobs {
+				job.run()
+			}
+		}()
+	}
+	return p
+}
+
+// WithDecodeConcurrency makes decoder decompress LZF compressed string values in n goroutines,
+// which speeds up dumps dominated by large compressible values. Only values of string keys are decompressed
+// concurrently, LZF compressed quicklist nodes and other elements of collections are still decoded in the calling
+// goroutine. Objects are still passed to callback one by one in the order they appear, but callback may be called
+// after decoder has read ahead of the object. n <= 1 means decoding in the calling goroutine
+func (dec *Decoder) WithDecodeConcurrency(n int) *Decoder {
+	dec.decodeConcurrency = n
+	return dec
+}
+
+// readConcurrentString reads a string object, its value is decoded by pipeline later if it is LZF compressed
+func (dec *Decoder) readConcurrentString(base *model.BaseObject) (model.RedisObject, error) {
+	length, special, err := dec.readLength()
+	if err != nil {
+		return nil, err
+	}
+	obj := &model.StringObject{
+		BaseObject: base,
+	}
+	if !special || length != encodeLZF {
+		obj.Value, err = dec.readStringContent(length, special)
+		if err != nil {
+			return nil, err
+		}
+		base.Encoding = stringObjectEncoding(obj.Value)
+		return obj, nil
+	}
+	inLen, _, err := dec.readLength()
+	if err != nil {
+		return nil, err
+	}
+	outLen, _, err := dec.readLength()
+	if err != nil {
+		return nil, err
+	}
+	compressedLen, err := lengthToInt(inLen)
+	if err != nil {
+		return nil, err
+	}
+	decompressedLen, err := lengthToInt(outLen)
+	if err != nil {
+		return nil, err
+	}
+	compressed := make([]byte, compressedLen)
+	if err = dec.readFull(compressed); err != nil {
+		return nil, err
+	}
+	dec.pendingJob = &decodeJob{
+		obj:        obj,
+		compressed: compressed,
+		outLen:     decompressedLen,
+		done:       make(chan struct{}),
+		offset:     dec.phaseOffset,
+	}
+	return obj, nil
+}
+
+// deliverObject completes object and passes it to callback
+func (dec *Decoder) deliverObject(obj model.RedisObject, base *model.BaseObject, cb func(object model.RedisObject) bool) bool {
+	base.Size = memprofiler.SizeOfObjectWithMeta(obj, dec.memMeta)
+	base.Type = obj.GetType()
+	dec.stats.Keys[base.Type]++
+	return cb(obj)
+}
+
+// pushObject queues obj and passes finished objects at the front of queue to callback.
+// It waits for the front object if the queue is full. It returns false if callback stopped or decoding failed
+func (dec *Decoder) pushObject(obj model.RedisObject, base *model.BaseObject, job *decodeJob,
+	cb func(object model.RedisObject) bool) bool {
+	p := dec.pipeline
+	if job != nil {
+		p.jobs <- job
+	}
+	p.pending = append(p.pending, pendingObject{obj: obj, base: base, job: job})
+	return dec.drainPending(cb, false)
+}
+
+// drainPending passes finished objects at the front of queue to callback, or all objects if all is true
+func (dec *Decoder) drainPending(cb func(object model.RedisObject) bool, all bool) bool {
+	p := dec.pipeline
+	for len(p.pending) > 0 {
+		front := p.pending[0]
+		if front.job != nil {
+			if all || len(p.pending) >= p.window {
+				<-front.job.done
+			} else {
+				select {
+				case <-front.job.done:
+				default:
+					return true
+				}
+			}
+			if front.job.err != nil {
+				p.err = &ParseError{
+					Offset: front.job.offset,
+					Opcode: typeString,
+					Phase:  PhaseValue,
+					Err:    front.job.err,
+				}
+				return false
+			}
+			dec.stats.LZFTime += front.job.elapsed
+			dec.stats.LZFCount++
+			dec.stats.LZFCompressedBytes += int64(len(front.job.compressed))
+			dec.stats.LZFDecompressedBytes += int64(len(front.job.obj.Value))
+		}
+		p.pending[0] = pendingObject{}
+		p.pending = p.pending[1:]
+		if !dec.deliverObject(front.obj, front.base, cb) {
+			return false
+		}
+	}
+	return true
+}
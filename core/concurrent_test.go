@@ -0,0 +1,172 @@
+package core
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hdt3213/rdb/lzf"
+	"github.com/hdt3213/rdb/model"
+)
+
+// buildCompressibleRDB writes count keys in each of 2 dbs, half of them are large compressible strings
+func buildCompressibleRDB(t testing.TB, count int, valueSize int) []byte {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf).EnableCompress()
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	for db := uint(0); db < 2; db++ {
+		if err := enc.WriteDBHeader(db, uint64(count), 0); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < count; i++ {
+			key := "k" + strconv.Itoa(i)
+			var err error
+			switch i % 4 {
+			case 0:
+				err = enc.WriteStringObject(key, []byte(strconv.Itoa(i)))
+			case 1:
+				err = enc.WriteListObject(key, [][]byte{[]byte("a"), []byte(key)})
+			default:
+				err = enc.WriteStringObject(key, []byte(strings.Repeat(key, valueSize/len(key))))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type decodedObject struct {
+	db       int
+	key      string
+	typ      string
+	encoding string
+	size     int
+	value    string
+}
+
+func decodeAll(t *testing.T, data []byte, concurrency int, limit int) ([]decodedObject, *Stats) {
+	var result []decodedObject
+	dec := NewDecoder(bytes.NewReader(data)).WithSpecialOpCode().WithDecodeConcurrency(concurrency)
+	err := dec.Parse(func(object model.RedisObject) bool {
+		item := decodedObject{
+			db:  object.GetDBIndex(),
+			key: object.GetKey(),
+			typ: object.GetType(),
+		}
+		if str, ok := object.(*model.StringObject); ok {
+			item.encoding = str.Encoding
+			item.size = str.Size
+			item.value = string(str.Value)
+		}
+		result = append(result, item)
+		return limit <= 0 || len(result) < limit
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, dec.Stats()
+}
+
+func TestDecodeConcurrency(t *testing.T) {
+	data := buildCompressibleRDB(t, 200, 4096)
+	expect, expectStats := decodeAll(t, data, 1, 0)
+	if expectStats.LZFCount != 200 {
+		t.Fatalf("expect 200 lzf strings, actual %d", expectStats.LZFCount)
+	}
+	actual, stats := decodeAll(t, data, 4, 0)
+	if !reflect.DeepEqual(expect, actual) {
+		t.Error("concurrent decoding returns different objects")
+	}
+	if stats.LZFCount != expectStats.LZFCount || stats.LZFDecompressedBytes != expectStats.LZFDecompressedBytes {
+		t.Errorf("expect %d lzf strings, actual %d", expectStats.LZFCount, stats.LZFCount)
+	}
+	if !reflect.DeepEqual(expectStats.Keys, stats.Keys) {
+		t.Errorf("expect keys %v, actual %v", expectStats.Keys, stats.Keys)
+	}
+
+	// callback is not called after it returns false
+	for _, limit := range []int{1, 7, 150, 201} {
+		actual, _ = decodeAll(t, data, 4, limit)
+		if len(actual) != limit {
+			t.Errorf("expect %d objects, actual %d", limit, len(actual))
+			continue
+		}
+		if !reflect.DeepEqual(expect[:limit], actual) {
+			t.Errorf("wrong objects before stop at %d", limit)
+		}
+	}
+}
+
+func TestDecodeConcurrencyCorrupt(t *testing.T) {
+	data := buildCompressibleRDB(t, 4, 4096)
+	compressed, err := lzf.Compress([]byte(strings.Repeat("k3", 2048)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.Index(data, compressed)
+	if i < 0 {
+		t.Fatal("compressed value not found")
+	}
+	// a back reference before the start of output
+	data[i] = 0x20
+	err = NewDecoder(bytes.NewReader(data)).WithDecodeConcurrency(4).Parse(func(object model.RedisObject) bool {
+		return true
+	})
+	if err == nil {
+		t.Error("expect error")
+	}
+}
+
+func TestDecodeConcurrencyTruncated(t *testing.T) {
+	data := buildCompressibleRDB(t, 40, 4096)
+	// truncate right after key k20 of db 0, so that k0 to k19 are complete
+	i := bytes.Index(data, []byte{3, 'k', '2', '0'})
+	if i < 0 {
+		t.Fatal("key not found")
+	}
+	data = data[:i+4]
+	for _, concurrency := range []int{1, 4} {
+		var keys []string
+		err := NewDecoder(bytes.NewReader(data)).WithDecodeConcurrency(concurrency).Parse(func(object model.RedisObject) bool {
+			keys = append(keys, object.GetKey())
+			return true
+		})
+		if err == nil {
+			t.Errorf("concurrency %d: expect error of truncated file", concurrency)
+		}
+		if len(keys) != 20 || keys[19] != "k19" {
+			t.Errorf("concurrency %d: expect k0 to k19 before error, actual %v", concurrency, keys)
+		}
+	}
+}
+
+func benchmarkDecodeConcurrency(b *testing.B, concurrency int) {
+	data := buildCompressibleRDB(b, 500, 64*1024)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := NewDecoder(bytes.NewReader(data)).WithDecodeConcurrency(concurrency).Parse(func(object model.RedisObject) bool {
+			return true
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeConcurrency1(b *testing.B) {
+	benchmarkDecodeConcurrency(b, 1)
+}
+
+func BenchmarkDecodeConcurrency4(b *testing.B) {
+	benchmarkDecodeConcurrency(b, 4)
+}
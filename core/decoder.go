@@ -7,34 +7,124 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"sort"
 	"strconv"
 	"time"
 
+	"github.com/hdt3213/rdb/crc64jones"
 	"github.com/hdt3213/rdb/memprofiler"
 	"github.com/hdt3213/rdb/model"
 )
 
 // Decoder is an instance of rdb parsing process
 type Decoder struct {
-	input     *bufio.Reader
-	readCount int
+	input     byteReader
+	source    io.Reader
+	readCount int64
 	buffer    []byte
+	// crc is running crc64 of all bytes read, excluding the checksum at the end
+	crc        hash.Hash64
+	crcByte    [1]byte
+	discardBuf []byte
 
-	withSpecialOpCode bool
-	withSpecialTypes  map[string]ModuleTypeHandleFunc
+	withSpecialOpCode  bool
+	withSpecialTypes   map[string]ModuleTypeHandleFunc
+	withVendorOpCodes  map[byte]VendorOpCodeHandleFunc
+	withTypeHandlers   map[byte]TypeHandleFunc
+	elementCap         int
+	maxElementCount    int
+	strictOpcodes      bool
+	withByteRanges     bool
+	withDumpPayload    bool
+	withRawContainer   bool
+	keyFilter          func(key string) bool
+	expireRange        bool
+	expireMin          int64
+	expireMax          int64
+	hashFieldFilter    func(field []byte) bool
+	withOrderedFields  bool
+	withOrderedMembers bool
+	withKeyMetadata    bool
+	streamIDMin        *model.StreamId
+	streamIDMax        *model.StreamId
+	diskless           bool
+
+	// version is rdb version in header
+	version int
+	// capturing is true while reading value if withDumpPayload, all bytes read are appended to captured
+	capturing bool
+	captured  []byte
+	// rawContainer is the last container read by readContainer if withRawContainer
+	rawContainer []byte
+
+	// hashFields records fields of current hash in on-disk order if withOrderedFields
+	hashFields []model.HashField
+
+	// truncated is true if some elements of current object were skipped because of elementCap
+	truncated bool
+
+	// auxFields stores all aux fields in the order they appear, including non-standard ones
+	auxFields []*model.AuxObject
 
 	// Redis 7.0+ metadata (RDB v12)
 	currentFreq uint8  // LFU frequency (0-255)
 	currentIdle uint64 // LRU idle time
+
+	stats *Stats
+
+	// memMeta describes redis which wrote rdb, it is used to evaluate memory usage
+	memMeta *memprofiler.RedisMeta
+	// archBitsFixed means memMeta.Bits is set by WithArchBits and redis-bits aux field is ignored
+	archBitsFixed bool
+
+	decodeConcurrency int
+	pipeline          *decodePipeline
+	// startDB is database of keys before the first selectdb opcode, it is set by ParseObject
+	startDB int
+
+	// phase, phaseOpcode and phaseOffset describe what is being read, they are reported by ParseError
+	phase       string
+	phaseOpcode byte
+	phaseOffset int64
+
+	collectWarnings bool
+	warnings        []Warning
+	encodingSanity  bool
+	// skippedKeyMetadata has names of KeyDB per key metadata which have been warned
+	skippedKeyMetadata map[string]struct{}
+
+	// batchSize is max number of objects passed to callback of ParseBatches at a time
+	batchSize int
+	// pendingJob is set by readConcurrentString if value of the object just read is left to pipeline
+	pendingJob *decodeJob
+	// step is state of Step, it is nil before the first Step
+	step *parseState
 }
 
+// defaultBufferSize is size of the buffer NewDecoder reads reader through
+const defaultBufferSize = 4096
+
 // NewDecoder creates a new RDB decoder
 func NewDecoder(reader io.Reader) *Decoder {
+	return NewDecoderSize(reader, defaultBufferSize)
+}
+
+// NewDecoderSize creates a new RDB decoder which reads reader through a buffer of at least bufSize bytes,
+// a larger buffer reduces reads on slow sources such as network sockets.
+// If reader is already a *bufio.Reader with at least bufSize bytes, it is used directly without double buffering
+func NewDecoderSize(reader io.Reader, bufSize int) *Decoder {
 	parser := new(Decoder)
-	parser.input = bufio.NewReader(reader)
+	parser.input = bufio.NewReaderSize(reader, bufSize)
+	parser.source = reader
 	parser.buffer = make([]byte, 8)
+	parser.crc = crc64jones.New()
 	parser.withSpecialTypes = make(map[string]ModuleTypeHandleFunc)
+	parser.withVendorOpCodes = make(map[byte]VendorOpCodeHandleFunc)
+	parser.withTypeHandlers = make(map[byte]TypeHandleFunc)
+	parser.stats = newStats()
+	parser.memMeta = &memprofiler.RedisMeta{Bits: 64}
 	return parser
 }
 
@@ -44,6 +134,126 @@ func (dec *Decoder) WithSpecialOpCode() *Decoder {
 	return dec
 }
 
+// WithElementCap makes decoder read only the first n elements of list, set, hash and zset.
+// The rest elements are skipped and the object is marked as Truncated. n <= 0 means no limit
+func (dec *Decoder) WithElementCap(n int) *Decoder {
+	dec.elementCap = n
+	return dec
+}
+
+// capReached returns true and marks current object as truncated if there are more elements
+// after n elements have been read
+func (dec *Decoder) capReached(n int) bool {
+	if dec.elementCap > 0 && n >= dec.elementCap {
+		dec.truncated = true
+		return true
+	}
+	return false
+}
+
+// WithMaxElementCount makes decoder return error if list, set, hash or zset declares more than n elements,
+// which protects against corrupt length. n <= 0 means no limit
+func (dec *Decoder) WithMaxElementCount(n int) *Decoder {
+	dec.maxElementCount = n
+	return dec
+}
+
+// WithDumpPayload makes decoder keep value of every key in the format of DUMP command in BaseObject.DumpPayload,
+// which can be used by RESTORE command. It doubles memory usage of each object
+func (dec *Decoder) WithDumpPayload() *Decoder {
+	dec.withDumpPayload = true
+	return dec
+}
+
+// WithRawContainer makes decoder keep the decompressed ziplist, listpack, intset or zipmap of values in
+// BaseObject.RawContainer, so that it could be compared with a hex dump to diagnose malformed containers
+func (dec *Decoder) WithRawContainer() *Decoder {
+	dec.withRawContainer = true
+	return dec
+}
+
+// WithByteRanges makes decoder set BaseObject.StartOffset and EndOffset of every object,
+// so that its raw bytes can be extracted from rdb file by data[StartOffset:EndOffset]
+func (dec *Decoder) WithByteRanges() *Decoder {
+	dec.withByteRanges = true
+	return dec
+}
+
+// WithDisklessReplication makes decoder stop right after the checksum following EOF opcode,
+// so reader is positioned at the start of replication command stream after Parse.
+// Decoder reads byte by byte unless reader is an io.ByteReader, e.g. *bufio.Reader which could be used to read commands
+func (dec *Decoder) WithDisklessReplication() *Decoder {
+	dec.diskless = true
+	if r, ok := dec.source.(byteReader); ok {
+		dec.input = r
+	} else {
+		dec.input = &unbufferedReader{reader: dec.source}
+	}
+	return dec
+}
+
+// WithOrderedFields makes decoder set HashObject.Fields, which preserves field order of compact encoded hashes
+func (dec *Decoder) WithOrderedFields() *Decoder {
+	dec.withOrderedFields = true
+	return dec
+}
+
+// WithOrderedMembers makes decoder sort members of hashtable encoded sets, which have no meaningful order on disk,
+// so that decoded sets are stable for diffs. Members of intset and listpack encoded sets are always in stored order,
+// which is ascending numeric order for intsets
+func (dec *Decoder) WithOrderedMembers() *Decoder {
+	dec.withOrderedMembers = true
+	return dec
+}
+
+// WithArchBits makes decoder evaluate memory usage of objects as if redis is built for 32 or 64 bit architecture,
+// which overrides redis-bits aux field. By default, it follows redis-bits or assumes 64 bit if it is absent
+func (dec *Decoder) WithArchBits(bits int) *Decoder {
+	dec.memMeta.Bits = bits
+	dec.archBitsFixed = true
+	return dec
+}
+
+// WithKeyFilter makes decoder skip values of keys which filter returns false without decoding them.
+// If it is called more than once, keys must pass all filters
+func (dec *Decoder) WithKeyFilter(filter func(key string) bool) *Decoder {
+	if prev := dec.keyFilter; prev != nil {
+		dec.keyFilter = func(key string) bool {
+			return prev(key) && filter(key)
+		}
+		return dec
+	}
+	dec.keyFilter = filter
+	return dec
+}
+
+// WithExpireRange makes decoder keep only keys expiring between minMs and maxMs (inclusive, unix time in
+// milliseconds), 0 means unbounded. Persistent keys are skipped, values of skipped keys are not decoded
+func (dec *Decoder) WithExpireRange(minMs, maxMs int64) *Decoder {
+	dec.expireRange = true
+	dec.expireMin = minMs
+	dec.expireMax = maxMs
+	return dec
+}
+
+// inExpireRange returns whether key with the expiration should be kept by WithExpireRange
+func (dec *Decoder) inExpireRange(hasExpire bool, expireMs int64) bool {
+	if !dec.expireRange {
+		return true
+	}
+	if !hasExpire {
+		return false
+	}
+	return (dec.expireMin == 0 || expireMs >= dec.expireMin) && (dec.expireMax == 0 || expireMs <= dec.expireMax)
+}
+
+// WithHashFieldFilter makes decoder keep only hash fields which filter returns true,
+// values of other fields are skipped without decoding if possible
+func (dec *Decoder) WithHashFieldFilter(filter func(field []byte) bool) *Decoder {
+	dec.hashFieldFilter = filter
+	return dec
+}
+
 // WithSpecialType enables returning redis module data structure to callback
 func (dec *Decoder) WithSpecialType(moduleType string, f ModuleTypeHandleFunc) *Decoder {
 	dec.withSpecialTypes[moduleType] = f
@@ -58,17 +268,23 @@ const (
 )
 
 const (
-	opCodeFreq         = 244 /* LFU frequency. (Redis 7.0+) */
-	opCodeIdle         = 245 /* LRU idle time. (Redis 7.0+) */
-	opCodeModuleAux    = 247 /* Module auxiliary data. */
-	opCodeAux          = 250 /* RDB aux field. */
-	opCodeResizeDB     = 251 /* Hash table resize hint. */
-	opCodeExpireTimeMs = 252 /* Expire time in milliseconds. */
-	opCodeExpireTime   = 253 /* Old expire time in seconds. */
-	opCodeSelectDB     = 254 /* DB number of the following keys. */
-	opCodeEOF          = 255
+	opCodeSlotInfo      = 244 /* Slot id and hash table sizes of the slot. (Redis 8.0+) */
+	opCodeFunction2     = 245 /* Function library. (Redis 7.0+) */
+	opCodeFunctionPreGA = 246 /* Function library of redis 7.0 release candidates. */
+	opCodeModuleAux     = 247 /* Module auxiliary data. */
+	opCodeIdle          = 248 /* LRU idle time. (Redis 5.0+) */
+	opCodeFreq          = 249 /* LFU frequency. (Redis 5.0+) */
+	opCodeAux           = 250 /* RDB aux field. */
+	opCodeResizeDB      = 251 /* Hash table resize hint. */
+	opCodeExpireTimeMs  = 252 /* Expire time in milliseconds. */
+	opCodeExpireTime    = 253 /* Old expire time in seconds. */
+	opCodeSelectDB      = 254 /* DB number of the following keys. */
+	opCodeEOF           = 255
 )
 
+// auxRedisBits is aux field of architecture of redis, 32 or 64
+const auxRedisBits = "redis-bits"
+
 const (
 	typeString = iota
 	typeList
@@ -119,6 +335,7 @@ var encodingMap = map[int]string{
 	typeListQuickList:         model.QuickListEncoding,
 	typeStreamListPacks:       model.ListPackEncoding,
 	typeStreamListPacks2:      model.ListPackEncoding,
+	typeStreamListPacks3:      model.ListPackEncoding,
 	typeHashListPack:          model.ListPackEncoding,
 	typeZsetListPack:          model.ListPackEncoding,
 	typeListQuickList2:        model.QuickList2Encoding,
@@ -129,6 +346,20 @@ var encodingMap = map[int]string{
 	typeHashListPackWithHfe:   model.ListPackExEncoding,
 }
 
+// singleContainerTypes are value types stored in a single ziplist, listpack, intset or zipmap
+var singleContainerTypes = map[byte]bool{
+	typeHashZipMap:            true,
+	typeListZipList:           true,
+	typeSetIntSet:             true,
+	typeZsetZipList:           true,
+	typeHashZipList:           true,
+	typeHashListPack:          true,
+	typeZsetListPack:          true,
+	typeSetListPack:           true,
+	typeHashListPackWithHfeRc: true,
+	typeHashListPackWithHfe:   true,
+}
+
 // checkHeader checks whether input has valid RDB file header
 func (dec *Decoder) checkHeader() error {
 	header := make([]byte, 9)
@@ -149,17 +380,29 @@ func (dec *Decoder) checkHeader() error {
 	if version < minVersion || version > maxVersion {
 		return fmt.Errorf("cannot parse version: %d", version)
 	}
+	dec.version = version
 	return nil
 }
 
 func (dec *Decoder) readObject(flag byte, base *model.BaseObject) (model.RedisObject, error) {
+	if handle, ok := dec.withTypeHandlers[flag]; ok {
+		obj, err := handle(moduleTypeHandlerImpl{dec: dec}, base)
+		if err != nil {
+			return nil, fmt.Errorf("read type %d failed: %v", flag, err)
+		}
+		return obj, nil
+	}
 	base.Encoding = encodingMap[int(flag)]
 	switch flag {
 	case typeString:
+		if dec.pipeline != nil {
+			return dec.readConcurrentString(base)
+		}
 		bs, err := dec.readString()
 		if err != nil {
 			return nil, err
 		}
+		base.Encoding = stringObjectEncoding(bs)
 		return &model.StringObject{
 			BaseObject: base,
 			Value:      bs,
@@ -178,6 +421,11 @@ func (dec *Decoder) readObject(flag byte, base *model.BaseObject) (model.RedisOb
 		if err != nil {
 			return nil, err
 		}
+		if dec.withOrderedMembers {
+			sort.Slice(set, func(i, j int) bool {
+				return bytes.Compare(set[i], set[j]) < 0
+			})
+		}
 		return &model.SetObject{
 			BaseObject: base,
 			Members:    set,
@@ -200,6 +448,7 @@ func (dec *Decoder) readObject(flag byte, base *model.BaseObject) (model.RedisOb
 		return &model.HashObject{
 			BaseObject: base,
 			Hash:       hash,
+			Fields:     dec.takeHashFields(true),
 		}, nil
 	case typeListZipList:
 		list, err := dec.readZipList()
@@ -238,6 +487,7 @@ func (dec *Decoder) readObject(flag byte, base *model.BaseObject) (model.RedisOb
 		return &model.HashObject{
 			BaseObject: base,
 			Hash:       m,
+			Fields:     dec.takeHashFields(false),
 		}, nil
 	case typeHashZipList:
 		m, extra, err := dec.readZipListHash()
@@ -248,6 +498,7 @@ func (dec *Decoder) readObject(flag byte, base *model.BaseObject) (model.RedisOb
 		return &model.HashObject{
 			BaseObject: base,
 			Hash:       m,
+			Fields:     dec.takeHashFields(false),
 		}, nil
 	case typeHashListPack:
 		m, extra, err := dec.readListPackHash()
@@ -258,6 +509,7 @@ func (dec *Decoder) readObject(flag byte, base *model.BaseObject) (model.RedisOb
 		return &model.HashObject{
 			BaseObject: base,
 			Hash:       m,
+			Fields:     dec.takeHashFields(false),
 		}, nil
 	case typeZset:
 		entries, err := dec.readZSet(false)
@@ -310,6 +562,9 @@ func (dec *Decoder) readObject(flag byte, base *model.BaseObject) (model.RedisOb
 		}
 		stream.BaseObject = base
 		return stream, nil
+	case typeModule:
+		// redis has never been able to load values of pre-GA module api, they carry no module id to skip them
+		return nil, fmt.Errorf("cannot load pre-GA module type %d", flag)
 	case typeModule2:
 		moduleType, val, err := dec.readModuleType()
 		if err != nil {
@@ -339,6 +594,7 @@ func (dec *Decoder) readObject(flag byte, base *model.BaseObject) (model.RedisOb
 			BaseObject:       base,
 			Hash:             hash,
 			FieldExpirations: expire,
+			Fields:           dec.takeHashFields(true),
 		}, nil
 	case typeHashListPackWithHfe, typeHashListPackWithHfeRc:
 		m, e, extra, err := dec.readListPackHashEx(func() bool { return flag == typeHashListPackWithHfeRc }())
@@ -350,155 +606,445 @@ func (dec *Decoder) readObject(flag byte, base *model.BaseObject) (model.RedisOb
 			BaseObject:       base,
 			Hash:             m,
 			FieldExpirations: e,
+			Fields:           dec.takeHashFields(false),
 		}, nil
 	}
 	return nil, fmt.Errorf("unknown type flag: %b", flag)
 }
 
+// parseState is the state of parse loop between two opcodes, so that parsing could be resumed by Step
+type parseState struct {
+	dbIndex    int
+	expireMs   int64
+	hasExpire  bool  // expiration may be 0 or negative, which means the key is expired
+	hasIdle    bool  // idle time 0 is valid, so it needs a flag too
+	metaStart  int64 // offset of the first metadata opcode (expire, freq, idle) of the next key
+	keyCounter dbKeyCounter
+	// stopped is true if callback returned false in pipeline
+	stopped bool
+	// headerRead and err are used by Step only
+	headerRead bool
+	err        error
+}
+
+func (dec *Decoder) newParseState() *parseState {
+	return &parseState{
+		dbIndex:   dec.startDB,
+		metaStart: -1,
+	}
+}
+
 func (dec *Decoder) parse(cb func(object model.RedisObject) bool) error {
-	var dbIndex int
-	var expireMs int64
+	objectCb := cb
+	st := dec.newParseState()
+	if dec.pipeline != nil {
+		// special objects are passed to callback after all objects in front of them
+		cb = func(object model.RedisObject) bool {
+			if !dec.drainPending(objectCb, true) || !objectCb(object) {
+				st.stopped = true
+				return false
+			}
+			return true
+		}
+	}
 	for {
-		b, err := dec.readByte()
+		done, err := dec.parseOpcode(st, cb, objectCb)
 		if err != nil {
+			// objects read before the error are delivered as in sequential mode, unless one of them failed first
+			if dec.pipeline != nil && !st.stopped && !dec.drainPending(objectCb, true) && dec.pipeline.err != nil {
+				return dec.pipeline.err
+			}
 			return err
 		}
-		if b == opCodeEOF {
+		if done {
 			break
-		} else if b == opCodeSelectDB {
-			dbIndex64, _, err := dec.readLength()
-			if err != nil {
-				return err
-			}
-			dbIndex = int(dbIndex64)
-			continue
-		} else if b == opCodeExpireTime {
-			err = dec.readFull(dec.buffer[:4])
-			if err != nil {
-				return err
-			}
-			expireMs = int64(binary.LittleEndian.Uint32(dec.buffer)) * 1000
-			continue
-		} else if b == opCodeExpireTimeMs {
-			err = dec.readFull(dec.buffer)
-			if err != nil {
-				return err
-			}
-			expireMs = int64(binary.LittleEndian.Uint64(dec.buffer))
-			continue
-		} else if b == opCodeResizeDB {
-			keyCount, _, err := dec.readLength()
-			if err != nil {
-				return err
+		}
+	}
+	if dec.pipeline != nil {
+		if !st.stopped {
+			dec.drainPending(objectCb, true)
+		}
+		if dec.pipeline.err != nil {
+			return dec.pipeline.err
+		}
+	}
+	return dec.readChecksum()
+}
+
+// readChecksum reads crc64 at the end, which is not a part of checksum
+func (dec *Decoder) readChecksum() error {
+	dec.setPhase(PhaseCRC, opCodeEOF, dec.readCount)
+	n, err := io.ReadFull(dec.input, dec.buffer)
+	dec.readCount += int64(n)
+	if err != nil && dec.diskless {
+		return fmt.Errorf("read checksum failed: %v", err)
+	}
+	return nil
+}
+
+// parseOpcode reads an opcode and what follows it, objects are passed to cb, or to objectCb through pipeline.
+// It returns true if opcode is EOF or callback stopped
+func (dec *Decoder) parseOpcode(st *parseState, cb, objectCb func(object model.RedisObject) bool) (bool, error) {
+	dec.setPhase(PhaseOpcode, 0, dec.readCount)
+	b, err := dec.readByte()
+	if err != nil {
+		return false, err
+	}
+	offset := dec.readCount - 1
+	dec.setPhase(PhaseMetadata, b, offset)
+	if st.metaStart < 0 && (b == opCodeExpireTime || b == opCodeExpireTimeMs || b == opCodeFreq || b == opCodeIdle) {
+		st.metaStart = offset
+	}
+	if name, ok := opCodeNames[b]; ok {
+		dec.stats.OpCodes[name]++
+	}
+	if b == opCodeEOF {
+		st.keyCounter.check(dec)
+		return true, nil
+	} else if b == opCodeSelectDB {
+		dbIndex64, _, err := dec.readLength()
+		if err != nil {
+			return false, err
+		}
+		st.keyCounter.check(dec)
+		st.dbIndex = int(dbIndex64)
+		dec.stats.DBs = append(dec.stats.DBs, st.dbIndex)
+		return false, nil
+	} else if b == opCodeExpireTime {
+		err = dec.readFull(dec.buffer[:4])
+		if err != nil {
+			return false, err
+		}
+		st.expireMs = int64(binary.LittleEndian.Uint32(dec.buffer)) * 1000
+		st.hasExpire = true
+		return false, nil
+	} else if b == opCodeExpireTimeMs {
+		err = dec.readFull(dec.buffer)
+		if err != nil {
+			return false, err
+		}
+		st.expireMs = int64(binary.LittleEndian.Uint64(dec.buffer))
+		st.hasExpire = true
+		return false, nil
+	} else if b == opCodeResizeDB {
+		keyCount, _, err := dec.readLength()
+		if err != nil {
+			return false, err
+		}
+		ttlCount, _, err := dec.readLength()
+		if err != nil {
+			return false, errors.New("Parse Aux value failed: " + err.Error())
+		}
+		st.keyCounter = dbKeyCounter{
+			db:       st.dbIndex,
+			declared: keyCount,
+			offset:   offset,
+			valid:    true,
+		}
+		if dec.withSpecialOpCode {
+			obj := &model.DBSizeObject{
+				BaseObject: &model.BaseObject{},
 			}
-			ttlCount, _, err := dec.readLength()
-			if err != nil {
-				err = errors.New("Parse Aux value failed: " + err.Error())
-				break
+			obj.DB = st.dbIndex
+			obj.KeyCount = keyCount
+			obj.TTLCount = ttlCount
+			tbc := cb(obj)
+			if !tbc {
+				return true, nil
 			}
-			if dec.withSpecialOpCode {
-				obj := &model.DBSizeObject{
-					BaseObject: &model.BaseObject{},
-				}
-				obj.DB = dbIndex
-				obj.KeyCount = keyCount
-				obj.TTLCount = ttlCount
-				tbc := cb(obj)
-				if !tbc {
-					break
+		}
+		return false, nil
+	} else if b == opCodeAux {
+		key, err := dec.readString()
+		if err != nil {
+			return false, err
+		}
+		value, err := dec.readString()
+		if err != nil {
+			return false, errors.New("Parse Aux value failed: " + err.Error())
+		}
+		obj := &model.AuxObject{
+			BaseObject: &model.BaseObject{},
+		}
+		obj.Key = unsafeBytes2Str(key)
+		obj.Value = unsafeBytes2Str(value)
+		obj.Bytes = value
+		if _, ok := keydbKeyAuxFields[obj.Key]; ok {
+			if !dec.withKeyMetadata {
+				if _, warned := dec.skippedKeyMetadata[obj.Key]; !warned {
+					if dec.skippedKeyMetadata == nil {
+						dec.skippedKeyMetadata = make(map[string]struct{})
+					}
+					dec.skippedKeyMetadata[obj.Key] = struct{}{}
+					dec.warn(WarningKeyMetadata, offset, "skipped %s of KeyDB, use WithKeyMetadata to read it", obj.Key)
 				}
+				return false, nil
 			}
-			continue
-		} else if b == opCodeAux {
-			key, err := dec.readString()
-			if err != nil {
-				return err
-			}
-			value, err := dec.readString()
-			if err != nil {
-				err = errors.New("Parse Aux value failed: " + err.Error())
-				break
-			}
-			if dec.withSpecialOpCode {
-				obj := &model.AuxObject{
-					BaseObject: &model.BaseObject{},
-				}
-				obj.Key = unsafeBytes2Str(key)
-				obj.Value = unsafeBytes2Str(value)
-				tbc := cb(obj)
-				if !tbc {
-					break
-				}
+			obj.DB = st.dbIndex
+			return !cb(obj), nil
+		}
+		dec.auxFields = append(dec.auxFields, obj)
+		if _, ok := knownAuxFields[obj.Key]; !ok {
+			dec.warn(WarningUnknownAux, offset, "unknown aux field %s", obj.Key)
+		}
+		if obj.Key == auxRedisBits && !dec.archBitsFixed {
+			if bits, err := strconv.Atoi(obj.Value); err == nil && (bits == 32 || bits == 64) {
+				dec.memMeta.Bits = bits
 			}
-			continue
-		} else if b == opCodeFreq {
-			freq, err := dec.readByte()
-			if err != nil {
-				return err
+		}
+		if dec.withSpecialOpCode {
+			var special model.RedisObject = obj
+			if obj.Key == auxLuaScript {
+				special = newScriptObject(obj.Value)
 			}
-			dec.currentFreq = freq
-			continue
-		} else if b == opCodeIdle {
-			idle, _, err := dec.readLength()
-			if err != nil {
-				return err
+			tbc := cb(special)
+			if !tbc {
+				return true, nil
 			}
-			dec.currentIdle = idle
-			continue
-		} else if b == opCodeModuleAux {
-			_, _, err = dec.readModuleType()
-			if err != nil {
-				return err
+		}
+		return false, nil
+	} else if b == opCodeFreq {
+		freq, err := dec.readByte()
+		if err != nil {
+			return false, err
+		}
+		dec.currentFreq = freq
+		return false, nil
+	} else if b == opCodeIdle {
+		idle, _, err := dec.readLength()
+		if err != nil {
+			return false, err
+		}
+		dec.currentIdle = idle
+		st.hasIdle = true
+		return false, nil
+	} else if b == opCodeSlotInfo {
+		// slot id, size of keys and size of expires of the slot, which are resize hints for cluster like resizedb
+		for i := 0; i < 3; i++ {
+			if _, _, err = dec.readLength(); err != nil {
+				return false, fmt.Errorf("read slot info failed: %v", err)
 			}
-			continue
 		}
-		key, err := dec.readString()
+		return false, nil
+	} else if b == opCodeModuleAux {
+		_, _, err = dec.readModuleType()
 		if err != nil {
-			return err
+			return false, err
+		}
+		return false, nil
+	} else if b == opCodeFunction2 || b == opCodeFunctionPreGA {
+		var lib *model.FunctionLibrary
+		if b == opCodeFunction2 {
+			lib, err = dec.readFunction2()
+		} else {
+			lib, err = dec.readFunctionPreGA()
 		}
-		base := &model.BaseObject{
-			DB:  dbIndex,
-			Key: unsafeBytes2Str(key),
+		if err != nil {
+			return false, err
 		}
-		if expireMs > 0 {
-			expiration := time.Unix(0, expireMs*int64(time.Millisecond))
-			base.Expiration = &expiration
-			expireMs = 0 // reset expire ms
+		if dec.withSpecialOpCode {
+			if !cb(lib) {
+				return true, nil
+			}
 		}
-		obj, err := dec.readObject(b, base)
+		return false, nil
+	} else if handle, ok := dec.withVendorOpCodes[b]; ok {
+		err = handle(moduleTypeHandlerImpl{dec: dec})
 		if err != nil {
-			return err
+			return false, fmt.Errorf("read vendor opcode %d failed: %v", b, err)
+		}
+		return false, nil
+	}
+	objStart := offset
+	dec.phase = PhaseKey
+	if name, ok := valueTypeNames[b]; ok {
+		dec.stats.ValueTypes[name]++
+	}
+	if _, ok := encodingMap[int(b)]; !ok && b != typeModule2 && dec.withTypeHandlers[b] == nil && dec.strictOpcodes {
+		return false, &ErrUnknownOpcode{Byte: b, Offset: objStart}
+	}
+	key, err := dec.readString()
+	if err != nil {
+		return false, err
+	}
+	if st.hasExpire {
+		if dec.stats.ExpireCount == 0 || st.expireMs < dec.stats.MinExpireMs {
+			dec.stats.MinExpireKey = string(key)
+			dec.stats.MinExpireMs = st.expireMs
 		}
-		base.Size = memprofiler.SizeOfObject(obj)
-		base.Type = obj.GetType()
-		tbc := cb(obj)
-		// Reset metadata after processing each object
+		dec.stats.ExpireCount++
+	}
+	dec.phase = PhaseValue
+	if !dec.inExpireRange(st.hasExpire, st.expireMs) || dec.keyFilter != nil && !dec.keyFilter(unsafeBytes2Str(key)) {
+		if err := dec.skipObject(b); err != nil {
+			return false, err
+		}
+		st.hasExpire = false
+		st.hasIdle = false
+		st.metaStart = -1
 		dec.currentFreq = 0
 		dec.currentIdle = 0
-		if !tbc {
-			break
+		dec.stats.SkippedKeys++
+		st.keyCounter.actual++
+		return false, nil
+	}
+	base := &model.BaseObject{
+		DB:  st.dbIndex,
+		Key: unsafeBytes2Str(key),
+	}
+	if st.hasExpire {
+		expiration := time.Unix(0, st.expireMs*int64(time.Millisecond))
+		base.Expiration = &expiration
+		st.hasExpire = false // reset expiration
+	}
+	if st.hasIdle {
+		idle := dec.currentIdle
+		base.Idle = &idle
+		st.hasIdle = false
+	}
+	dec.truncated = false
+	if dec.withDumpPayload {
+		dec.capturing = true
+		dec.captured = []byte{b}
+	}
+	obj, err := dec.readObject(b, base)
+	dec.capturing = false
+	if err != nil {
+		return false, err
+	}
+	if dec.withDumpPayload {
+		base.DumpPayload = makeDumpPayload(dec.captured, dec.version)
+		dec.captured = nil
+	}
+	if dec.withRawContainer {
+		if singleContainerTypes[b] {
+			base.RawContainer = dec.rawContainer
 		}
+		dec.rawContainer = nil
 	}
-	// read crc64 at the end
-	_ = dec.readFull(dec.buffer)
-	return nil
+	base.Truncated = dec.truncated
+	if dec.truncated {
+		dec.warn(WarningTruncated, objStart, "value of %s is truncated", base.Key)
+	}
+	st.keyCounter.actual++
+	base.DiskSize = int(dec.readCount - objStart)
+	if dec.encodingSanity {
+		dec.checkEncodingSanity(obj, objStart)
+	}
+	if dec.withByteRanges {
+		base.StartOffset = objStart
+		if st.metaStart >= 0 {
+			base.StartOffset = st.metaStart
+		}
+		base.EndOffset = dec.readCount
+	}
+	st.metaStart = -1
+	// Reset metadata after processing each object
+	dec.currentFreq = 0
+	dec.currentIdle = 0
+	if dec.pipeline != nil {
+		job := dec.pendingJob
+		dec.pendingJob = nil
+		if !dec.pushObject(obj, base, job, objectCb) {
+			st.stopped = true
+			return true, nil
+		}
+	} else if !dec.deliverObject(obj, base, cb) {
+		return true, nil
+	}
+	return false, nil
 }
 
 // Parse parses rdb and callback
-// cb returns true to continue, returns false to stop the iteration
+// cb returns true to continue, returns false to stop the iteration.
+// Objects are passed in on-disk order, WithDecodeConcurrency as well. Elements of lists, sets, sorted sets and streams
+// are in on-disk order, while HashObject.Hash is a map, use HashObject.Fields WithOrderedFields for on-disk order
 func (dec *Decoder) Parse(cb func(object model.RedisObject) bool) (err error) {
+	start := time.Now()
 	defer func() {
 		if err2 := recover(); err2 != nil {
 			err = fmt.Errorf("panic: %v", err2)
 		}
+		if err != nil {
+			err = dec.wrapParseError(err)
+		}
+		dec.stats.WallTime += time.Since(start)
 	}()
+	dec.setPhase(PhaseHeader, 0, dec.readCount)
 	err = dec.checkHeader()
 	if err != nil {
 		return err
 	}
+	if dec.decodeConcurrency > 1 {
+		dec.pipeline = newDecodePipeline(dec.decodeConcurrency)
+		defer func() {
+			close(dec.pipeline.jobs)
+			dec.pipeline = nil
+		}()
+	}
 	return dec.parse(cb)
 }
 
-func (dec *Decoder) GetReadCount() int {
+// ParseObject decodes the single object at current position of reader without rdb header.
+// Reader should be positioned at BaseObject.StartOffset of an object decoded WithByteRanges, and db is its database
+func (dec *Decoder) ParseObject(db int) (obj model.RedisObject, err error) {
+	defer func() {
+		if err2 := recover(); err2 != nil {
+			err = fmt.Errorf("panic: %v", err2)
+		}
+		if err != nil {
+			err = dec.wrapParseError(err)
+		}
+	}()
+	dec.version = maxVersion
+	dec.startDB = db
+	err = dec.parse(func(object model.RedisObject) bool {
+		obj = object
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, errors.New("no object at offset")
+	}
+	return obj, nil
+}
+
+// GetVersion returns rdb version read from header
+func (dec *Decoder) GetVersion() int {
+	return dec.version
+}
+
+// GetReadCount returns number of bytes read so far, it is int64 so that it does not overflow on 32-bit platforms
+func (dec *Decoder) GetReadCount() int64 {
 	return dec.readCount
 }
+
+// CurrentCRC returns crc64 of all bytes read so far, i.e. the first GetReadCount() bytes.
+// After Parse finished, it equals the checksum at the end of file if the file is not corrupted
+func (dec *Decoder) CurrentCRC() uint64 {
+	return dec.crc.Sum64()
+}
+
+// GetAuxFields returns all aux fields read so far in the order they appear in the file.
+// Unknown fields (e.g. `x-` prefixed fields written by other tools) are preserved verbatim
+func (dec *Decoder) GetAuxFields() []*model.AuxObject {
+	return dec.auxFields
+}
+
+// AuxBytes returns value of aux field read so far without any conversion, the last one wins if key appears twice
+func (dec *Decoder) AuxBytes(key string) ([]byte, bool) {
+	for i := len(dec.auxFields) - 1; i >= 0; i-- {
+		if dec.auxFields[i].Key == key {
+			return dec.auxFields[i].Bytes, true
+		}
+	}
+	return nil, false
+}
+
+// AuxString returns value of aux field read so far as string, which holds the same bytes as AuxBytes
+func (dec *Decoder) AuxString(key string) (string, bool) {
+	value, ok := dec.AuxBytes(key)
+	return string(value), ok
+}
@@ -0,0 +1,701 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hdt3213/rdb/crc64jones"
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestCurrentCRC(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 3, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteStringObject("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteListObject("b", [][]byte{[]byte("x"), []byte("y")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteSetObject("c", [][]byte{[]byte(RandString(5000))}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	checksum := func(p []byte) uint64 {
+		crc := crc64jones.New()
+		_, _ = crc.Write(p)
+		return crc.Sum64()
+	}
+
+	// skip "b" to make sure discarded bytes are in crc
+	dec := NewDecoder(bytes.NewReader(data)).WithKeyFilter(func(key string) bool {
+		return key != "b"
+	})
+	count := 0
+	err := dec.Parse(func(o model.RedisObject) bool {
+		count++
+		if actual, expect := dec.CurrentCRC(), checksum(data[:dec.GetReadCount()]); actual != expect {
+			t.Errorf("crc after %s: expect %x, actual %x", o.GetKey(), expect, actual)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expect 2 objects, actual %d", count)
+	}
+	if expect := binary.LittleEndian.Uint64(data[len(data)-8:]); dec.CurrentCRC() != expect {
+		t.Errorf("expect checksum %x, actual %x", expect, dec.CurrentCRC())
+	}
+}
+
+func TestStrictOpcodes(t *testing.T) {
+	data := buildRDB(t, func(enc *Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 2, 0))
+		// value of unregistered module type, which can be skipped by default
+		writeRawObject(enc, check, typeModule2, "module", func() {
+			check(enc.writeLength(createModuleId("unknown-t", 1)))
+			check(enc.writeLength(uint64(ModuleOpcodeString)))
+			check(enc.writeString("data"))
+			check(enc.writeLength(uint64(ModuleOpcodeEOF)))
+		})
+		check(enc.WriteStringObject("a", []byte("1")))
+	})
+	var keys []string
+	err := NewDecoder(bytes.NewReader(data)).Parse(func(o model.RedisObject) bool {
+		keys = append(keys, o.GetKey())
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Errorf("expect 2 keys, actual %v", keys)
+	}
+	err = NewDecoder(bytes.NewReader(data)).WithStrictOpcodes().Parse(func(o model.RedisObject) bool {
+		return true
+	})
+	var unknownModule *ErrUnknownOpcode
+	if !errors.As(err, &unknownModule) {
+		t.Errorf("expect ErrUnknownOpcode for unknown module type in strict mode, actual %v", err)
+	} else if unknownModule.Byte != typeModule2 || data[unknownModule.Offset] != typeModule2 {
+		t.Errorf("expect type byte of module value, actual %d at offset %d", unknownModule.Byte, unknownModule.Offset)
+	}
+
+	// bogus type byte cannot be skipped
+	bogus := []byte{
+		'R', 'E', 'D', 'I', 'S', '0', '0', '1', '1',
+		0xFE, 0x00,
+		0x30, 0x01, 'a', 0x01, '1',
+		0xFF,
+	}
+	err = NewDecoder(bytes.NewReader(bogus)).Parse(func(o model.RedisObject) bool {
+		return true
+	})
+	if err == nil {
+		t.Error("expect error for bogus type")
+	}
+	err = NewDecoder(bytes.NewReader(bogus)).WithStrictOpcodes().Parse(func(o model.RedisObject) bool {
+		return true
+	})
+	var unknown *ErrUnknownOpcode
+	if !errors.As(err, &unknown) {
+		t.Fatalf("expect ErrUnknownOpcode, actual %v", err)
+	}
+	if unknown.Byte != 0x30 || unknown.Offset != 11 {
+		t.Errorf("wrong error: %v", unknown)
+	}
+}
+
+func TestByteRanges(t *testing.T) {
+	expireMs := uint64(time.Now().Add(time.Hour).UnixNano() / 1e6)
+	data := buildRDB(t, func(enc *Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 3, 1))
+		check(enc.WriteStringObject("a", []byte("1")))
+		check(enc.WriteListObject("b", [][]byte{[]byte("x"), []byte("y")}, WithTTL(expireMs)))
+		check(enc.WriteStringObject("c", []byte("3")))
+	})
+	objects := make(map[string]model.RedisObject)
+	var keys []string
+	err := NewDecoder(bytes.NewReader(data)).WithByteRanges().Parse(func(o model.RedisObject) bool {
+		objects[o.GetKey()] = o
+		keys = append(keys, o.GetKey())
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(keys); i++ {
+		if getBase(objects[keys[i-1]]).EndOffset != getBase(objects[keys[i]]).StartOffset {
+			t.Errorf("%s should start at the end of %s", keys[i], keys[i-1])
+		}
+	}
+	b := getBase(objects["b"])
+	if data[b.StartOffset] != opCodeExpireTimeMs {
+		t.Errorf("range of b should start with its expiration, actual %d", data[b.StartOffset])
+	}
+
+	// extracted bytes form a valid rdb with header, select db and eof
+	extracted := []byte("REDIS0011")
+	extracted = append(extracted, opCodeSelectDB, 0)
+	extracted = append(extracted, data[b.StartOffset:b.EndOffset]...)
+	extracted = append(extracted, opCodeEOF)
+	var actual model.RedisObject
+	err = NewDecoder(bytes.NewReader(extracted)).Parse(func(o model.RedisObject) bool {
+		actual = o
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, ok := actual.(*model.ListObject)
+	if !ok || list.Key != "b" || len(list.Values) != 2 {
+		t.Fatalf("wrong extracted object: %v", actual)
+	}
+	if list.Expiration == nil || uint64(list.Expiration.UnixNano()/1e6) != expireMs {
+		t.Errorf("wrong expiration of extracted object: %v", list.Expiration)
+	}
+}
+
+func getBase(obj model.RedisObject) *model.BaseObject {
+	switch o := obj.(type) {
+	case *model.StringObject:
+		return o.BaseObject
+	case *model.ListObject:
+		return o.BaseObject
+	}
+	return nil
+}
+
+func TestDisklessReplication(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := enc.WriteDBHeader(0, 1, 0); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := enc.WriteStringObject("a", []byte("1")); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Error(err)
+		return
+	}
+	rdbSize := buf.Len()
+	commands := "*1\r\n$4\r\nPING\r\n"
+	buf.WriteString(commands)
+	data := buf.Bytes()
+
+	// bytes.Reader is an io.ByteReader which is used directly
+	reader := bytes.NewReader(data)
+	dec := NewDecoder(reader).WithDisklessReplication()
+	if err := dec.Parse(func(object model.RedisObject) bool { return true }); err != nil {
+		t.Error(err)
+		return
+	}
+	if reader.Len() != len(commands) || dec.GetReadCount() != int64(rdbSize) {
+		t.Errorf("reader should be positioned after checksum, remaining %d bytes", reader.Len())
+	}
+
+	// other readers are read byte by byte
+	src := bytes.NewReader(data)
+	dec = NewDecoder(struct{ io.Reader }{src}).WithDisklessReplication()
+	if err := dec.Parse(func(object model.RedisObject) bool { return true }); err != nil {
+		t.Error(err)
+		return
+	}
+	rest, _ := io.ReadAll(src)
+	if string(rest) != commands {
+		t.Errorf("wrong command stream after rdb: %q", rest)
+	}
+
+	// missing checksum is an error
+	dec = NewDecoder(bytes.NewReader(data[:rdbSize-4])).WithDisklessReplication()
+	if err := dec.Parse(func(object model.RedisObject) bool { return true }); err == nil {
+		t.Error("expect error for truncated checksum")
+	}
+}
+
+func TestNewDecoderSize(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 100, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := enc.WriteStringObject(RandString(10), []byte(RandString(100))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	for _, size := range []int{1, 64, 1 << 16} {
+		count := 0
+		err := NewDecoderSize(bytes.NewReader(data), size).Parse(func(object model.RedisObject) bool {
+			count++
+			return true
+		})
+		if err != nil {
+			t.Errorf("buffer size %d: %v", size, err)
+			continue
+		}
+		if count != 100 {
+			t.Errorf("buffer size %d: expect 100 keys, actual %d", size, count)
+		}
+	}
+
+	// an already buffered reader is used directly
+	buffered := bufio.NewReaderSize(bytes.NewReader(data), 1<<16)
+	dec := NewDecoderSize(buffered, 4096)
+	if dec.input != buffered {
+		t.Error("expect buffered reader to be used directly")
+	}
+}
+
+// slowReader returns at most chunk bytes per Read and spends some time on every call, like a raw socket
+type slowReader struct {
+	reader io.Reader
+	chunk  int
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if len(p) > r.chunk {
+		p = p[:r.chunk]
+	}
+	start := time.Now()
+	for time.Since(start) < time.Microsecond {
+	}
+	return r.reader.Read(p)
+}
+
+func benchmarkDecoderSize(b *testing.B, bufSize int) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		b.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 1000, 0); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if err := enc.WriteStringObject(RandString(10), []byte(RandString(100))); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader := &slowReader{reader: bytes.NewReader(data), chunk: 1 << 20}
+		err := NewDecoderSize(reader, bufSize).Parse(func(object model.RedisObject) bool {
+			return true
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoderSize16(b *testing.B) {
+	benchmarkDecoderSize(b, 16)
+}
+
+func BenchmarkDecoderSize4K(b *testing.B) {
+	benchmarkDecoderSize(b, 4096)
+}
+
+func BenchmarkDecoderSize64K(b *testing.B) {
+	benchmarkDecoderSize(b, 1<<16)
+}
+
+func TestArchBits(t *testing.T) {
+	build := func(bits string) []byte {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf)
+		if bits != "" {
+			enc.SetAuxField("redis-bits", bits)
+		}
+		if err := enc.WriteHeader(); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteDBHeader(0, 1, 0); err != nil {
+			t.Fatal(err)
+		}
+		hash := make(map[string][]byte)
+		for i := 0; i < 1000; i++ {
+			hash[RandString(10)] = []byte(RandString(100))
+		}
+		if err := enc.WriteHashMapObject("h", hash); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteEnd(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	sizeOf := func(data []byte, bits int) int {
+		size := 0
+		dec := NewDecoder(bytes.NewReader(data))
+		if bits > 0 {
+			dec.WithArchBits(bits)
+		}
+		err := dec.Parse(func(object model.RedisObject) bool {
+			size = object.GetSize()
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return size
+	}
+	data := build("")
+	size64 := sizeOf(data, 0)
+	if sizeOf(data, 64) != size64 {
+		t.Error("expect 64 bit by default")
+	}
+	size32 := sizeOf(data, 32)
+	if size32 >= size64 {
+		t.Errorf("expect 32 bit size %d less than 64 bit size %d", size32, size64)
+	}
+
+	// redis-bits aux field is followed unless WithArchBits is set
+	data32 := build("32")
+	if actual := sizeOf(data32, 0); actual >= size64 {
+		t.Errorf("expect redis-bits 32 to be followed, actual size %d", actual)
+	}
+	if actual := sizeOf(data32, 64); actual != size64 {
+		t.Errorf("expect WithArchBits to override redis-bits, actual size %d", actual)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 3, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := enc.WriteStringObject(key, []byte("value of "+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	offset := int64(-1)
+	err := NewDecoder(bytes.NewReader(data)).WithByteRanges().Parse(func(o model.RedisObject) bool {
+		if o.GetKey() == "b" {
+			offset = o.(*model.StringObject).StartOffset
+		}
+		return true
+	})
+	if err != nil || offset < 0 {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	// unknown type byte of key b
+	corrupted := append([]byte{}, data...)
+	corrupted[offset] = 0x60
+	err = NewDecoder(bytes.NewReader(corrupted)).Parse(func(o model.RedisObject) bool { return true })
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expect ParseError, actual %v", err)
+	}
+	if parseErr.Offset != offset || parseErr.Opcode != 0x60 || parseErr.Phase != PhaseValue {
+		t.Errorf("unexpected error: %v", parseErr)
+	}
+
+	// strict mode returns ErrUnknownOpcode wrapped in ParseError
+	err = NewDecoder(bytes.NewReader(corrupted)).WithStrictOpcodes().Parse(func(o model.RedisObject) bool { return true })
+	var unknown *ErrUnknownOpcode
+	if !errors.As(err, &parseErr) || !errors.As(err, &unknown) || unknown.Offset != offset {
+		t.Errorf("expect ErrUnknownOpcode in ParseError, actual %v", err)
+	}
+
+	// truncated in the middle of value of key b
+	err = NewDecoder(bytes.NewReader(data[:offset+5])).Parse(func(o model.RedisObject) bool { return true })
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expect ParseError, actual %v", err)
+	}
+	if parseErr.Offset != offset || parseErr.Opcode != typeString || parseErr.Phase != PhaseValue {
+		t.Errorf("unexpected error: %v", parseErr)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		t.Errorf("expect EOF error, actual %v", parseErr.Err)
+	}
+
+	// truncated before the next opcode
+	err = NewDecoder(bytes.NewReader(data[:offset])).Parse(func(o model.RedisObject) bool { return true })
+	if !errors.As(err, &parseErr) || parseErr.Offset != offset || parseErr.Phase != PhaseOpcode {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = NewDecoder(bytes.NewReader([]byte("REDIT0009"))).Parse(func(o model.RedisObject) bool { return true })
+	if !errors.As(err, &parseErr) || parseErr.Offset != 0 || parseErr.Phase != PhaseHeader {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestOffsetsBeyond2GB pretends 2GB has been read before the dump, offsets must not wrap around on 32-bit platforms
+func TestOffsetsBeyond2GB(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteStringObject("a", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	const base = int64(math.MaxInt32)
+	dec := NewDecoder(bytes.NewReader(data)).WithByteRanges()
+	dec.readCount = base
+	var obj *model.StringObject
+	err := dec.Parse(func(o model.RedisObject) bool {
+		obj = o.(*model.StringObject)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec.GetReadCount() != base+int64(len(data)) {
+		t.Errorf("expect read count %d, actual %d", base+int64(len(data)), dec.GetReadCount())
+	}
+	if obj.StartOffset <= base || obj.EndOffset-obj.StartOffset != int64(obj.DiskSize) {
+		t.Errorf("wrong byte range [%d, %d)", obj.StartOffset, obj.EndOffset)
+	}
+	if data[obj.StartOffset-base] != typeString {
+		t.Errorf("byte range should start at type of a")
+	}
+
+	dec = NewDecoder(bytes.NewReader(data[:obj.StartOffset-base+3]))
+	dec.readCount = base
+	err = dec.Parse(func(o model.RedisObject) bool { return true })
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Offset != obj.StartOffset {
+		t.Errorf("expect error at offset %d, actual %v", obj.StartOffset, err)
+	}
+
+	if _, err = lengthToInt(math.MaxUint64); err == nil {
+		t.Error("expect error for length beyond int range")
+	}
+	if n, err := lengthToInt(math.MaxInt32); err != nil || n != math.MaxInt32 {
+		t.Errorf("expect %d, actual %d, %v", math.MaxInt32, n, err)
+	}
+}
+
+func TestSelectLargeDB(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	dbs := []uint{255, 300, 70000}
+	for _, db := range dbs {
+		if err := enc.WriteDBHeader(db, 1, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteStringObject("k", []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// db index 300 is length encoded in 14 bits
+	if !bytes.Contains(data, []byte{opCodeSelectDB, 0x41, 0x2C}) {
+		t.Errorf("expect 2 bytes db index 300: %x", data)
+	}
+	var actual []int
+	dec := NewDecoder(bytes.NewReader(data))
+	err := dec.Parse(func(o model.RedisObject) bool {
+		actual = append(actual, o.GetDBIndex())
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual) != len(dbs) {
+		t.Fatalf("expect %d objects, actual %d", len(dbs), len(actual))
+	}
+	for i, db := range dbs {
+		if actual[i] != int(db) || dec.Stats().DBs[i] != int(db) {
+			t.Errorf("expect db %d, actual %d", db, actual[i])
+		}
+	}
+}
+
+func TestExpireRange(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 4, 3); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		expireAt := uint64(key[0]-'a'+1) * 1000
+		if err := enc.WriteStringObject(key, []byte(key), WithTTL(expireAt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteListObject("persistent", [][]byte{[]byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	tests := []struct {
+		min, max int64
+		expect   string
+	}{
+		{1000, 2000, "ab"},
+		{1001, 1999, ""},
+		{2000, 2000, "b"},
+		{0, 2000, "ab"},
+		{2000, 0, "bc"},
+		{0, 0, "abc"},
+	}
+	for _, tc := range tests {
+		dec := NewDecoder(bytes.NewReader(data)).WithExpireRange(tc.min, tc.max)
+		actual := ""
+		err := dec.Parse(func(o model.RedisObject) bool {
+			actual += o.GetKey()
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != tc.expect {
+			t.Errorf("range [%d, %d]: expect %q, actual %q", tc.min, tc.max, tc.expect, actual)
+		}
+		if skipped := dec.Stats().SkippedKeys; skipped != 4-len(tc.expect) {
+			t.Errorf("range [%d, %d]: expect %d skipped keys, actual %d", tc.min, tc.max, 4-len(tc.expect), skipped)
+		}
+	}
+}
+
+// checkEmptyCollection checks o is a collection of typ without elements, whose slice or map is not nil
+func checkEmptyCollection(t *testing.T, o model.RedisObject, typ string) {
+	if o.GetType() != typ || o.GetElemCount() != 0 {
+		t.Errorf("%s: expect empty %s, actual %s of %d elements", o.GetKey(), typ, o.GetType(), o.GetElemCount())
+	}
+	empty := false
+	switch x := o.(type) {
+	case *model.ListObject:
+		empty = x.Values != nil
+	case *model.SetObject:
+		empty = x.Members != nil
+	case *model.HashObject:
+		empty = x.Hash != nil
+	case *model.ZSetObject:
+		empty = x.Entries != nil
+	}
+	if !empty {
+		t.Errorf("%s: expect empty rather than nil elements", o.GetKey())
+	}
+}
+
+func TestEmptyCollections(t *testing.T) {
+	rdbFile, err := os.Open("../cases/empty_collections.rdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	types := map[string]string{
+		"list": model.ListType,
+		"set":  model.SetType,
+		"hash": model.HashType,
+		"zset": model.ZSetType,
+	}
+	count := 0
+	err = NewDecoder(rdbFile).Parse(func(o model.RedisObject) bool {
+		checkEmptyCollection(t, o, types[o.GetKey()])
+		count++
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != len(types) {
+		t.Errorf("expect %d objects, actual %d", len(types), count)
+	}
+
+	// compact encodings without elements
+	emptyZipList := []byte{11, 0, 0, 0, 10, 0, 0, 0, 0, 0, 0xff}
+	emptyIntSet := []byte{2, 0, 0, 0, 0, 0, 0, 0}
+	containers := []struct {
+		flag      byte
+		typ       string
+		container []byte
+	}{
+		{typeListZipList, model.ListType, emptyZipList},
+		{typeSetIntSet, model.SetType, emptyIntSet},
+		{typeSetListPack, model.SetType, testListPack()},
+		{typeHashZipList, model.HashType, emptyZipList},
+		{typeHashListPack, model.HashType, testListPack()},
+		{typeZsetZipList, model.ZSetType, emptyZipList},
+		{typeZsetListPack, model.ZSetType, testListPack()},
+	}
+	for _, c := range containers {
+		data := buildRDB(t, func(enc *Encoder, check func(error)) {
+			check(enc.WriteDBHeader(0, 1, 0))
+			writeRawObject(enc, check, c.flag, encodingMap[int(c.flag)], func() {
+				check(enc.writeString(string(c.container)))
+			})
+		})
+		count = 0
+		err = NewDecoder(bytes.NewReader(data)).Parse(func(o model.RedisObject) bool {
+			checkEmptyCollection(t, o, c.typ)
+			count++
+			return true
+		})
+		if err != nil || count != 1 {
+			t.Errorf("type %d: expect an object, actual %d, %v", c.flag, count, err)
+		}
+	}
+}
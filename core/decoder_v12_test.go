@@ -7,7 +7,7 @@ import (
 	"github.com/hdt3213/rdb/model"
 )
 
-// TestRDBV12FreqOpcode tests parsing RDB v12 files with FREQ (0xF4) opcode
+// TestRDBV12FreqOpcode tests parsing RDB v12 files with FREQ (0xF9) opcode
 func TestRDBV12FreqOpcode(t *testing.T) {
 	// Construct RDB v12 data with FREQ opcode
 	rdbData := []byte{
@@ -20,8 +20,8 @@ func TestRDBV12FreqOpcode(t *testing.T) {
 		// RESIZEDB (1 key, 0 expires)
 		0xFB, 0x01, 0x00,
 
-		// FREQ opcode (0xF4) + frequency value (42)
-		0xF4, 42,
+		// FREQ opcode (0xF9) + frequency value (42)
+		0xF9, 42,
 
 		// String type (0x00)
 		0x00,
@@ -69,7 +69,7 @@ func TestRDBV12FreqOpcode(t *testing.T) {
 	}
 }
 
-// TestRDBV12IdleOpcode tests parsing RDB v12 files with IDLE (0xF5) opcode
+// TestRDBV12IdleOpcode tests parsing RDB v12 files with IDLE (0xF8) opcode
 func TestRDBV12IdleOpcode(t *testing.T) {
 	rdbData := []byte{
 		// Header: "REDIS0012"
@@ -81,8 +81,8 @@ func TestRDBV12IdleOpcode(t *testing.T) {
 		// RESIZEDB
 		0xFB, 0x01, 0x00,
 
-		// IDLE opcode (0xF5) + idle time (1000 in little-endian, encoded as length)
-		0xF5, 0xC2, 0xE8, 0x03, // length encoding: 1000
+		// IDLE opcode (0xF8) + idle time (1000 in 14 bit length encoding)
+		0xF8, 0x43, 0xE8,
 
 		// String type
 		0x00,
@@ -129,10 +129,10 @@ func TestRDBV12FreqAndIdle(t *testing.T) {
 		0xFB, 0x01, 0x00,
 
 		// FREQ (5)
-		0xF4, 0x05,
+		0xF9, 0x05,
 
 		// IDLE (2000)
-		0xF5, 0xC2, 0xD0, 0x0F,
+		0xF8, 0x47, 0xD0,
 
 		// String type
 		0x00,
@@ -183,7 +183,7 @@ func TestRDBV12MetadataReset(t *testing.T) {
 		0xFB, 0x02, 0x00,
 
 		// First object WITH FREQ metadata
-		0xF4, 10, // freq = 10
+		0xF9, 10, // freq = 10
 		0x00, 0x04, 'k', 'e', 'y', '1',
 		0x04, 'v', 'a', 'l', '1',
 
@@ -239,7 +239,7 @@ func TestRDBV12FreqWithExpiration(t *testing.T) {
 		0xFC, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
 
 		// FREQ
-		0xF4, 15,
+		0xF9, 15,
 
 		// String type
 		0x00,
@@ -290,18 +290,18 @@ func TestRDBV12MultipleKeys(t *testing.T) {
 		0xFB, 0x03, 0x00,
 
 		// Key 1: With FREQ
-		0xF4, 10,
+		0xF9, 10,
 		0x00, 0x04, 'k', 'e', 'y', '1',
 		0x04, 'v', 'a', 'l', '1',
 
 		// Key 2: With IDLE
-		0xF5, 0xC2, 0xF4, 0x01, // 500 encoded as length
+		0xF8, 0x41, 0xF4, // 500 encoded as length
 		0x00, 0x04, 'k', 'e', 'y', '2',
 		0x04, 'v', 'a', 'l', '2',
 
 		// Key 3: With both FREQ and IDLE
-		0xF4, 20,
-		0xF5, 0xC2, 0xDC, 0x05, // 1500
+		0xF9, 20,
+		0xF8, 0x45, 0xDC, // 1500
 		0x00, 0x04, 'k', 'e', 'y', '3',
 		0x04, 'v', 'a', 'l', '3',
 
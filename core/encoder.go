@@ -22,6 +22,11 @@ type Encoder struct {
 	hashZipListOpt  *zipListOpt
 	zsetZipListOpt  *zipListOpt
 	listZipListSize int
+
+	// auxFields are set by SetAuxField and written before the first db
+	auxFields [][2]string
+	// targetVersion is rdb version of header set by WithTargetVersion, 0 means the default version
+	targetVersion int
 }
 
 type zipListOpt struct {
@@ -90,9 +95,26 @@ var stateChanges = map[string]map[string]struct{}{ // state -> allow next states
 	writtenEndState: {},
 }
 
-// NewEncoder creates an encoder instance
-func NewEncoder(writer io.Writer) *Encoder {
-	return &Encoder{
+// TargetVersionOption sets rdb version of output
+type TargetVersionOption int
+
+// WithTargetVersion makes encoder write an rdb which could be loaded by redis supporting rdb version (8 to 12),
+// e.g. 9 for redis 6. Streams are written in the stream format of the version, idle time is dropped below 9 and
+// hashes with field expirations, which have no representation before 12, are rejected. The default version is 11,
+// so WithTargetVersion(12) is required to write hashes with field expirations
+func WithTargetVersion(version int) TargetVersionOption {
+	return TargetVersionOption(version)
+}
+
+const (
+	minTargetVersion     = 8
+	defaultTargetVersion = 11
+	maxTargetVersion     = 12
+)
+
+// NewEncoder creates an encoder instance, WithTargetVersion is the only option
+func NewEncoder(writer io.Writer, options ...interface{}) *Encoder {
+	enc := &Encoder{
 		writer:          writer,
 		crc:             crc64jones.New(),
 		buffer:          make([]byte, 8),
@@ -100,6 +122,25 @@ func NewEncoder(writer io.Writer) *Encoder {
 		existDB:         make(map[uint]struct{}),
 		listZipListSize: 4 * 1024,
 	}
+	for _, opt := range options {
+		if o, ok := opt.(TargetVersionOption); ok {
+			enc.targetVersion = int(o)
+		}
+	}
+	return enc
+}
+
+// version returns rdb version of output, which is defaultTargetVersion without WithTargetVersion
+func (enc *Encoder) version() int {
+	if enc.targetVersion == 0 {
+		return defaultTargetVersion
+	}
+	return enc.targetVersion
+}
+
+// supports reports whether rdb version of output is at least version
+func (enc *Encoder) supports(version int) bool {
+	return enc.version() >= version
 }
 
 // SetListZipListOpt sets list-max-ziplist-value and list-max-ziplist-entries
@@ -129,6 +170,36 @@ func (enc *Encoder) SetZSetZipListOpt(maxValue, maxEntries int) *Encoder {
 	return enc
 }
 
+// SetAuxField sets an aux field which is written right after header, such as aof-base or redis-ver.
+// Setting a key again replaces its value. Fields must be set before the first db header is written
+func (enc *Encoder) SetAuxField(key, value string) *Encoder {
+	for i := range enc.auxFields {
+		if enc.auxFields[i][0] == key {
+			enc.auxFields[i][1] = value
+			return enc
+		}
+	}
+	enc.auxFields = append(enc.auxFields, [2]string{key, value})
+	return enc
+}
+
+// writeAuxFields writes aux fields set by SetAuxField which have not been written yet
+func (enc *Encoder) writeAuxFields() error {
+	if len(enc.auxFields) == 0 {
+		return nil
+	}
+	if !enc.validateStateChange(writtenAuxState) {
+		return fmt.Errorf("cannot writing aux field %s at state: %s", enc.auxFields[0][0], enc.state)
+	}
+	for _, field := range enc.auxFields {
+		if err := enc.WriteAux(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+	enc.auxFields = nil
+	return nil
+}
+
 // remain unfixed bugs, don't open
 func (enc *Encoder) EnableCompress() *Encoder {
 	enc.compress = true
@@ -147,8 +218,6 @@ func (enc *Encoder) write(p []byte) error {
 	return nil
 }
 
-var rdbHeader = []byte("REDIS0011")
-
 func (enc *Encoder) validateStateChange(toState string) bool {
 	_, ok := stateChanges[enc.state][toState]
 	return ok
@@ -158,12 +227,20 @@ func (enc *Encoder) WriteHeader() error {
 	if !enc.validateStateChange(writtenHeaderState) {
 		return fmt.Errorf("cannot writing header at state: %s", enc.state)
 	}
-	err := enc.write(rdbHeader)
+	version := defaultTargetVersion
+	if enc.targetVersion != 0 {
+		if enc.targetVersion < minTargetVersion || enc.targetVersion > maxTargetVersion {
+			return fmt.Errorf("unsupported target version %d, it should be in [%d, %d]",
+				enc.targetVersion, minTargetVersion, maxTargetVersion)
+		}
+		version = enc.targetVersion
+	}
+	err := enc.write([]byte(fmt.Sprintf("REDIS%04d", version)))
 	if err != nil {
 		return err
 	}
 	enc.state = writtenHeaderState
-	return nil
+	return enc.writeAuxFields()
 }
 
 // WriteAux writes aux object
@@ -192,6 +269,9 @@ func (enc *Encoder) WriteDBHeader(dbIndex uint, keyCount, ttlCount uint64) error
 	if !enc.validateStateChange(writtenDBHeaderState) {
 		return fmt.Errorf("cannot writing db header at state: %s", enc.state)
 	}
+	if err := enc.writeAuxFields(); err != nil {
+		return err
+	}
 	if _, ok := enc.existDB[dbIndex]; ok {
 		return fmt.Errorf("db %d existed", dbIndex)
 	}
@@ -225,6 +305,9 @@ func (enc *Encoder) WriteEnd() error {
 	if !enc.validateStateChange(writtenEndState) {
 		return fmt.Errorf("cannot writing end at state: %s", enc.state)
 	}
+	if err := enc.writeAuxFields(); err != nil {
+		return err
+	}
 	err := enc.write([]byte{opCodeEOF})
 	if err != nil {
 		return err
@@ -263,6 +346,22 @@ func WithTTL(expirationMs uint64) TTLOption {
 	return TTLOption(expirationMs)
 }
 
+// IdleOption specific LRU idle time in seconds for object
+type IdleOption uint64
+
+// WithIdle specific LRU idle time in seconds for object
+func WithIdle(idleSeconds uint64) IdleOption {
+	return IdleOption(idleSeconds)
+}
+
+func (enc *Encoder) writeIdle(idle uint64) error {
+	err := enc.write([]byte{opCodeIdle})
+	if err != nil {
+		return err
+	}
+	return enc.writeLength(idle)
+}
+
 func (enc *Encoder) beforeWriteObject(options ...interface{}) error {
 	if !enc.validateStateChange(writtenObjectState) {
 		return fmt.Errorf("cannot write object at state: %s", enc.state)
@@ -274,6 +373,14 @@ func (enc *Encoder) beforeWriteObject(options ...interface{}) error {
 			if err != nil {
 				return err
 			}
+		case IdleOption:
+			if !enc.supports(9) {
+				continue // RDB_OPCODE_IDLE is introduced in version 9
+			}
+			err := enc.writeIdle(uint64(o))
+			if err != nil {
+				return err
+			}
 		}
 	}
 	return nil
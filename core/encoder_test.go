@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"github.com/hdt3213/rdb/model"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 )
@@ -114,3 +115,351 @@ func TestEncode(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestCustomAuxFields(t *testing.T) {
+	auxFields := [][2]string{
+		{"redis-ver", "7.2.0"},
+		{"x-tenant-id", "tenant-42"},
+		{"x-schema-version", "3"},
+		{"x-exported-by", "rdb-tool"},
+	}
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	err := enc.WriteHeader()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, field := range auxFields {
+		err = enc.WriteAux(field[0], field[1])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	err = enc.WriteDBHeader(0, 1, 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = enc.WriteStringObject("a", []byte("b"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = enc.WriteEnd()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// aux fields are collected even if WithSpecialOpCode is not enabled
+	dec := NewDecoder(buf)
+	err = dec.Parse(func(object model.RedisObject) bool {
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	actual := dec.GetAuxFields()
+	if len(actual) != len(auxFields) {
+		t.Errorf("expect %d aux fields, actual %d", len(auxFields), len(actual))
+		return
+	}
+	for i, field := range auxFields {
+		if actual[i].Key != field[0] || actual[i].Value != field[1] {
+			t.Errorf("expect aux %s=%s at %d, actual %s=%s", field[0], field[1], i, actual[i].Key, actual[i].Value)
+		}
+	}
+}
+
+func TestSetAuxField(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf).
+		SetAuxField("redis-ver", "7.0.0").
+		SetAuxField("aof-base", "0").
+		SetAuxField("aof-base", "1")
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteAux("x-extra", "1"); err != nil {
+		t.Fatal(err)
+	}
+	enc.SetAuxField("x-late", "2")
+	if err := enc.WriteDBHeader(0, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteStringObject("a", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	enc.SetAuxField("x-too-late", "3")
+	if err := enc.WriteEnd(); err == nil {
+		t.Error("expect error for aux field after keys")
+	}
+
+	buf = bytes.NewBuffer(nil)
+	enc = NewEncoder(buf).SetAuxField("aof-base", "1")
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	enc.SetAuxField("x-late", "2")
+	if err := enc.WriteDBHeader(0, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteStringObject("a", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	dec := NewDecoder(buf)
+	err := dec.Parse(func(object model.RedisObject) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := [][2]string{{"aof-base", "1"}, {"x-late", "2"}}
+	actual := dec.GetAuxFields()
+	if len(actual) != len(expect) {
+		t.Fatalf("expect %d aux fields, actual %d", len(expect), len(actual))
+	}
+	for i, field := range expect {
+		if actual[i].Key != field[0] || actual[i].Value != field[1] {
+			t.Errorf("expect aux %s=%s at %d, actual %s=%s", field[0], field[1], i, actual[i].Key, actual[i].Value)
+		}
+	}
+}
+
+func TestZeroTTL(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteStringObject("zero", []byte("a"), WithTTL(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteStringObject("persistent", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	expirations := make(map[string]*time.Time)
+	err := NewDecoder(buf).Parse(func(o model.RedisObject) bool {
+		expirations[o.GetKey()] = o.GetExpiration()
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expirations["zero"] == nil || expirations["zero"].UnixNano() != 0 {
+		t.Errorf("expiration 0 should be kept, actual %v", expirations["zero"])
+	}
+	if expirations["persistent"] != nil {
+		t.Errorf("persistent key should not have expiration")
+	}
+}
+
+func TestWriteIdle(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	offset := buf.Len()
+	if err := enc.WriteStringObject("k", []byte("v"), WithTTL(1000), WithIdle(120)); err != nil {
+		t.Fatal(err)
+	}
+	// expire, RDB_OPCODE_IDLE with a 14 bit length, then type as rdbSaveKeyValuePair does
+	expect := []byte{opCodeExpireTimeMs, 0xe8, 0x03, 0, 0, 0, 0, 0, 0, opCodeIdle, 0x40, 0x78, typeString}
+	if actual := buf.Bytes()[offset : offset+len(expect)]; !bytes.Equal(actual, expect) {
+		t.Errorf("expect % x, actual % x", expect, actual)
+	}
+	if opCodeIdle != 0xf8 {
+		t.Errorf("RDB_OPCODE_IDLE should be 248, actual %d", opCodeIdle)
+	}
+}
+
+func TestBinaryAux(t *testing.T) {
+	value := "a\x00\xff\xfeb"
+	data := buildRDB(t, func(enc *Encoder, check func(error)) {
+		check(enc.WriteAux("x-bin", value))
+		check(enc.WriteAux("x-dup", "1"))
+		check(enc.WriteAux("x-dup", "2"))
+		check(enc.WriteDBHeader(0, 1, 0))
+		check(enc.WriteStringObject("a", []byte("b")))
+	})
+	dec := NewDecoder(bytes.NewReader(data))
+	if err := dec.Parse(func(object model.RedisObject) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	raw, ok := dec.AuxBytes("x-bin")
+	if !ok || !bytes.Equal(raw, []byte(value)) {
+		t.Errorf("expect aux bytes %q, actual %q", value, raw)
+	}
+	if s, ok := dec.AuxString("x-bin"); !ok || s != value {
+		t.Errorf("expect aux string %q, actual %q", value, s)
+	}
+	if s, _ := dec.AuxString("x-dup"); s != "2" {
+		t.Errorf("expect the last aux value, actual %s", s)
+	}
+	if _, ok = dec.AuxBytes("missing"); ok {
+		t.Error("missing aux field should not be found")
+	}
+}
+
+func TestTargetVersion(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithTargetVersion(9))
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 3, 0); err != nil {
+		t.Fatal(err)
+	}
+	expire := map[string]int64{"a": time.Now().Add(time.Hour).UnixMilli()}
+	err := enc.WriteHashMapObjectEx("hfe", map[string][]byte{"a": []byte("1")}, expire)
+	if err == nil || !strings.Contains(err.Error(), "requires rdb version 12") {
+		t.Errorf("expect error of hash field expiration, actual %v", err)
+	}
+	// a hash without field ttl could be downgraded
+	if err = enc.WriteHashMapObjectEx("hash", map[string][]byte{"a": []byte("1")}, map[string]int64{"a": 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err = enc.WriteStreamObject("stream", testStreamObject(3)); err != nil {
+		t.Fatal(err)
+	}
+	if err = enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("REDIS0009")) {
+		t.Errorf("wrong header: %q", buf.Bytes()[:9])
+	}
+	dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+	objects := make(map[string]model.RedisObject)
+	err = dec.Parse(func(o model.RedisObject) bool {
+		objects[o.GetKey()] = o
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash := objects["hash"]; hash == nil || hash.GetEncoding() != model.ZipListEncoding {
+		t.Errorf("expect ziplist hash, actual %v", hash)
+	}
+	if stream, ok := objects["stream"].(*model.StreamObject); !ok || stream.Version != 1 {
+		t.Errorf("expect stream of version 1, actual %v", objects["stream"])
+	}
+
+	for _, version := range []int{7, 13} {
+		err = NewEncoder(bytes.NewBuffer(nil), WithTargetVersion(version)).WriteHeader()
+		if err == nil || !strings.Contains(err.Error(), "unsupported target version") {
+			t.Errorf("version %d: expect unsupported error, actual %v", version, err)
+		}
+	}
+	buf.Reset()
+	enc = NewEncoder(buf, WithTargetVersion(8))
+	if err = enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err = enc.WriteDBHeader(0, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err = enc.WriteStreamObject("stream", testStreamObject(1)); err == nil {
+		t.Error("expect error of stream in version 8")
+	}
+	// idle opcode is introduced in version 9
+	if err = enc.WriteStringObject("s", []byte("v"), WithIdle(10)); err != nil {
+		t.Fatal(err)
+	}
+	if err = enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	err = NewDecoder(bytes.NewReader(buf.Bytes())).Parse(func(o model.RedisObject) bool {
+		if o.(*model.StringObject).Idle != nil {
+			t.Error("idle should be dropped in version 8")
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHeaderVersion(t *testing.T) {
+	for _, target := range []int{0, 10, 11, 12} {
+		var options []interface{}
+		if target != 0 {
+			options = append(options, WithTargetVersion(target))
+		}
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, options...)
+		if err := enc.WriteHeader(); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteDBHeader(0, 2, 0); err != nil {
+			t.Fatal(err)
+		}
+		expire := map[string]int64{"a": time.Now().Add(time.Hour).UnixMilli()}
+		hash := map[string][]byte{"a": []byte("1")}
+		if err := enc.WriteHashMapObjectEx("hfe", hash, expire); err != nil {
+			if target == 12 {
+				t.Fatal(err)
+			}
+			if err = enc.WriteHashMapObject("hfe", hash); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := enc.WriteStreamObject("stream", testStreamObject(3)); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteEnd(); err != nil {
+			t.Fatal(err)
+		}
+		dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+		err := dec.Parse(func(o model.RedisObject) bool {
+			required := 0
+			switch x := o.(type) {
+			case *model.HashObject:
+				if len(x.FieldExpirations) > 0 {
+					required = 12
+				}
+			case *model.StreamObject:
+				required = 8 + int(x.Version)
+			}
+			if dec.GetVersion() < required {
+				t.Errorf("target %d: %s requires version %d, header is %d", target, o.GetKey(), required, dec.GetVersion())
+			}
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// redis 7.0 and 7.2 refuse rdb version 12, so it must be required explicitly
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf.Bytes()) != "REDIS0011" {
+		t.Errorf("expect default header REDIS0011, actual %q", buf.Bytes())
+	}
+	if err := enc.WriteDBHeader(0, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	expire := map[string]int64{"a": time.Now().Add(time.Hour).UnixMilli()}
+	err := enc.WriteHashMapObjectEx("hfe", map[string][]byte{"a": []byte("1")}, expire)
+	if err == nil || !strings.Contains(err.Error(), "target version is 11") {
+		t.Errorf("expect error of field expirations without target version, actual %v", err)
+	}
+}
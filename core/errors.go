@@ -0,0 +1,56 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Phases of parsing in ParseError
+const (
+	PhaseHeader   = "header"   // reading magic number and version
+	PhaseOpcode   = "opcode"   // reading the opcode or type byte of the next record
+	PhaseMetadata = "metadata" // reading payload of an opcode which is not a key, e.g. aux field, select db, expire
+	PhaseKey      = "key"
+	PhaseValue    = "value"
+	PhaseCRC      = "crc" // reading checksum after EOF opcode
+)
+
+// ParseError is returned by Parse and ParseObject on every decode failure, underlying error could be got by errors.As
+// or errors.Unwrap
+type ParseError struct {
+	// Offset is position in rdb file of the opcode or type byte of the record being decoded,
+	// or position of failed read in PhaseHeader, PhaseOpcode and PhaseCRC
+	Offset int64
+	Opcode byte // Opcode is the opcode or type byte of the record being decoded
+	Phase  string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse %s failed at offset %d (opcode %d): %v", e.Phase, e.Offset, e.Opcode, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// setPhase records what the decoder is reading, which is reported by ParseError
+func (dec *Decoder) setPhase(phase string, opcode byte, offset int64) {
+	dec.phase = phase
+	dec.phaseOpcode = opcode
+	dec.phaseOffset = offset
+}
+
+// wrapParseError wraps err as ParseError of current phase, unless it is a ParseError already
+func (dec *Decoder) wrapParseError(err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return err
+	}
+	return &ParseError{
+		Offset: dec.phaseOffset,
+		Opcode: dec.phaseOpcode,
+		Phase:  dec.phase,
+		Err:    err,
+	}
+}
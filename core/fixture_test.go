@@ -0,0 +1,34 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+// buildRDB returns an rdb whose content between header and end is written by write,
+// check fails the test if a write returns error
+func buildRDB(t testing.TB, write func(enc *Encoder, check func(error)), options ...interface{}) []byte {
+	t.Helper()
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, options...)
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(enc.WriteHeader())
+	write(enc, check)
+	check(enc.WriteEnd())
+	return buf.Bytes()
+}
+
+// writeRawObject writes type and key of an object whose value is written by value,
+// e.g. an encoding or a module value which encoder does not write
+func writeRawObject(enc *Encoder, check func(error), typ byte, key string, value func()) {
+	check(enc.beforeWriteObject())
+	check(enc.write([]byte{typ}))
+	check(enc.writeString(key))
+	value()
+	enc.state = writtenObjectState
+}
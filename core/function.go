@@ -0,0 +1,108 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+// registerFunctionReg matches `redis.register_function('name', ...)` and
+// `redis.register_function{function_name='name', ...}`. It is a best effort, e.g. names built at runtime are missed
+var registerFunctionReg = regexp.MustCompile(
+	`redis\.register_function\s*(?:\(\s*)?(?:['"]([^'"]+)['"]|\{[^}]*?function_name\s*=\s*['"]([^'"]+)['"])`)
+
+// extractFunctionNames scans lua code for names of registered functions
+func extractFunctionNames(code string) []string {
+	var names []string
+	for _, match := range registerFunctionReg.FindAllStringSubmatch(code, -1) {
+		if match[1] != "" {
+			names = append(names, match[1])
+		} else {
+			names = append(names, match[2])
+		}
+	}
+	return names
+}
+
+// ParseFunctionCode parses source of function library, which starts with shebang like `#!lua name=mylib`
+func ParseFunctionCode(code string) (*model.FunctionLibrary, error) {
+	if !strings.HasPrefix(code, "#!") {
+		return nil, errors.New("missing library metadata")
+	}
+	shebang := code[2:]
+	if i := strings.IndexByte(shebang, '\n'); i >= 0 {
+		shebang = shebang[:i]
+	}
+	parts := strings.Fields(shebang)
+	if len(parts) == 0 {
+		return nil, errors.New("missing engine name in library metadata")
+	}
+	lib := &model.FunctionLibrary{
+		BaseObject: &model.BaseObject{},
+		Engine:     parts[0],
+		Code:       code,
+		Functions:  extractFunctionNames(code),
+	}
+	for _, part := range parts[1:] {
+		if strings.HasPrefix(part, "name=") {
+			lib.Name = part[len("name="):]
+		}
+	}
+	if lib.Name == "" {
+		return nil, errors.New("library name was not given")
+	}
+	lib.Key = lib.Name
+	return lib, nil
+}
+
+// readFunction2 reads function library of opcode RDB_OPCODE_FUNCTION2, which is the library code only,
+// engine and name are in its shebang
+func (dec *Decoder) readFunction2() (*model.FunctionLibrary, error) {
+	code, err := dec.readString()
+	if err != nil {
+		return nil, fmt.Errorf("read function code failed: %v", err)
+	}
+	lib, err := ParseFunctionCode(string(code))
+	if err != nil {
+		return nil, fmt.Errorf("parse function library failed: %v", err)
+	}
+	return lib, nil
+}
+
+// readFunctionPreGA reads function library of opcode RDB_OPCODE_FUNCTION_PRE_GA written by redis 7.0 release candidates,
+// which stores name, engine and description besides code
+func (dec *Decoder) readFunctionPreGA() (*model.FunctionLibrary, error) {
+	name, err := dec.readString()
+	if err != nil {
+		return nil, fmt.Errorf("read function name failed: %v", err)
+	}
+	engine, err := dec.readString()
+	if err != nil {
+		return nil, fmt.Errorf("read function engine failed: %v", err)
+	}
+	hasDesc, _, err := dec.readLength()
+	if err != nil {
+		return nil, err
+	}
+	if hasDesc != 0 {
+		if err = dec.skipString(); err != nil {
+			return nil, fmt.Errorf("read function description failed: %v", err)
+		}
+	}
+	code, err := dec.readString()
+	if err != nil {
+		return nil, fmt.Errorf("read function code failed: %v", err)
+	}
+	return &model.FunctionLibrary{
+		BaseObject: &model.BaseObject{
+			Key: string(name),
+		},
+		Name:      string(name),
+		Engine:    string(engine),
+		Code:      string(code),
+		Functions: extractFunctionNames(string(code)),
+	}, nil
+}
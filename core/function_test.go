@@ -0,0 +1,120 @@
+package core
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+const testFunctionCode = "#!lua name=mylib\n" +
+	"redis.register_function('knockknock', function() return 'Who\\'s there?' end)\n" +
+	"redis.register_function{function_name='my_hset', callback=function(keys, args) return 1 end, flags={'no-writes'}}\n"
+
+func TestParseFunctionCode(t *testing.T) {
+	lib, err := ParseFunctionCode(testFunctionCode)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if lib.Name != "mylib" || lib.Engine != "lua" || lib.Code != testFunctionCode {
+		t.Errorf("wrong library: %s %s", lib.Name, lib.Engine)
+	}
+	if len(lib.Functions) != 2 || lib.Functions[0] != "knockknock" || lib.Functions[1] != "my_hset" {
+		t.Errorf("wrong functions: %v", lib.Functions)
+	}
+	if _, err = ParseFunctionCode("return 1"); err == nil {
+		t.Error("expect error for missing shebang")
+	}
+	if _, err = ParseFunctionCode("#!lua\nreturn 1"); err == nil {
+		t.Error("expect error for missing library name")
+	}
+}
+
+func TestFunctionPreGA(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("REDIS0010")
+	buf.WriteByte(opCodeFunctionPreGA)
+	writeStr := func(s string) {
+		buf.WriteByte(0x80)
+		buf.Write([]byte{byte(len(s) >> 24), byte(len(s) >> 16), byte(len(s) >> 8), byte(len(s))})
+		buf.WriteString(s)
+	}
+	writeStr("mylib")
+	writeStr("LUA")
+	buf.WriteByte(1)
+	writeStr("my library")
+	writeStr(testFunctionCode)
+	buf.Write([]byte{0xFE, 0x00, 0x00, 0x01, 'a', 0x01, '1', 0xFF})
+	buf.Write(make([]byte, 8))
+
+	var lib *model.FunctionLibrary
+	var keys []string
+	err := NewDecoder(bytes.NewReader(buf.Bytes())).WithSpecialOpCode().Parse(func(object model.RedisObject) bool {
+		switch o := object.(type) {
+		case *model.FunctionLibrary:
+			lib = o
+		case *model.StringObject:
+			keys = append(keys, o.Key)
+		}
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if lib == nil {
+		t.Error("function library not found")
+		return
+	}
+	if lib.Name != "mylib" || lib.Engine != "LUA" || lib.Code != testFunctionCode || lib.GetType() != model.FunctionType {
+		t.Errorf("wrong library: %s %s", lib.Name, lib.Engine)
+	}
+	if len(lib.Functions) != 2 || lib.Functions[0] != "knockknock" || lib.Functions[1] != "my_hset" {
+		t.Errorf("wrong functions: %v", lib.Functions)
+	}
+	if len(keys) != 1 || keys[0] != "a" {
+		t.Errorf("wrong keys: %v", keys)
+	}
+}
+
+func TestFunction2(t *testing.T) {
+	// cases/function.rdb is saved by redis 7.0 after FUNCTION LOAD, the library code is LZF compressed
+	data, err := os.ReadFile("../cases/function.rdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var libs []*model.FunctionLibrary
+	var keys []string
+	dec := NewDecoder(bytes.NewReader(data)).WithSpecialOpCode().WithStrictOpcodes()
+	err = dec.Parse(func(object model.RedisObject) bool {
+		switch o := object.(type) {
+		case *model.FunctionLibrary:
+			libs = append(libs, o)
+		case *model.StringObject:
+			keys = append(keys, o.Key)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(libs) != 1 {
+		t.Fatalf("expect 1 library, actual %d", len(libs))
+	}
+	lib := libs[0]
+	if lib.Name != "mylib" || lib.Engine != "lua" || !strings.HasPrefix(lib.Code, "#!lua name=mylib\n") {
+		t.Errorf("wrong library: %s %s", lib.Name, lib.Engine)
+	}
+	if len(lib.Functions) != 2 || lib.Functions[0] != "my_hset" || lib.Functions[1] != "knockknock" {
+		t.Errorf("wrong functions: %v", lib.Functions)
+	}
+	if len(keys) != 1 || keys[0] != "a" {
+		t.Errorf("wrong keys: %v", keys)
+	}
+	if dec.Stats().OpCodes["function2"] != 1 {
+		t.Errorf("expect 1 function2 opcode, actual %v", dec.Stats().OpCodes)
+	}
+}
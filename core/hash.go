@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/hdt3213/rdb/model"
 )
@@ -12,22 +13,67 @@ import (
 	if hlen <= ZIPMAP_VALUE_MAX_FREE
 */
 
+func (dec *Decoder) acceptHashField(field []byte) bool {
+	return dec.hashFieldFilter == nil || dec.hashFieldFilter(field)
+}
+
+func (dec *Decoder) recordHashField(field string, value []byte) {
+	if dec.withOrderedFields {
+		dec.hashFields = append(dec.hashFields, model.HashField{Name: field, Value: value})
+	}
+}
+
+// takeHashFields returns fields recorded for current hash, fields of hashtable encoding should be sorted
+// since they have no meaningful order
+func (dec *Decoder) takeHashFields(sortByName bool) []model.HashField {
+	if !dec.withOrderedFields {
+		return nil
+	}
+	fields := dec.hashFields
+	dec.hashFields = nil
+	if fields == nil {
+		fields = []model.HashField{}
+	}
+	if sortByName {
+		sort.Slice(fields, func(i, j int) bool {
+			return fields[i].Name < fields[j].Name
+		})
+	}
+	return fields
+}
+
 func (dec *Decoder) readHashMap() (map[string][]byte, error) {
-	size, _, err := dec.readLength()
+	size, err := dec.readElementCount()
 	if err != nil {
 		return nil, err
 	}
 	m := make(map[string][]byte)
-	for i := 0; i < int(size); i++ {
+	for i := 0; i < size; i++ {
+		if dec.capReached(len(m)) {
+			if err := dec.skipString(); err != nil {
+				return nil, err
+			}
+			if err := dec.skipString(); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		field, err := dec.readString()
 		if err != nil {
 			return nil, err
 		}
+		if !dec.acceptHashField(field) {
+			if err := dec.skipString(); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		value, err := dec.readString()
 		if err != nil {
 			return nil, err
 		}
 		m[unsafeBytes2Str(field)] = value
+		dec.recordHashField(unsafeBytes2Str(field), value)
 	}
 	return m, nil
 }
@@ -46,7 +92,7 @@ func (dec *Decoder) readHashMapEx(rc bool) (map[string][]byte, map[string]int64,
 		}
 		minExpire = min
 	}
-	size, _, err := dec.readLength()
+	size, err := dec.readElementCount()
 	if err != nil {
 		return nil, nil, err
 	} else if size == 0 {
@@ -54,7 +100,7 @@ func (dec *Decoder) readHashMapEx(rc bool) (map[string][]byte, map[string]int64,
 	}
 	m := make(map[string][]byte)
 	e := make(map[string]int64)
-	for i := 0; i < int(size); i++ {
+	for i := 0; i < size; i++ {
 		ttl, _, err := dec.readLength()
 		if err != nil {
 			return nil, nil, err
@@ -72,22 +118,38 @@ func (dec *Decoder) readHashMapEx(rc bool) (map[string][]byte, map[string]int64,
 		if expire > EB_EXPIRE_TIME_MAX {
 			return nil, nil, fmt.Errorf("invalid expireAt time: %d", expire)
 		}
+		if dec.capReached(len(m)) {
+			if err := dec.skipString(); err != nil {
+				return nil, nil, err
+			}
+			if err := dec.skipString(); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
 		field, err := dec.readString()
 		if err != nil {
 			return nil, nil, err
 		}
+		if !dec.acceptHashField(field) {
+			if err := dec.skipString(); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
 		value, err := dec.readString()
 		if err != nil {
 			return nil, nil, err
 		}
 		m[unsafeBytes2Str(field)] = value
 		e[unsafeBytes2Str(field)] = expire
+		dec.recordHashField(unsafeBytes2Str(field), value)
 	}
 	return m, e, nil
 }
 
 func (dec *Decoder) readZipMapHash() (map[string][]byte, error) {
-	buf, err := dec.readString()
+	buf, err := dec.readContainer()
 	if err != nil {
 		return nil, err
 	}
@@ -109,6 +171,9 @@ func (dec *Decoder) readZipMapHash() (map[string][]byte, error) {
 	}
 	m := make(map[string][]byte)
 	for i := 0; i < length; i++ {
+		if dec.capReached(len(m)) {
+			break
+		}
 		fieldB, err := readZipMapEntry(buf, &cursor, false)
 		if err != nil {
 			return nil, err
@@ -118,7 +183,11 @@ func (dec *Decoder) readZipMapHash() (map[string][]byte, error) {
 		if err != nil {
 			return nil, err
 		}
+		if !dec.acceptHashField(fieldB) {
+			continue
+		}
 		m[field] = value
+		dec.recordHashField(field, value)
 	}
 	return m, nil
 }
@@ -186,7 +255,7 @@ func countZipMapEntries(buf []byte, cursor *int) (int, error) {
 }
 
 func (dec *Decoder) readZipListHash() (map[string][]byte, *model.ZiplistDetail, error) {
-	buf, err := dec.readString()
+	buf, err := dec.readContainer()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -194,6 +263,9 @@ func (dec *Decoder) readZipListHash() (map[string][]byte, *model.ZiplistDetail,
 	size := readZipListLength(buf, &cursor)
 	m := make(map[string][]byte)
 	for i := 0; i < size; i += 2 {
+		if dec.capReached(len(m)) {
+			break
+		}
 		key, err := dec.readZipListEntry(buf, &cursor)
 		if err != nil {
 			return nil, nil, err
@@ -202,7 +274,11 @@ func (dec *Decoder) readZipListHash() (map[string][]byte, *model.ZiplistDetail,
 		if err != nil {
 			return nil, nil, err
 		}
+		if !dec.acceptHashField(key) {
+			continue
+		}
 		m[unsafeBytes2Str(key)] = val
+		dec.recordHashField(unsafeBytes2Str(key), val)
 	}
 	detail := &model.ZiplistDetail{
 		RawStringSize: len(buf),
@@ -211,14 +287,17 @@ func (dec *Decoder) readZipListHash() (map[string][]byte, *model.ZiplistDetail,
 }
 
 func (dec *Decoder) readListPackHash() (map[string][]byte, *model.ListpackDetail, error) {
-	buf, err := dec.readString()
+	buf, err := dec.readContainer()
 	if err != nil {
 		return nil, nil, err
 	}
 	cursor := 0
-	size := readListPackLength(buf, &cursor)
+	size := dec.readListPackLength(buf, &cursor)
 	m := make(map[string][]byte)
 	for i := 0; i < size; i += 2 {
+		if dec.capReached(len(m)) {
+			break
+		}
 		key, err := dec.readListPackEntryAsString(buf, &cursor)
 		if err != nil {
 			return nil, nil, err
@@ -227,7 +306,11 @@ func (dec *Decoder) readListPackHash() (map[string][]byte, *model.ListpackDetail
 		if err != nil {
 			return nil, nil, err
 		}
+		if !dec.acceptHashField(key) {
+			continue
+		}
 		m[unsafeBytes2Str(key)] = val
+		dec.recordHashField(unsafeBytes2Str(key), val)
 	}
 	detail := &model.ListpackDetail{
 		RawStringSize: len(buf),
@@ -243,12 +326,12 @@ func (dec *Decoder) readListPackHashEx(rc bool) (map[string][]byte, map[string]i
 			return nil, nil, nil, err
 		}
 	}
-	buf, err := dec.readString()
+	buf, err := dec.readContainer()
 	if err != nil {
 		return nil, nil, nil, err
 	}
 	cursor := 0
-	size := readListPackLength(buf, &cursor)
+	size := dec.readListPackLength(buf, &cursor)
 	if size == 0 {
 		return nil, nil, nil, fmt.Errorf("hash listpack read empty key")
 	} else if size%3 != 0 {
@@ -257,6 +340,9 @@ func (dec *Decoder) readListPackHashEx(rc bool) (map[string][]byte, map[string]i
 	m := make(map[string][]byte)
 	e := make(map[string]int64)
 	for i := 0; i < size; i += 3 {
+		if dec.capReached(len(m)) {
+			break
+		}
 		key, err := dec.readListPackEntryAsString(buf, &cursor)
 		if err != nil {
 			return nil, nil, nil, err
@@ -269,8 +355,12 @@ func (dec *Decoder) readListPackHashEx(rc bool) (map[string][]byte, map[string]i
 		if err != nil {
 			return nil, nil, nil, err
 		}
+		if !dec.acceptHashField(key) {
+			continue
+		}
 		m[unsafeBytes2Str(key)] = val
 		e[unsafeBytes2Str(key)] = expire
+		dec.recordHashField(unsafeBytes2Str(key), val)
 	}
 	detail := &model.ListpackDetail{
 		RawStringSize: len(buf),
@@ -297,20 +387,56 @@ func (enc *Encoder) WriteHashMapObject(key string, hash map[string][]byte, optio
 	return nil
 }
 
+// WriteHashMapObjectEx writes a hash with field expirations (Redis 7.4+).
+// expire maps field to its absolute expiration timestamp in milliseconds, 0 means the field has no ttl.
+// Small hashes are written as listpack ex, others are written as hash with metadata
 func (enc *Encoder) WriteHashMapObjectEx(key string, hash map[string][]byte, expire map[string]int64, options ...interface{}) error {
+	if !enc.supports(12) {
+		for field := range hash {
+			if expire[field] != 0 {
+				return fmt.Errorf("hash field expiration of %s requires rdb version 12, target version is %d",
+					key, enc.version())
+			}
+		}
+		return enc.WriteHashMapObject(key, hash, options...)
+	}
 	err := enc.beforeWriteObject(options...)
 	if err != nil {
 		return err
 	}
-
-	err = enc.writeHashEncodingEx(key, hash, expire, options...)
+	minExpire, err := getHashMinExpire(hash, expire)
 	if err != nil {
 		return err
 	}
+	ok, err := enc.tryWriteListPackHashEx(key, hash, expire, minExpire)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		err = enc.writeHashEncodingEx(key, hash, expire, minExpire)
+		if err != nil {
+			return err
+		}
+	}
 	enc.state = writtenObjectState
 	return nil
 }
 
+// getHashMinExpire returns the earliest field expiration, or EB_EXPIRE_TIME_INVALID if no field has ttl
+func getHashMinExpire(hash map[string][]byte, expire map[string]int64) (int64, error) {
+	minExpire := EB_EXPIRE_TIME_INVALID
+	for field := range hash {
+		e := expire[field]
+		if e < 0 || e > EB_EXPIRE_TIME_MAX {
+			return 0, fmt.Errorf("invalid expireAt time of field %s: %d", field, e)
+		}
+		if e > 0 && e < minExpire {
+			minExpire = e
+		}
+	}
+	return minExpire, nil
+}
+
 func (enc *Encoder) writeHashEncoding(key string, hash map[string][]byte, options ...interface{}) error {
 	err := enc.write([]byte{typeHash})
 	if err != nil {
@@ -337,7 +463,7 @@ func (enc *Encoder) writeHashEncoding(key string, hash map[string][]byte, option
 	return nil
 }
 
-func (enc *Encoder) writeHashEncodingEx(key string, hash map[string][]byte, expire map[string]int64, options ...interface{}) error {
+func (enc *Encoder) writeHashEncodingEx(key string, hash map[string][]byte, expire map[string]int64, minExpire int64) error {
 	err := enc.write([]byte{typeHashWithHfe})
 	if err != nil {
 		return err
@@ -347,15 +473,8 @@ func (enc *Encoder) writeHashEncodingEx(key string, hash map[string][]byte, expi
 		return err
 	}
 	// Hash with HFEs. min TTL at start (7.4+), 7.4RC not included
-	var minExpire int64 = 0
-	for _, e := range expire {
-		if e > minExpire {
-			minExpire = e
-		}
-	}
-	minExpireBytes := make([]byte, 8)
-	binary.LittleEndian.PutUint64(minExpireBytes[:], uint64(minExpire))
-	err = enc.write(minExpireBytes)
+	binary.LittleEndian.PutUint64(enc.buffer, uint64(minExpire))
+	err = enc.write(enc.buffer)
 	if err != nil {
 		return err
 	}
@@ -364,7 +483,12 @@ func (enc *Encoder) writeHashEncodingEx(key string, hash map[string][]byte, expi
 		return err
 	}
 	for field, value := range hash {
-		err = enc.writeLength(uint64(expire[field] + 1 - minExpire))
+		// 0 indicates no TTL, otherwise TTL is relative to minExpire (with +1 to avoid 0 that already taken)
+		var ttl uint64
+		if e := expire[field]; e > 0 {
+			ttl = uint64(e - minExpire + 1)
+		}
+		err = enc.writeLength(ttl)
 		if err != nil {
 			return err
 		}
@@ -380,6 +504,50 @@ func (enc *Encoder) writeHashEncodingEx(key string, hash map[string][]byte, expi
 	return nil
 }
 
+func (enc *Encoder) tryWriteListPackHashEx(key string, hash map[string][]byte, expire map[string]int64, minExpire int64) (bool, error) {
+	if len(hash) > enc.hashZipListOpt.getMaxEntries() {
+		return false, nil
+	}
+	maxValue := enc.hashZipListOpt.getMaxValue()
+	for _, v := range hash {
+		if len(v) > maxValue {
+			return false, nil
+		}
+	}
+	err := enc.write([]byte{typeHashListPackWithHfe})
+	if err != nil {
+		return true, err
+	}
+	err = enc.writeString(key)
+	if err != nil {
+		return true, err
+	}
+	binary.LittleEndian.PutUint64(enc.buffer, uint64(minExpire))
+	err = enc.write(enc.buffer)
+	if err != nil {
+		return true, err
+	}
+	// each field is stored as 3 entries: field, value, expire(0 means no ttl)
+	buf := make([]byte, 6) // reserve 6 bytes for list pack header
+	appendEntry := func(entry []byte) {
+		buf = append(buf, entry...)
+		buf = append(buf, enc.encodeBacklen(uint32(len(entry)))...)
+	}
+	for field, value := range hash {
+		appendEntry(enc.encodeListPackString(field))
+		appendEntry(enc.encodeListPackString(unsafeBytes2Str(value)))
+		appendEntry(enc.encodeListPackInt(expire[field]))
+	}
+	buf = append(buf, 0xff)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(buf)))
+	binary.LittleEndian.PutUint16(buf[4:6], uint16(len(hash)*3))
+	err = enc.writeNanString(unsafeBytes2Str(buf))
+	if err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
 func (enc *Encoder) tryWriteZipListHashMap(key string, hash map[string][]byte, options ...interface{}) (bool, error) {
 	if len(hash) > enc.hashZipListOpt.getMaxEntries() {
 		return false, nil
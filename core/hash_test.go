@@ -2,9 +2,13 @@ package core
 
 import (
 	"bytes"
+	"encoding/binary"
 	"github.com/hdt3213/rdb/model"
 	"math/rand"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestHashEncoding(t *testing.T) {
@@ -78,3 +82,444 @@ func TestHashEncoding(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestHashExEncoding(t *testing.T) {
+	now := time.Now().UnixNano() / 1e6
+	hashMap := map[string]map[string][]byte{
+		"small": {
+			"a": []byte("foo bar"),
+			"b": []byte("114514"),
+			"c": []byte(""),
+		},
+		"persist": {
+			"a": []byte("foo bar"),
+		},
+	}
+	expireMap := map[string]map[string]int64{
+		"small": {
+			"a": now + 1000,
+			"b": now + 3600*1000,
+		},
+		"persist": {},
+	}
+	large := make(map[string][]byte)
+	largeExpire := make(map[string]int64)
+	for i := 0; i < 1024; i++ {
+		field := strconv.Itoa(i) + RandString(rand.Intn(32))
+		large[field] = []byte(RandString(rand.Intn(128)))
+		if i%3 != 0 {
+			largeExpire[field] = now + int64(rand.Intn(86400*1000))
+		}
+	}
+	hashMap["large"] = large
+	expireMap["large"] = largeExpire
+	expectEncoding := map[string]string{
+		"small":   model.ListPackExEncoding,
+		"persist": model.ListPackExEncoding,
+		"large":   model.HashExEncoding,
+	}
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithTargetVersion(12)).SetHashZipListOpt(64, 64)
+	err := enc.WriteHeader()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = enc.WriteDBHeader(0, uint64(len(hashMap)), 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for k, v := range hashMap {
+		err = enc.WriteHashMapObjectEx(k, v, expireMap[k])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	err = enc.WriteEnd()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	dec := NewDecoder(buf)
+	count := 0
+	err = dec.Parse(func(object model.RedisObject) bool {
+		o, ok := object.(*model.HashObject)
+		if !ok {
+			t.Errorf("object %s is not a hash", object.GetKey())
+			return true
+		}
+		count++
+		if o.GetEncoding() != expectEncoding[o.GetKey()] {
+			t.Errorf("hash %s has wrong encoding %s", o.GetKey(), o.GetEncoding())
+		}
+		expect := hashMap[o.GetKey()]
+		if len(expect) != o.GetElemCount() {
+			t.Errorf("hash %s has wrong element count", o.GetKey())
+			return true
+		}
+		for field, expectV := range expect {
+			if !bytes.Equal(expectV, o.Hash[field]) {
+				t.Errorf("hash %s has wrong value at field %s", o.GetKey(), field)
+			}
+			if expireMap[o.GetKey()][field] != o.FieldExpirations[field] {
+				t.Errorf("hash %s has wrong expiration at field %s, expect %d, actual %d",
+					o.GetKey(), field, expireMap[o.GetKey()][field], o.FieldExpirations[field])
+			}
+		}
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if count != len(hashMap) {
+		t.Errorf("expect %d hashes, actual %d", len(hashMap), count)
+	}
+}
+
+func TestElementCap(t *testing.T) {
+	hash := make(map[string][]byte)
+	for i := 0; i < 10000; i++ {
+		hash["field"+strconv.Itoa(i)] = []byte(strconv.Itoa(i) + RandString(64))
+	}
+	smallHash := make(map[string][]byte)
+	for i := 0; i < 200; i++ {
+		smallHash[strconv.Itoa(i)] = []byte(strconv.Itoa(i))
+	}
+	var list [][]byte
+	var set [][]byte
+	var zset []*model.ZSetEntry
+	for i := 0; i < 1000; i++ {
+		list = append(list, []byte(strconv.Itoa(i)))
+		set = append(set, []byte(strconv.Itoa(i)))
+		zset = append(zset, &model.ZSetEntry{Member: strconv.Itoa(i), Score: float64(i)})
+	}
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf).SetHashZipListOpt(512, 64)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 6, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteHashMapObject("hash", hash); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteHashMapObject("small", smallHash); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteListObject("list", list); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteSetObject("set", set); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteZSetObject("zset", zset); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteStringObject("tail", []byte("end")); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	truncated := make(map[string]bool)
+	err := NewDecoder(buf).WithElementCap(100).Parse(func(o model.RedisObject) bool {
+		counts[o.GetKey()] = o.GetElemCount()
+		switch obj := o.(type) {
+		case *model.HashObject:
+			truncated[obj.Key] = obj.Truncated
+			for field, value := range obj.Hash {
+				if obj.Key == "hash" && !bytes.HasPrefix(value, []byte(field[len("field"):])) {
+					t.Errorf("wrong value of field %s", field)
+				}
+			}
+		case *model.ListObject:
+			truncated[obj.Key] = obj.Truncated
+		case *model.SetObject:
+			truncated[obj.Key] = obj.Truncated
+		case *model.ZSetObject:
+			truncated[obj.Key] = obj.Truncated
+		case *model.StringObject:
+			truncated[obj.Key] = obj.Truncated
+			if string(obj.Value) != "end" {
+				t.Errorf("wrong value of tail: %s", obj.Value)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"hash", "small", "list", "set", "zset"} {
+		if counts[key] != 100 || !truncated[key] {
+			t.Errorf("%s expect 100 elements and truncated, actual %d %v", key, counts[key], truncated[key])
+		}
+	}
+	if _, ok := counts["tail"]; !ok || truncated["tail"] {
+		t.Error("tail should be read without truncation")
+	}
+}
+
+func TestOrderedFields(t *testing.T) {
+	fields := []model.HashField{
+		{Name: "zeta", Value: []byte("1")},
+		{Name: "alpha", Value: []byte("2")},
+		{Name: "mid", Value: []byte("3")},
+	}
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := enc.WriteDBHeader(0, 2, 0); err != nil {
+		t.Error(err)
+		return
+	}
+	// listpack hash in the order of fields
+	listpack := make([]byte, 6)
+	appendEntry := func(entry []byte) {
+		listpack = append(listpack, entry...)
+		listpack = append(listpack, enc.encodeBacklen(uint32(len(entry)))...)
+	}
+	for _, field := range fields {
+		appendEntry(enc.encodeListPackString(field.Name))
+		appendEntry(enc.encodeListPackString(string(field.Value)))
+	}
+	listpack = append(listpack, 0xff)
+	binary.LittleEndian.PutUint32(listpack[0:4], uint32(len(listpack)))
+	binary.LittleEndian.PutUint16(listpack[4:6], uint16(len(fields)*2))
+	if err := enc.write([]byte{typeHashListPack}); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := enc.writeString("lp"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := enc.writeNanString(string(listpack)); err != nil {
+		t.Error(err)
+		return
+	}
+	hash := map[string][]byte{"c": []byte("1"), "a": []byte("2"), "b": []byte("3")}
+	if err := enc.writeHashEncoding("ht", hash); err != nil {
+		t.Error(err)
+		return
+	}
+	enc.state = writtenObjectState
+	if err := enc.WriteEnd(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	objects := make(map[string]*model.HashObject)
+	err := NewDecoder(bytes.NewReader(buf.Bytes())).WithOrderedFields().Parse(func(object model.RedisObject) bool {
+		objects[object.GetKey()] = object.(*model.HashObject)
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	lp := objects["lp"]
+	if lp == nil || len(lp.Fields) != len(fields) {
+		t.Errorf("wrong listpack hash: %v", lp)
+		return
+	}
+	for i, field := range fields {
+		if lp.Fields[i].Name != field.Name || string(lp.Fields[i].Value) != string(field.Value) {
+			t.Errorf("wrong field at %d: %s", i, lp.Fields[i].Name)
+		}
+	}
+	data, err := lp.MarshalJSON()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Contains(data, []byte(`"hash":{"zeta":"1","alpha":"2","mid":"3"}`)) {
+		t.Errorf("wrong json: %s", data)
+	}
+	ht := objects["ht"]
+	if ht == nil || len(ht.Fields) != 3 || ht.Fields[0].Name != "a" || ht.Fields[1].Name != "b" || ht.Fields[2].Name != "c" {
+		t.Errorf("hashtable fields should be sorted: %v", ht)
+	}
+
+	err = NewDecoder(bytes.NewReader(buf.Bytes())).Parse(func(object model.RedisObject) bool {
+		if object.(*model.HashObject).Fields != nil {
+			t.Error("fields should be nil without WithOrderedFields")
+		}
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHashFieldFilter(t *testing.T) {
+	large := make(map[string][]byte)
+	for i := 0; i < 100000; i++ {
+		day := "2023-12-31"
+		if i%10 == 0 {
+			day = "2024-01-01"
+		}
+		large[day+":"+strconv.Itoa(i)] = []byte(strconv.Itoa(i))
+	}
+	small := map[string][]byte{
+		"2024-01-01": []byte("1"),
+		"2023-12-31": []byte("2"),
+	}
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := enc.WriteDBHeader(0, 2, 0); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := enc.WriteHashMapObject("large", large); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := enc.WriteHashMapObject("small", small); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	prefix := []byte("2024-")
+	dec := NewDecoder(buf).WithHashFieldFilter(func(field []byte) bool {
+		return bytes.HasPrefix(field, prefix)
+	})
+	err := dec.Parse(func(object model.RedisObject) bool {
+		obj := object.(*model.HashObject)
+		src := large
+		if obj.Key == "small" {
+			src = small
+		}
+		count := 0
+		for field, value := range src {
+			if !strings.HasPrefix(field, "2024-") {
+				continue
+			}
+			count++
+			if string(obj.Hash[field]) != string(value) {
+				t.Errorf("%s: wrong value of %s", obj.Key, field)
+			}
+		}
+		if len(obj.Hash) != count {
+			t.Errorf("%s: expect %d fields, actual %d", obj.Key, count, len(obj.Hash))
+		}
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRawContainer(t *testing.T) {
+	value := strings.Repeat("v", 60)
+	listPack := testListPack(testListPackStr("f1"), testListPackStr(value), testListPackStr("f2"), testListPackStr(value))
+	data := buildRDB(t, func(enc *Encoder, check func(error)) {
+		// values longer than 1 byte make encoder write hash table
+		enc.EnableCompress().SetHashZipListOpt(1, 1)
+		check(enc.WriteDBHeader(0, 2, 0))
+		writeRawObject(enc, check, typeHashListPack, "listpack", func() {
+			check(enc.writeString(string(listPack)))
+		})
+		check(enc.WriteHashMapObject("hashtable", map[string][]byte{"f": []byte(value)}))
+	})
+	if bytes.Contains(data, listPack) {
+		t.Fatal("listpack should be compressed")
+	}
+	raws := make(map[string][]byte)
+	err := NewDecoder(bytes.NewReader(data)).WithRawContainer().Parse(func(o model.RedisObject) bool {
+		raws[o.GetKey()] = o.GetRawContainer()
+		if o.GetKey() == "listpack" && o.GetElemCount() != 2 {
+			t.Errorf("expect 2 fields, actual %d", o.GetElemCount())
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raws["listpack"], listPack) {
+		t.Errorf("raw container is not the source listpack: %x", raws["listpack"])
+	}
+	if raws["hashtable"] != nil {
+		t.Error("hashtable should have no raw container")
+	}
+
+	err = NewDecoder(bytes.NewReader(data)).Parse(func(o model.RedisObject) bool {
+		if o.GetRawContainer() != nil {
+			t.Error("raw container should be nil without WithRawContainer")
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestMixedHashEncodings decodes a dump written during a rolling upgrade, which has hashes in both ziplist and listpack
+func TestMixedHashEncodings(t *testing.T) {
+	zipList := testZipList(testZipStr("name"), testZipStr("redis"), testZipStr("version"), []byte{0xf8})
+	listPack := testListPack(testListPackStr("name"), testListPackStr("redis"), testListPackStr("version"), testListPackInt(7))
+	data := buildRDB(t, func(enc *Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 3, 0))
+		raw := func(typ byte, key string, container []byte) {
+			writeRawObject(enc, check, typ, key, func() {
+				check(enc.writeString(string(container)))
+			})
+		}
+		raw(typeHashZipList, "old", zipList)
+		raw(typeHashListPack, "new", listPack)
+		raw(typeHashZipList, "old2", zipList)
+	})
+	hashes := make(map[string]*model.HashObject)
+	err := NewDecoder(bytes.NewReader(data)).WithStrictOpcodes().Parse(func(o model.RedisObject) bool {
+		hash, ok := o.(*model.HashObject)
+		if !ok {
+			t.Errorf("%s is not a hash", o.GetKey())
+			return true
+		}
+		hashes[o.GetKey()] = hash
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEncoding := map[string]string{
+		"old":  model.ZipListEncoding,
+		"new":  model.ListPackEncoding,
+		"old2": model.ZipListEncoding,
+	}
+	expect := map[string]string{"name": "redis", "version": "7"}
+	for key, encoding := range expectEncoding {
+		hash := hashes[key]
+		if hash == nil {
+			t.Errorf("%s is missing", key)
+			continue
+		}
+		if hash.GetEncoding() != encoding {
+			t.Errorf("%s: expect encoding %s, actual %s", key, encoding, hash.GetEncoding())
+		}
+		if len(hash.Hash) != len(expect) {
+			t.Errorf("%s: expect %d fields, actual %d", key, len(expect), len(hash.Hash))
+		}
+		for field, value := range expect {
+			if string(hash.Hash[field]) != value {
+				t.Errorf("%s: expect %s=%s, actual %s", key, field, value, hash.Hash[field])
+			}
+		}
+	}
+}
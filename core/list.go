@@ -22,13 +22,18 @@ const (
 )
 
 func (dec *Decoder) readList() ([][]byte, error) {
-	size64, _, err := dec.readLength()
+	size, err := dec.readElementCount()
 	if err != nil {
 		return nil, err
 	}
-	size := int(size64)
-	values := make([][]byte, 0, size)
+	values := make([][]byte, 0, preallocSize(size))
 	for i := 0; i < size; i++ {
+		if dec.capReached(len(values)) {
+			if err := dec.skipString(); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		val, err := dec.readString()
 		if err != nil {
 			return nil, err
@@ -45,12 +50,22 @@ func (dec *Decoder) readQuickList() ([][]byte, *model.QuicklistDetail, error) {
 	}
 	entries := make([][]byte, 0)
 	detail := &model.QuicklistDetail{}
-	for i := 0; i < int(size); i++ {
+	for i := uint64(0); i < size; i++ {
+		if dec.capReached(len(entries)) {
+			if err := dec.skipString(); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
 		page, err := dec.readZipList()
 		if err != nil {
 			return nil, nil, err
 		}
 		entries = append(entries, page...)
+		if dec.elementCap > 0 && len(entries) > dec.elementCap {
+			entries = entries[:dec.elementCap]
+			dec.truncated = true
+		}
 		detail.ZiplistStruct = append(detail.ZiplistStruct, page)
 	}
 	return entries, detail, nil
@@ -64,11 +79,17 @@ func (dec *Decoder) readQuickList2() ([][]byte, *model.Quicklist2Detail, error)
 	}
 	entries := make([][]byte, 0)
 	detail := &model.Quicklist2Detail{}
-	for i := 0; i < int(size); i++ {
+	for i := uint64(0); i < size; i++ {
 		length, _, err := dec.readLength()
 		if err != nil {
 			return nil, nil, err
 		}
+		if dec.capReached(len(entries)) {
+			if err := dec.skipString(); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
 		if length == model.QuicklistNodeContainerPlain {
 			entry, err := dec.readString()
 			if err != nil {
@@ -77,11 +98,16 @@ func (dec *Decoder) readQuickList2() ([][]byte, *model.Quicklist2Detail, error)
 			entries = append(entries, entry)
 			detail.NodeEncodings = append(detail.NodeEncodings, model.QuicklistNodeContainerPlain)
 		} else if length == model.QuicklistNodeContainerPacked {
+			// nodes compressed because of list-compress-depth are saved as lzf string, readListPack decompresses them
 			page, lengths, err := dec.readListPack()
 			if err != nil {
 				return nil, nil, err
 			}
 			entries = append(entries, page...)
+			if dec.elementCap > 0 && len(entries) > dec.elementCap {
+				entries = entries[:dec.elementCap]
+				dec.truncated = true
+			}
 			detail.NodeEncodings = append(detail.NodeEncodings, model.QuicklistNodeContainerPlain)
 			detail.ListPackEntrySize = append(detail.ListPackEntrySize, lengths)
 		} else {
@@ -2,10 +2,13 @@ package core
 
 import (
 	"bytes"
+	"github.com/hdt3213/rdb/lzf"
 	"github.com/hdt3213/rdb/model"
 	"math"
 	"math/rand"
+	"os"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -108,10 +111,10 @@ func TestZipListEncoding(t *testing.T) {
 		strconv.Itoa(math.MaxInt32) + "1",
 		strconv.Itoa(math.MinInt32),
 		strconv.Itoa(math.MinInt32) + "1",
-		strconv.Itoa(math.MaxInt64),
-		strconv.Itoa(math.MaxInt64) + "1",
-		strconv.Itoa(math.MinInt64),
-		strconv.Itoa(math.MinInt64) + "1",
+		strconv.FormatInt(math.MaxInt64, 10),
+		strconv.FormatInt(math.MaxInt64, 10) + "1",
+		strconv.FormatInt(math.MinInt64, 10),
+		strconv.FormatInt(math.MinInt64, 10) + "1",
 		RandString(60),
 		RandString(1638),
 		RandString(10000),
@@ -172,3 +175,193 @@ func TestRandomZipListEncoding(t *testing.T) {
 		}
 	}
 }
+
+func TestQuickList2CompressedNode(t *testing.T) {
+	// saved with list-compress-depth 1, the interior node is lzf compressed
+	rdbFile, err := os.Open("../cases/quicklist_compressed.rdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	var list *model.ListObject
+	err = NewDecoder(rdbFile).Parse(func(o model.RedisObject) bool {
+		list, _ = o.(*model.ListObject)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list == nil || list.GetEncoding() != model.QuickList2Encoding {
+		t.Fatal("expect quicklist2 object")
+	}
+	if len(list.Values) != 24 {
+		t.Fatalf("expect 24 values, actual %d", len(list.Values))
+	}
+	for i, value := range list.Values {
+		expect := "node" + strconv.Itoa(i/8) + "-element" + strconv.Itoa(i%8) + "-" + strings.Repeat("a", 28)
+		if string(value) != expect {
+			t.Errorf("expect %s, actual %s", expect, value)
+		}
+	}
+}
+
+// decodeBacklen reads backlen from right to left like lpDecodeBacklen of redis, p is index of the last byte
+func decodeBacklen(buf []byte, p int) uint32 {
+	var val uint32
+	var shift uint
+	for {
+		val |= uint32(buf[p]&127) << shift
+		if buf[p]&128 == 0 {
+			break
+		}
+		shift += 7
+		p--
+	}
+	return val
+}
+
+func TestLargeEntryLength(t *testing.T) {
+	enc := NewEncoder(bytes.NewBuffer(nil))
+	dec := NewDecoder(bytes.NewBuffer(nil))
+	sizes := []int{63, 64, 4095, 4096, 16383, 16384, 40960, 1<<21 + 1}
+	for _, size := range sizes {
+		value := strings.Repeat("a", size)
+
+		// listpack
+		entry := enc.encodeListPackString(value)
+		backlen := enc.encodeBacklen(uint32(len(entry)))
+		buf := append(append(entry, backlen...), 0xff)
+		if decoded := decodeBacklen(buf, len(buf)-2); decoded != uint32(len(entry)) {
+			t.Errorf("listpack entry of %d bytes: wrong backlen %d", size, decoded)
+		}
+		cursor := 0
+		result, _, length, err := dec.readListPackEntry(buf, &cursor)
+		if err != nil {
+			t.Errorf("listpack entry of %d bytes: %v", size, err)
+			continue
+		}
+		if string(result) != value || int(length) != len(buf)-1 || cursor != len(buf)-1 {
+			t.Errorf("listpack entry of %d bytes: wrong result of %d bytes", size, len(result))
+		}
+
+		// ziplist, prevlen of the second entry is multi-byte if the first one is longer than 253 bytes
+		first := encodeZipListEntry(0, value)
+		second := encodeZipListEntry(uint32(len(first)), value)
+		buf = append(first, second...)
+		cursor = 0
+		for i := 0; i < 2; i++ {
+			result, err := dec.readZipListEntry(buf, &cursor)
+			if err != nil {
+				t.Errorf("ziplist entry of %d bytes: %v", size, err)
+				break
+			}
+			if string(result) != value {
+				t.Errorf("ziplist entry of %d bytes: wrong result of %d bytes", size, len(result))
+			}
+		}
+		if cursor != len(buf) {
+			t.Errorf("ziplist entry of %d bytes: cursor %d, expect %d", size, cursor, len(buf))
+		}
+	}
+
+	// backlen of entries up to 2^28 bytes, contents are not needed
+	for _, l := range []uint32{127, 128, 16382, 16383, 2097150, 2097151, 1<<28 - 2, 1<<28 - 1, 1 << 28} {
+		backlen := enc.encodeBacklen(l)
+		if len(backlen) != int(getBackLen(l)) {
+			t.Errorf("backlen of %d: expect %d bytes, actual %d", l, getBackLen(l), len(backlen))
+		}
+		if decoded := decodeBacklen(backlen, len(backlen)-1); decoded != l {
+			t.Errorf("backlen of %d: decoded %d", l, decoded)
+		}
+	}
+}
+
+func TestLargeZipListHash(t *testing.T) {
+	hash := map[string][]byte{
+		"small": []byte("1"),
+		"large": []byte(strings.Repeat("v", 40960)),
+	}
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf).SetHashZipListOpt(1<<20, 64)
+	if err := enc.WriteHeader(); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := enc.WriteDBHeader(0, 1, 0); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := enc.WriteHashMapObject("h", hash); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Error(err)
+		return
+	}
+	err := NewDecoder(buf).Parse(func(object model.RedisObject) bool {
+		obj := object.(*model.HashObject)
+		if obj.GetEncoding() != model.ZipListEncoding {
+			t.Errorf("expect ziplist, actual %s", obj.GetEncoding())
+		}
+		for field, value := range hash {
+			if string(obj.Hash[field]) != string(value) {
+				t.Errorf("wrong value of %s, %d bytes", field, len(obj.Hash[field]))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestListType1 decodes a list crafted per RDB_TYPE_LIST of redis 2.x: a length followed by that many strings
+func TestListType1(t *testing.T) {
+	compressible := strings.Repeat("ab", 30) // lengths fit in 6 bits
+	compressed, err := lzf.Compress([]byte(compressible))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("REDIS0003")
+	data = append(data, opCodeSelectDB, 0)
+	data = append(data, typeList, 4)
+	data = append(data, "old1"...)
+	data = append(data, 6)
+	data = append(data, 5)
+	data = append(data, "first"...)
+	data = append(data, lenSpecial<<6|encodeInt8, 123)
+	data = append(data, lenSpecial<<6|encodeInt16, 0xfe, 0xff)
+	data = append(data, lenSpecial<<6|encodeInt32, 0xa0, 0x86, 0x01, 0x00)
+	data = append(data, encodeLZFPrefix, byte(len(compressed)), byte(len(compressible)))
+	data = append(data, compressed...)
+	data = append(data, 4)
+	data = append(data, "last"...)
+	data = append(data, opCodeEOF)
+	expect := []string{"first", "123", "-2", "100000", compressible, "last"}
+
+	var list *model.ListObject
+	err = NewDecoder(bytes.NewReader(data)).Parse(func(object model.RedisObject) bool {
+		list, _ = object.(*model.ListObject)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list == nil || list.Key != "old1" {
+		t.Fatal("expect list old1")
+	}
+	if list.Encoding != model.ListEncoding {
+		t.Errorf("expect encoding %s, actual %s", model.ListEncoding, list.Encoding)
+	}
+	if len(list.Values) != len(expect) {
+		t.Fatalf("expect %d values, actual %d", len(expect), len(list.Values))
+	}
+	for i, value := range expect {
+		if string(list.Values[i]) != value {
+			t.Errorf("expect %s at %d, actual %s", value, i, list.Values[i])
+		}
+	}
+}
@@ -9,12 +9,12 @@ import (
 
 // readListPack returns: list of entry, list of entry size, error
 func (dec *Decoder) readListPack() ([][]byte, []uint32, error) {
-	buf, err := dec.readString()
+	buf, err := dec.readContainer()
 	if err != nil {
 		return nil, nil, err
 	}
 	cursor := 0
-	size := readListPackLength(buf, &cursor)
+	size := dec.readListPackLength(buf, &cursor)
 	entries := make([][]byte, 0, size)
 	entrySizes := make([]uint32, 0, size)
 	for i := 0; i < size; i++ {
@@ -31,12 +31,24 @@ func (dec *Decoder) readListPack() ([][]byte, []uint32, error) {
 	return entries, entrySizes, nil
 }
 
-func readListPackLength(buf []byte, cursor *int) int {
+// listPackUnknownLength is entry count in header of listpack with 65535 or more entries, see LP_HDR_NUMELE_UNKNOWN
+const listPackUnknownLength = 65535
+
+func (dec *Decoder) readListPackLength(buf []byte, cursor *int) int {
 	start := *cursor + 4
 	end := start + 2
 	// list pack buf: [0, 4] -> total bytes, [4:6] -> entry count
 	size := int(binary.LittleEndian.Uint16(buf[start:end]))
 	*cursor += 6
+	if size == listPackUnknownLength {
+		// count entries until the end byte
+		size = 0
+		for c := *cursor; c < len(buf) && buf[c] != 0xff; size++ {
+			if _, _, _, err := dec.readListPackEntry(buf, &c); err != nil {
+				break // the error will be returned when reading entries
+			}
+		}
+	}
 	return size
 }
 
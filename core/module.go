@@ -89,8 +89,12 @@ func (dec *Decoder) readModuleType() (string, interface{}, error) {
 func (dec *Decoder) handleModuleType(moduleId uint64) (string, interface{}, error) {
 	moduleType := moduleTypeNameByID(moduleId)
 	handler, found := dec.withSpecialTypes[moduleType]
+	if !found && dec.strictOpcodes {
+		// phaseOpcode is RDB_TYPE_MODULE_2 or RDB_OPCODE_MODULE_AUX which is followed by the module id
+		return moduleType, nil, &ErrUnknownOpcode{Byte: dec.phaseOpcode, Offset: dec.phaseOffset}
+	}
 	if !found {
-		fmt.Printf("unknown module type: %s,will skip\n", moduleType)
+		dec.warn(WarningSkippedModule, dec.phaseOffset, "unknown module type %s is skipped", moduleType)
 		handler = skipModuleAuxData
 	}
 	encVersion := moduleTypeEncVersionByID(moduleId)
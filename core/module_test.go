@@ -2,9 +2,12 @@ package core
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
-	"github.com/hdt3213/rdb/model"
+	"math"
 	"testing"
+
+	"github.com/hdt3213/rdb/model"
 )
 
 const testModuleType = "test-type"
@@ -229,3 +232,46 @@ func charCode(c uint8) uint8 {
 	}
 	panic(fmt.Errorf("unsupported char %c", c))
 }
+
+func TestSkipUnknownModule2(t *testing.T) {
+	double := make([]byte, 8)
+	binary.LittleEndian.PutUint64(double, math.Float64bits(3.14))
+	float := make([]byte, 4)
+	binary.LittleEndian.PutUint32(float, math.Float32bits(2.5))
+	// MODULE_2 value of an unregistered module, which contains every kind of module opcode
+	data := buildRDB(t, func(enc *Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 3, 0))
+		check(enc.WriteStringObject("before", []byte("1")))
+		writeRawObject(enc, check, typeModule2, "module", func() {
+			check(enc.writeLength(createModuleId("unknown-t", 3)))
+			check(enc.writeLength(uint64(ModuleOpcodeSInt)))
+			check(enc.writeLength(uint64(1) << 40))
+			check(enc.writeLength(uint64(ModuleOpcodeUInt)))
+			check(enc.writeLength(7))
+			check(enc.writeLength(uint64(ModuleOpcodeFloat)))
+			check(enc.write(float))
+			check(enc.writeLength(uint64(ModuleOpcodeDouble)))
+			check(enc.write(double))
+			check(enc.writeLength(uint64(ModuleOpcodeString)))
+			check(enc.writeString("payload of unknown module"))
+			check(enc.writeLength(uint64(ModuleOpcodeEOF)))
+		})
+		check(enc.WriteStringObject("after", []byte("2")))
+	})
+	values := make(map[string]string)
+	err := NewDecoder(bytes.NewReader(data)).Parse(func(o model.RedisObject) bool {
+		switch o := o.(type) {
+		case *model.StringObject:
+			values[o.Key] = string(o.Value)
+		case *model.ModuleTypeObject:
+			values[o.Key] = o.ModuleType
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["before"] != "1" || values["module"] != "unknown-t" || values["after"] != "2" || len(values) != 3 {
+		t.Errorf("unexpected objects: %v", values)
+	}
+}
@@ -0,0 +1,23 @@
+package core
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+// auxLuaScript is the aux field in which redis 4-6 stores each cached lua script
+const auxLuaScript = "lua"
+
+// newScriptObject creates script object from body, sha is computed just like SCRIPT LOAD
+func newScriptObject(body string) *model.ScriptObject {
+	sum := sha1.Sum([]byte(body))
+	return &model.ScriptObject{
+		BaseObject: &model.BaseObject{
+			Key: auxLuaScript,
+		},
+		SHA:  hex.EncodeToString(sum[:]),
+		Body: body,
+	}
+}
@@ -11,13 +11,18 @@ import (
 )
 
 func (dec *Decoder) readSet() ([][]byte, error) {
-	size64, _, err := dec.readLength()
+	size, err := dec.readElementCount()
 	if err != nil {
 		return nil, err
 	}
-	size := int(size64)
-	values := make([][]byte, 0, size)
+	values := make([][]byte, 0, preallocSize(size))
 	for i := 0; i < size; i++ {
+		if dec.capReached(len(values)) {
+			if err := dec.skipString(); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		val, err := dec.readString()
 		if err != nil {
 			return nil, err
@@ -29,7 +34,7 @@ func (dec *Decoder) readSet() ([][]byte, error) {
 
 func (dec *Decoder) readIntSet() (result [][]byte, detail *model.IntsetDetail, err error) {
 	var buf []byte
-	buf, err = dec.readString()
+	buf, err = dec.readContainer()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -40,9 +45,15 @@ func (dec *Decoder) readIntSet() (result [][]byte, detail *model.IntsetDetail, e
 	}
 	lenBytes := buf[4:8]
 	cardinality := binary.LittleEndian.Uint32(lenBytes)
+	if uint64(cardinality)*uint64(intSize) > uint64(len(buf)-8) {
+		return nil, nil, fmt.Errorf("intset of %d integers exceeds its container of %d bytes", cardinality, len(buf))
+	}
 	cursor := 8
 	result = make([][]byte, 0, cardinality)
 	for i := uint32(0); i < cardinality; i++ {
+		if dec.capReached(len(result)) {
+			break
+		}
 		var intBytes []byte
 		intBytes, err = readBytes(buf, &cursor, intSize)
 		if err != nil {
@@ -66,14 +77,17 @@ func (dec *Decoder) readIntSet() (result [][]byte, detail *model.IntsetDetail, e
 }
 
 func (dec *Decoder) readListPackSet() ([][]byte, *model.ListpackDetail, error) {
-	buf, err := dec.readString()
+	buf, err := dec.readContainer()
 	if err != nil {
 		return nil, nil, err
 	}
 	cursor := 0
-	size := readListPackLength(buf, &cursor)
+	size := dec.readListPackLength(buf, &cursor)
 	values := make([][]byte, 0, size)
 	for i := 0; i < size; i += 1 {
+		if dec.capReached(len(values)) {
+			break
+		}
 		member, err := dec.readListPackEntryAsString(buf, &cursor)
 		if err != nil {
 			return nil, nil, err
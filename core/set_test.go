@@ -2,6 +2,8 @@ package core
 
 import (
 	"bytes"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/hdt3213/rdb/model"
@@ -78,3 +80,110 @@ func TestSetEncoding(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestHugeSetCount(t *testing.T) {
+	rdbData := []byte{
+		'R', 'E', 'D', 'I', 'S', '0', '0', '0', '9',
+		0xFE, 0x00,
+		// set claims 1<<31 members but has only one
+		0x02, 0x01, 's', 0x80, 0x80, 0x00, 0x00, 0x00, 0x01, 'a',
+		0xFF,
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	err := NewDecoder(bytes.NewReader(rdbData)).Parse(func(o model.RedisObject) bool {
+		return true
+	})
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Error("expect error")
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("expect incremental allocation, actual allocated %d bytes", allocated)
+	}
+
+	err = NewDecoder(bytes.NewReader(rdbData)).WithMaxElementCount(1000).Parse(func(o model.RedisObject) bool {
+		return true
+	})
+	if err == nil || !strings.Contains(err.Error(), "exceeds limit") {
+		t.Errorf("expect limit error, actual %v", err)
+	}
+}
+
+func TestOrderedMembers(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteSetObject("ints", [][]byte{[]byte("5"), []byte("1"), []byte("100")}); err != nil {
+		t.Fatal(err)
+	}
+	var members [][]byte
+	for i := 0; i < 200; i++ {
+		members = append(members, []byte(RandString(10)))
+	}
+	if err := enc.WriteSetObject("strs", members); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	sets := make(map[string]*model.SetObject)
+	err := NewDecoder(buf).WithOrderedMembers().Parse(func(object model.RedisObject) bool {
+		if o, ok := object.(*model.SetObject); ok {
+			sets[o.Key] = o
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ints := sets["ints"]
+	if ints == nil || ints.Encoding != model.IntSetEncoding {
+		t.Fatal("expect intset")
+	}
+	expect := []string{"1", "5", "100"}
+	if len(ints.Members) != len(expect) {
+		t.Fatalf("expect %d members, actual %d", len(expect), len(ints.Members))
+	}
+	for i, member := range expect {
+		if string(ints.Members[i]) != member {
+			t.Errorf("expect %s at %d, actual %s", member, i, ints.Members[i])
+		}
+	}
+	strs := sets["strs"]
+	if strs == nil || strs.Encoding != model.SetEncoding || len(strs.Members) != len(members) {
+		t.Fatal("expect hashtable set")
+	}
+	for i := 1; i < len(strs.Members); i++ {
+		if bytes.Compare(strs.Members[i-1], strs.Members[i]) > 0 {
+			t.Errorf("expect sorted members, %s before %s", strs.Members[i-1], strs.Members[i])
+		}
+	}
+}
+
+func TestHugeIntSetCount(t *testing.T) {
+	rdbData := []byte{
+		'R', 'E', 'D', 'I', 'S', '0', '0', '0', '9',
+		0xFE, 0x00,
+		// intset of 8-byte integers claims 1<<32-1 members but has none
+		typeSetIntSet, 0x01, 's', 0x08, 0x08, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF,
+		0xFF,
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	err := NewDecoder(bytes.NewReader(rdbData)).Parse(func(o model.RedisObject) bool {
+		return true
+	})
+	runtime.ReadMemStats(&after)
+	if err == nil || !strings.Contains(err.Error(), "exceeds its container") {
+		t.Errorf("expect container error, actual %v", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("expect no allocation by declared count, actual allocated %d bytes", allocated)
+	}
+}
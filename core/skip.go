@@ -0,0 +1,106 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+// ErrKeyNotFound means the requested key does not exist in rdb
+var ErrKeyNotFound = errors.New("key not found")
+
+// skipObject skips value of the given type without decoding it into redis object
+func (dec *Decoder) skipObject(flag byte) error {
+	if _, ok := dec.withTypeHandlers[flag]; ok {
+		_, err := dec.readObject(flag, &model.BaseObject{})
+		return err
+	}
+	switch flag {
+	case typeString, typeListZipList, typeSetIntSet, typeHashZipMap, typeHashZipList, typeHashListPack,
+		typeZsetZipList, typeZsetListPack, typeSetListPack:
+		return dec.skipString()
+	case typeList, typeSet, typeListQuickList:
+		return dec.skipStrings(1)
+	case typeHash:
+		return dec.skipStrings(2)
+	case typeZset, typeZset2:
+		size, _, err := dec.readLength()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < size; i++ {
+			if err := dec.skipString(); err != nil {
+				return err
+			}
+			if flag == typeZset2 {
+				err = dec.discard(8)
+			} else {
+				_, _, err = dec.readLiteralFloat()
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case typeListQuickList2:
+		size, _, err := dec.readLength()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < size; i++ {
+			if _, _, err := dec.readLength(); err != nil { // container
+				return err
+			}
+			if err := dec.skipString(); err != nil {
+				return err
+			}
+		}
+		return nil
+	case typeHashWithHfe, typeHashWithHfeRc:
+		if flag == typeHashWithHfe {
+			if err := dec.discard(8); err != nil { // min expire
+				return err
+			}
+		}
+		size, _, err := dec.readLength()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < size; i++ {
+			if _, _, err := dec.readLength(); err != nil { // ttl
+				return err
+			}
+			if err := dec.skipString(); err != nil {
+				return err
+			}
+			if err := dec.skipString(); err != nil {
+				return err
+			}
+		}
+		return nil
+	case typeHashListPackWithHfe, typeHashListPackWithHfeRc:
+		if flag == typeHashListPackWithHfe {
+			if err := dec.discard(8); err != nil { // min expire
+				return err
+			}
+		}
+		return dec.skipString()
+	}
+	// streams and modules have complex structure, just read and drop them
+	_, err := dec.readObject(flag, &model.BaseObject{})
+	return err
+}
+
+// skipStrings reads a length n, then skips n*m strings
+func (dec *Decoder) skipStrings(m int) error {
+	size, _, err := dec.readLength()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < size*uint64(m); i++ {
+		if err := dec.skipString(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestKeyFilterSkip(t *testing.T) {
+	files, err := filepath.Glob("../cases/*.rdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parseKeys := func(filename string, filter func(key string) bool) ([]string, error) {
+		rdbFile, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = rdbFile.Close() }()
+		dec := NewDecoder(rdbFile)
+		if filter != nil {
+			dec.WithKeyFilter(filter)
+		}
+		var keys []string
+		err = dec.Parse(func(o model.RedisObject) bool {
+			keys = append(keys, o.GetKey())
+			return true
+		})
+		return keys, err
+	}
+	// skip every other key so that skipped values sit between decoded ones
+	filter := func(key string) bool {
+		return len(key)%2 == 0
+	}
+	for _, filename := range files {
+		all, err := parseKeys(filename, nil)
+		if err != nil {
+			continue // fixtures for malformed files
+		}
+		var expect []string
+		for _, key := range all {
+			if filter(key) {
+				expect = append(expect, key)
+			}
+		}
+		actual, err := parseKeys(filename, filter)
+		if err != nil {
+			t.Errorf("%s: %v", filename, err)
+			continue
+		}
+		if len(actual) != len(expect) {
+			t.Errorf("%s: expect %d keys, actual %d", filename, len(expect), len(actual))
+			continue
+		}
+		for i := range expect {
+			if actual[i] != expect[i] {
+				t.Errorf("%s: expect key %s, actual %s", filename, expect[i], actual[i])
+				break
+			}
+		}
+	}
+}
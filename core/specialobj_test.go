@@ -1,9 +1,13 @@
 package core
 
 import (
+	"bytes"
+	"errors"
 	"github.com/hdt3213/rdb/model"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -64,3 +68,320 @@ func TestWithSpecialObject(t *testing.T) {
 		t.Error("wrong db size object count")
 	}
 }
+
+func TestLuaScripts(t *testing.T) {
+	rdbFilename := filepath.Join("../cases", "lua_scripts.rdb")
+	rdbFile, err := os.Open(rdbFilename)
+	if err != nil {
+		t.Errorf("open rdb %s failed, %v", rdbFilename, err)
+		return
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	expectScripts := map[string]string{
+		"4e6d8fc8bb01276962cce5371fa795a7763657ae": "return redis.call('get', KEYS[1])",
+		"e0e1f9fabfc9d4800c877a703b823ac0578ff8db": "return 1",
+	}
+	scriptCount := 0
+	dec := NewDecoder(rdbFile).WithSpecialOpCode()
+	err = dec.Parse(func(object model.RedisObject) bool {
+		if o, ok := object.(*model.ScriptObject); ok {
+			if o.GetType() != model.ScriptType {
+				t.Error("script obj with wrong type")
+			}
+			if body, ok := expectScripts[o.SHA]; !ok || body != o.Body {
+				t.Errorf("unexpected script %s: %s", o.SHA, o.Body)
+			}
+			scriptCount++
+		}
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if scriptCount != len(expectScripts) {
+		t.Errorf("expect %d scripts, actual %d", len(expectScripts), scriptCount)
+	}
+	if len(dec.GetAuxFields()) != 3 {
+		t.Errorf("expect 3 aux fields, actual %d", len(dec.GetAuxFields()))
+	}
+}
+
+func TestVendorAuxFields(t *testing.T) {
+	rdbFilename := filepath.Join("../cases", "valkey.rdb")
+	rdbFile, err := os.Open(rdbFilename)
+	if err != nil {
+		t.Errorf("open rdb %s failed, %v", rdbFilename, err)
+		return
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	var keys []string
+	dec := NewDecoder(rdbFile)
+	err = dec.Parse(func(object model.RedisObject) bool {
+		keys = append(keys, object.GetKey())
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(keys) != 2 {
+		t.Errorf("expect 2 keys, actual %v", keys)
+	}
+	auxFields := dec.GetAuxFields()
+	if len(auxFields) != 3 || auxFields[0].Key != "valkey-ver" || auxFields[0].Value != "8.0.1" {
+		t.Errorf("valkey-ver is not preserved: %v", auxFields)
+	}
+}
+
+func TestKeyDBMetadata(t *testing.T) {
+	rdbFile, err := os.Open(filepath.Join("../cases", "keydb.rdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	objects := make(map[string]model.RedisObject)
+	dec := NewDecoder(rdbFile).WithWarnings()
+	err = dec.Parse(func(object model.RedisObject) bool {
+		objects[object.GetKey()] = object
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || objects["k1"] == nil || objects["s"] == nil {
+		t.Fatalf("expect k1 and s, actual %v", objects)
+	}
+	// mvcc-tstamp between expiration and key should not drop the expiration
+	if expiration := objects["k1"].GetExpiration(); expiration == nil || expiration.Year() != 2030 {
+		t.Errorf("expect expiration of k1, actual %v", expiration)
+	}
+	if objects["s"].GetExpiration() != nil {
+		t.Errorf("s should be persistent")
+	}
+	if auxFields := dec.GetAuxFields(); len(auxFields) != 2 {
+		t.Errorf("per key metadata should not be aux fields: %v", auxFields)
+	}
+	// each kind of metadata is warned once, though mvcc-tstamp appears twice
+	warnings := dec.Warnings()
+	if len(warnings) != 3 {
+		t.Errorf("expect 3 warnings, actual %v", warnings)
+	}
+	for _, w := range warnings {
+		if w.Kind != WarningKeyMetadata {
+			t.Errorf("unexpected warning %v", w)
+		}
+	}
+
+	// WithKeyMetadata passes metadata to callback in the order of file
+	_, _ = rdbFile.Seek(0, io.SeekStart)
+	var sequence []string
+	dec = NewDecoder(rdbFile).WithKeyMetadata().WithWarnings()
+	err = dec.Parse(func(object model.RedisObject) bool {
+		if aux, ok := object.(*model.AuxObject); ok {
+			sequence = append(sequence, aux.Key+"="+aux.Value)
+		} else {
+			sequence = append(sequence, object.GetKey())
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "mvcc-tstamp=7296218526430691328,k1,mvcc-tstamp=7296218526430691329,s," +
+		"keydb-subexpire-key=a,keydb-subexpire-when=1893456000000"
+	if actual := strings.Join(sequence, ","); actual != expect {
+		t.Errorf("expect %s, actual %s", expect, actual)
+	}
+	if auxFields := dec.GetAuxFields(); len(auxFields) != 2 {
+		t.Errorf("per key metadata should not be aux fields: %v", auxFields)
+	}
+	if warnings := dec.Warnings(); len(warnings) != 0 {
+		t.Errorf("expect no warnings, actual %v", warnings)
+	}
+}
+
+func TestVendorOpCode(t *testing.T) {
+	rdbData := []byte{
+		'R', 'E', 'D', 'I', 'S', '0', '0', '1', '1',
+		0xFE, 0x00,
+		// vendor opcode 0xF0 followed by two strings
+		0xF0, 0x02, 'm', 'v', 0x01, '1',
+		0x00, 0x01, 'a', 0x01, '1',
+		0xFF,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	err := NewDecoder(bytes.NewReader(rdbData)).Parse(func(object model.RedisObject) bool {
+		return true
+	})
+	if err == nil {
+		t.Error("expect error for unknown opcode")
+	}
+
+	var meta []string
+	var keys []string
+	dec := NewDecoder(bytes.NewReader(rdbData)).WithVendorOpCode(0xF0, func(h ModuleTypeHandler) error {
+		for i := 0; i < 2; i++ {
+			s, err := h.ReadString()
+			if err != nil {
+				return err
+			}
+			meta = append(meta, string(s))
+		}
+		return nil
+	})
+	err = dec.Parse(func(object model.RedisObject) bool {
+		keys = append(keys, object.GetKey())
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(meta) != 2 || meta[0] != "mv" || meta[1] != "1" {
+		t.Errorf("wrong vendor payload: %v", meta)
+	}
+	if len(keys) != 1 || keys[0] != "a" {
+		t.Errorf("wrong keys: %v", keys)
+	}
+}
+
+func TestTypeHandler(t *testing.T) {
+	rdbData := []byte{
+		'R', 'E', 'D', 'I', 'S', '0', '0', '1', '1',
+		0xFE, 0x00,
+		// unused type 0x20, key "c", then a length and a string
+		0x20, 0x01, 'c', 0x03, 0x02, 'h', 'i',
+		0x00, 0x01, 'a', 0x01, '1',
+		0xFF,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	calls := 0
+	handler := func(h ModuleTypeHandler, base *model.BaseObject) (model.RedisObject, error) {
+		calls++
+		n, err := h.ReadUInt()
+		if err != nil {
+			return nil, err
+		}
+		s, err := h.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		return &model.StringObject{BaseObject: base, Value: bytes.Repeat(s, int(n))}, nil
+	}
+	objects := make(map[string]model.RedisObject)
+	dec := NewDecoder(bytes.NewReader(rdbData)).WithStrictOpcodes().WithTypeHandler(0x20, handler)
+	err := dec.Parse(func(object model.RedisObject) bool {
+		objects[object.GetKey()] = object
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || len(objects) != 2 {
+		t.Fatalf("expect handler called for c, actual %d calls, %d objects", calls, len(objects))
+	}
+	c, ok := objects["c"].(*model.StringObject)
+	if !ok || string(c.Value) != "hihihi" || c.GetType() != model.StringType || c.GetDiskSize() != 7 {
+		t.Errorf("wrong object of custom type: %+v", objects["c"])
+	}
+
+	// handler reads value of skipped keys
+	calls = 0
+	var keys []string
+	dec = NewDecoder(bytes.NewReader(rdbData)).WithTypeHandler(0x20, handler).WithKeyFilter(func(key string) bool {
+		return key == "a"
+	})
+	err = dec.Parse(func(object model.RedisObject) bool {
+		keys = append(keys, object.GetKey())
+		return true
+	})
+	if err != nil || calls != 1 || len(keys) != 1 || keys[0] != "a" {
+		t.Errorf("expect c skipped by handler, actual %v, %d calls, %v", err, calls, keys)
+	}
+
+	// handlers take precedence over built-in types
+	dec = NewDecoder(bytes.NewReader(rdbData)).WithTypeHandler(0x20, handler).WithTypeHandler(typeString,
+		func(h ModuleTypeHandler, base *model.BaseObject) (model.RedisObject, error) {
+			s, err := h.ReadString()
+			if err != nil {
+				return nil, err
+			}
+			return &model.StringObject{BaseObject: base, Value: append(s, '!')}, nil
+		})
+	err = dec.Parse(func(object model.RedisObject) bool {
+		objects[object.GetKey()] = object
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := objects["a"].(*model.StringObject); string(a.Value) != "1!" {
+		t.Errorf("expect overridden string, actual %s", a.Value)
+	}
+
+	// errors of handler are returned
+	err = NewDecoder(bytes.NewReader(rdbData)).WithTypeHandler(0x20,
+		func(h ModuleTypeHandler, base *model.BaseObject) (model.RedisObject, error) {
+			return nil, errors.New("boom")
+		}).Parse(func(object model.RedisObject) bool {
+		return true
+	})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expect error of handler, actual %v", err)
+	}
+}
+
+func TestRegisterTypeHandler(t *testing.T) {
+	rdbData := []byte{
+		'R', 'E', 'D', 'I', 'S', '0', '0', '1', '1',
+		0xFE, 0x00,
+		0xFC, 0x00, 0x10, 0xA5, 0xD4, 0xE8, 0x00, 0x00, 0x00,
+		// unused type 0x21, key "c", then a string
+		0x21, 0x01, 'c', 0x02, 'h', 'i',
+		0xFF,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	var keys []string
+	dec := NewDecoder(bytes.NewReader(rdbData)).WithStrictOpcodes().RegisterTypeHandler(0x21,
+		func(r Reader, key string) (model.RedisObject, error) {
+			keys = append(keys, key)
+			s, err := r.ReadString()
+			if err != nil {
+				return nil, err
+			}
+			return &model.StringObject{Value: s}, nil
+		})
+	var objects []model.RedisObject
+	err := dec.Parse(func(object model.RedisObject) bool {
+		objects = append(objects, object)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "c" || len(objects) != 1 {
+		t.Fatalf("expect handler called for c, actual %v, %d objects", keys, len(objects))
+	}
+	c := objects[0].(*model.StringObject)
+	if string(c.Value) != "hi" || c.GetKey() != "c" || c.GetExpiration() == nil || c.GetDiskSize() != 6 {
+		t.Errorf("wrong object of custom type: %+v", c.BaseObject)
+	}
+
+	// nil object cannot carry key
+	err = NewDecoder(bytes.NewReader(rdbData)).RegisterTypeHandler(0x21,
+		func(r Reader, key string) (model.RedisObject, error) {
+			_, err := r.ReadString()
+			return nil, err
+		}).Parse(func(object model.RedisObject) bool {
+		return true
+	})
+	if err == nil || !strings.Contains(err.Error(), "should embed *model.BaseObject") {
+		t.Errorf("expect error of nil object, actual %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package core
+
+import "time"
+
+// Stats is statistics of a parsing process, which helps to find out where the time goes
+type Stats struct {
+	// OpCodes counts each special opcode seen, such as aux, selectdb and expiretimems
+	OpCodes map[string]int
+	// Keys counts decoded keys of each redis type
+	Keys map[string]int
+	// ValueTypes counts keys of each rdb value type, such as hash_listpack and list_quicklist2, including skipped keys
+	ValueTypes map[string]int
+	// DBs is index of each database selected by selectdb opcode, in the order they appear
+	DBs []int
+	// SkippedKeys is number of keys skipped by key filter without decoding
+	SkippedKeys int
+	// ExpireCount is number of keys with expiration, including skipped keys
+	ExpireCount int
+	// MinExpireKey and MinExpireMs are the key which expires first and its expiration in unix milliseconds,
+	// they are valid only if ExpireCount > 0
+	MinExpireKey string
+	MinExpireMs  int64
+	// LZFCount is number of LZF compressed strings
+	LZFCount int
+	// LZFCompressedBytes is number of bytes fed to LZF decompression
+	LZFCompressedBytes int64
+	// LZFDecompressedBytes is number of bytes produced by LZF decompression
+	LZFDecompressedBytes int64
+	// LZFTime is time spent in LZF decompression
+	LZFTime time.Duration
+	// WallTime is time spent in Parse
+	WallTime time.Duration
+}
+
+var opCodeNames = map[byte]string{
+	opCodeSlotInfo:      "slotinfo",
+	opCodeFunction2:     "function2",
+	opCodeFreq:          "freq",
+	opCodeIdle:          "idle",
+	opCodeFunctionPreGA: "function",
+	opCodeModuleAux:     "moduleaux",
+	opCodeAux:           "aux",
+	opCodeResizeDB:      "resizedb",
+	opCodeExpireTimeMs:  "expiretimems",
+	opCodeExpireTime:    "expiretime",
+	opCodeSelectDB:      "selectdb",
+	opCodeEOF:           "eof",
+}
+
+// valueTypeNames are names of rdb value types as RDB_TYPE_* in rdb.h of redis
+var valueTypeNames = map[byte]string{
+	typeString:                "string",
+	typeList:                  "list",
+	typeSet:                   "set",
+	typeZset:                  "zset",
+	typeHash:                  "hash",
+	typeZset2:                 "zset_2",
+	typeModule:                "module_pre_ga",
+	typeModule2:               "module_2",
+	typeHashZipMap:            "hash_zipmap",
+	typeListZipList:           "list_ziplist",
+	typeSetIntSet:             "set_intset",
+	typeZsetZipList:           "zset_ziplist",
+	typeHashZipList:           "hash_ziplist",
+	typeListQuickList:         "list_quicklist",
+	typeStreamListPacks:       "stream_listpacks",
+	typeHashListPack:          "hash_listpack",
+	typeZsetListPack:          "zset_listpack",
+	typeListQuickList2:        "list_quicklist_2",
+	typeStreamListPacks2:      "stream_listpacks_2",
+	typeSetListPack:           "set_listpack",
+	typeStreamListPacks3:      "stream_listpacks_3",
+	typeHashWithHfeRc:         "hash_metadata_pre_ga",
+	typeHashListPackWithHfeRc: "hash_listpack_ex_pre_ga",
+	typeHashWithHfe:           "hash_metadata",
+	typeHashListPackWithHfe:   "hash_listpack_ex",
+}
+
+func newStats() *Stats {
+	return &Stats{
+		OpCodes:    make(map[string]int),
+		Keys:       make(map[string]int),
+		ValueTypes: make(map[string]int),
+	}
+}
+
+// Stats returns statistics of parsing, it is updated while parsing and complete after Parse returned
+func (dec *Decoder) Stats() *Stats {
+	return dec.stats
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestStats(t *testing.T) {
+	rdbData := []byte{
+		'R', 'E', 'D', 'I', 'S', '0', '0', '1', '2',
+		// AUX redis-ver 7.4.0
+		0xFA, 0x09, 'r', 'e', 'd', 'i', 's', '-', 'v', 'e', 'r', 0x05, '7', '.', '4', '.', '0',
+		// AUX ctime 0
+		0xFA, 0x05, 'c', 't', 'i', 'm', 'e', 0xC0, 0x00,
+		// SELECTDB 0, RESIZEDB 2 1
+		0xFE, 0x00, 0xFB, 0x02, 0x01,
+		// EXPIRETIME_MS + FREQ + string k1
+		0xFC, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xF9, 0x05,
+		0x00, 0x02, 'k', '1', 0x02, 'v', '1',
+		// IDLE + set s1
+		0xF8, 0x03,
+		0x02, 0x02, 's', '1', 0x01, 0x01, 'a',
+		// SELECTDB 1 + string k2
+		0xFE, 0x01,
+		0x00, 0x02, 'k', '2', 0x02, 'v', '2',
+		0xFF,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	dec := NewDecoder(bytes.NewReader(rdbData))
+	err := dec.Parse(func(object model.RedisObject) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := dec.Stats()
+	expectOpCodes := map[string]int{
+		"aux":          2,
+		"selectdb":     2,
+		"resizedb":     1,
+		"expiretimems": 1,
+		"freq":         1,
+		"idle":         1,
+		"eof":          1,
+	}
+	if len(stats.OpCodes) != len(expectOpCodes) {
+		t.Errorf("expect opcodes %v, actual %v", expectOpCodes, stats.OpCodes)
+	}
+	for name, count := range expectOpCodes {
+		if stats.OpCodes[name] != count {
+			t.Errorf("expect %d %s, actual %d", count, name, stats.OpCodes[name])
+		}
+	}
+	if stats.Keys[model.StringType] != 2 || stats.Keys[model.SetType] != 1 {
+		t.Errorf("wrong key count: %v", stats.Keys)
+	}
+	if stats.LZFCount != 0 {
+		t.Errorf("expect no lzf string, actual %d", stats.LZFCount)
+	}
+	if stats.WallTime <= 0 {
+		t.Error("expect wall time")
+	}
+}
+
+func TestStatsLZF(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf).EnableCompress()
+	value := strings.Repeat("a", 1024)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteStringObject("a", []byte(value)); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteStringObject("b", []byte(value)); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	dec := NewDecoder(buf).WithKeyFilter(func(key string) bool {
+		return key == "a"
+	})
+	err := dec.Parse(func(object model.RedisObject) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := dec.Stats()
+	if stats.LZFCount != 1 {
+		t.Errorf("expect 1 lzf string, actual %d", stats.LZFCount)
+	}
+	if stats.LZFDecompressedBytes != int64(len(value)) {
+		t.Errorf("expect %d decompressed bytes, actual %d", len(value), stats.LZFDecompressedBytes)
+	}
+	if stats.LZFCompressedBytes <= 0 || stats.LZFCompressedBytes >= int64(len(value)) {
+		t.Errorf("wrong compressed bytes: %d", stats.LZFCompressedBytes)
+	}
+	if stats.SkippedKeys != 1 {
+		t.Errorf("expect 1 skipped key, actual %d", stats.SkippedKeys)
+	}
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+// Step parses rdb until the next object and returns it, so that an external event loop could pump objects one at
+// a time instead of being called back by Parse. It returns io.EOF after the last object. Any other error, including
+// a file truncated before the EOF opcode, is a *ParseError which is returned again by later calls.
+// Objects are read one by one, WithDecodeConcurrency is ignored. Do not mix Step with Parse on the same decoder
+func (dec *Decoder) Step() (obj model.RedisObject, err error) {
+	if dec.step == nil {
+		dec.step = dec.newParseState()
+	}
+	st := dec.step
+	if st.err != nil {
+		return nil, st.err
+	}
+	start := time.Now()
+	defer func() {
+		if err2 := recover(); err2 != nil {
+			err = fmt.Errorf("panic: %v", err2)
+		}
+		if err != nil && err != io.EOF {
+			err = dec.wrapParseError(err)
+		}
+		if err != nil {
+			st.err = err
+		}
+		dec.stats.WallTime += time.Since(start)
+	}()
+	if !st.headerRead {
+		dec.setPhase(PhaseHeader, 0, dec.readCount)
+		if err = dec.checkHeader(); err != nil {
+			return nil, err
+		}
+		st.headerRead = true
+	}
+	cb := func(object model.RedisObject) bool {
+		obj = object
+		return true
+	}
+	for obj == nil {
+		var done bool
+		done, err = dec.parseOpcode(st, cb, cb)
+		if err == io.EOF {
+			// io.EOF before the EOF opcode means rdb is truncated, it must not be taken as the end
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			if err = dec.readChecksum(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+	}
+	return obj, nil
+}
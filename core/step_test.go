@@ -0,0 +1,134 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestStep(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteAux("redis-ver", "7.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	for db := 0; db < 2; db++ {
+		if err := enc.WriteDBHeader(uint(db), 5, 0); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			if err := enc.WriteStringObject("k"+strconv.Itoa(i), []byte("v")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	dec := NewDecoder(bytes.NewReader(data))
+	var objects []model.RedisObject
+	for {
+		obj, err := dec.Step()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, obj)
+	}
+	if len(objects) != 10 {
+		t.Fatalf("expect 10 objects, actual %d", len(objects))
+	}
+	for i, obj := range objects {
+		if obj.GetKey() != "k"+strconv.Itoa(i%5) || obj.GetDBIndex() != i/5 {
+			t.Errorf("expect k%d of db %d, actual %s of db %d", i%5, i/5, obj.GetKey(), obj.GetDBIndex())
+		}
+	}
+	if _, err := dec.Step(); err != io.EOF {
+		t.Errorf("expect io.EOF again, actual %v", err)
+	}
+	if dec.GetReadCount() != int64(len(data)) {
+		t.Errorf("expect checksum read, read count %d of %d", dec.GetReadCount(), len(data))
+	}
+
+	// special objects are stepped as well
+	dec = NewDecoder(bytes.NewReader(data)).WithSpecialOpCode()
+	obj, err := dec.Step()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.GetType() != model.AuxType {
+		t.Errorf("expect aux object first, actual %s", obj.GetType())
+	}
+
+	// truncated rdb is an error rather than io.EOF
+	dec = NewDecoder(bytes.NewReader(data[:len(data)-12]))
+	n := 0
+	for {
+		_, err = dec.Step()
+		if err != nil {
+			break
+		}
+		n++
+	}
+	var parseErr *ParseError
+	if err == io.EOF || errors.Is(err, io.EOF) || !errors.As(err, &parseErr) {
+		t.Errorf("expect ParseError for truncated rdb, actual %v", err)
+	}
+	if n == 0 || n >= 10 {
+		t.Errorf("expect objects before error, actual %d", n)
+	}
+	if _, err2 := dec.Step(); err2 != err {
+		t.Errorf("expect the same error again, actual %v", err2)
+	}
+}
+
+func TestStepSameAsParse(t *testing.T) {
+	rdbFile, err := os.Open("../cases/multiple_databases.rdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdbFile.Close()
+	var expected []string
+	err = NewDecoder(rdbFile).Parse(func(object model.RedisObject) bool {
+		expected = append(expected, object.GetKey())
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = rdbFile.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	dec := NewDecoder(rdbFile)
+	var actual []string
+	for {
+		obj, err := dec.Step()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual = append(actual, obj.GetKey())
+	}
+	if len(expected) == 0 || len(actual) != len(expected) {
+		t.Fatalf("expect %d objects, actual %d", len(expected), len(actual))
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("expect %s, actual %s", expected[i], actual[i])
+		}
+	}
+}
@@ -17,6 +17,23 @@ const (
 	StreamItemFlagSameFields = 1 << 1
 )
 
+// WithStreamIDRange makes decoder keep only stream messages with id in [min, max], and pending entries of consumer
+// groups are filtered by the same range. Length and FirstId of stream describe the messages kept, while LastId,
+// MaxDeletedId, AddedEntriesCount and lag of groups are left as they are in rdb
+func (dec *Decoder) WithStreamIDRange(min, max model.StreamId) *Decoder {
+	dec.streamIDMin = &min
+	dec.streamIDMax = &max
+	return dec
+}
+
+// inStreamIDRange returns whether id is in the range set by WithStreamIDRange
+func (dec *Decoder) inStreamIDRange(id *model.StreamId) bool {
+	if dec.streamIDMin == nil {
+		return true
+	}
+	return id.Compare(dec.streamIDMin) >= 0 && id.Compare(dec.streamIDMax) <= 0
+}
+
 func (dec *Decoder) readStreamListPacks(version uint) (*model.StreamObject, error) {
 	entries, err := dec.readStreamEntries()
 	if err != nil {
@@ -59,9 +76,74 @@ func (dec *Decoder) readStreamListPacks(version uint) (*model.StreamObject, erro
 	}
 	stream.Groups = groups
 	stream.Version = version
+	for _, group := range groups {
+		group.Lag = streamGroupLag(stream, group)
+	}
+	if dec.streamIDMin != nil {
+		dec.filterStreamByIDRange(stream)
+	}
 	return stream, nil
 }
 
+// filterStreamByIDRange removes empty entries and pending entries out of range, then counts messages kept.
+// Messages out of range have been dropped while reading entries
+func (dec *Decoder) filterStreamByIDRange(stream *model.StreamObject) {
+	entries := stream.Entries[:0]
+	var length uint64
+	var firstId *model.StreamId
+	for _, entry := range stream.Entries {
+		if len(entry.Msgs) == 0 {
+			continue
+		}
+		entries = append(entries, entry)
+		for _, msg := range entry.Msgs {
+			if msg.Deleted {
+				continue
+			}
+			length++
+			if firstId == nil {
+				firstId = msg.Id
+			}
+		}
+	}
+	stream.Entries = entries
+	stream.Length = length
+	if stream.Version >= 2 {
+		if firstId == nil {
+			firstId = &model.StreamId{}
+		}
+		stream.FirstId = firstId
+	}
+	for _, group := range stream.Groups {
+		pending := group.Pending[:0]
+		for _, nack := range group.Pending {
+			if dec.inStreamIDRange(nack.Id) {
+				pending = append(pending, nack)
+			}
+		}
+		group.Pending = pending
+		for _, consumer := range group.Consumers {
+			ids := consumer.Pending[:0]
+			for _, id := range consumer.Pending {
+				if dec.inStreamIDRange(id) {
+					ids = append(ids, id)
+				}
+			}
+			consumer.Pending = ids
+		}
+	}
+}
+
+// streamInvalidEntriesRead is SCG_INVALID_ENTRIES_READ of redis, which means entries read by group is unknown
+const streamInvalidEntriesRead = ^uint64(0)
+
+func streamGroupLag(stream *model.StreamObject, group *model.StreamGroup) int64 {
+	if stream.Version < 2 || group.EntriesRead == streamInvalidEntriesRead || group.EntriesRead > stream.AddedEntriesCount {
+		return model.StreamLagUnknown
+	}
+	return int64(stream.AddedEntriesCount - group.EntriesRead)
+}
+
 func (dec *Decoder) readStreamId() (*model.StreamId, error) {
 	ms, _, err := dec.readLength()
 	if err != nil {
@@ -140,13 +222,13 @@ func (dec *Decoder) readStreamEntryContent(buf []byte, cursor *int, firstId *mod
 		return nil, fmt.Errorf("read stream field number failed: %v", err)
 	}
 	masterFieldNum := int(fieldNum0)
-	masterFieldNames := make([]string, masterFieldNum)
+	masterFieldNames := make([]string, 0, preallocSize(masterFieldNum))
 	for i := 0; i < masterFieldNum; i++ {
 		name, err := dec.readListPackEntryAsString(buf, cursor)
 		if err != nil {
 			return nil, fmt.Errorf("read field name of stream entry failed: %v", err)
 		}
-		masterFieldNames[i] = string(name)
+		masterFieldNames = append(masterFieldNames, string(name))
 	}
 	// read lp count of master entry
 	if _, err = dec.readListPackEntryAsString(buf, cursor); err != nil {
@@ -154,7 +236,7 @@ func (dec *Decoder) readStreamEntryContent(buf []byte, cursor *int, firstId *mod
 	}
 
 	total := count + deleted
-	msgs := make([]*model.StreamMessage, 0, total)
+	msgs := make([]*model.StreamMessage, 0, preallocSize(int(total)))
 	for i := int64(0); i < total; i++ {
 		flag, err := dec.readListPackEntryAsInt(buf, cursor)
 		if err != nil {
@@ -183,7 +265,7 @@ func (dec *Decoder) readStreamEntryContent(buf []byte, cursor *int, firstId *mod
 		}
 		msg := &model.StreamMessage{
 			Id:      msgId,
-			Fields:  make(map[string]string, masterFieldNum),
+			Fields:  make(map[string]string, preallocSize(masterFieldNum)),
 			Deleted: flag&StreamItemFlagDeleted > 0,
 		}
 
@@ -208,6 +290,9 @@ func (dec *Decoder) readStreamEntryContent(buf []byte, cursor *int, firstId *mod
 		if _, err = dec.readListPackEntryAsString(buf, cursor); err != nil {
 			return nil, fmt.Errorf("read fields end flag failed: %v", err)
 		}
+		if !dec.inStreamIDRange(msgId) {
+			continue
+		}
 		msgs = append(msgs, msg)
 	}
 	return &model.StreamEntry{
@@ -221,7 +306,7 @@ func (dec *Decoder) readStreamGroups(version uint) ([]*model.StreamGroup, error)
 	if err != nil {
 		return nil, err
 	}
-	groups := make([]*model.StreamGroup, 0, int(groupCount))
+	groups := make([]*model.StreamGroup, 0, preallocSize(int(groupCount)))
 	for i := uint64(0); i < groupCount; i++ {
 		name, _ := dec.readString()
 		if err != nil {
@@ -245,7 +330,8 @@ func (dec *Decoder) readStreamGroups(version uint) ([]*model.StreamGroup, error)
 		if err != nil {
 			return nil, err
 		}
-		pending := make([]*model.StreamNAck, 0, int(pendingCount))
+		pending := make([]*model.StreamNAck, 0, preallocSize(int(pendingCount)))
+		pendingMap := make(map[model.StreamId]*model.StreamNAck, preallocSize(int(pendingCount)))
 		for j := uint64(0); j < pendingCount; j++ {
 			if err := dec.readFull(dec.buffer); err != nil {
 				return nil, err
@@ -267,11 +353,13 @@ func (dec *Decoder) readStreamGroups(version uint) ([]*model.StreamGroup, error)
 			if err != nil {
 				return nil, err
 			}
-			pending = append(pending, &model.StreamNAck{
+			nack := &model.StreamNAck{
 				Id:            streamId,
 				DeliveryTime:  deliveryTime,
 				DeliveryCount: deliveryCount,
-			})
+			}
+			pending = append(pending, nack)
+			pendingMap[*streamId] = nack
 		}
 
 		// read consumers
@@ -279,7 +367,7 @@ func (dec *Decoder) readStreamGroups(version uint) ([]*model.StreamGroup, error)
 		if err != nil {
 			return nil, err
 		}
-		consumers := make([]*model.StreamConsumer, 0, int(consumerCount))
+		consumers := make([]*model.StreamConsumer, 0, preallocSize(int(consumerCount)))
 		for j := uint64(0); j < consumerCount; j++ {
 			consumerName, err := dec.readString()
 			if err != nil {
@@ -300,7 +388,7 @@ func (dec *Decoder) readStreamGroups(version uint) ([]*model.StreamGroup, error)
 			if err != nil {
 				return nil, err
 			}
-			consumerPending := make([]*model.StreamId, 0, int(consumerPendingCount))
+			consumerPending := make([]*model.StreamId, 0, preallocSize(int(consumerPendingCount)))
 			for k := uint64(0); k < consumerPendingCount; k++ {
 				if err := dec.readFull(dec.buffer); err != nil {
 					return nil, err
@@ -310,10 +398,13 @@ func (dec *Decoder) readStreamGroups(version uint) ([]*model.StreamGroup, error)
 					return nil, err
 				}
 				seq := binary.BigEndian.Uint64(dec.buffer)
-				consumerPending = append(consumerPending, &model.StreamId{
-					Ms:       ms,
-					Sequence: seq,
-				})
+				// consumer PEL only stores id, other fields are in the global PEL of group
+				nack := pendingMap[model.StreamId{Ms: ms, Sequence: seq}]
+				if nack == nil {
+					return nil, fmt.Errorf("consumer %s pending entry %d-%d not found in group PEL", consumerName, ms, seq)
+				}
+				nack.Consumer = unsafeBytes2Str(consumerName)
+				consumerPending = append(consumerPending, nack.Id)
 			}
 			consumers = append(consumers, &model.StreamConsumer{
 				SeenTime:   seenTime,
@@ -335,14 +426,24 @@ func (dec *Decoder) readStreamGroups(version uint) ([]*model.StreamGroup, error)
 
 // WriteStreamObject writes a stream object to RDB file
 func (enc *Encoder) WriteStreamObject(key string, stream *model.StreamObject, options ...interface{}) error {
+	if !enc.supports(9) {
+		return fmt.Errorf("stream %s requires rdb version 9, target version is %d", key, enc.version())
+	}
 	err := enc.beforeWriteObject(options...)
 	if err != nil {
 		return err
 	}
 
+	// stream format is downgraded to the newest one of target version, fields it lacks are dropped
+	version := stream.Version
+	if !enc.supports(10) && version > 1 {
+		version = 1
+	} else if !enc.supports(11) && version > 2 {
+		version = 2
+	}
 	// Write stream type based on version
 	var streamType byte
-	switch stream.Version {
+	switch version {
 	case 1:
 		streamType = typeStreamListPacks
 	case 2:
@@ -382,7 +483,7 @@ func (enc *Encoder) WriteStreamObject(key string, stream *model.StreamObject, op
 	}
 
 	// Write version 2+ fields if available
-	if stream.Version >= 2 {
+	if version >= 2 {
 		if stream.FirstId != nil {
 			err = enc.writeStreamId(stream.FirstId)
 			if err != nil {
@@ -416,7 +517,7 @@ func (enc *Encoder) WriteStreamObject(key string, stream *model.StreamObject, op
 	}
 
 	// Write stream groups
-	err = enc.writeStreamGroups(stream.Groups, stream.Version)
+	err = enc.writeStreamGroups(stream.Groups, version)
 	if err != nil {
 		return err
 	}
@@ -602,36 +703,20 @@ func (enc *Encoder) buildListpackWithBacklen(entries []listpackEntry) []byte {
 }
 
 // encodeBacklen encodes a backlen value
+// Each byte of backlen holds 7 bits of length, the most significant group comes first.
+// All bytes except the first one have the high bit set, so that backlen can be read from right to left.
+// see lpEncodeBacklen at [listpack.c](https://github.com/redis/redis/blob/unstable/src/listpack.c)
 func (enc *Encoder) encodeBacklen(elementLen uint32) []byte {
-	if elementLen <= 127 {
-		return []byte{byte(elementLen)}
-	} else if elementLen < (1<<14)-1 {
-		return []byte{
-			byte(0x80 | (elementLen >> 8)),
-			byte(elementLen & 0xFF),
-		}
-	} else if elementLen < (1<<21)-1 {
-		return []byte{
-			byte(0xC0 | (elementLen >> 16)),
-			byte((elementLen >> 8) & 0xFF),
-			byte(elementLen & 0xFF),
-		}
-	} else if elementLen < (1<<28)-1 {
-		return []byte{
-			byte(0xE0 | (elementLen >> 24)),
-			byte((elementLen >> 16) & 0xFF),
-			byte((elementLen >> 8) & 0xFF),
-			byte(elementLen & 0xFF),
-		}
-	} else {
-		return []byte{
-			0xF0,
-			byte((elementLen >> 24) & 0xFF),
-			byte((elementLen >> 16) & 0xFF),
-			byte((elementLen >> 8) & 0xFF),
-			byte(elementLen & 0xFF),
+	n := getBackLen(elementLen)
+	backlen := make([]byte, n)
+	for i := int(n) - 1; i >= 0; i-- {
+		backlen[i] = byte(elementLen & 127)
+		if i > 0 {
+			backlen[i] |= 128
 		}
+		elementLen >>= 7
 	}
+	return backlen
 }
 
 // writeStreamGroups writes stream groups
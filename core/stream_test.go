@@ -2,6 +2,7 @@ package core
 
 import (
 	"bytes"
+	"os"
 	"reflect"
 	"testing"
 
@@ -375,3 +376,375 @@ func decodeStreamObject(t *testing.T, buf *bytes.Buffer, stream *model.StreamObj
 		}
 	}
 }
+
+func TestStreamPendingOwnership(t *testing.T) {
+	id1 := &model.StreamId{Ms: 1681085300799, Sequence: 0}
+	id2 := &model.StreamId{Ms: 1681085312465, Sequence: 0}
+	id3 := &model.StreamId{Ms: 1681085312465, Sequence: 1}
+	stream := &model.StreamObject{
+		BaseObject: &model.BaseObject{
+			Key: "astream",
+		},
+		Version:      3,
+		Length:       0,
+		LastId:       id3,
+		FirstId:      &model.StreamId{},
+		MaxDeletedId: &model.StreamId{},
+		Entries:      []*model.StreamEntry{},
+		Groups: []*model.StreamGroup{
+			{
+				Name:   "g1",
+				LastId: id3,
+				Pending: []*model.StreamNAck{
+					{Id: id1, DeliveryTime: 1681085400000, DeliveryCount: 1},
+					{Id: id2, DeliveryTime: 1681085500000, DeliveryCount: 2},
+					{Id: id3, DeliveryTime: 1681085600000, DeliveryCount: 3},
+				},
+				Consumers: []*model.StreamConsumer{
+					{
+						Name:       "c1",
+						SeenTime:   1681085500000,
+						ActiveTime: 1681085500000,
+						Pending:    []*model.StreamId{id1, id3},
+					},
+					{
+						Name:       "c2",
+						SeenTime:   1681085600000,
+						ActiveTime: 1681085600000,
+						Pending:    []*model.StreamId{id2},
+					},
+				},
+			},
+		},
+	}
+	writeStream := func() *bytes.Buffer {
+		var buf bytes.Buffer
+		encoder := NewEncoder(&buf)
+		if err := encoder.WriteHeader(); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		if err := encoder.WriteDBHeader(0, 1, 0); err != nil {
+			t.Fatalf("Failed to write DB header: %v", err)
+		}
+		if err := encoder.WriteStreamObject("astream", stream); err != nil {
+			t.Fatalf("Failed to write stream object: %v", err)
+		}
+		if err := encoder.WriteEnd(); err != nil {
+			t.Fatalf("Failed to write end: %v", err)
+		}
+		return &buf
+	}
+
+	var decoded *model.StreamObject
+	err := NewDecoder(writeStream()).Parse(func(obj model.RedisObject) bool {
+		decoded, _ = obj.(*model.StreamObject)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if decoded == nil || len(decoded.Groups) != 1 {
+		t.Fatal("Failed to decode stream object")
+	}
+	expect := []*model.PendingEntry{
+		{Id: id1, DeliveryTime: 1681085400000, DeliveryCount: 1, Consumer: "c1"},
+		{Id: id2, DeliveryTime: 1681085500000, DeliveryCount: 2, Consumer: "c2"},
+		{Id: id3, DeliveryTime: 1681085600000, DeliveryCount: 3, Consumer: "c1"},
+	}
+	if !reflect.DeepEqual(decoded.Groups[0].Pending, expect) {
+		t.Errorf("Pending mismatch: expected %+v, got %+v", expect, decoded.Groups[0].Pending)
+	}
+	for _, consumer := range decoded.Groups[0].Consumers {
+		for _, id := range consumer.Pending {
+			found := false
+			for _, nack := range decoded.Groups[0].Pending {
+				if nack.Id == id && nack.Consumer == consumer.Name {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("consumer %s pending %d-%d should refer to group PEL", consumer.Name, id.Ms, id.Sequence)
+			}
+		}
+	}
+
+	// consumer PEL refers to a message not in group PEL
+	stream.Groups[0].Pending = stream.Groups[0].Pending[:2]
+	err = NewDecoder(writeStream()).Parse(func(obj model.RedisObject) bool {
+		return true
+	})
+	if err == nil {
+		t.Error("expect error")
+	}
+}
+
+func TestStreamIds(t *testing.T) {
+	stream := &model.StreamObject{
+		BaseObject: &model.BaseObject{
+			Key: "astream",
+		},
+		Version:           2,
+		Length:            1,
+		LastId:            &model.StreamId{Ms: 1681085312465, Sequence: 3},
+		FirstId:           &model.StreamId{Ms: 1681085300799, Sequence: 0},
+		MaxDeletedId:      &model.StreamId{Ms: 1681085312465, Sequence: 1},
+		AddedEntriesCount: 4,
+		Entries: []*model.StreamEntry{
+			{
+				FirstMsgId: &model.StreamId{Ms: 1681085300799, Sequence: 0},
+				Fields:     []string{"a"},
+				Msgs: []*model.StreamMessage{
+					{
+						Id:     &model.StreamId{Ms: 1681085300799, Sequence: 0},
+						Fields: map[string]string{"a": "1"},
+					},
+				},
+			},
+		},
+	}
+	var buf bytes.Buffer
+	encoder := NewEncoder(&buf)
+	if err := encoder.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.WriteDBHeader(0, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.WriteStreamObject("astream", stream); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	var actual *model.StreamObject
+	err := NewDecoder(&buf).Parse(func(o model.RedisObject) bool {
+		actual, _ = o.(*model.StreamObject)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual == nil {
+		t.Fatal("expect stream object")
+	}
+	if actual.LastId.String() != "1681085312465-3" {
+		t.Errorf("wrong last id: %s", actual.LastId)
+	}
+	if actual.FirstId.String() != "1681085300799-0" {
+		t.Errorf("wrong first id: %s", actual.FirstId)
+	}
+	if actual.MaxDeletedId.String() != "1681085312465-1" {
+		t.Errorf("wrong max deleted id: %s", actual.MaxDeletedId)
+	}
+
+	ordered := []*model.StreamID{nil, actual.FirstId, actual.MaxDeletedId, actual.LastId}
+	for i := range ordered {
+		for j := range ordered {
+			expect := 0
+			if i < j {
+				expect = -1
+			} else if i > j {
+				expect = 1
+			}
+			if c := ordered[i].Compare(ordered[j]); c != expect {
+				t.Errorf("compare %s with %s: expect %d, actual %d", ordered[i], ordered[j], expect, c)
+			}
+		}
+	}
+	if (&model.StreamID{Ms: 1, Sequence: 2}).Compare(&model.StreamID{Ms: 1, Sequence: 2}) != 0 {
+		t.Error("expect equal ids")
+	}
+}
+
+func TestStreamGroupLag(t *testing.T) {
+	rdbFile, err := os.Open("../cases/stream_lag.rdb")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	var stream *model.StreamObject
+	err = NewDecoder(rdbFile).Parse(func(object model.RedisObject) bool {
+		stream, _ = object.(*model.StreamObject)
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if stream == nil || stream.AddedEntriesCount != 3 || len(stream.Groups) != 2 {
+		t.Errorf("wrong stream: %v", stream)
+		return
+	}
+	lags := map[string]int64{"half": 2, "done": 0}
+	for _, group := range stream.Groups {
+		if group.Lag != lags[group.Name] {
+			t.Errorf("group %s: expect lag %d, actual %d", group.Name, lags[group.Name], group.Lag)
+		}
+	}
+
+	// lag is unknown for v1 streams which have no entries-added
+	stream.Version = 1
+	if lag := streamGroupLag(stream, stream.Groups[0]); lag != model.StreamLagUnknown {
+		t.Errorf("expect unknown lag for v1 stream, actual %d", lag)
+	}
+}
+
+// TestStreamListPacksV1 decodes a stream saved by redis 5.0 as RDB_TYPE_STREAM_LISTPACKS,
+// which has no entries-added, first id, max deleted id, entries read or consumer active time
+func TestStreamListPacksV1(t *testing.T) {
+	rdbFile, err := os.Open("../cases/stream_listpacks_1.rdb")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	var stream *model.StreamObject
+	err = NewDecoder(rdbFile).Parse(func(object model.RedisObject) bool {
+		if object.GetKey() == "listpack" {
+			stream, _ = object.(*model.StreamObject)
+		}
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if stream == nil || stream.Version != 1 {
+		t.Errorf("expect v1 stream: %v", stream)
+		return
+	}
+	if stream.Length != 150 || stream.LastId.String() != "1528507831415-0" {
+		t.Errorf("wrong stream length %d or last id %s", stream.Length, stream.LastId)
+	}
+	if stream.FirstId != nil || stream.MaxDeletedId != nil || stream.AddedEntriesCount != 0 {
+		t.Error("fields since v2 should be zero")
+	}
+	if len(stream.Groups) != 4 {
+		t.Errorf("expect 4 groups, actual %d", len(stream.Groups))
+		return
+	}
+	g1 := stream.Groups[0]
+	if g1.Name != "g1" || len(g1.Pending) != 4 || len(g1.Consumers) != 2 || g1.EntriesRead != 0 || g1.Lag != model.StreamLagUnknown {
+		t.Errorf("wrong group: %+v", g1)
+	}
+	for _, group := range stream.Groups {
+		for _, consumer := range group.Consumers {
+			// consumer has no active time before rdb 11, it is the same as seen time
+			if consumer.ActiveTime != consumer.SeenTime {
+				t.Errorf("group %s consumer %s: active time %d, seen time %d", group.Name, consumer.Name, consumer.ActiveTime, consumer.SeenTime)
+			}
+		}
+	}
+}
+
+func TestStreamIDRange(t *testing.T) {
+	decodeStream := func(dec *Decoder) *model.StreamObject {
+		var stream *model.StreamObject
+		err := dec.Parse(func(object model.RedisObject) bool {
+			if object.GetKey() == "listpack" {
+				stream, _ = object.(*model.StreamObject)
+			}
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stream == nil {
+			t.Fatal("stream listpack not found")
+		}
+		return stream
+	}
+	data, err := os.ReadFile("../cases/stream_listpacks_1.rdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := decodeStream(NewDecoder(bytes.NewReader(data)))
+	var ids []*model.StreamId
+	for _, entry := range full.Entries {
+		for _, msg := range entry.Msgs {
+			if !msg.Deleted {
+				ids = append(ids, msg.Id)
+			}
+		}
+	}
+	if len(ids) < 100 {
+		t.Fatalf("expect enough messages, actual %d", len(ids))
+	}
+	min, max := *ids[40], *ids[99]
+	expectPending := make(map[string]int)
+	for _, group := range full.Groups {
+		for _, nack := range group.Pending {
+			if nack.Id.Compare(&min) >= 0 && nack.Id.Compare(&max) <= 0 {
+				expectPending[group.Name]++
+			}
+		}
+	}
+
+	stream := decodeStream(NewDecoder(bytes.NewReader(data)).WithStreamIDRange(min, max))
+	var actual []*model.StreamId
+	for _, entry := range stream.Entries {
+		if len(entry.Msgs) == 0 {
+			t.Error("empty entry should be removed")
+		}
+		for _, msg := range entry.Msgs {
+			if !msg.Deleted {
+				actual = append(actual, msg.Id)
+			}
+			if msg.Id.Compare(&min) < 0 || msg.Id.Compare(&max) > 0 {
+				t.Errorf("message %s out of range", msg.Id)
+			}
+		}
+	}
+	if !reflect.DeepEqual(actual, ids[40:100]) {
+		t.Errorf("expect ids %v, actual %v", ids[40:100], actual)
+	}
+	if stream.Length != 60 {
+		t.Errorf("expect length 60, actual %d", stream.Length)
+	}
+	if stream.LastId.String() != full.LastId.String() {
+		t.Errorf("last id should be kept, actual %s", stream.LastId)
+	}
+	for _, group := range stream.Groups {
+		if len(group.Pending) != expectPending[group.Name] {
+			t.Errorf("group %s: expect %d pending, actual %d", group.Name, expectPending[group.Name], len(group.Pending))
+		}
+		for _, consumer := range group.Consumers {
+			for _, id := range consumer.Pending {
+				if id.Compare(&min) < 0 || id.Compare(&max) > 0 {
+					t.Errorf("pending %s of consumer %s out of range", id, consumer.Name)
+				}
+			}
+		}
+	}
+}
+
+func TestHugeStreamGroupCount(t *testing.T) {
+	stream := &model.StreamObject{
+		BaseObject: &model.BaseObject{Key: "s"},
+		Version:    1,
+		LastId:     &model.StreamId{},
+	}
+	data := buildRDB(t, func(enc *Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 1, 0))
+		check(enc.WriteStreamObject("s", stream))
+	})
+	// replace group count 0 which precedes EOF opcode and checksum with 1<<62 groups
+	end := len(data) - 9
+	if data[end-1] != 0 {
+		t.Fatalf("unexpected group count %x", data[end-1])
+	}
+	corrupt := append([]byte{}, data[:end-1]...)
+	corrupt = append(corrupt, 0x81, 0x40, 0, 0, 0, 0, 0, 0, 0)
+	corrupt = append(corrupt, data[end:]...)
+	err := NewDecoder(bytes.NewReader(corrupt)).Parse(func(o model.RedisObject) bool {
+		return true
+	})
+	if err == nil {
+		t.Error("expect error")
+	}
+}
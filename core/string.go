@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"time"
 	"unicode"
 
 	"github.com/hdt3213/rdb/lzf"
+	"github.com/hdt3213/rdb/model"
 )
 
 const (
@@ -36,6 +38,9 @@ const (
 	encodeLZFPrefix        = lenSpecial<<6 | encodeLZF
 )
 
+// ErrLZFOverflow means a LZF compressed string claims or tries to write more than its declared length
+var ErrLZFOverflow = lzf.ErrOverflow
+
 // readLength parse Length Encoding
 // see: https://github.com/sripathikrishnan/redis-rdb-tools/wiki/Redis-RDB-Dump-File-Format#length-encoding
 func (dec *Decoder) readLength() (uint64, bool, error) {
@@ -83,7 +88,20 @@ func (dec *Decoder) readString() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	return dec.readStringContent(length, special)
+}
+
+// readContainer reads string of a ziplist, listpack, intset or zipmap, which is kept if withRawContainer
+func (dec *Decoder) readContainer() ([]byte, error) {
+	buf, err := dec.readString()
+	if err == nil && dec.withRawContainer {
+		dec.rawContainer = buf
+	}
+	return buf, err
+}
 
+// readStringContent reads content of a string whose length has been read
+func (dec *Decoder) readStringContent(length uint64, special bool) ([]byte, error) {
 	if special {
 		switch length {
 		case encodeInt8:
@@ -102,11 +120,85 @@ func (dec *Decoder) readString() ([]byte, error) {
 		}
 	}
 
-	res := make([]byte, length)
+	size, err := lengthToInt(length)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]byte, size)
 	err = dec.readFull(res)
 	return res, err
 }
 
+// skipString skips a string without decoding or decompressing it
+func (dec *Decoder) skipString() error {
+	length, special, err := dec.readLength()
+	if err != nil {
+		return err
+	}
+	if special {
+		switch length {
+		case encodeInt8:
+			return dec.discard(1)
+		case encodeInt16:
+			return dec.discard(2)
+		case encodeInt32:
+			return dec.discard(4)
+		case encodeLZF:
+			inLen, _, err := dec.readLength()
+			if err != nil {
+				return err
+			}
+			_, _, err = dec.readLength() // outLen
+			if err != nil {
+				return err
+			}
+			return dec.discard(inLen)
+		default:
+			return errors.New("Unknown string encode type ")
+		}
+	}
+	return dec.discard(length)
+}
+
+// embStrSizeLimit is OBJ_ENCODING_EMBSTR_SIZE_LIMIT of redis
+const embStrSizeLimit = 44
+
+// stringObjectEncoding infers encoding of string value after loaded by redis, just like OBJECT ENCODING.
+// see tryObjectEncoding at [object.c](https://github.com/redis/redis/blob/unstable/src/object.c)
+func stringObjectEncoding(value []byte) string {
+	if len(value) <= 20 && isCanonicalInt64(value) {
+		return model.StringIntEncoding
+	}
+	if len(value) <= embStrSizeLimit {
+		return model.StringEmbStrEncoding
+	}
+	return model.StringRawEncoding
+}
+
+// isCanonicalInt64 works like string2ll of redis: no spaces, no leading zeros, no '+' and within int64
+func isCanonicalInt64(value []byte) bool {
+	if len(value) == 0 {
+		return false
+	}
+	if len(value) == 1 && value[0] == '0' {
+		return true
+	}
+	digits := value
+	if digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if len(digits) == 0 || digits[0] < '1' || digits[0] > '9' {
+		return false
+	}
+	for _, b := range digits {
+		if b < '0' || b > '9' {
+			return false
+		}
+	}
+	_, err := strconv.ParseInt(unsafeBytes2Str(value), 10, 64)
+	return err == nil
+}
+
 func (dec *Decoder) readInt16() (int16, error) {
 	err := dec.readFull(dec.buffer[:2])
 	if err != nil {
@@ -137,29 +229,30 @@ func (dec *Decoder) readInt64() (int64, error) {
 	return int64(i), nil
 }
 
-func (dec *Decoder) readLiteralFloat() (float64, error) {
+// readLiteralFloat reads a double stored as text, it returns the text as well unless it is inf or nan
+func (dec *Decoder) readLiteralFloat() (float64, string, error) {
 	first, err := dec.readByte()
 	if err != nil {
-		return 0, err
+		return 0, "", err
 	}
 	if first == 0xff {
-		return math.Inf(-1), nil
+		return math.Inf(-1), "", nil
 	} else if first == 0xfe {
-		return math.Inf(1), nil
+		return math.Inf(1), "", nil
 	} else if first == 0xfd {
-		return math.NaN(), nil
+		return math.NaN(), "", nil
 	}
 	buf := make([]byte, first)
 	err = dec.readFull(buf)
 	if err != nil {
-		return 0, err
+		return 0, "", err
 	}
 	str := unsafeBytes2Str(buf)
 	val, err := strconv.ParseFloat(str, 64)
 	if err != nil {
-		return 0, fmt.Errorf("")
+		return 0, "", fmt.Errorf("illegal float score %q: %v", str, err)
 	}
-	return val, err
+	return val, str, err
 }
 
 func (dec *Decoder) readFloat() (float64, error) {
@@ -188,12 +281,26 @@ func (dec *Decoder) readLZF() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	val := make([]byte, inLen)
+	compressedLen, err := lengthToInt(inLen)
+	if err != nil {
+		return nil, err
+	}
+	decompressedLen, err := lengthToInt(outLen)
+	if err != nil {
+		return nil, err
+	}
+	val := make([]byte, compressedLen)
 	err = dec.readFull(val)
 	if err != nil {
 		return nil, err
 	}
-	return lzf.Decompress(val, int(inLen), int(outLen))
+	start := time.Now()
+	out, err := lzf.Decompress(val, compressedLen, decompressedLen)
+	dec.stats.LZFTime += time.Since(start)
+	dec.stats.LZFCount++
+	dec.stats.LZFCompressedBytes += int64(compressedLen)
+	dec.stats.LZFDecompressedBytes += int64(len(out))
+	return out, err
 }
 
 func (enc *Encoder) writeLength(value uint64) error {
@@ -2,11 +2,15 @@ package core
 
 import (
 	"bytes"
+	"errors"
 	"math"
 	"math/rand"
+	"os"
 	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/hdt3213/rdb/model"
 )
 
 func TestLengthEncoding(t *testing.T) {
@@ -61,10 +65,10 @@ func TestStringEncoding(t *testing.T) {
 		strconv.Itoa(math.MaxInt32) + "1",
 		strconv.Itoa(math.MinInt32),
 		strconv.Itoa(math.MinInt32) + "1",
-		strconv.Itoa(math.MaxInt64),
-		strconv.Itoa(math.MaxInt64) + "1",
-		strconv.Itoa(math.MinInt64),
-		strconv.Itoa(math.MinInt64) + "1",
+		strconv.FormatInt(math.MaxInt64, 10),
+		strconv.FormatInt(math.MaxInt64, 10) + "1",
+		strconv.FormatInt(math.MinInt64, 10),
+		strconv.FormatInt(math.MinInt64, 10) + "1",
 		RandString(20000),
 	}
 	for _, str := range strList {
@@ -144,3 +148,81 @@ func TestLZFString(t *testing.T) {
 		}
 	}
 }
+
+func TestStringObjectEncoding(t *testing.T) {
+	expectMap := map[string]string{
+		"int":         model.StringIntEncoding,
+		"negative":    model.StringIntEncoding,
+		"zero":        model.StringIntEncoding,
+		"maxint64":    model.StringIntEncoding,
+		"overflow":    model.StringEmbStrEncoding,
+		"leadingzero": model.StringEmbStrEncoding,
+		"negzero":     model.StringEmbStrEncoding,
+		"empty":       model.StringEmbStrEncoding,
+		"44":          model.StringEmbStrEncoding,
+		"45":          model.StringRawEncoding,
+	}
+	values := map[string]string{
+		"int":         "12345",
+		"negative":    "-12345",
+		"zero":        "0",
+		"maxint64":    strconv.FormatInt(math.MaxInt64, 10),
+		"overflow":    strconv.FormatInt(math.MaxInt64, 10) + "1",
+		"leadingzero": "007",
+		"negzero":     "-0",
+		"empty":       "",
+		"44":          strings.Repeat("a", 44),
+		"45":          strings.Repeat("a", 45),
+	}
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	err := enc.WriteHeader()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = enc.WriteDBHeader(0, uint64(len(values)), 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for k, v := range values {
+		err = enc.WriteStringObject(k, []byte(v))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	err = enc.WriteEnd()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	dec := NewDecoder(buf)
+	err = dec.Parse(func(object model.RedisObject) bool {
+		if object.GetEncoding() != expectMap[object.GetKey()] {
+			t.Errorf("%s expect encoding %s, actual %s", object.GetKey(), expectMap[object.GetKey()], object.GetEncoding())
+		}
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLZFExpansionAttack(t *testing.T) {
+	rdbFile, err := os.Open("../cases/lzf_expansion.rdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	err = NewDecoder(rdbFile).Parse(func(o model.RedisObject) bool {
+		t.Errorf("unexpected object %s", o.GetKey())
+		return true
+	})
+	if !errors.Is(err, ErrLZFOverflow) {
+		t.Errorf("expect ErrLZFOverflow, actual %v", err)
+	}
+}
@@ -0,0 +1,349 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+// testZipList builds a ziplist of encoded entries, every entry must be shorter than 254 bytes
+func testZipList(entries ...[]byte) []byte {
+	body := make([]byte, 0)
+	prevLen := 0
+	for _, entry := range entries {
+		body = append(body, byte(prevLen))
+		body = append(body, entry...)
+		prevLen = len(entry) + 1
+	}
+	buf := make([]byte, 10, 10+len(body)+1)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(10+len(body)+1))
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(10+len(body)-prevLen))
+	binary.LittleEndian.PutUint16(buf[8:10], uint16(len(entries)))
+	buf = append(buf, body...)
+	return append(buf, 0xff)
+}
+
+// testZipStr encodes a ziplist string entry shorter than 64 bytes
+func testZipStr(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+// testListPack builds a listpack of encoded entries, every entry must be shorter than 128 bytes
+func testListPack(entries ...[]byte) []byte {
+	body := make([]byte, 0)
+	for _, entry := range entries {
+		body = append(body, entry...)
+		body = append(body, byte(len(entry)))
+	}
+	buf := make([]byte, 6, 6+len(body)+1)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(6+len(body)+1))
+	binary.LittleEndian.PutUint16(buf[4:6], uint16(min(len(entries), listPackUnknownLength)))
+	buf = append(buf, body...)
+	return append(buf, 0xff)
+}
+
+// testListPackStr encodes a listpack string entry shorter than 64 bytes
+func testListPackStr(s string) []byte {
+	return append([]byte{0x80 | byte(len(s))}, s...)
+}
+
+// testListPackInt encodes a listpack integer entry between 0 and 127
+func testListPackInt(i byte) []byte {
+	return []byte{i}
+}
+
+func testStreamObject(version uint) *model.StreamObject {
+	id := &model.StreamId{Ms: 1, Sequence: 0}
+	return &model.StreamObject{
+		BaseObject: &model.BaseObject{},
+		Version:    version,
+		Length:     1,
+		LastId:     id,
+		FirstId:    id,
+		Entries: []*model.StreamEntry{
+			{
+				FirstMsgId: id,
+				Fields:     []string{"f"},
+				Msgs: []*model.StreamMessage{
+					{Id: id, Fields: map[string]string{"f": "v"}},
+				},
+			},
+		},
+		Groups:            []*model.StreamGroup{},
+		AddedEntriesCount: 1,
+		MaxDeletedId:      &model.StreamId{},
+	}
+}
+
+// TestValueTypes builds a minimal payload for every rdb value type and checks the decoder reads it
+func TestValueTypes(t *testing.T) {
+	double := make([]byte, 8)
+	binary.LittleEndian.PutUint64(double, math.Float64bits(1))
+	intSet := make([]byte, 10)
+	binary.LittleEndian.PutUint32(intSet[0:4], 2)
+	binary.LittleEndian.PutUint32(intSet[4:8], 1)
+	binary.LittleEndian.PutUint16(intSet[8:10], 1)
+	noMinExpire := make([]byte, 8)
+	binary.LittleEndian.PutUint64(noMinExpire, uint64(EB_EXPIRE_TIME_INVALID))
+
+	raw := func(parts ...func() error) error {
+		for _, part := range parts {
+			if err := part(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	str := func(enc *Encoder, s string) func() error {
+		return func() error { return enc.writeString(s) }
+	}
+	length := func(enc *Encoder, l uint64) func() error {
+		return func() error { return enc.writeLength(l) }
+	}
+	bin := func(enc *Encoder, p []byte) func() error {
+		return func() error { return enc.write(p) }
+	}
+	stream := func(version uint) func(enc *Encoder) error {
+		return func(enc *Encoder) error {
+			return enc.WriteStreamObject("key", testStreamObject(version))
+		}
+	}
+
+	tests := []struct {
+		typ      byte
+		write    func(enc *Encoder) error
+		objType  string
+		elements int
+		lenient  bool // unregistered module types are rejected in strict mode
+	}{
+		{typeString, func(enc *Encoder) error { return raw(str(enc, "v")) }, model.StringType, 0, false},
+		{typeList, func(enc *Encoder) error { return raw(length(enc, 1), str(enc, "a")) }, model.ListType, 1, false},
+		{typeSet, func(enc *Encoder) error { return raw(length(enc, 1), str(enc, "a")) }, model.SetType, 1, false},
+		{typeZset, func(enc *Encoder) error {
+			return raw(length(enc, 1), str(enc, "a"), bin(enc, []byte{1, '1'}))
+		}, model.ZSetType, 1, false},
+		{typeHash, func(enc *Encoder) error { return raw(length(enc, 1), str(enc, "f"), str(enc, "v")) }, model.HashType, 1, false},
+		{typeZset2, func(enc *Encoder) error { return raw(length(enc, 1), str(enc, "a"), bin(enc, double)) }, model.ZSetType, 1, false},
+		{typeModule2, func(enc *Encoder) error {
+			return raw(length(enc, createModuleId("unknown-t", 1)), length(enc, uint64(ModuleOpcodeEOF)))
+		}, "unknown-t", 0, true},
+		{typeHashZipMap, func(enc *Encoder) error {
+			return raw(str(enc, string([]byte{1, 1, 'f', 1, 0, 'v', 0xff})))
+		}, model.HashType, 1, false},
+		{typeListZipList, func(enc *Encoder) error {
+			return raw(str(enc, string(testZipList(testZipStr("a"), []byte{0xf2}))))
+		}, model.ListType, 2, false},
+		{typeSetIntSet, func(enc *Encoder) error { return raw(str(enc, string(intSet))) }, model.SetType, 1, false},
+		{typeZsetZipList, func(enc *Encoder) error {
+			return raw(str(enc, string(testZipList(testZipStr("a"), []byte{0xf2}))))
+		}, model.ZSetType, 1, false},
+		{typeHashZipList, func(enc *Encoder) error {
+			return raw(str(enc, string(testZipList(testZipStr("f"), testZipStr("v")))))
+		}, model.HashType, 1, false},
+		{typeListQuickList, func(enc *Encoder) error {
+			return raw(length(enc, 1), str(enc, string(testZipList(testZipStr("a")))))
+		}, model.ListType, 1, false},
+		{typeStreamListPacks, stream(1), model.StreamType, 0, false},
+		{typeHashListPack, func(enc *Encoder) error {
+			return raw(str(enc, string(testListPack(testListPackStr("f"), testListPackStr("v")))))
+		}, model.HashType, 1, false},
+		{typeZsetListPack, func(enc *Encoder) error {
+			return raw(str(enc, string(testListPack(testListPackStr("a"), testListPackInt(1)))))
+		}, model.ZSetType, 1, false},
+		{typeListQuickList2, func(enc *Encoder) error {
+			return raw(length(enc, 1), length(enc, model.QuicklistNodeContainerPacked),
+				str(enc, string(testListPack(testListPackStr("a")))))
+		}, model.ListType, 1, false},
+		{typeStreamListPacks2, stream(2), model.StreamType, 0, false},
+		{typeSetListPack, func(enc *Encoder) error {
+			return raw(str(enc, string(testListPack(testListPackStr("a")))))
+		}, model.SetType, 1, false},
+		{typeStreamListPacks3, stream(3), model.StreamType, 0, false},
+		{typeHashWithHfeRc, func(enc *Encoder) error {
+			return raw(length(enc, 1), length(enc, 0), str(enc, "f"), str(enc, "v"))
+		}, model.HashType, 1, false},
+		{typeHashListPackWithHfeRc, func(enc *Encoder) error {
+			return raw(str(enc, string(testListPack(testListPackStr("f"), testListPackStr("v"), testListPackInt(0)))))
+		}, model.HashType, 1, false},
+		{typeHashWithHfe, func(enc *Encoder) error {
+			return raw(bin(enc, noMinExpire), length(enc, 1), length(enc, 0), str(enc, "f"), str(enc, "v"))
+		}, model.HashType, 1, false},
+		{typeHashListPackWithHfe, func(enc *Encoder) error {
+			return raw(bin(enc, noMinExpire),
+				str(enc, string(testListPack(testListPackStr("f"), testListPackStr("v"), testListPackInt(0)))))
+		}, model.HashType, 1, false},
+	}
+
+	covered := make(map[byte]bool)
+	for _, tc := range tests {
+		covered[tc.typ] = true
+		name := valueTypeNames[tc.typ]
+		data := buildRDB(t, func(enc *Encoder, check func(error)) {
+			check(enc.WriteDBHeader(0, 1, 0))
+			if tc.typ == typeStreamListPacks || tc.typ == typeStreamListPacks2 || tc.typ == typeStreamListPacks3 {
+				// stream writer writes type and key itself
+				check(tc.write(enc))
+				return
+			}
+			writeRawObject(enc, check, tc.typ, "key", func() {
+				check(tc.write(enc))
+			})
+		})
+		for _, strict := range []bool{false, true} {
+			if strict && tc.lenient {
+				continue
+			}
+			dec := NewDecoder(bytes.NewReader(data))
+			if strict {
+				dec = dec.WithStrictOpcodes()
+			}
+			var objects []model.RedisObject
+			err := dec.Parse(func(o model.RedisObject) bool {
+				objects = append(objects, o)
+				return true
+			})
+			if err != nil {
+				t.Errorf("%s (strict %v): %v", name, strict, err)
+				continue
+			}
+			if len(objects) != 1 {
+				t.Errorf("%s: expect 1 object, actual %d", name, len(objects))
+				continue
+			}
+			o := objects[0]
+			if o.GetKey() != "key" || o.GetType() != tc.objType {
+				t.Errorf("%s: wrong object %s %s", name, o.GetType(), o.GetKey())
+			}
+			if o.GetElemCount() != tc.elements {
+				t.Errorf("%s: expect %d elements, actual %d", name, tc.elements, o.GetElemCount())
+			}
+			// module values have no encoding, encoding of strings is refined by their content
+			if _, ok := encodingMap[int(tc.typ)]; ok && o.GetEncoding() == "" {
+				t.Errorf("%s: wrong encoding %q", name, o.GetEncoding())
+			}
+		}
+	}
+	for typ, name := range valueTypeNames {
+		if typ != typeModule && !covered[typ] {
+			t.Errorf("value type %s is not covered", name)
+		}
+	}
+}
+
+// TestPreGAModuleType checks values of pre-GA module api, which redis cannot load either, are rejected
+func TestPreGAModuleType(t *testing.T) {
+	data := buildRDB(t, func(enc *Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 1, 0))
+		writeRawObject(enc, check, typeModule, "key", func() {
+			check(enc.writeLength(createModuleId("unknown-t", 1)))
+		})
+	})
+	err := NewDecoder(bytes.NewReader(data)).Parse(func(o model.RedisObject) bool { return true })
+	if err == nil {
+		t.Error("expect error for pre-GA module type")
+	}
+}
+
+// TestOpcodes checks every opcode of rdb.h through rdb version 12, including RDB_OPCODE_SLOT_INFO of redis 8.0,
+// is read with its payload, so that the string after it is decoded in strict mode
+func TestOpcodes(t *testing.T) {
+	payload := func(write func(enc *Encoder) error) []byte {
+		buf := bytes.NewBuffer(nil)
+		if err := write(NewEncoder(buf)); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	tests := []struct {
+		name    string
+		opcode  byte
+		payload []byte
+		lenient bool // module aux of unregistered module is skipped only in lenient mode
+		check   func(o model.RedisObject) bool
+	}{
+		{name: "slotinfo", opcode: 244, payload: []byte{0x40, 0xff, 0x01, 0x00}},
+		{name: "function2", opcode: 245, payload: payload(func(enc *Encoder) error {
+			return enc.writeString(testFunctionCode)
+		})},
+		{name: "function", opcode: 246, payload: payload(func(enc *Encoder) error {
+			for _, s := range []string{"mylib", "LUA"} {
+				if err := enc.writeString(s); err != nil {
+					return err
+				}
+			}
+			if err := enc.writeLength(0); err != nil {
+				return err
+			}
+			return enc.writeString(testFunctionCode)
+		})},
+		{name: "moduleaux", opcode: 247, lenient: true, payload: payload(func(enc *Encoder) error {
+			for _, n := range []uint64{createModuleId("unknown-t", 1), uint64(ModuleOpcodeUInt), 2, uint64(ModuleOpcodeEOF)} {
+				if err := enc.writeLength(n); err != nil {
+					return err
+				}
+			}
+			return nil
+		})},
+		{name: "idle", opcode: 248, payload: []byte{0x43, 0xe8}, check: func(o model.RedisObject) bool {
+			idle := o.(*model.StringObject).Idle
+			return idle != nil && *idle == 1000
+		}},
+		{name: "freq", opcode: 249, payload: []byte{42}},
+		{name: "aux", opcode: 250, payload: []byte{0x01, 'x', 0x01, 'y'}},
+		{name: "resizedb", opcode: 251, payload: []byte{0x01, 0x00}},
+		{name: "expiretimems", opcode: 252, payload: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			check: func(o model.RedisObject) bool {
+				return o.GetExpiration() != nil && o.GetExpiration().UnixNano() == 0
+			}},
+		{name: "expiretime", opcode: 253, payload: []byte{0x00, 0x00, 0x00, 0x00}, check: func(o model.RedisObject) bool {
+			return o.GetExpiration() != nil && o.GetExpiration().UnixNano() == 0
+		}},
+		{name: "selectdb", opcode: 254, payload: []byte{0x03}, check: func(o model.RedisObject) bool {
+			return o.GetDBIndex() == 3
+		}},
+	}
+	covered := make(map[byte]bool)
+	for _, tc := range tests {
+		covered[tc.opcode] = true
+		if opCodeNames[tc.opcode] != tc.name {
+			t.Errorf("opcode %d: expect name %s, actual %s", tc.opcode, tc.name, opCodeNames[tc.opcode])
+		}
+		data := buildRDB(t, func(enc *Encoder, check func(error)) {
+			check(enc.WriteDBHeader(0, 1, 0))
+			check(enc.write([]byte{tc.opcode}))
+			check(enc.write(tc.payload))
+			check(enc.WriteStringObject("k", []byte("v")))
+		})
+		dec := NewDecoder(bytes.NewReader(data))
+		if !tc.lenient {
+			dec = dec.WithStrictOpcodes()
+		}
+		var objects []model.RedisObject
+		err := dec.Parse(func(o model.RedisObject) bool {
+			objects = append(objects, o)
+			return true
+		})
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if len(objects) != 1 || objects[0].GetKey() != "k" {
+			t.Errorf("%s: wrong objects %v", tc.name, objects)
+			continue
+		}
+		if tc.check != nil && !tc.check(objects[0]) {
+			t.Errorf("%s: wrong metadata of object", tc.name)
+		}
+		if dec.Stats().OpCodes[tc.name] == 0 {
+			t.Errorf("%s: opcode is not counted, actual %v", tc.name, dec.Stats().OpCodes)
+		}
+	}
+	for opcode, name := range opCodeNames {
+		if opcode != opCodeEOF && !covered[opcode] {
+			t.Errorf("opcode %s is not covered", name)
+		}
+	}
+}
@@ -3,9 +3,13 @@ package core
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"math/rand"
 	"unsafe"
+
+	"github.com/hdt3213/rdb/crc64jones"
 )
 
 func readBytes(buf []byte, cursor *int, size int) ([]byte, error) {
@@ -42,12 +46,39 @@ func readZipListLength(buf []byte, cursor *int) int {
 	return size
 }
 
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// unbufferedReader reads exactly the bytes requested, so that the underlying reader is not read ahead
+type unbufferedReader struct {
+	reader io.Reader
+	buf    [1]byte
+}
+
+func (r *unbufferedReader) Read(p []byte) (int, error) {
+	return r.reader.Read(p)
+}
+
+func (r *unbufferedReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(r.reader, r.buf[:]); err != nil {
+		return 0, err
+	}
+	return r.buf[0], nil
+}
+
 func (dec *Decoder) readByte() (byte, error) {
 	b, err := dec.input.ReadByte()
 	if err != nil {
 		return 0, err
 	}
 	dec.readCount++
+	dec.crcByte[0] = b
+	_, _ = dec.crc.Write(dec.crcByte[:])
+	if dec.capturing {
+		dec.captured = append(dec.captured, b)
+	}
 	return b, nil
 }
 
@@ -56,10 +87,70 @@ func (dec *Decoder) readFull(buf []byte) error {
 	if err != nil {
 		return err
 	}
-	dec.readCount += n
+	dec.readCount += int64(n)
+	_, _ = dec.crc.Write(buf)
+	if dec.capturing {
+		dec.captured = append(dec.captured, buf...)
+	}
+	return nil
+}
+
+// discard skips n bytes, they still have to be read to keep crc and captured payload.
+// n is uint64 so that strings larger than 2GB could be skipped on 32-bit platforms
+func (dec *Decoder) discard(n uint64) error {
+	if dec.discardBuf == nil {
+		dec.discardBuf = make([]byte, 4096)
+	}
+	for n > 0 {
+		chunk := uint64(len(dec.discardBuf))
+		if n < chunk {
+			chunk = n
+		}
+		if err := dec.readFull(dec.discardBuf[:chunk]); err != nil {
+			return err
+		}
+		n -= chunk
+	}
 	return nil
 }
 
+// lengthToInt converts length read from rdb to int, it fails instead of wrapping around if int is 32-bit
+func lengthToInt(length uint64) (int, error) {
+	if length > math.MaxInt {
+		return 0, fmt.Errorf("length %d exceeds int range", length)
+	}
+	return int(length), nil
+}
+
+// maxPrealloc limits capacity allocated according to declared element count, since the count may be corrupt.
+// Slices grow incrementally beyond it
+const maxPrealloc = 1024
+
+func preallocSize(n int) int {
+	if n > maxPrealloc {
+		return maxPrealloc
+	} else if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// readElementCount reads declared element count of list, set, hash or zset and validates it
+func (dec *Decoder) readElementCount() (int, error) {
+	size64, _, err := dec.readLength()
+	if err != nil {
+		return 0, err
+	}
+	if dec.maxElementCount > 0 && size64 > uint64(dec.maxElementCount) {
+		return 0, fmt.Errorf("element count %d exceeds limit %d", size64, dec.maxElementCount)
+	}
+	size, err := lengthToInt(size64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid element count: %d", size64)
+	}
+	return size, nil
+}
+
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
 
 // RandString create a random string no longer than n
@@ -74,3 +165,16 @@ func RandString(n int) string {
 func unsafeBytes2Str(b []byte) string {
 	return *(*string)(unsafe.Pointer(&b))
 }
+
+// makeDumpPayload appends rdb version and crc64 to serialized value, just like DUMP command.
+// see createDumpPayload at [cluster.c](https://github.com/redis/redis/blob/unstable/src/cluster.c)
+func makeDumpPayload(value []byte, version int) []byte {
+	payload := make([]byte, len(value), len(value)+10)
+	copy(payload, value)
+	payload = append(payload, byte(version), byte(version>>8))
+	crc := crc64jones.New()
+	_, _ = crc.Write(payload)
+	crcBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(crcBytes, crc.Sum64())
+	return append(payload, crcBytes...)
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+// VendorOpCodeHandleFunc reads payload of a vendor specific opcode, e.g. opcodes added by KeyDB.
+// The opcode byte has been read before it is called
+type VendorOpCodeHandleFunc func(handler ModuleTypeHandler) error
+
+// WithVendorOpCode makes decoder call f to read (or skip) payload of the given opcode,
+// which is not defined by redis but written by forks such as KeyDB.
+// Vendor aux fields like `valkey-ver` or `keydb-version` need no handling, they are preserved in GetAuxFields,
+// and per key multi-master metadata of KeyDB is skipped with a warning unless WithKeyMetadata
+func (dec *Decoder) WithVendorOpCode(opcode byte, f VendorOpCodeHandleFunc) *Decoder {
+	dec.withVendorOpCodes[opcode] = f
+	return dec
+}
+
+// keydbKeyAuxFields are aux fields KeyDB writes around keys as multi-master metadata: mvcc-tstamp before a key if
+// active replica is enabled, and keydb-subexpire-key, keydb-subexpire-when pairs after a key for expirations of its
+// members. They are about the key rather than the file, so they are never collected in GetAuxFields
+var keydbKeyAuxFields = map[string]struct{}{
+	"mvcc-tstamp":          {},
+	"keydb-subexpire-key":  {},
+	"keydb-subexpire-when": {},
+}
+
+// WithKeyMetadata makes decoder pass per key metadata of KeyDB (mvcc-tstamp, keydb-subexpire-key and
+// keydb-subexpire-when) to callback as model.AuxObject in the order of file, even without WithSpecialOpCode.
+// mvcc-tstamp belongs to the key after it and keydb-subexpire-* belong to the key before them
+func (dec *Decoder) WithKeyMetadata() *Decoder {
+	dec.withKeyMetadata = true
+	return dec
+}
+
+// TypeHandleFunc reads value of a type registered by WithTypeHandler, the type byte and key have been read before
+// it is called. base has key, db and expiration of the object, the returned object should embed it, e.g.
+// &model.StringObject{BaseObject: base, Value: value}, so that decoder could fill in size and offsets
+type TypeHandleFunc func(handler ModuleTypeHandler, base *model.BaseObject) (model.RedisObject, error)
+
+// WithTypeHandler makes decoder call f to read values of the given type byte, e.g. a type added by a patched redis.
+// Registered handlers take precedence over built-in types, so an encoding could also be decoded differently
+func (dec *Decoder) WithTypeHandler(typeByte byte, f TypeHandleFunc) *Decoder {
+	dec.withTypeHandlers[typeByte] = f
+	return dec
+}
+
+// Reader reads primitives of rdb encoding in handlers of RegisterTypeHandler
+type Reader = ModuleTypeHandler
+
+// RegisterTypeHandler is a shorthand of WithTypeHandler for handlers which only need the key, e.g.
+// func(r core.Reader, key string) (model.RedisObject, error) { ...; return &model.StringObject{Value: v}, nil }.
+// The returned object must be a pointer to a struct embedding *model.BaseObject, which is set by decoder
+// with key, db, expiration, size and offsets
+func (dec *Decoder) RegisterTypeHandler(typeByte byte, fn func(r Reader, key string) (model.RedisObject, error)) *Decoder {
+	return dec.WithTypeHandler(typeByte, func(handler ModuleTypeHandler, base *model.BaseObject) (model.RedisObject, error) {
+		obj, err := fn(handler, base.Key)
+		if err != nil {
+			return nil, err
+		}
+		if !setBaseObject(obj, base) {
+			return nil, fmt.Errorf("object of key %s should embed *model.BaseObject", base.Key)
+		}
+		return obj, nil
+	})
+}
+
+// setBaseObject sets the embedded *model.BaseObject of obj, it returns false if obj does not embed one
+func setBaseObject(obj model.RedisObject, base *model.BaseObject) bool {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	field := v.Elem().FieldByName("BaseObject")
+	if !field.IsValid() || !field.CanSet() || field.Type() != reflect.TypeOf(base) {
+		return false
+	}
+	field.Set(reflect.ValueOf(base))
+	return true
+}
+
+// ErrUnknownOpcode is returned in strict mode when decoder meets an opcode, type or module type it cannot recognize
+type ErrUnknownOpcode struct {
+	Byte   byte
+	Offset int64 // Offset is position of the byte in rdb file
+}
+
+func (e *ErrUnknownOpcode) Error() string {
+	return fmt.Sprintf("unknown opcode %d at offset %d", e.Byte, e.Offset)
+}
+
+// WithStrictOpcodes makes decoder fail immediately with *ErrUnknownOpcode on anything it cannot recognize.
+// By default, decoder skips what it is able to skip, e.g. values of unregistered module types, which keeps
+// parsing new files going but may silently produce incomplete output. Strict mode is useful for validation in CI
+func (dec *Decoder) WithStrictOpcodes() *Decoder {
+	dec.strictOpcodes = true
+	return dec
+}
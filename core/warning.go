@@ -0,0 +1,129 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+// Kinds of Warning
+const (
+	// WarningSkippedModule means value or aux data of an unregistered module type was skipped
+	WarningSkippedModule = "skipped-module"
+	// WarningUnknownAux means an aux field not written by redis, e.g. fields added by forks or other tools
+	WarningUnknownAux = "unknown-aux"
+	// WarningCountMismatch means number of keys in a db differs from the count declared by RDB_OPCODE_RESIZEDB
+	WarningCountMismatch = "count-mismatch"
+	// WarningTruncated means some elements of a value were skipped because of WithElementCap
+	WarningTruncated = "truncated"
+	// WarningEncodingSanity means a collection in a compact encoding is far larger than redis would store in it,
+	// which often indicates a corrupted or hand-edited dump. It is reported WithEncodingSanity
+	WarningEncodingSanity = "encoding-sanity"
+	// WarningKeyMetadata means per key metadata KeyDB writes as aux fields was skipped, it is reported once for
+	// each field name. WithKeyMetadata passes the metadata to callback instead
+	WarningKeyMetadata = "key-metadata"
+)
+
+// knownAuxFields are aux fields written by redis
+var knownAuxFields = map[string]struct{}{
+	"redis-ver":      {},
+	auxRedisBits:     {},
+	"ctime":          {},
+	"used-mem":       {},
+	"repl-stream-db": {},
+	"repl-id":        {},
+	"repl-offset":    {},
+	"aof-base":       {},
+	"aof-preamble":   {},
+	auxLuaScript:     {},
+}
+
+// Warning is a non-fatal observation during parsing
+type Warning struct {
+	Kind    string
+	Offset  int64 // Offset is position in rdb file of the opcode or type byte of the record
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s at offset %d: %s", w.Kind, w.Offset, w.Message)
+}
+
+// WithWarnings makes decoder collect non-fatal observations like skipped modules, unknown aux fields and
+// key count mismatches, which could be got by Warnings after Parse
+func (dec *Decoder) WithWarnings() *Decoder {
+	dec.collectWarnings = true
+	return dec
+}
+
+// Warnings returns warnings collected so far, it is empty unless decoder WithWarnings
+func (dec *Decoder) Warnings() []Warning {
+	return dec.warnings
+}
+
+// Limits of WithEncodingSanity
+const (
+	// sanityMaxEntries is 64 times of default hash-max-listpack-entries, zset-max-listpack-entries and
+	// set-max-listpack-entries, and 16 times of default set-max-intset-entries
+	sanityMaxEntries = 8192
+	// sanityMaxBytes is 64 times of the largest hash listpack of default configs, 128 fields and values of 64 bytes
+	sanityMaxBytes = 1 << 20
+)
+
+// compactEncodings store a whole collection in a single container
+var compactEncodings = map[string]bool{
+	model.ZipListEncoding:    true,
+	model.ZipMapEncoding:     true,
+	model.IntSetEncoding:     true,
+	model.ListPackEncoding:   true,
+	model.ListPackExEncoding: true,
+}
+
+// WithEncodingSanity makes decoder warn about collections in listpack, ziplist, zipmap or intset which have far more
+// elements or bytes than redis would ever store in such encodings, it implies WithWarnings
+func (dec *Decoder) WithEncodingSanity() *Decoder {
+	dec.encodingSanity = true
+	dec.collectWarnings = true
+	return dec
+}
+
+// checkEncodingSanity adds a warning if obj in a compact encoding exceeds sanity limits
+func (dec *Decoder) checkEncodingSanity(obj model.RedisObject, offset int64) {
+	if !compactEncodings[obj.GetEncoding()] {
+		return
+	}
+	count := obj.GetElemCount()
+	size := obj.GetDiskSize() - len(obj.GetKey())
+	if count > sanityMaxEntries || size > sanityMaxBytes {
+		dec.warn(WarningEncodingSanity, offset, "%s %s has %d elements in %d bytes, which is too large for %s",
+			obj.GetType(), obj.GetKey(), count, size, obj.GetEncoding())
+	}
+}
+
+func (dec *Decoder) warn(kind string, offset int64, format string, args ...interface{}) {
+	if !dec.collectWarnings {
+		return
+	}
+	dec.warnings = append(dec.warnings, Warning{
+		Kind:    kind,
+		Offset:  offset,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// dbKeyCounter compares number of keys in a db with RDB_OPCODE_RESIZEDB
+type dbKeyCounter struct {
+	db       int
+	declared uint64
+	offset   int64 // offset of RDB_OPCODE_RESIZEDB
+	actual   uint64
+	valid    bool
+}
+
+// check adds a warning if the db has finished and its key count mismatches
+func (c *dbKeyCounter) check(dec *Decoder) {
+	if c.valid && c.actual != c.declared {
+		dec.warn(WarningCountMismatch, c.offset, "db %d declares %d keys, actual %d", c.db, c.declared, c.actual)
+	}
+	c.valid = false
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestWarnings(t *testing.T) {
+	data := buildRDB(t, func(enc *Encoder, check func(error)) {
+		check(enc.WriteAux("x-tool", "1.0"))
+		check(enc.WriteAux("redis-ver", "7.2.0"))
+		check(enc.WriteDBHeader(0, 2, 0))
+		writeRawObject(enc, check, typeModule2, "module", func() {
+			check(enc.writeLength(createModuleId("unknown-t", 1)))
+			check(enc.writeLength(uint64(ModuleOpcodeUInt)))
+			check(enc.writeLength(1))
+			check(enc.writeLength(uint64(ModuleOpcodeEOF)))
+		})
+		check(enc.WriteStringObject("a", []byte("1")))
+	})
+
+	dec := NewDecoder(bytes.NewReader(data)).WithWarnings()
+	err := dec.Parse(func(o model.RedisObject) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings := dec.Warnings()
+	if len(warnings) != 2 {
+		t.Fatalf("expect 2 warnings, actual %v", warnings)
+	}
+	if warnings[0].Kind != WarningUnknownAux || warnings[0].Offset != 9 {
+		t.Errorf("unexpected warning: %v", warnings[0])
+	}
+	if warnings[1].Kind != WarningSkippedModule || data[warnings[1].Offset] != typeModule2 {
+		t.Errorf("unexpected warning: %v", warnings[1])
+	}
+
+	dec = NewDecoder(bytes.NewReader(data))
+	if err = dec.Parse(func(o model.RedisObject) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	if len(dec.Warnings()) != 0 {
+		t.Errorf("expect no warnings without WithWarnings, actual %v", dec.Warnings())
+	}
+}
+
+func TestCountMismatchWarning(t *testing.T) {
+	data := buildRDB(t, func(enc *Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 3, 0))
+		check(enc.WriteStringObject("a", []byte("1")))
+		check(enc.WriteDBHeader(1, 1, 0))
+		check(enc.WriteStringObject("b", []byte("2")))
+	})
+	dec := NewDecoder(bytes.NewReader(data)).WithWarnings()
+	err := dec.Parse(func(o model.RedisObject) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings := dec.Warnings()
+	if len(warnings) != 1 || warnings[0].Kind != WarningCountMismatch {
+		t.Fatalf("expect 1 count mismatch warning, actual %v", warnings)
+	}
+	if expect := "db 0 declares 3 keys, actual 1"; warnings[0].Message != expect {
+		t.Errorf("expect message %s, actual %s", expect, warnings[0].Message)
+	}
+}
+
+func TestEncodingSanity(t *testing.T) {
+	const fields = 100000
+	entries := make([][]byte, 0, fields*2)
+	for i := 0; i < fields; i++ {
+		entries = append(entries, testListPackStr("f"+strconv.Itoa(i)), testListPackInt(1))
+	}
+	data := buildRDB(t, func(enc *Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 2, 0))
+		writeRawObject(enc, check, typeHashListPack, "huge", func() {
+			check(enc.writeString(string(testListPack(entries...))))
+		})
+		check(enc.WriteHashMapObject("small", map[string][]byte{"f": []byte("v")}))
+	})
+
+	dec := NewDecoder(bytes.NewReader(data)).WithEncodingSanity()
+	counts := make(map[string]int)
+	err := dec.Parse(func(o model.RedisObject) bool {
+		counts[o.GetKey()] = o.GetElemCount()
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// listpack with more than 65535 entries has unknown length in header
+	if counts["huge"] != fields || counts["small"] != 1 {
+		t.Errorf("wrong element counts: %v", counts)
+	}
+	warnings := dec.Warnings()
+	if len(warnings) != 1 || warnings[0].Kind != WarningEncodingSanity || warnings[0].Offset != 14 {
+		t.Fatalf("expect a sanity warning of huge, actual %v", warnings)
+	}
+	if !strings.Contains(warnings[0].Message, "huge") {
+		t.Errorf("warning should mention the key: %s", warnings[0].Message)
+	}
+
+	dec = NewDecoder(bytes.NewReader(data)).WithWarnings()
+	if err = dec.Parse(func(o model.RedisObject) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	if len(dec.Warnings()) != 0 {
+		t.Errorf("expect no warning without sanity check, actual %v", dec.Warnings())
+	}
+}
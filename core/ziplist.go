@@ -10,7 +10,7 @@ import (
 )
 
 func (dec *Decoder) readZipList() ([][]byte, error) {
-	buf, err := dec.readString()
+	buf, err := dec.readContainer()
 	if err != nil {
 		return nil, err
 	}
@@ -18,6 +18,9 @@ func (dec *Decoder) readZipList() ([][]byte, error) {
 	size := readZipListLength(buf, &cursor)
 	entries := make([][]byte, 0, size)
 	for i := 0; i < size; i++ {
+		if dec.capReached(len(entries)) {
+			break
+		}
 		entry, err := dec.readZipListEntry(buf, &cursor)
 		if err != nil {
 			return nil, err
@@ -150,9 +153,10 @@ func encodeZipListEntry(prevLen uint32, val string) []byte {
 		buf.Write([]byte{byte(len(val))}) // 00 + xxxxxx
 	} else if len(val) <= maxUint14 {
 		buf.Write([]byte{byte(len(val)>>8) | len14BitMask, byte(len(val))})
-	} else if len(val) <= math.MaxUint32 {
-		buffer := make([]byte, 8)
-		binary.LittleEndian.PutUint32(buffer, uint32(len(val)))
+	} else if uint64(len(val)) <= math.MaxUint32 {
+		// 10000000 + 4 bytes length in big endian
+		buffer := make([]byte, 4)
+		binary.BigEndian.PutUint32(buffer, uint32(len(val)))
 		buf.Write([]byte{0x80})
 		buf.Write(buffer)
 	} else {
@@ -7,35 +7,46 @@ import (
 )
 
 func (dec *Decoder) readZSet(zset2 bool) ([]*model.ZSetEntry, error) {
-	length, _, err := dec.readLength()
+	length, err := dec.readElementCount()
 	if err != nil {
 		return nil, err
 	}
-	entries := make([]*model.ZSetEntry, 0, int(length))
-	for i := uint64(0); i < length; i++ {
-		member, err := dec.readString()
+	entries := make([]*model.ZSetEntry, 0, preallocSize(length))
+	for i := 0; i < length; i++ {
+		capped := dec.capReached(len(entries))
+		var member []byte
+		if capped {
+			err = dec.skipString()
+		} else {
+			member, err = dec.readString()
+		}
 		if err != nil {
 			return nil, err
 		}
 		var score float64
+		var scoreText string
 		if zset2 {
 			score, err = dec.readFloat()
 		} else {
-			score, err = dec.readLiteralFloat()
+			score, scoreText, err = dec.readLiteralFloat()
 		}
 		if err != nil {
 			return nil, err
 		}
+		if capped {
+			continue
+		}
 		entries = append(entries, &model.ZSetEntry{
-			Member: unsafeBytes2Str(member),
-			Score:  score,
+			Member:    unsafeBytes2Str(member),
+			Score:     score,
+			ScoreText: scoreText,
 		})
 	}
 	return entries, nil
 }
 
 func (dec *Decoder) readZipListZSet() ([]*model.ZSetEntry, *model.ZiplistDetail, error) {
-	buf, err := dec.readString()
+	buf, err := dec.readContainer()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -43,6 +54,9 @@ func (dec *Decoder) readZipListZSet() ([]*model.ZSetEntry, *model.ZiplistDetail,
 	size := readZipListLength(buf, &cursor)
 	entries := make([]*model.ZSetEntry, 0, size)
 	for i := 0; i < size; i += 2 {
+		if dec.capReached(len(entries)) {
+			break
+		}
 		member, err := dec.readZipListEntry(buf, &cursor)
 		if err != nil {
 			return nil, nil, err
@@ -56,8 +70,9 @@ func (dec *Decoder) readZipListZSet() ([]*model.ZSetEntry, *model.ZiplistDetail,
 			return nil, nil, err
 		}
 		entries = append(entries, &model.ZSetEntry{
-			Member: unsafeBytes2Str(member),
-			Score:  score,
+			Member:    unsafeBytes2Str(member),
+			Score:     score,
+			ScoreText: unsafeBytes2Str(scoreLiteral),
 		})
 	}
 	detail := &model.ZiplistDetail{
@@ -67,14 +82,17 @@ func (dec *Decoder) readZipListZSet() ([]*model.ZSetEntry, *model.ZiplistDetail,
 }
 
 func (dec *Decoder) readListPackZSet() ([]*model.ZSetEntry, *model.ListpackDetail, error) {
-	buf, err := dec.readString()
+	buf, err := dec.readContainer()
 	if err != nil {
 		return nil, nil, err
 	}
 	cursor := 0
-	size := readListPackLength(buf, &cursor)
+	size := dec.readListPackLength(buf, &cursor)
 	entries := make([]*model.ZSetEntry, 0, size)
 	for i := 0; i < size; i += 2 {
+		if dec.capReached(len(entries)) {
+			break
+		}
 		member, err := dec.readListPackEntryAsString(buf, &cursor)
 		if err != nil {
 			return nil, nil, err
@@ -88,8 +106,9 @@ func (dec *Decoder) readListPackZSet() ([]*model.ZSetEntry, *model.ListpackDetai
 			return nil, nil, err
 		}
 		entries = append(entries, &model.ZSetEntry{
-			Member: unsafeBytes2Str(member),
-			Score:  score,
+			Member:    unsafeBytes2Str(member),
+			Score:     score,
+			ScoreText: unsafeBytes2Str(scoreLiteral),
 		})
 	}
 	detail := &model.ListpackDetail{
@@ -163,7 +182,10 @@ func (enc *Encoder) tryWriteZipListZSet(key string, entries []*model.ZSetEntry)
 	}
 	zlElements := make([]string, 0, len(entries)*2)
 	for _, entry := range entries {
-		scoreStr := strconv.FormatFloat(entry.Score, 'f', -1, 64)
+		scoreStr := model.FormatScore(entry.Score)
+		if number, ok := entry.ScoreNumber(); ok && string(number) == entry.ScoreText {
+			scoreStr = entry.ScoreText // keep score as it was stored
+		}
 		zlElements = append(zlElements, entry.Member, scoreStr)
 	}
 	err = enc.writeZipList(zlElements)
@@ -2,8 +2,13 @@ package core
 
 import (
 	"bytes"
-	"github.com/hdt3213/rdb/model"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/hdt3213/rdb/model"
 )
 
 func TestZSetEncoding(t *testing.T) {
@@ -98,3 +103,116 @@ func TestZSetEncoding(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestScoreText(t *testing.T) {
+	// scores of RDB_TYPE_ZSET are text, e.g. %.17g written by old redis
+	texts := []string{"3.1899999999999999", "1.50", "-2"}
+	binaryScores := []float64{0.1, 1.0 / 3, 1e300, -5}
+	entries := make([]*model.ZSetEntry, len(binaryScores))
+	for i, score := range binaryScores {
+		entries[i] = &model.ZSetEntry{Member: strconv.Itoa(i), Score: score}
+	}
+	data := buildRDB(t, func(enc *Encoder, check func(error)) {
+		enc.SetZSetZipListOpt(64, 1)
+		check(enc.WriteDBHeader(0, 2, 0))
+		writeRawObject(enc, check, typeZset, "text", func() {
+			check(enc.writeLength(uint64(len(texts))))
+			for i, text := range texts {
+				check(enc.writeString(strconv.Itoa(i)))
+				check(enc.write(append([]byte{byte(len(text))}, text...)))
+			}
+		})
+		check(enc.WriteZSetObject("binary", entries))
+	})
+
+	objects := make(map[string]*model.ZSetObject)
+	err := NewDecoder(bytes.NewReader(data)).Parse(func(o model.RedisObject) bool {
+		objects[o.GetKey()] = o.(*model.ZSetObject)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if objects["binary"].GetEncoding() != model.ZSet2Encoding {
+		t.Fatalf("expect zset2, actual %s", objects["binary"].GetEncoding())
+	}
+	scores := func(o *model.ZSetObject) []json.Number {
+		data, err := json.Marshal(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result struct {
+			Entries []struct {
+				Score json.Number `json:"score"`
+			} `json:"entries"`
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err = decoder.Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		numbers := make([]json.Number, len(result.Entries))
+		for i, e := range result.Entries {
+			numbers[i] = e.Score
+		}
+		return numbers
+	}
+	for i, score := range scores(objects["text"]) {
+		if string(score) != texts[i] {
+			t.Errorf("expect text score %s, actual %s", texts[i], score)
+		}
+	}
+	for i, score := range scores(objects["binary"]) {
+		f, err := score.Float64()
+		if err != nil || f != binaryScores[i] {
+			t.Errorf("expect binary score %v, actual %s", binaryScores[i], score)
+		}
+		if strings.HasSuffix(string(score), "00000001") {
+			t.Errorf("spurious precision of %s", score)
+		}
+	}
+}
+
+func TestSpecialScores(t *testing.T) {
+	// RDB_TYPE_ZSET writes inf, -inf and nan as single byte markers instead of text
+	markers := []byte{0xfe, 0xff, 0xfd}
+	data := buildRDB(t, func(enc *Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 1, 0))
+		writeRawObject(enc, check, typeZset, "special", func() {
+			check(enc.writeLength(uint64(len(markers))))
+			for i, marker := range markers {
+				check(enc.writeString(strconv.Itoa(i)))
+				check(enc.write([]byte{marker}))
+			}
+		})
+	})
+
+	var zset *model.ZSetObject
+	err := NewDecoder(bytes.NewReader(data)).Parse(func(o model.RedisObject) bool {
+		zset = o.(*model.ZSetObject)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zset == nil || len(zset.Entries) != len(markers) {
+		t.Fatalf("expect %d entries, actual %v", len(markers), zset)
+	}
+	if score := zset.Entries[0].Score; !math.IsInf(score, 1) {
+		t.Errorf("expect +inf, actual %v", score)
+	}
+	if score := zset.Entries[1].Score; !math.IsInf(score, -1) {
+		t.Errorf("expect -inf, actual %v", score)
+	}
+	if score := zset.Entries[2].Score; !math.IsNaN(score) {
+		t.Errorf("expect nan, actual %v", score)
+	}
+	js, err := json.Marshal(zset.Entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `[{"member":"0","score":"inf"},{"member":"1","score":"-inf"},{"member":"2","score":"nan"}]`
+	if string(js) != expect {
+		t.Errorf("expect %s, actual %s", expect, js)
+	}
+}
@@ -0,0 +1,41 @@
+// Package crc16 implements the CRC16 checksum used by redis cluster to compute hash slot of keys.
+//
+// Specification of this CRC16 variant follows:
+// - Name: XMODEM (also known as ZMODEM or CRC-16/ACORN)
+// - Width: 16 bit
+// - Poly: 0x1021 (That is actually x^16 + x^12 + x^5 + 1)
+// - Initialization: 0x0000
+// - Reflect Input byte: False
+// - Reflect Output CRC: False
+// - Xor constant to output CRC: 0x0000
+// - Output for "123456789": 0x31C3
+package crc16
+
+const poly = 0x1021
+
+var table = makeTable()
+
+func makeTable() [256]uint16 {
+	var t [256]uint16
+	for i := 0; i < 256; i++ {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ poly
+			} else {
+				crc <<= 1
+			}
+		}
+		t[i] = crc
+	}
+	return t
+}
+
+// Checksum returns the CRC16 checksum of data
+func Checksum(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = crc<<8 ^ table[byte(crc>>8)^b]
+	}
+	return crc
+}
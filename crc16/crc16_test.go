@@ -0,0 +1,12 @@
+package crc16
+
+import "testing"
+
+func TestGolden(t *testing.T) {
+	if s := Checksum([]byte("123456789")); s != 0x31c3 {
+		t.Fatalf("crc16(123456789) = 0x%x want 0x31c3", s)
+	}
+	if s := Checksum(nil); s != 0 {
+		t.Fatalf("crc16() = 0x%x want 0", s)
+	}
+}
@@ -10,3 +10,9 @@ var NewEncoder = core.NewEncoder
 
 // WithTTL specific expiration timestamp for object
 var WithTTL = core.WithTTL
+
+// WithIdle specific LRU idle time in seconds for object
+var WithIdle = core.WithIdle
+
+// WithTargetVersion makes encoder write rdb of version, which could be loaded by older redis
+var WithTargetVersion = core.WithTargetVersion
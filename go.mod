@@ -1,11 +1,15 @@
 module github.com/echohu96/rdb
 
-go 1.16
+go 1.21
+
+require github.com/bytedance/sonic v1.12.1
 
 require (
-	github.com/bytedance/sonic v1.12.1
-	github.com/chenzhuoyu/base64x v0.0.0-20221115062448-fe3a3abad311 // indirect
+	github.com/bytedance/sonic/loader v0.2.0 // indirect
+	github.com/cloudwego/base64x v0.1.4 // indirect
+	github.com/cloudwego/iasm v0.2.0 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.8 // indirect
+	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
 	golang.org/x/arch v0.9.0 // indirect
 	golang.org/x/sys v0.24.0 // indirect
 )
@@ -0,0 +1,244 @@
+package helper
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// respReader reads commands in RESP2 multibulk or inline form, as redis reads aof
+type respReader struct {
+	input *bufio.Reader
+}
+
+// readLine returns a line without its \r\n or \n, it returns io.EOF only if nothing is read
+func (r *respReader) readLine() ([]byte, error) {
+	line, err := r.input.ReadBytes('\n')
+	if err == io.EOF && len(line) > 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	line = line[:len(line)-1]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line, nil
+}
+
+// readCommand returns the next command, empty commands (e.g. blank lines) are skipped
+func (r *respReader) readCommand() (CmdLine, error) {
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != '*' {
+			args, err := splitInlineArgs(line)
+			if err != nil {
+				return nil, err
+			}
+			if len(args) == 0 {
+				continue
+			}
+			return args, nil
+		}
+		count, err := strconv.Atoi(string(line[1:]))
+		if err != nil || count > 1024*1024 {
+			return nil, fmt.Errorf("illegal multibulk length: %q", line)
+		}
+		if count <= 0 {
+			continue
+		}
+		cmdLine := make(CmdLine, 0, count)
+		for i := 0; i < count; i++ {
+			header, err := r.readLine()
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return nil, err
+			}
+			if len(header) == 0 || header[0] != '$' {
+				return nil, fmt.Errorf("expect bulk string, actual %q", header)
+			}
+			// 512MB is the default proto-max-bulk-len of redis
+			length, err := strconv.Atoi(string(header[1:]))
+			if err != nil || length < 0 || length > 512*1024*1024 {
+				return nil, fmt.Errorf("illegal bulk length: %q", header)
+			}
+			arg := make([]byte, length+2)
+			if _, err = io.ReadFull(r.input, arg); err != nil {
+				if err == io.EOF {
+					return nil, io.ErrUnexpectedEOF
+				}
+				return nil, err
+			}
+			if arg[length] != '\r' || arg[length+1] != '\n' {
+				return nil, errors.New("bulk string is not terminated by CRLF")
+			}
+			cmdLine = append(cmdLine, arg[:length])
+		}
+		return cmdLine, nil
+	}
+}
+
+// splitInlineArgs splits inline command like sdssplitargs of redis, arguments may be quoted by double quotes
+// with escapes such as \n and \x3f, or by single quotes without escapes except \'
+func splitInlineArgs(line []byte) (CmdLine, error) {
+	var args CmdLine
+	i := 0
+	isSpace := func(c byte) bool {
+		return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\v' || c == '\f'
+	}
+	isHex := func(c byte) bool {
+		return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+	}
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i >= len(line) {
+			return args, nil
+		}
+		var arg []byte
+		switch line[i] {
+		case '"':
+			i++
+			for {
+				if i >= len(line) {
+					return nil, errors.New("unbalanced quotes in inline command")
+				}
+				c := line[i]
+				if c == '"' {
+					i++
+					break
+				}
+				if c == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]) {
+					v, _ := strconv.ParseUint(string(line[i+2:i+4]), 16, 8)
+					arg = append(arg, byte(v))
+					i += 4
+					continue
+				}
+				if c == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						c = '\n'
+					case 'r':
+						c = '\r'
+					case 't':
+						c = '\t'
+					case 'b':
+						c = '\b'
+					case 'a':
+						c = '\a'
+					default:
+						c = line[i]
+					}
+				}
+				arg = append(arg, c)
+				i++
+			}
+		case '\'':
+			i++
+			for {
+				if i >= len(line) {
+					return nil, errors.New("unbalanced quotes in inline command")
+				}
+				c := line[i]
+				if c == '\'' {
+					i++
+					break
+				}
+				if c == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					c = '\''
+				}
+				arg = append(arg, c)
+				i++
+			}
+		default:
+			start := i
+			for i < len(line) && !isSpace(line[i]) {
+				i++
+			}
+			arg = bytes.Clone(line[start:i])
+		}
+		// closing quote must be followed by a space or nothing
+		if i < len(line) && !isSpace(line[i]) {
+			return nil, errors.New("closing quote must be followed by a space")
+		}
+		if arg == nil {
+			arg = []byte{}
+		}
+		args = append(args, arg)
+	}
+}
+
+// ParseAOFCommands reads commands of aof from reader and passes them to cb until reader returns io.EOF,
+// cb returns false to stop. Both RESP2 multibulk and inline commands are supported. Reader is read in small chunks,
+// so it could be a pipe or socket receiving the aof. An error wrapping io.ErrUnexpectedEOF is returned if the last
+// command is incomplete, e.g. aof is truncated
+func ParseAOFCommands(reader io.Reader, cb func(cmdLine CmdLine) bool) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	r := &respReader{input: bufio.NewReader(reader)}
+	for {
+		cmdLine, err := r.readCommand()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read aof command failed: %w", err)
+		}
+		if !cb(cmdLine) {
+			return nil
+		}
+	}
+}
+
+// ParseAOF reads aof from reader, which may begin with a rdb preamble (aof-use-rdb-preamble yes). Objects of
+// the preamble are passed to objectCb, then the command tail appended after it is passed to cmdCb.
+// An aof without preamble is passed to cmdCb only. Options are applied to objects of the preamble
+func ParseAOF(reader io.Reader, objectCb func(object model.RedisObject) bool, cmdCb func(cmdLine CmdLine) bool,
+	options ...interface{}) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	// decoder reads through input directly since it is large enough, so the tail is left in input
+	input := bufio.NewReader(reader)
+	magic, err := input.Peek(5)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("read aof failed: %v", err)
+	}
+	if string(magic) == "REDIS" {
+		var dec decoder = core.NewDecoder(input)
+		if dec, err = wrapDecoder(dec, options...); err != nil {
+			return err
+		}
+		stopped := false
+		err = dec.Parse(func(object model.RedisObject) bool {
+			stopped = !objectCb(object)
+			return !stopped
+		})
+		if err != nil {
+			return err
+		}
+		if stopped {
+			return nil
+		}
+	}
+	return ParseAOFCommands(input, cmdCb)
+}
@@ -0,0 +1,96 @@
+package helper
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestParseAOF(t *testing.T) {
+	buf := bytes.NewBuffer(buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 1, 0))
+		check(enc.WriteStringObject("base", []byte("1")))
+	}))
+	buf.Write(CmdLinesToResp([]CmdLine{
+		{[]byte("SELECT"), []byte("0")},
+		{[]byte("SET"), []byte("a"), []byte("1\r\n2")},
+		{[]byte("EXPIRE"), []byte("a"), []byte("100")},
+		{[]byte("SELECT"), []byte("1")},
+	}))
+	buf.WriteString("SET b \"x y\\x41\\n\" \r\n\r\n")
+	buf.WriteString("EXPIRE 'b' 200\n")
+	buf.Write(CmdLinesToResp([]CmdLine{
+		{[]byte("SET"), []byte("c"), {}},
+	}))
+	expect := []string{
+		"SELECT 0",
+		"SET a 1\r\n2",
+		"EXPIRE a 100",
+		"SELECT 1",
+		"SET b x yA\n",
+		"EXPIRE b 200",
+		"SET c ",
+	}
+	data := buf.Bytes()
+	// the tail may arrive in small chunks, e.g. from a pipe
+	for _, reader := range []io.Reader{bytes.NewReader(data), iotest.OneByteReader(bytes.NewReader(data))} {
+		var keys []string
+		var cmds []string
+		err := ParseAOF(reader, func(object model.RedisObject) bool {
+			keys = append(keys, object.GetKey())
+			return true
+		}, func(cmdLine CmdLine) bool {
+			cmds = append(cmds, string(bytes.Join(cmdLine, []byte(" "))))
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 1 || keys[0] != "base" {
+			t.Errorf("wrong preamble objects: %v", keys)
+		}
+		if strings.Join(cmds, "|") != strings.Join(expect, "|") {
+			t.Errorf("expect commands %q, actual %q", expect, cmds)
+		}
+	}
+
+	// aof without preamble
+	count := 0
+	err := ParseAOF(strings.NewReader("*1\r\n$4\r\nPING\r\n"), func(object model.RedisObject) bool {
+		t.Error("unexpected object")
+		return true
+	}, func(cmdLine CmdLine) bool {
+		count++
+		return true
+	})
+	if err != nil || count != 1 {
+		t.Errorf("expect 1 command, actual %d, err: %v", count, err)
+	}
+
+	// truncated tail
+	for _, tail := range []string{"*2\r\n$3\r\nGET\r\n", "*2\r\n$3\r\nGET\r\n$1\r\na", "SET a"} {
+		err = ParseAOFCommands(strings.NewReader(tail), func(cmdLine CmdLine) bool { return true })
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%q: expect unexpected EOF, actual %v", tail, err)
+		}
+	}
+	for _, bad := range []string{"*x\r\n", "*1\r\n+OK\r\n", "*1\r\n$1\r\nab\r\n", "SET \"a\r\n", "SET \"a\"b\r\n"} {
+		err = ParseAOFCommands(strings.NewReader(bad), func(cmdLine CmdLine) bool { return true })
+		if err == nil || errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%q: expect protocol error, actual %v", bad, err)
+		}
+	}
+	// bulk length is checked before allocating
+	for _, bad := range []string{"*1\r\n$536870913\r\n", "*1\r\n$9223372036854775807\r\nPING\r\n", "*1\r\n$-2\r\n"} {
+		err = ParseAOFCommands(strings.NewReader(bad), func(cmdLine CmdLine) bool { return true })
+		if err == nil || !strings.Contains(err.Error(), "illegal bulk length") {
+			t.Errorf("%q: expect illegal bulk length, actual %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package helper
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/hdt3213/rdb/bytefmt"
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// maxElementNameLen limits length of set and sorted set members in report, since the member itself may be huge
+const maxElementNameLen = 64
+
+// bigElement is an element of a collection found by FindBiggestElements
+type bigElement struct {
+	db      int
+	key     string
+	typ     string
+	element string
+	size    int
+}
+
+func (e *bigElement) GetSize() int {
+	return e.size
+}
+
+// elementName returns name of element in report, long names are truncated and copied so that values are not retained
+func elementName(name string) string {
+	if len(name) > maxElementNameLen {
+		return strings.Clone(name[:maxElementNameLen]) + "..."
+	}
+	return strings.Clone(name)
+}
+
+// FindBiggestElements reads rdb and writes the largest N elements of lists, sets, hashes, sorted sets and streams
+// into out as csv, so a collection with few elements but a huge one could be pinpointed. Element is index of list,
+// member of set and sorted set (truncated to 64 bytes), field of hash and message id of stream. Size is bytes of
+// the element: value of list, member of set, field and value of hash, member and score of sorted set,
+// and fields and values of stream message
+func FindBiggestElements(reader io.Reader, topN int, out io.Writer, options ...interface{}) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	if out == nil {
+		return errors.New("output writer is required")
+	}
+	if topN <= 0 {
+		return errors.New("n must greater than 0")
+	}
+	var dec decoder = core.NewDecoder(reader)
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	top := newToplist(topN)
+	err = dec.Parse(func(object model.RedisObject) bool {
+		add := func(size int, name func() string) {
+			if len(top.list) == topN && size <= top.list[topN-1].GetSize() {
+				return
+			}
+			top.add(&bigElement{
+				db:      object.GetDBIndex(),
+				key:     object.GetKey(),
+				typ:     object.GetType(),
+				element: name(),
+				size:    size,
+			})
+		}
+		switch o := object.(type) {
+		case *model.ListObject:
+			for i, v := range o.Values {
+				add(len(v), func() string { return strconv.Itoa(i) })
+			}
+		case *model.SetObject:
+			for _, member := range o.Members {
+				add(len(member), func() string { return elementName(string(member)) })
+			}
+		case *model.HashObject:
+			for field, v := range o.Hash {
+				add(len(field)+len(v), func() string { return elementName(field) })
+			}
+		case *model.ZSetObject:
+			for _, entry := range o.Entries {
+				add(len(entry.Member)+8, func() string { return elementName(entry.Member) })
+			}
+		case *model.StreamObject:
+			for _, entry := range o.Entries {
+				for _, msg := range entry.Msgs {
+					if msg.Deleted {
+						continue
+					}
+					size := 0
+					for field, v := range msg.Fields {
+						size += len(field) + len(v)
+					}
+					add(size, func() string { return msg.Id.String() })
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, "database,key,type,element,size,size_readable\n")
+	if err != nil {
+		return fmt.Errorf("write header failed: %v", err)
+	}
+	csvWriter := csv.NewWriter(out)
+	for _, e := range top.list {
+		element := e.(*bigElement)
+		err = csvWriter.Write([]string{
+			strconv.Itoa(element.db),
+			element.key,
+			element.typ,
+			element.element,
+			strconv.Itoa(element.size),
+			bytefmt.FormatSize(uint64(element.size)),
+		})
+		if err != nil {
+			return fmt.Errorf("csv write failed: %v", err)
+		}
+	}
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		return fmt.Errorf("csv write failed: %v", err)
+	}
+	return nil
+}
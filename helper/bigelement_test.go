@@ -0,0 +1,94 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestFindBiggestElements(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := encoder.NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 4, 0); err != nil {
+		t.Fatal(err)
+	}
+	hash := map[string][]byte{"huge": bytes.Repeat([]byte("x"), 10*1024*1024)}
+	for i := 0; i < 10; i++ {
+		hash["f"+strconv.Itoa(i)] = []byte("v")
+	}
+	if err := enc.WriteHashMapObject("h", hash); err != nil {
+		t.Fatal(err)
+	}
+	values := make([][]byte, 1000)
+	for i := range values {
+		values[i] = bytes.Repeat([]byte("y"), 100)
+	}
+	values[500] = bytes.Repeat([]byte("y"), 2000)
+	if err := enc.WriteListObject("l", values); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteSetObject("s", [][]byte{[]byte(strings.Repeat("m", 1000))}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteZSetObject("z", []*model.ZSetEntry{{Member: "a", Score: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := FindBiggestElements(bytes.NewReader(buf.Bytes()), 3, out); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"database", "key", "type", "element", "size"},
+		{"0", "h", "hash", "huge", strconv.Itoa(4 + 10*1024*1024)},
+		{"0", "l", "list", "500", "2000"},
+		{"0", "s", "set", strings.Repeat("m", maxElementNameLen) + "...", "1000"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expect %d rows, actual %v", len(expected), records)
+	}
+	for i, e := range expected {
+		for j := range e {
+			if records[i][j] != e[j] {
+				t.Errorf("expect %v, actual %v", e, records[i][:len(e)])
+				break
+			}
+		}
+	}
+
+	rdbFile, err := os.Open("../cases/stream_listpacks_2.rdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	out.Reset()
+	if err = FindBiggestElements(rdbFile, 1, out); err != nil {
+		t.Fatal(err)
+	}
+	records, err = csv.NewReader(out).ReadAll()
+	if err != nil || len(records) != 2 || records[1][2] != "stream" || !strings.Contains(records[1][3], "-") {
+		t.Errorf("expect a stream message, actual %v, %v", records, err)
+	}
+
+	if err = FindBiggestElements(bytes.NewReader(buf.Bytes()), 0, out); err == nil {
+		t.Error("expect error when n is 0")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 
@@ -28,7 +29,11 @@ func FindBiggestKeys(rdbFilename string, topN int, output *os.File, options ...i
 	defer func() {
 		_ = rdbFile.Close()
 	}()
-	var dec decoder = core.NewDecoder(rdbFile)
+	coreDec := core.NewDecoder(rdbFile)
+	if err = applyArchBits(coreDec, options); err != nil {
+		return err
+	}
+	var dec decoder = coreDec
 	if dec, err = wrapDecoder(dec, options...); err != nil {
 		return err
 	}
@@ -40,7 +45,7 @@ func FindBiggestKeys(rdbFilename string, topN int, output *os.File, options ...i
 	if err != nil {
 		return err
 	}
-	_, err = output.WriteString("database,key,type,size,size_readable,element_count\n")
+	_, err = output.WriteString("database,key,type,size,size_readable,element_count,key_bytes,key_runes\n")
 	if err != nil {
 		return fmt.Errorf("write header failed: %v", err)
 	}
@@ -55,6 +60,8 @@ func FindBiggestKeys(rdbFilename string, topN int, output *os.File, options ...i
 			strconv.Itoa(object.GetSize()),
 			bytefmt.FormatSize(uint64(object.GetSize())),
 			strconv.Itoa(object.GetElemCount()),
+			strconv.Itoa(len(object.GetKey())),
+			strconv.Itoa(keyRunes(object.GetKey())),
 		})
 		if err != nil {
 			return fmt.Errorf("csv write failed: %v", err)
@@ -62,3 +69,82 @@ func FindBiggestKeys(rdbFilename string, topN int, output *os.File, options ...i
 	}
 	return nil
 }
+
+// objectLocation records where an object is in rdb file, so it could be decoded again without being retained
+type objectLocation struct {
+	db     int
+	offset int64
+	size   int
+}
+
+func (l *objectLocation) GetSize() int {
+	return l.size
+}
+
+// ParseSortedBySize passes the largest topN objects in rdb to cb in descending order of size.
+// The first pass records offset and size of objects, then the top N objects are decoded again by their offsets,
+// so at most topN locations are retained instead of objects. cb returns false to stop.
+// Options like WithRegexOption and WithArchBits are applied to the first pass
+func ParseSortedBySize(reader io.ReadSeeker, topN int, cb func(obj model.RedisObject) bool, options ...interface{}) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	if topN <= 0 {
+		return errors.New("n must greater than 0")
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	coreDec := core.NewDecoder(reader).WithByteRanges()
+	if err := applyArchBits(coreDec, options); err != nil {
+		return err
+	}
+	var dec decoder = coreDec
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	top := newToplist(topN)
+	err = dec.Parse(func(object model.RedisObject) bool {
+		top.add(&objectLocation{
+			db:     object.GetDBIndex(),
+			offset: baseObject(object).StartOffset,
+			size:   object.GetSize(),
+		})
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	readerAt, _ := reader.(io.ReaderAt)
+	for _, x := range top.list {
+		location := x.(*objectLocation)
+		var object model.RedisObject
+		if readerAt != nil {
+			object, err = DecodeObjectAt(readerAt, location.offset, location.db)
+		} else {
+			object, err = decodeObjectBySeek(reader, location.offset, location.db)
+		}
+		if err != nil {
+			return err
+		}
+		// keep size of the first pass, which may be evaluated WithArchBits
+		baseObject(object).Size = location.size
+		if !cb(object) {
+			break
+		}
+	}
+	return nil
+}
+
+// decodeObjectBySeek decodes the object at offset of a reader which is not an io.ReaderAt
+func decodeObjectBySeek(reader io.ReadSeeker, offset int64, db int) (model.RedisObject, error) {
+	if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	obj, err := core.NewDecoder(reader).ParseObject(db)
+	if err != nil {
+		return nil, fmt.Errorf("decode object at %d failed: %w", offset, err)
+	}
+	return obj, nil
+}
@@ -1,6 +1,7 @@
 package helper
 
 import (
+	"io"
 	"math/rand"
 	"os"
 	"path/filepath"
@@ -9,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/hdt3213/rdb/model"
+	"github.com/hdt3213/rdb/parser"
 )
 
 func TestTopList(t *testing.T) {
@@ -131,3 +133,58 @@ func TestFindBiggestKeyWithRegex(t *testing.T) {
 		t.Error("expect error")
 	}
 }
+
+// seekOnlyReader hides io.ReaderAt of *os.File
+type seekOnlyReader struct {
+	io.ReadSeeker
+}
+
+func TestParseSortedBySize(t *testing.T) {
+	rdbFile, err := os.Open("../cases/memory.rdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	var sizes []int
+	err = parser.NewDecoder(rdbFile).Parse(func(o parser.RedisObject) bool {
+		sizes = append(sizes, o.GetSize())
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+	topN := 5
+	for _, reader := range []io.ReadSeeker{rdbFile, &seekOnlyReader{rdbFile}} {
+		var objects []model.RedisObject
+		err = ParseSortedBySize(reader, topN, func(obj model.RedisObject) bool {
+			objects = append(objects, obj)
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(objects) != topN {
+			t.Fatalf("expect %d objects, actual %d", topN, len(objects))
+		}
+		for i, obj := range objects {
+			if obj.GetSize() != sizes[i] {
+				t.Errorf("expect size %d at %d, actual %d of %s", sizes[i], i, obj.GetSize(), obj.GetKey())
+			}
+			if obj.GetElemCount() == 0 && obj.GetType() != model.StringType {
+				t.Errorf("%s is not fully decoded", obj.GetKey())
+			}
+		}
+	}
+
+	count := 0
+	err = ParseSortedBySize(rdbFile, topN, func(obj model.RedisObject) bool {
+		count++
+		return false
+	})
+	if err != nil || count != 1 {
+		t.Errorf("expect stop after the first object, actual %d, %v", count, err)
+	}
+}
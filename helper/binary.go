@@ -0,0 +1,110 @@
+package helper
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+// BinaryEncodingOption tells exporters how to render values
+type BinaryEncodingOption string
+
+// WithBinaryEncoding renders values, hash fields and set/zset members as text in the given encoding: hex (lowercase)
+// or base64 (standard encoding), so binary values such as protobuf survive json and csv exports unmangled.
+// It is respected by ToJsons, ToCSV, ToParquet and ToMap. Keys, stream and module values are not encoded
+func WithBinaryEncoding(encoding string) BinaryEncodingOption {
+	return BinaryEncodingOption(encoding)
+}
+
+// binaryEncoder returns encode function of BinaryEncodingOption in options, or nil if there is none
+func binaryEncoder(options []interface{}) (func([]byte) string, error) {
+	var encoding BinaryEncodingOption
+	for _, opt := range options {
+		if o, ok := opt.(BinaryEncodingOption); ok {
+			encoding = o
+		}
+	}
+	switch encoding {
+	case "":
+		return nil, nil
+	case "hex":
+		return hex.EncodeToString, nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString, nil
+	}
+	return nil, fmt.Errorf("unsupported binary encoding: %s", encoding)
+}
+
+// encodeBinary returns a copy of object whose values are encoded by encode, object itself is not modified
+func encodeBinary(object model.RedisObject, encode func([]byte) string) model.RedisObject {
+	encodeAll := func(values [][]byte) [][]byte {
+		result := make([][]byte, len(values))
+		for i, v := range values {
+			result[i] = []byte(encode(v))
+		}
+		return result
+	}
+	switch o := object.(type) {
+	case *model.StringObject:
+		return &model.StringObject{BaseObject: o.BaseObject, Value: []byte(encode(o.Value))}
+	case *model.ListObject:
+		return &model.ListObject{BaseObject: o.BaseObject, Values: encodeAll(o.Values)}
+	case *model.SetObject:
+		return &model.SetObject{BaseObject: o.BaseObject, Members: encodeAll(o.Members)}
+	case *model.HashObject:
+		hash := &model.HashObject{BaseObject: o.BaseObject, Hash: make(map[string][]byte, len(o.Hash))}
+		for field, v := range o.Hash {
+			hash.Hash[encode([]byte(field))] = []byte(encode(v))
+		}
+		if o.FieldExpirations != nil {
+			hash.FieldExpirations = make(map[string]int64, len(o.FieldExpirations))
+			for field, expire := range o.FieldExpirations {
+				hash.FieldExpirations[encode([]byte(field))] = expire
+			}
+		}
+		if o.Fields != nil {
+			hash.Fields = make([]model.HashField, len(o.Fields))
+			for i, field := range o.Fields {
+				hash.Fields[i] = model.HashField{Name: encode([]byte(field.Name)), Value: []byte(encode(field.Value))}
+			}
+		}
+		return hash
+	case *model.ZSetObject:
+		zset := &model.ZSetObject{BaseObject: o.BaseObject, Entries: make([]*model.ZSetEntry, len(o.Entries))}
+		for i, entry := range o.Entries {
+			zset.Entries[i] = &model.ZSetEntry{
+				Member:    encode([]byte(entry.Member)),
+				Score:     entry.Score,
+				ScoreText: entry.ScoreText,
+			}
+		}
+		return zset
+	}
+	return object
+}
+
+// binaryDecoder encodes values of objects of dec
+type binaryDecoder struct {
+	dec    decoder
+	encode func([]byte) string
+}
+
+func (d *binaryDecoder) Parse(cb func(object model.RedisObject) bool) error {
+	return d.dec.Parse(func(object model.RedisObject) bool {
+		return cb(encodeBinary(object, d.encode))
+	})
+}
+
+// withBinaryEncoding wraps dec if there is a BinaryEncodingOption in options
+func withBinaryEncoding(dec decoder, options []interface{}) (decoder, error) {
+	encode, err := binaryEncoder(options)
+	if err != nil {
+		return nil, err
+	}
+	if encode == nil {
+		return dec, nil
+	}
+	return &binaryDecoder{dec: dec, encode: encode}, nil
+}
@@ -0,0 +1,105 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestBinaryEncoding(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := encoder.NewEncoder(buf).EnableCompress().SetHashZipListOpt(1, 1)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteStringObject("bin", []byte{0xde, 0xad, 0xbe, 0xef}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteHashMapObject("hash", map[string][]byte{"\x01": {0xff}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := ToCSV(bytes.NewReader(buf.Bytes()), out, WithBinaryEncoding("hex")); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"database", "key", "type", "field", "value"},
+		{"0", "bin", "string", "", "deadbeef"},
+		{"0", "hash", "hash", "01", "ff"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expect %v, actual %v", expected, records)
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if records[i][j] != expected[i][j] {
+				t.Errorf("expect %v, actual %v", expected[i], records[i])
+				break
+			}
+		}
+	}
+
+	if err = os.MkdirAll("tmp", os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	srcRdb := filepath.Join("tmp", "binary.rdb")
+	if err = os.WriteFile(srcRdb, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, typed := range []bool{false, true} {
+		output := filepath.Join("tmp", "binary.json")
+		options := []interface{}{WithBinaryEncoding("hex")}
+		hashField := "hash"
+		if typed {
+			options = append(options, WithTypedJSON())
+			hashField = "value"
+		}
+		if err = ToJsons(srcRdb, output, options...); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var objects []map[string]interface{}
+		if err = json.Unmarshal(data, &objects); err != nil {
+			t.Fatal(err)
+		}
+		if len(objects) != 2 || objects[0]["value"] != "deadbeef" {
+			t.Fatalf("typed %v: expect deadbeef, actual %s", typed, data)
+		}
+		if hash, ok := objects[1][hashField].(map[string]interface{}); !ok || hash["01"] != "ff" {
+			t.Errorf("typed %v: wrong hash %s", typed, data)
+		}
+	}
+
+	str := &model.StringObject{BaseObject: &model.BaseObject{Key: "bin"}, Value: []byte{0xde, 0xad, 0xbe, 0xef}}
+	if m := ToMap(str, WithBinaryEncoding("base64")); m["value"] != "3q2+7w==" {
+		t.Errorf("expect base64 value, actual %v", m["value"])
+	}
+	if string(str.Value) != "\xde\xad\xbe\xef" {
+		t.Error("object should not be modified")
+	}
+
+	err = ToCSV(bytes.NewReader(buf.Bytes()), out, WithBinaryEncoding("octal"))
+	if err == nil || err.Error() != "unsupported binary encoding: octal" {
+		t.Errorf("expect unsupported encoding error, actual %v", err)
+	}
+}
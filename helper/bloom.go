@@ -0,0 +1,130 @@
+package helper
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// BloomFilter answers whether a key may be in a dump, it has no false negatives
+type BloomFilter struct {
+	bits []uint64
+	m    uint64 // number of bits
+	k    uint32 // number of hash functions
+}
+
+// bloomHash returns two independent hashes of key, the i-th hash function is h1 + i*h2
+func bloomHash(key string) (uint64, uint64) {
+	h := fnv.New128a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum(nil)
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+// newBloomFilter returns a filter of n keys with false positive rate fpRate
+func newBloomFilter(n int, fpRate float64) *BloomFilter {
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint32(1)
+	if n > 0 {
+		k = uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	}
+	return &BloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+func (f *BloomFilter) add(h1, h2 uint64) {
+	for i := uint32(0); i < f.k; i++ {
+		bit := (h1 + uint64(i)*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Contains returns false if key is definitely not in the dump, true if it probably is
+func (f *BloomFilter) Contains(key string) bool {
+	h1, h2 := bloomHash(key)
+	for i := uint32(0); i < f.k; i++ {
+		bit := (h1 + uint64(i)*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalBinary encodes filter as number of hash functions (4 bytes), number of bits (8 bytes) and the bits,
+// integers are big endian
+func (f *BloomFilter) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 12+8*len(f.bits))
+	binary.BigEndian.PutUint32(data, f.k)
+	binary.BigEndian.PutUint64(data[4:], f.m)
+	for i, word := range f.bits {
+		binary.BigEndian.PutUint64(data[12+8*i:], word)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes filter encoded by MarshalBinary
+func (f *BloomFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < 12 {
+		return errors.New("bloom filter is too short")
+	}
+	k := binary.BigEndian.Uint32(data)
+	m := binary.BigEndian.Uint64(data[4:])
+	if k == 0 || m == 0 || uint64(len(data)-12) != (m+63)/64*8 {
+		return fmt.Errorf("illegal bloom filter of %d bits, %d hash functions and %d bytes", m, k, len(data))
+	}
+	f.k = k
+	f.m = m
+	f.bits = make([]uint64, (m+63)/64)
+	for i := range f.bits {
+		f.bits[i] = binary.BigEndian.Uint64(data[12+8*i:])
+	}
+	return nil
+}
+
+// BuildKeyBloom reads keys of all databases from rdb and returns a bloom filter of them with false positive rate
+// fpRate, so that membership of a key in many dumps could be checked without parsing them again.
+// Values are skipped without decoding. Persist the filter by MarshalBinary
+func BuildKeyBloom(reader io.Reader, fpRate float64, options ...interface{}) (*BloomFilter, error) {
+	if reader == nil {
+		return nil, errors.New("src reader is required")
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		return nil, errors.New("false positive rate should be between 0 and 1")
+	}
+	var dec decoder = core.NewDecoder(reader)
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return nil, err
+	}
+	// filter is sized by number of keys, so hashes are kept until all keys are read
+	var hashes [][2]uint64
+	dec = withKeyFilter(dec, func(key string) bool {
+		h1, h2 := bloomHash(key)
+		hashes = append(hashes, [2]uint64{h1, h2})
+		return false
+	})
+	err = dec.Parse(func(object model.RedisObject) bool {
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	filter := newBloomFilter(len(hashes), fpRate)
+	for _, h := range hashes {
+		filter.add(h[0], h[1])
+	}
+	return filter, nil
+}
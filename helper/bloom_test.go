@@ -0,0 +1,109 @@
+package helper
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestBuildKeyBloom(t *testing.T) {
+	const n = 2000
+	buf := bytes.NewBuffer(nil)
+	enc := encoder.NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, n, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := enc.WriteListObject("key:"+strconv.Itoa(i), [][]byte{[]byte("v")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+
+	const fpRate = 0.01
+	filter, err := BuildKeyBloom(bytes.NewReader(buf.Bytes()), fpRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if !filter.Contains("key:" + strconv.Itoa(i)) {
+			t.Fatalf("false negative of key:%d", i)
+		}
+	}
+	falsePositives := 0
+	const queries = 100000
+	for i := 0; i < queries; i++ {
+		if filter.Contains("absent:" + strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / queries; rate > 2*fpRate {
+		t.Errorf("expect false positive rate about %v, actual %v", fpRate, rate)
+	}
+
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &BloomFilter{}
+	if err = loaded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		key := "key:" + strconv.Itoa(i*2)
+		absent := "absent:" + strconv.Itoa(i)
+		if !loaded.Contains(key) || loaded.Contains(absent) != filter.Contains(absent) {
+			t.Fatalf("loaded filter differs at %s or %s", key, absent)
+		}
+	}
+	if err = loaded.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Error("expect error for truncated filter")
+	}
+
+	if _, err = BuildKeyBloom(bytes.NewReader(buf.Bytes()), 1); err == nil {
+		t.Error("expect error for illegal false positive rate")
+	}
+}
+
+func TestBuildKeyBloomFixture(t *testing.T) {
+	objects, err := ReadAll(mustOpen(t, "../cases/memory.rdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := BuildKeyBloom(mustOpen(t, "../cases/memory.rdb"), 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range objects {
+		if o.GetType() == model.AuxType || o.GetType() == model.DBSizeType {
+			continue
+		}
+		if !filter.Contains(o.GetKey()) {
+			t.Errorf("false negative of %s", o.GetKey())
+		}
+	}
+	if filter.Contains("definitely not a key of memory.rdb") {
+		t.Error("unexpected positive")
+	}
+
+	filter, err = BuildKeyBloom(mustOpen(t, "../cases/memory.rdb"), 0.001, WithRegexOption("^l"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range objects {
+		if o.GetType() == model.AuxType || o.GetType() == model.DBSizeType {
+			continue
+		}
+		if o.GetKey()[0] == 'l' && !filter.Contains(o.GetKey()) {
+			t.Errorf("false negative of %s", o.GetKey())
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package helper
+
+import (
+	"errors"
+	"io"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// DBBudget is estimated memory usage of a database compared with budget
+type DBBudget struct {
+	// Used is sum of estimated memory of keys in bytes
+	Used int64
+	// Overage is bytes exceeding budget, it is 0 if the database fits
+	Overage int64
+}
+
+// Fits returns whether the database is within budget
+func (b *DBBudget) Fits() bool {
+	return b.Overage == 0
+}
+
+// BudgetReport reads rdb from reader and returns estimated memory usage of each database compared with
+// perDBBudgetBytes, which is the same estimation as memory report. Databases without keys are absent
+func BudgetReport(reader io.Reader, perDBBudgetBytes int64, options ...interface{}) (map[int]*DBBudget, error) {
+	if reader == nil {
+		return nil, errors.New("src reader is required")
+	}
+	if perDBBudgetBytes < 0 {
+		return nil, errors.New("budget should not be negative")
+	}
+	coreDec := core.NewDecoder(reader)
+	if err := applyArchBits(coreDec, options); err != nil {
+		return nil, err
+	}
+	var dec decoder = coreDec
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return nil, err
+	}
+	report := make(map[int]*DBBudget)
+	err = dec.Parse(func(object model.RedisObject) bool {
+		budget := report[object.GetDBIndex()]
+		if budget == nil {
+			budget = &DBBudget{}
+			report[object.GetDBIndex()] = budget
+		}
+		budget.Used += int64(object.GetSize())
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, budget := range report {
+		if budget.Used > perDBBudgetBytes {
+			budget.Overage = budget.Used - perDBBudgetBytes
+		}
+	}
+	return report, nil
+}
+
+// BudgetCheck reads rdb from reader and returns whether estimated memory usage of each database is within
+// perDBBudgetBytes, use BudgetReport for the overage
+func BudgetCheck(reader io.Reader, perDBBudgetBytes int64, options ...interface{}) (map[int]bool, error) {
+	report, err := BudgetReport(reader, perDBBudgetBytes, options...)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int]bool, len(report))
+	for db, budget := range report {
+		result[db] = budget.Fits()
+	}
+	return result, nil
+}
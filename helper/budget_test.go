@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestBudgetCheck(t *testing.T) {
+	data := buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 2, 0))
+		check(enc.WriteStringObject("big1", []byte(strings.Repeat("a", 4096))))
+		check(enc.WriteStringObject("big2", []byte(strings.Repeat("b", 4096))))
+		check(enc.WriteDBHeader(3, 1, 0))
+		check(enc.WriteStringObject("small", []byte("c")))
+	})
+	sizes := make(map[int]int64)
+	objects, err := ReadAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range objects {
+		if o.GetType() != model.AuxType && o.GetType() != model.DBSizeType {
+			sizes[o.GetDBIndex()] += int64(o.GetSize())
+		}
+	}
+
+	const budget = 4096
+	fits, err := BudgetCheck(bytes.NewReader(data), budget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fits) != 2 || fits[0] || !fits[3] {
+		t.Errorf("expect db0 exceeds and db3 fits, actual %v", fits)
+	}
+	report, err := BudgetReport(bytes.NewReader(data), budget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report[0].Used != sizes[0] || report[0].Overage != sizes[0]-budget {
+		t.Errorf("db0: expect used %d, actual %+v", sizes[0], report[0])
+	}
+	if report[3].Used != sizes[3] || report[3].Overage != 0 {
+		t.Errorf("db3: expect used %d, actual %+v", sizes[3], report[3])
+	}
+
+	if _, err = BudgetCheck(bytes.NewReader(data), -1); err == nil {
+		t.Error("expect error for negative budget")
+	}
+}
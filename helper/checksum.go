@@ -0,0 +1,247 @@
+package helper
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// objectHasher writes values into hash with length prefix, so that different values never have the same input
+type objectHasher struct {
+	h   hash.Hash
+	buf [8]byte
+}
+
+func (w *objectHasher) num(n uint64) {
+	binary.BigEndian.PutUint64(w.buf[:], n)
+	_, _ = w.h.Write(w.buf[:])
+}
+
+func (w *objectHasher) bytes(b []byte) {
+	w.num(uint64(len(b)))
+	_, _ = w.h.Write(b)
+}
+
+func (w *objectHasher) str(s string) {
+	w.bytes([]byte(s))
+}
+
+func (w *objectHasher) id(id *model.StreamId) {
+	if id == nil {
+		id = &model.StreamId{}
+	}
+	w.num(id.Ms)
+	w.num(id.Sequence)
+}
+
+func (w *objectHasher) sortedBytes(values [][]byte) {
+	sorted := make([][]byte, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+	w.num(uint64(len(sorted)))
+	for _, v := range sorted {
+		w.bytes(v)
+	}
+}
+
+func (w *objectHasher) stringMap(m map[string]string) {
+	fields := make([]string, 0, len(m))
+	for field := range m {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	w.num(uint64(len(fields)))
+	for _, field := range fields {
+		w.str(field)
+		w.str(m[field])
+	}
+}
+
+func (w *objectHasher) stream(stream *model.StreamObject) {
+	w.id(stream.LastId)
+	count := 0
+	for _, entry := range stream.Entries {
+		for _, msg := range entry.Msgs {
+			if !msg.Deleted {
+				count++
+			}
+		}
+	}
+	w.num(uint64(count))
+	for _, entry := range stream.Entries {
+		for _, msg := range entry.Msgs {
+			if msg.Deleted {
+				continue
+			}
+			w.id(msg.Id)
+			w.stringMap(msg.Fields)
+		}
+	}
+	groups := make([]*model.StreamGroup, len(stream.Groups))
+	copy(groups, stream.Groups)
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	w.num(uint64(len(groups)))
+	for _, group := range groups {
+		w.str(group.Name)
+		w.id(group.LastId)
+		w.num(uint64(len(group.Pending)))
+		for _, nack := range group.Pending {
+			w.id(nack.Id)
+			w.str(nack.Consumer)
+			w.num(nack.DeliveryCount)
+		}
+		consumers := make([]string, 0, len(group.Consumers))
+		for _, consumer := range group.Consumers {
+			consumers = append(consumers, consumer.Name)
+		}
+		sort.Strings(consumers)
+		w.num(uint64(len(consumers)))
+		for _, consumer := range consumers {
+			w.str(consumer)
+		}
+	}
+}
+
+// ObjectHash returns sha256 of logical content of object, which does not depend on encoding, compression or order
+// of set members and hash fields, so it is the same for an object restored from dump into another redis.
+// Expirations of keys and hash fields, and delivery time of stream pending messages are not part of content
+func ObjectHash(object model.RedisObject) ([]byte, error) {
+	w := &objectHasher{h: sha256.New()}
+	w.str(object.GetType())
+	switch o := object.(type) {
+	case *model.StringObject:
+		w.bytes(o.Value)
+	case *model.ListObject:
+		w.num(uint64(len(o.Values)))
+		for _, v := range o.Values {
+			w.bytes(v)
+		}
+	case *model.SetObject:
+		w.sortedBytes(o.Members)
+	case *model.HashObject:
+		fields := make([]string, 0, len(o.Hash))
+		for field := range o.Hash {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		w.num(uint64(len(fields)))
+		for _, field := range fields {
+			w.str(field)
+			w.bytes(o.Hash[field])
+		}
+	case *model.ZSetObject:
+		entries := make([]*model.ZSetEntry, len(o.Entries))
+		copy(entries, o.Entries)
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Member < entries[j].Member
+		})
+		w.num(uint64(len(entries)))
+		for _, e := range entries {
+			w.str(e.Member)
+			w.num(math.Float64bits(e.Score))
+		}
+	case *model.StreamObject:
+		w.stream(o)
+	case *model.ModuleTypeObject:
+		data, err := json.Marshal(o.Value)
+		if err != nil {
+			return nil, fmt.Errorf("marshal module value of %s failed: %v", o.Key, err)
+		}
+		w.bytes(data)
+	default:
+		return nil, fmt.Errorf("unsupported object type: %s", object.GetType())
+	}
+	return w.h.Sum(nil), nil
+}
+
+// manifestKey quotes key in Go syntax if it has spaces, quotes or unprintable characters, so each line has two fields
+func manifestKey(key string) string {
+	for _, r := range key {
+		if r == utf8.RuneError || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return strconv.Quote(key)
+		}
+	}
+	return key
+}
+
+type checksumLine struct {
+	db   int
+	key  string
+	hash string
+}
+
+// ChecksumManifest reads rdb from reader and writes `key <hex of ObjectHash>` lines into out, lines are sorted by key
+// and keys of each database follow a `# db<index>` line. Manifests of logically equal dumps are identical, so that
+// diff of manifests of a backup and the dump of restored instance shows keys not restored intact
+func ChecksumManifest(reader io.Reader, out io.Writer, options ...interface{}) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	if out == nil {
+		return errors.New("output writer is required")
+	}
+	var dec decoder = core.NewDecoder(reader)
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	var lines []checksumLine
+	var hashErr error
+	err = dec.Parse(func(object model.RedisObject) bool {
+		var sum []byte
+		sum, hashErr = ObjectHash(object)
+		if hashErr != nil {
+			return false
+		}
+		lines = append(lines, checksumLine{
+			db:   object.GetDBIndex(),
+			key:  object.GetKey(),
+			hash: hex.EncodeToString(sum),
+		})
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if hashErr != nil {
+		return hashErr
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].db != lines[j].db {
+			return lines[i].db < lines[j].db
+		}
+		return lines[i].key < lines[j].key
+	})
+	writer := bufio.NewWriter(out)
+	currentDB := -1
+	for _, line := range lines {
+		if line.db != currentDB {
+			currentDB = line.db
+			_, _ = fmt.Fprintf(writer, "# db%d\n", currentDB) // error will be returned by Flush
+		}
+		_, _ = fmt.Fprintf(writer, "%s %s\n", manifestKey(line.key), line.hash)
+	}
+	if err = writer.Flush(); err != nil {
+		return fmt.Errorf("write manifest failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package helper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+type checksumFixture struct {
+	compact bool // compact dump stores small values in ziplist/listpack and is not compressed
+	reorder bool
+	value   string
+}
+
+func (f checksumFixture) dump(t *testing.T) []byte {
+	long := strings.Repeat(f.value, 30)
+	members := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	entries := []*model.ZSetEntry{{Member: "x", Score: 1}, {Member: "y", Score: 2}}
+	if f.reorder {
+		members = [][]byte{[]byte("c"), []byte("a"), []byte("b")}
+		entries = []*model.ZSetEntry{{Member: "y", Score: 2}, {Member: "x", Score: 1}}
+	}
+	return buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		if !f.compact {
+			enc.EnableCompress().SetHashZipListOpt(1, 1).SetZSetZipListOpt(1, 1).SetListZipListOpt(1, 1)
+		}
+		writeObjects := []func() error{
+			func() error { return enc.WriteStringObject("string", []byte(long)) },
+			func() error { return enc.WriteListObject("list", [][]byte{[]byte(long), []byte("2")}) },
+			func() error { return enc.WriteSetObject("set", members) },
+			func() error {
+				return enc.WriteHashMapObject("hash key", map[string][]byte{"f": []byte(long), "g": []byte("1")})
+			},
+			func() error { return enc.WriteZSetObject("zset", entries) },
+		}
+		if f.reorder {
+			for i, j := 0, len(writeObjects)-1; i < j; i, j = i+1, j-1 {
+				writeObjects[i], writeObjects[j] = writeObjects[j], writeObjects[i]
+			}
+		}
+		check(enc.WriteDBHeader(0, 5, 0))
+		for _, write := range writeObjects {
+			check(write())
+		}
+		check(enc.WriteDBHeader(1, 1, 0))
+		check(enc.WriteStringObject("string", []byte("db1")))
+	})
+}
+
+func TestChecksumManifest(t *testing.T) {
+	manifest := func(data []byte) string {
+		out := bytes.NewBuffer(nil)
+		if err := ChecksumManifest(bytes.NewReader(data), out); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	compact := checksumFixture{compact: true, value: "v"}.dump(t)
+	other := checksumFixture{reorder: true, value: "v"}.dump(t)
+	if bytes.Equal(compact, other) {
+		t.Fatal("dumps should be byte-different")
+	}
+	expect := manifest(compact)
+	if actual := manifest(other); actual != expect {
+		t.Errorf("manifests of logically equal dumps are different:\n%s\n%s", expect, actual)
+	}
+	lines := strings.Split(strings.TrimSuffix(expect, "\n"), "\n")
+	if len(lines) != 8 || lines[0] != "# db0" || lines[6] != "# db1" {
+		t.Fatalf("wrong manifest:\n%s", expect)
+	}
+	if !strings.HasPrefix(lines[1], `"hash key" `) || len(strings.Fields(lines[2])) != 2 ||
+		len(strings.Fields(lines[2])[1]) != 64 {
+		t.Errorf("wrong manifest line: %s %s", lines[1], lines[2])
+	}
+
+	changed := manifest(checksumFixture{compact: true, value: "w"}.dump(t))
+	changedLines := strings.Split(strings.TrimSuffix(changed, "\n"), "\n")
+	diff := 0
+	for i := range lines {
+		if lines[i] != changedLines[i] {
+			diff++
+		}
+	}
+	// string, list and hash contain the changed value
+	if diff != 3 {
+		t.Errorf("expect 3 changed lines, actual %d:\n%s", diff, changed)
+	}
+}
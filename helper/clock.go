@@ -0,0 +1,22 @@
+package helper
+
+import "time"
+
+// ClockOption sets the function returning current time, which is used to decide whether a key is expired
+type ClockOption func() time.Time
+
+// WithClock makes helpers use clock instead of time.Now as current time, e.g. to pin "now" in tests
+func WithClock(clock func() time.Time) ClockOption {
+	return clock
+}
+
+// nowFunc returns clock given by WithClock, or time.Now
+func nowFunc(options []interface{}) func() time.Time {
+	now := time.Now
+	for _, opt := range options {
+		if clock, ok := opt.(ClockOption); ok && clock != nil {
+			now = clock
+		}
+	}
+	return now
+}
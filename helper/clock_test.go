@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestWithClock(t *testing.T) {
+	expireAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 2, 1))
+		check(enc.WriteStringObject("session", []byte("1"), encoder.WithTTL(uint64(expireAt.UnixNano()/1e6))))
+		check(enc.WriteStringObject("persistent", []byte("1")))
+	})
+
+	before := expireAt.Add(-time.Minute)
+	objects, err := ReadAll(bytes.NewReader(data), WithNoExpiredOption(), WithClock(func() time.Time { return before }))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(objects) != 2 {
+		t.Errorf("session should not be expired before %v, actual %d keys", expireAt, len(objects))
+	}
+	after := expireAt.Add(time.Minute)
+	objects, err = ReadAll(bytes.NewReader(data), WithNoExpiredOption(), WithClock(func() time.Time { return after }))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(objects) != 1 || objects[0].GetKey() != "persistent" {
+		t.Errorf("session should be expired after %v", expireAt)
+	}
+
+	var session model.RedisObject
+	err = core.NewDecoder(bytes.NewReader(data)).WithDumpPayload().Parse(func(object model.RedisObject) bool {
+		session = object
+		return false
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	cmdLine := ObjectToRestoreCmd(session, WithClock(func() time.Time { return before }))
+	if cmdLine == nil || string(cmdLine[2]) != "60000" {
+		t.Errorf("wrong restore ttl: %v", cmdLine)
+	}
+}
@@ -0,0 +1,77 @@
+package helper
+
+import (
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// Collision is a key which exists in more than one database
+type Collision struct {
+	Key string
+	// DBs are databases having the key in ascending order
+	DBs []int
+	// Types are types of the key in each of DBs
+	Types []string
+}
+
+// FindCrossDBCollisions reads rdb from reader and returns keys existing in more than one database sorted by key,
+// which would clash if databases were flattened into one, e.g. before loading into redis cluster that only has db 0.
+// Empty result means databases could be merged without a namespace prefix
+func FindCrossDBCollisions(reader io.Reader, options ...interface{}) ([]Collision, error) {
+	if reader == nil {
+		return nil, errors.New("src reader is required")
+	}
+	var dec decoder = core.NewDecoder(reader)
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*Collision)
+	err = dec.Parse(func(object model.RedisObject) bool {
+		c := keys[object.GetKey()]
+		if c == nil {
+			c = &Collision{Key: object.GetKey()}
+			keys[object.GetKey()] = c
+		}
+		c.DBs = append(c.DBs, object.GetDBIndex())
+		c.Types = append(c.Types, object.GetType())
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result []Collision
+	for _, c := range keys {
+		if len(c.DBs) < 2 {
+			continue
+		}
+		sort.Sort(collisionDBs{c})
+		result = append(result, *c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result, nil
+}
+
+// collisionDBs sorts DBs and Types of a Collision together
+type collisionDBs struct {
+	*Collision
+}
+
+func (c collisionDBs) Len() int {
+	return len(c.DBs)
+}
+
+func (c collisionDBs) Less(i, j int) bool {
+	return c.DBs[i] < c.DBs[j]
+}
+
+func (c collisionDBs) Swap(i, j int) {
+	c.DBs[i], c.DBs[j] = c.DBs[j], c.DBs[i]
+	c.Types[i], c.Types[j] = c.Types[j], c.Types[i]
+}
@@ -0,0 +1,47 @@
+package helper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestFindCrossDBCollisions(t *testing.T) {
+	data := buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		check(enc.WriteDBHeader(1, 2, 0))
+		check(enc.WriteSetObject("shared", [][]byte{[]byte("a")}))
+		check(enc.WriteStringObject("only1", []byte("b")))
+		check(enc.WriteDBHeader(0, 2, 0))
+		check(enc.WriteStringObject("shared", []byte("c")))
+		check(enc.WriteStringObject("only0", []byte("d")))
+	})
+
+	collisions, err := FindCrossDBCollisions(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collisions) != 1 {
+		t.Fatalf("expect 1 collision, actual %+v", collisions)
+	}
+	c := collisions[0]
+	if c.Key != "shared" || len(c.DBs) != 2 || c.DBs[0] != 0 || c.DBs[1] != 1 {
+		t.Errorf("expect shared in db 0 and 1, actual %+v", c)
+	}
+	if len(c.Types) != 2 || c.Types[0] != model.StringType || c.Types[1] != model.SetType {
+		t.Errorf("expect types string and set, actual %v", c.Types)
+	}
+
+	collisions, err = FindCrossDBCollisions(bytes.NewReader(data), WithRegexOption("^only"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collisions) != 0 {
+		t.Errorf("expect no collision, actual %+v", collisions)
+	}
+
+	if _, err = FindCrossDBCollisions(nil); err == nil {
+		t.Error("expect error for nil reader")
+	}
+}
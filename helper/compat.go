@@ -0,0 +1,167 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// redisVersion is major and minor version of redis
+type redisVersion [2]int
+
+func (v redisVersion) less(other redisVersion) bool {
+	return v[0] < other[0] || (v[0] == other[0] && v[1] < other[1])
+}
+
+func (v redisVersion) String() string {
+	return strconv.Itoa(v[0]) + "." + strconv.Itoa(v[1])
+}
+
+// parseRedisVersion parses version like 7.0 or 7.2.4, patch version is ignored
+func parseRedisVersion(s string) (redisVersion, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return redisVersion{}, fmt.Errorf("illegal redis version: %s", s)
+	}
+	var v redisVersion
+	for i := 0; i < len(parts); i++ {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return redisVersion{}, fmt.Errorf("illegal redis version: %s", s)
+		}
+		if i < 2 {
+			v[i] = n
+		}
+	}
+	return v, nil
+}
+
+// rdbVersions lists the first redis version of each rdb version, in ascending order
+var rdbVersions = []struct {
+	rdbVersion int
+	since      redisVersion
+}{
+	{6, redisVersion{2, 6}},
+	{7, redisVersion{3, 2}},
+	{8, redisVersion{4, 0}},
+	{9, redisVersion{5, 0}},
+	{10, redisVersion{7, 0}},
+	{11, redisVersion{7, 2}},
+	{12, redisVersion{7, 4}},
+}
+
+// compatFeature is a value type or opcode which requires a minimum redis version
+type compatFeature struct {
+	description string
+	since       redisVersion
+	// unsupported means no released redis version is able to load it
+	unsupported bool
+}
+
+// valueTypeFeatures are keyed by names in core.Stats.ValueTypes, types not listed are loadable by redis 2.6
+var valueTypeFeatures = map[string]compatFeature{
+	"zset_2":                  {description: "zset with binary scores", since: redisVersion{4, 0}},
+	"module_pre_ga":           {description: "module value of redis 4.0 release candidates", unsupported: true},
+	"module_2":                {description: "module value", since: redisVersion{4, 0}},
+	"list_quicklist":          {description: "quicklist encoded list", since: redisVersion{3, 2}},
+	"stream_listpacks":        {description: "stream", since: redisVersion{5, 0}},
+	"hash_listpack":           {description: "listpack encoded hash", since: redisVersion{7, 0}},
+	"zset_listpack":           {description: "listpack encoded zset", since: redisVersion{7, 0}},
+	"list_quicklist_2":        {description: "quicklist with listpack nodes", since: redisVersion{7, 0}},
+	"stream_listpacks_2":      {description: "stream with entries added and max deleted id", since: redisVersion{7, 0}},
+	"set_listpack":            {description: "listpack encoded set", since: redisVersion{7, 2}},
+	"stream_listpacks_3":      {description: "stream with consumer active time", since: redisVersion{7, 2}},
+	"hash_metadata_pre_ga":    {description: "hash field TTL", since: redisVersion{7, 4}},
+	"hash_listpack_ex_pre_ga": {description: "hash field TTL", since: redisVersion{7, 4}},
+	"hash_metadata":           {description: "hash field TTL", since: redisVersion{7, 4}},
+	"hash_listpack_ex":        {description: "hash field TTL", since: redisVersion{7, 4}},
+}
+
+// opCodeFeatures are keyed by names in core.Stats.OpCodes
+var opCodeFeatures = map[string]compatFeature{
+	"aux":       {description: "aux field", since: redisVersion{3, 2}},
+	"resizedb":  {description: "resize db hint", since: redisVersion{3, 2}},
+	"freq":      {description: "LFU frequency", since: redisVersion{5, 0}},
+	"idle":      {description: "LRU idle time", since: redisVersion{5, 0}},
+	"moduleaux": {description: "module aux data", since: redisVersion{4, 0}},
+	"function":  {description: "function library of redis 7.0 release candidates", unsupported: true},
+	"function2": {description: "function library", since: redisVersion{7, 0}},
+	"slotinfo":  {description: "slot info", since: redisVersion{8, 0}},
+}
+
+// CompatReport tells whether a rdb is loadable by the target redis version
+type CompatReport struct {
+	// TargetVersion is the redis version checked against
+	TargetVersion string
+	// RDBVersion is version in rdb header
+	RDBVersion int
+	// Compatible is true if no incompatibility is found
+	Compatible bool
+	// Incompatibilities describes each rdb version, value type or opcode the target is unable to load
+	Incompatibilities []string
+}
+
+// CompatibilityCheck scans rdb from reader and reports whether its rdb version, and every value type and opcode
+// in it are loadable by redis of targetRedisVersion, such as "7.0" or "6.2.14". Values are skipped without decoding
+func CompatibilityCheck(reader io.Reader, targetRedisVersion string) (*CompatReport, error) {
+	if reader == nil {
+		return nil, errors.New("src reader is required")
+	}
+	target, err := parseRedisVersion(targetRedisVersion)
+	if err != nil {
+		return nil, err
+	}
+	if target.less(rdbVersions[0].since) {
+		return nil, fmt.Errorf("target redis version %s is older than %s", targetRedisVersion, rdbVersions[0].since)
+	}
+	dec := core.NewDecoder(reader).WithKeyFilter(func(key string) bool {
+		return false
+	})
+	err = dec.Parse(func(object model.RedisObject) bool {
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	report := &CompatReport{
+		TargetVersion: targetRedisVersion,
+		RDBVersion:    dec.GetVersion(),
+	}
+	for _, v := range rdbVersions {
+		if v.rdbVersion == report.RDBVersion && target.less(v.since) {
+			report.Incompatibilities = append(report.Incompatibilities,
+				fmt.Sprintf("rdb version %d requires Redis >= %s", v.rdbVersion, v.since))
+		}
+	}
+	check := func(kind string, used map[string]int, features map[string]compatFeature) {
+		names := make([]string, 0, len(used))
+		for name := range used {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			feature, ok := features[name]
+			if !ok {
+				continue
+			}
+			if feature.unsupported {
+				report.Incompatibilities = append(report.Incompatibilities,
+					fmt.Sprintf("%s (%s %s) is not loadable by released Redis", feature.description, kind, name))
+			} else if target.less(feature.since) {
+				report.Incompatibilities = append(report.Incompatibilities,
+					fmt.Sprintf("%s (%s %s) requires Redis >= %s", feature.description, kind, name, feature.since))
+			}
+		}
+	}
+	stats := dec.Stats()
+	check("value type", stats.ValueTypes, valueTypeFeatures)
+	check("opcode", stats.OpCodes, opCodeFeatures)
+	report.Compatible = len(report.Incompatibilities) == 0
+	return report, nil
+}
@@ -0,0 +1,83 @@
+package helper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompatibilityCheck(t *testing.T) {
+	check := func(name string, target string) *CompatReport {
+		rdbFile, err := os.Open(filepath.Join("../cases", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = rdbFile.Close()
+		}()
+		report, err := CompatibilityCheck(rdbFile, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+	report := check("hash_with_hfe.rdb", "7.0")
+	if report.Compatible || report.RDBVersion != 12 {
+		t.Fatalf("expect rdb version 12 to be incompatible with 7.0, actual %+v", report)
+	}
+	hfe := false
+	version := false
+	for _, msg := range report.Incompatibilities {
+		if strings.HasPrefix(msg, "hash field TTL") && strings.HasSuffix(msg, "requires Redis >= 7.4") {
+			hfe = true
+		}
+		if msg == "rdb version 12 requires Redis >= 7.4" {
+			version = true
+		}
+	}
+	if !hfe || !version {
+		t.Errorf("expect hash field TTL and rdb version incompatibilities, actual %v", report.Incompatibilities)
+	}
+	if report = check("hash_with_hfe.rdb", "7.4.0"); !report.Compatible {
+		t.Errorf("expect compatible with 7.4.0, actual %v", report.Incompatibilities)
+	}
+	if report = check("hash_with_hfe.rdb", "8.0"); !report.Compatible {
+		t.Errorf("expect compatible with 8.0, actual %v", report.Incompatibilities)
+	}
+
+	report = check("set_listpack.rdb", "7.0.15")
+	if report.Compatible {
+		t.Error("expect set listpack to be incompatible with 7.0")
+	}
+	if report = check("stream_listpacks_1.rdb", "5.0"); !report.Compatible {
+		t.Errorf("expect compatible with 5.0, actual %v", report.Incompatibilities)
+	}
+	if report = check("stream_listpacks_1.rdb", "4.0"); report.Compatible {
+		t.Error("expect stream to be incompatible with 4.0")
+	}
+
+	// RDB_OPCODE_IDLE, RDB_OPCODE_FREQ are introduced in redis 5.0, RDB_OPCODE_FUNCTION2 in redis 7.0
+	for _, tc := range []struct{ name, target, expect string }{
+		{"lru.rdb", "4.0", "LRU idle time (opcode idle) requires Redis >= 5.0"},
+		{"function.rdb", "6.2", "function library (opcode function2) requires Redis >= 7.0"},
+	} {
+		report = check(tc.name, tc.target)
+		found := false
+		for _, msg := range report.Incompatibilities {
+			found = found || msg == tc.expect
+		}
+		if !found {
+			t.Errorf("%s: expect %q, actual %v", tc.name, tc.expect, report.Incompatibilities)
+		}
+	}
+	if report = check("function.rdb", "7.0"); !report.Compatible {
+		t.Errorf("expect function library compatible with 7.0, actual %v", report.Incompatibilities)
+	}
+
+	for _, target := range []string{"7", "seven.0", "2.4"} {
+		if _, err := CompatibilityCheck(strings.NewReader(""), target); err == nil {
+			t.Errorf("expect error for target %s", target)
+		}
+	}
+}
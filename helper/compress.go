@@ -0,0 +1,46 @@
+package helper
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// compressedWriter writes into a compressed stream and closes it before the underlying file
+type compressedWriter struct {
+	io.Writer
+	closers []io.Closer
+}
+
+func (w *compressedWriter) Close() error {
+	var err error
+	for _, c := range w.closers {
+		if cErr := c.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// NewCompressedRDBWriter creates file at path and returns a writer compressing everything written into it by algo,
+// which is gzip, or none to write the file as is. Pass it to encoder.NewEncoder to write a compressed dump,
+// this is compression of the whole file rather than LZF compression of values in rdb.
+// Caller must close the returned writer to flush the compressed stream
+func NewCompressedRDBWriter(path string, algo string) (io.WriteCloser, error) {
+	if algo != "gzip" && algo != "none" {
+		return nil, fmt.Errorf("unsupported compression algorithm: %s", algo)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create %s failed, %v", path, err)
+	}
+	if algo == "none" {
+		return file, nil
+	}
+	gzWriter := gzip.NewWriter(file)
+	return &compressedWriter{
+		Writer:  gzWriter,
+		closers: []io.Closer{gzWriter, file},
+	}, nil
+}
@@ -0,0 +1,62 @@
+package helper
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestNewCompressedRDBWriter(t *testing.T) {
+	err := os.MkdirAll("tmp", os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	path := filepath.Join("tmp", "dump.rdb.gz")
+	w, err := NewCompressedRDBWriter(path, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 2, 0))
+		check(enc.WriteStringObject("a", []byte("1")))
+		check(enc.WriteListObject("b", [][]byte{[]byte("2"), []byte("3")}))
+	})
+	if _, err = w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	gzReader, err := gzip.NewReader(mustOpen(t, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects, err := ReadAll(gzReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, o := range objects {
+		if o.GetType() != model.AuxType && o.GetType() != model.DBSizeType {
+			keys = append(keys, o.GetKey())
+		}
+	}
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("expect keys a and b, actual %v", keys)
+	}
+
+	if _, err = NewCompressedRDBWriter(filepath.Join("tmp", "dump.rdb.zst"), "zstd"); err == nil {
+		t.Error("expect error for unsupported algorithm")
+	}
+}
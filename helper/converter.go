@@ -16,6 +16,16 @@ func ToAOF(rdbFilename string, aofFilename string, options ...interface{}) error
 	if aofFilename == "" {
 		return errors.New("output file path is required")
 	}
+	if _, err := protocolVersion(options); err != nil {
+		return err
+	}
+	for _, opt := range options {
+		if o, ok := opt.(RestoreModeOption); ok && bool(o) {
+			if err := checkRestoreTransform(options); err != nil {
+				return err
+			}
+		}
+	}
 	rdbFile, err := os.Open(rdbFilename)
 	if err != nil {
 		return fmt.Errorf("open rdb %s failed, %v", rdbFilename, err)
@@ -31,12 +41,37 @@ func ToAOF(rdbFilename string, aofFilename string, options ...interface{}) error
 		_ = aofFile.Close()
 	}()
 
-	var dec decoder = core.NewDecoder(rdbFile)
+	restoreMode := false
+	coreDec := core.NewDecoder(rdbFile)
+	for _, opt := range options {
+		switch o := opt.(type) {
+		case RestoreModeOption:
+			restoreMode = bool(o)
+		case StreamIDRangeOption:
+			coreDec.WithStreamIDRange(o[0], o[1])
+		}
+	}
+	if restoreMode {
+		coreDec.WithDumpPayload()
+	}
+	var dec decoder = coreDec
 	if dec, err = wrapDecoder(dec, options...); err != nil {
 		return err
 	}
+	currentDB := 0
 	return dec.Parse(func(object model.RedisObject) bool {
-		cmdLines := ObjectToCmd(object, options...)
+		var cmdLines []CmdLine
+		if restoreMode {
+			if cmdLine := ObjectToRestoreCmd(object, options...); cmdLine != nil {
+				cmdLines = []CmdLine{cmdLine}
+			}
+		} else {
+			cmdLines = ObjectToCmd(object, options...)
+		}
+		if len(cmdLines) > 0 && object.GetDBIndex() != currentDB {
+			currentDB = object.GetDBIndex()
+			cmdLines = append([]CmdLine{makeSelectCmd(currentDB)}, cmdLines...)
+		}
 		data := CmdLinesToResp(cmdLines)
 		_, err = aofFile.Write(data)
 		if err != nil {
@@ -2,12 +2,19 @@ package helper
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/bytedance/sonic"
+	"github.com/hdt3213/rdb/encoder"
 	"github.com/hdt3213/rdb/model"
 	"github.com/hdt3213/rdb/parser"
 )
@@ -77,8 +84,10 @@ func TestToJson(t *testing.T) {
 		"listpack",
 		"memory",
 		"quicklist",
+		"quicklist_compressed",
 		"easily_compressible_string_key",
 		"empty_database",
+		"empty_collections",
 		"hash",
 		"hash_as_ziplist",
 		"integer_keys",
@@ -103,6 +112,7 @@ func TestToJson(t *testing.T) {
 		"zipmap_that_doesnt_compress",
 		"zipmap_with_big_values",
 		"zipmap_big_len",
+		"zset_special_scores",
 	}
 	for _, filename := range testCases {
 		srcRdb := filepath.Join("../cases", filename+".rdb")
@@ -212,6 +222,41 @@ func TestToAof(t *testing.T) {
 	}
 }
 
+func TestToAofProtocolVersion(t *testing.T) {
+	err := os.MkdirAll("tmp", os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	srcRdb := filepath.Join("../cases", "memory.rdb")
+	expectFile := filepath.Join("../cases", "memory.aof")
+	for _, version := range []int{2, 3} {
+		actualFile := filepath.Join("tmp", "memory_resp"+strconv.Itoa(version)+".aof")
+		err = ToAOF(srcRdb, actualFile, lexOrder{}, WithProtocolVersion(version))
+		if err != nil {
+			t.Errorf("error occurs during parse %s, err: %v", srcRdb, err)
+			return
+		}
+		equals, err := compareFileByLine(t, actualFile, expectFile)
+		if err != nil {
+			t.Errorf("error occurs during compare %s, err: %v", srcRdb, err)
+			return
+		}
+		if !equals {
+			t.Errorf("commands for RESP%d should be unchanged", version)
+		}
+	}
+	err = ToAOF(srcRdb, filepath.Join("tmp", "memory_resp1.aof"), WithProtocolVersion(1))
+	if err == nil {
+		t.Error("expect error for unsupported protocol version")
+	}
+}
+
 func TestToAofWithRegex(t *testing.T) {
 	err := os.MkdirAll("tmp", os.ModePerm)
 	if err != nil {
@@ -321,3 +366,814 @@ func TestExpiration(t *testing.T) {
 		t.Errorf("expect 1 got %d", count)
 	}
 }
+
+func TestExpireShift(t *testing.T) {
+	expiration := time.Now().Add(time.Hour)
+	obj := &model.StringObject{
+		BaseObject: &model.BaseObject{
+			Key:        "a",
+			Expiration: &expiration,
+		},
+		Value: []byte("b"),
+	}
+	getExpireAt := func(cmdLines []CmdLine) int64 {
+		for _, cmdLine := range cmdLines {
+			if string(cmdLine[0]) == string(pExpireAtBytes) {
+				ms, _ := strconv.ParseInt(string(cmdLine[2]), 10, 64)
+				return ms
+			}
+		}
+		return 0
+	}
+	cmdLines := ObjectToCmd(obj, WithExpireShift(24*time.Hour))
+	if expect := expiration.Add(24*time.Hour).UnixNano() / 1e6; getExpireAt(cmdLines) != expect {
+		t.Errorf("expect expire at %d, actual %d", expect, getExpireAt(cmdLines))
+	}
+	cmdLines = ObjectToCmd(obj, WithExpireShift(-30*time.Minute))
+	if expect := expiration.Add(-30*time.Minute).UnixNano() / 1e6; getExpireAt(cmdLines) != expect {
+		t.Errorf("expect expire at %d, actual %d", expect, getExpireAt(cmdLines))
+	}
+	// shifted into the past
+	cmdLines = ObjectToCmd(obj, WithExpireShift(-2*time.Hour))
+	if len(cmdLines) != 0 {
+		t.Errorf("expect key to be dropped")
+	}
+
+	past := time.Now().Add(-time.Hour)
+	obj.Expiration = &past
+	cmdLines = ObjectToCmd(obj, WithExpireShift(2*time.Hour))
+	if expect := past.Add(2*time.Hour).UnixNano() / 1e6; getExpireAt(cmdLines) != expect {
+		t.Errorf("expect expire at %d, actual %d", expect, getExpireAt(cmdLines))
+	}
+	// persistent keys are never dropped
+	obj.Expiration = nil
+	cmdLines = ObjectToCmd(obj, WithExpireShift(-2*time.Hour))
+	if len(cmdLines) != 1 {
+		t.Errorf("expect 1 command line, actual %d", len(cmdLines))
+	}
+}
+
+// parseResp parses multi bulk replies, only used in test
+func parseResp(t *testing.T, data []byte) []CmdLine {
+	reader := bufio.NewReader(bytes.NewReader(data))
+	readLine := func() string {
+		line, err := reader.ReadString('\n')
+		if err != nil || len(line) < 2 || line[len(line)-2] != '\r' {
+			t.Fatalf("illegal resp line: %q", line)
+		}
+		return line[:len(line)-2]
+	}
+	var result []CmdLine
+	for {
+		if _, err := reader.Peek(1); err == io.EOF {
+			break
+		}
+		header := readLine()
+		if header[0] != '*' {
+			t.Fatalf("expect multi bulk header, actual %q", header)
+		}
+		argc, _ := strconv.Atoi(header[1:])
+		cmdLine := make(CmdLine, 0, argc)
+		for i := 0; i < argc; i++ {
+			bulkHeader := readLine()
+			if bulkHeader[0] != '$' {
+				t.Fatalf("expect bulk string header, actual %q", bulkHeader)
+			}
+			size, _ := strconv.Atoi(bulkHeader[1:])
+			arg := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, arg); err != nil {
+				t.Fatal(err)
+			}
+			if string(arg[size:]) != crlf {
+				t.Fatalf("bulk string not terminated by crlf")
+			}
+			cmdLine = append(cmdLine, arg[:size])
+		}
+		result = append(result, cmdLine)
+	}
+	return result
+}
+
+func TestRespBinarySafe(t *testing.T) {
+	expiration := time.Now().Add(time.Hour)
+	objects := []model.RedisObject{
+		&model.StringObject{
+			BaseObject: &model.BaseObject{Key: "crlf\r\nkey", Expiration: &expiration},
+			Value:      []byte("a\r\n*3\r\n$3\r\nSET\r\n\x00\xff"),
+		},
+		&model.ListObject{
+			BaseObject: &model.BaseObject{Key: "list"},
+			Values:     [][]byte{[]byte("\r\n"), []byte(""), []byte("$-1\r\n")},
+		},
+	}
+	for _, obj := range objects {
+		expect := ObjectToCmd(obj)
+		buf := bytes.NewBuffer(nil)
+		if err := WriteObjectToResp(buf, obj); err != nil {
+			t.Error(err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), CmdLinesToResp(expect)) {
+			t.Errorf("WriteObjectToResp and CmdLinesToResp have different output of %q", obj.GetKey())
+		}
+		actual := parseResp(t, buf.Bytes())
+		if len(actual) != len(expect) {
+			t.Errorf("expect %d command lines, actual %d", len(expect), len(actual))
+			continue
+		}
+		for i := range expect {
+			if len(actual[i]) != len(expect[i]) {
+				t.Errorf("command line %d of %q has wrong args count", i, obj.GetKey())
+				continue
+			}
+			for j := range expect[i] {
+				if !bytes.Equal(actual[i][j], expect[i][j]) {
+					t.Errorf("expect arg %q, actual %q", expect[i][j], actual[i][j])
+				}
+			}
+		}
+	}
+}
+
+func TestToJsonStreaming(t *testing.T) {
+	err := os.MkdirAll("tmp", os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	srcRdb := filepath.Join("tmp", "many_keys.rdb")
+	rdbFile, err := os.Create(srcRdb)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	keyCount := 1000000
+	rdbWriter := bufio.NewWriter(rdbFile)
+	enc := encoder.NewEncoder(rdbWriter)
+	if err = enc.WriteHeader(); err != nil {
+		t.Error(err)
+		return
+	}
+	if err = enc.WriteDBHeader(0, uint64(keyCount), 0); err != nil {
+		t.Error(err)
+		return
+	}
+	for i := 0; i < keyCount; i++ {
+		if err = enc.WriteStringObject("key:"+strconv.Itoa(i), []byte("value")); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if err = enc.WriteEnd(); err != nil {
+		t.Error(err)
+		return
+	}
+	if err = rdbWriter.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	_ = rdbFile.Close()
+
+	// sample heap during export, it should not grow with number of keys
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	baseHeap := stats.HeapInuse
+	var peakHeap uint64
+	stop := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			var s runtime.MemStats
+			runtime.ReadMemStats(&s)
+			if s.HeapInuse > peakHeap {
+				peakHeap = s.HeapInuse
+			}
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	actualJSON := filepath.Join("tmp", "many_keys.json")
+	err = ToJsons(srcRdb, actualJSON)
+	close(stop)
+	<-sampled
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	const maxHeapGrowth = 64 << 20
+	if peakHeap > baseHeap+maxHeapGrowth {
+		t.Errorf("heap grows by %d bytes during export of %d keys", peakHeap-baseHeap, keyCount)
+	}
+
+	jsonFile, err := os.Open(actualJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = jsonFile.Close()
+	}()
+	decoder := json.NewDecoder(bufio.NewReader(jsonFile))
+	if _, err = decoder.Token(); err != nil {
+		t.Fatalf("output is not a valid json array: %v", err)
+	}
+	count := 0
+	for decoder.More() {
+		var object struct {
+			Key string `json:"key"`
+		}
+		if err = decoder.Decode(&object); err != nil {
+			t.Fatalf("output is not a valid json array: %v", err)
+		}
+		count++
+	}
+	if _, err = decoder.Token(); err != nil {
+		t.Fatalf("output is not a valid json array: %v", err)
+	}
+	if count != keyCount {
+		t.Errorf("expect %d objects, actual %d", keyCount, count)
+	}
+
+	// parse error should be reported
+	brokenRdb := filepath.Join("tmp", "broken.rdb")
+	if err = os.WriteFile(brokenRdb, nil, 0644); err != nil {
+		t.Error(err)
+		return
+	}
+	err = ToJsons(brokenRdb, filepath.Join("tmp", "broken.json"))
+	if err == nil {
+		t.Error("expect error")
+	}
+
+	// write error should be reported rather than printed
+	if _, err = os.Stat("/dev/full"); err == nil {
+		err = ToJsons(srcRdb, "/dev/full")
+		if err == nil || !strings.Contains(err.Error(), "write") {
+			t.Errorf("expect write error, actual %v", err)
+		}
+	}
+}
+
+func TestToAOFSkeletonOnly(t *testing.T) {
+	err := os.MkdirAll("tmp", os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	srcRdb := filepath.Join("tmp", "skeleton.rdb")
+	expireAt := uint64(time.Now().Add(time.Hour).UnixNano() / 1e6)
+	rdbData := buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 2, 1))
+		check(enc.WriteStringObject("str", []byte("value"), encoder.WithTTL(expireAt)))
+		check(enc.WriteListObject("list", [][]byte{[]byte("a"), []byte("b")}))
+		check(enc.WriteDBHeader(1, 2, 0))
+		check(enc.WriteSetObject("set", [][]byte{[]byte("a"), []byte("b")}))
+		check(enc.WriteHashMapObject("hash", map[string][]byte{"a": []byte("1"), "b": []byte("2")}))
+	})
+	if err = os.WriteFile(srcRdb, rdbData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	aofFile := filepath.Join("tmp", "skeleton.aof")
+	err = ToAOF(srcRdb, aofFile, WithSkeletonOnly())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	data, err := os.ReadFile(aofFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expect := [][]string{
+		{"SET", "str", ""},
+		{"PEXPIREAT", "str", strconv.FormatUint(expireAt, 10)},
+		{"SET", "list", ""},
+		{"SELECT", "1"},
+		{"SET", "set", ""},
+		{"SET", "hash", ""},
+	}
+	actual := parseResp(t, data)
+	if len(actual) != len(expect) {
+		t.Errorf("expect %d command lines, actual %d", len(expect), len(actual))
+		return
+	}
+	for i, cmdLine := range actual {
+		if len(cmdLine) != len(expect[i]) {
+			t.Errorf("command line %d has wrong args count: %q", i, cmdLine)
+			continue
+		}
+		for j := range cmdLine {
+			if string(cmdLine[j]) != expect[i][j] {
+				t.Errorf("expect arg %q, actual %q", expect[i][j], cmdLine[j])
+			}
+		}
+	}
+}
+
+func TestToJsonRenderer(t *testing.T) {
+	err := os.MkdirAll("tmp", os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	srcRdb := filepath.Join("tmp", "renderer.rdb")
+	rdbData := buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 3, 0))
+		check(enc.WriteListObject("log", [][]byte{[]byte("line 1"), []byte("line 2"), []byte("line 3")}))
+		check(enc.WriteHashMapObject("conf", map[string][]byte{"a": []byte("1")}))
+		check(enc.WriteSetObject("set", [][]byte{[]byte("a")}))
+	})
+	if err = os.WriteFile(srcRdb, rdbData, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	actualJSON := filepath.Join("tmp", "renderer.json")
+	err = ToJsons(srcRdb, actualJSON, WithConcurrent(1),
+		WithListRenderer(func(values [][]byte) []byte {
+			return bytes.Join(values, []byte("\n"))
+		}),
+		WithHashRenderer(func(hash map[string][]byte) []byte {
+			return []byte("a=" + string(hash["a"]))
+		}),
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	data, err := os.ReadFile(actualJSON)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var objects []map[string]interface{}
+	if err := json.Unmarshal(data, &objects); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(objects) != 3 {
+		t.Errorf("expect 3 objects, actual %d", len(objects))
+		return
+	}
+	if objects[0]["key"] != "log" || objects[0]["value"] != "line 1\nline 2\nline 3" {
+		t.Errorf("wrong rendered list: %v", objects[0])
+	}
+	if objects[1]["key"] != "conf" || objects[1]["value"] != "a=1" {
+		t.Errorf("wrong rendered hash: %v", objects[1])
+	}
+	if _, ok := objects[2]["members"]; !ok {
+		t.Errorf("set should not be rendered: %v", objects[2])
+	}
+}
+
+func TestStripExpirations(t *testing.T) {
+	err := os.MkdirAll("tmp", os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	countExpireCmds := func(srcRdb string, options ...interface{}) (int, int) {
+		actualFile := filepath.Join("tmp", "strip.aof")
+		if err := ToAOF(srcRdb, actualFile, options...); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(actualFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expireCmds := 0
+		cmdLines := parseResp(t, data)
+		for _, cmdLine := range cmdLines {
+			switch strings.ToUpper(string(cmdLine[0])) {
+			case "PEXPIREAT", "HPEXPIREAT", "EXPIRE", "PEXPIRE":
+				expireCmds++
+			}
+		}
+		return expireCmds, len(cmdLines)
+	}
+	for _, name := range []string{"keys_with_expiry.rdb", "hash_with_hfe.rdb"} {
+		srcRdb := filepath.Join("../cases", name)
+		expireCmds, total := countExpireCmds(srcRdb)
+		if expireCmds == 0 {
+			t.Fatalf("%s: expect expire commands without stripping", name)
+		}
+		stripped, strippedTotal := countExpireCmds(srcRdb, WithStripExpirations())
+		if stripped != 0 {
+			t.Errorf("%s: expect no expire command, actual %d", name, stripped)
+		}
+		if strippedTotal == 0 || strippedTotal > total-expireCmds {
+			t.Errorf("%s: expect %d commands without expirations, actual %d", name, total-expireCmds, strippedTotal)
+		}
+	}
+}
+
+// replayedGroup is consumer group state rebuilt from XGROUP and XCLAIM commands
+type replayedGroup struct {
+	lastId      string
+	entriesRead string
+	consumers   []string
+	pending     map[string]string // id -> "consumer time count"
+}
+
+func TestStreamToCmdReplay(t *testing.T) {
+	// stream created by XGROUP CREATE ... MKSTREAM is empty and its last id is 0-0
+	streams := []*model.StreamObject{{
+		BaseObject:   &model.BaseObject{Key: "empty"},
+		Version:      2,
+		LastId:       &model.StreamId{},
+		FirstId:      &model.StreamId{},
+		MaxDeletedId: &model.StreamId{},
+		Groups:       []*model.StreamGroup{{Name: "g", LastId: &model.StreamId{}}},
+	}}
+	for _, name := range []string{"stream_listpacks_1.rdb", "stream_listoacks_3.rdb"} {
+		rdbFile, err := os.Open(filepath.Join("../cases", name))
+		if err != nil {
+			t.Fatalf("open %s failed: %v", name, err)
+		}
+		dec := parser.NewDecoder(rdbFile)
+		err = dec.Parse(func(o parser.RedisObject) bool {
+			if stream, ok := o.(*model.StreamObject); ok {
+				streams = append(streams, stream)
+			}
+			return true
+		})
+		_ = rdbFile.Close()
+		if err != nil {
+			t.Fatalf("parse %s failed: %v", name, err)
+		}
+	}
+	for _, stream := range streams {
+		var ids []string
+		var lastId string
+		groups := make(map[string]*replayedGroup)
+		for _, cmdLine := range ObjectToCmd(stream) {
+			args := make([]string, len(cmdLine))
+			for i, arg := range cmdLine {
+				args[i] = string(arg)
+			}
+			switch {
+			case args[0] == "XADD" && args[2] != "MAXLEN":
+				ids = append(ids, args[2])
+			case args[0] == "XADD":
+				// redis rejects ids not greater than 0-0
+				if args[4] == "0-0" {
+					t.Errorf("%s: illegal placeholder %v", stream.Key, args)
+				}
+			case args[0] == "XSETID":
+				lastId = args[2]
+				if stream.Version >= 2 && (args[4] != strconv.FormatUint(stream.AddedEntriesCount, 10) ||
+					args[6] != stream.MaxDeletedId.String()) {
+					t.Errorf("%s: wrong XSETID %v", stream.Key, args)
+				}
+			case args[0] == "XGROUP" && args[1] == "CREATE":
+				g := &replayedGroup{lastId: args[4], pending: make(map[string]string)}
+				if len(args) > 6 {
+					g.entriesRead = args[6]
+				}
+				groups[args[3]] = g
+			case args[0] == "XGROUP" && args[1] == "CREATECONSUMER":
+				groups[args[3]].consumers = append(groups[args[3]].consumers, args[4])
+			case args[0] == "XCLAIM":
+				groups[args[2]].pending[args[5]] = args[3] + " " + args[7] + " " + args[9]
+			default:
+				t.Errorf("%s: unexpected command %v", stream.Key, args)
+			}
+		}
+
+		var expectIds []string
+		for _, entry := range stream.Entries {
+			for _, msg := range entry.Msgs {
+				if !msg.Deleted {
+					expectIds = append(expectIds, msg.Id.String())
+				}
+			}
+		}
+		if strings.Join(ids, ",") != strings.Join(expectIds, ",") {
+			t.Errorf("%s: expect ids %v, actual %v", stream.Key, expectIds, ids)
+		}
+		if lastId != stream.LastId.String() {
+			t.Errorf("%s: expect last id %s, actual %s", stream.Key, stream.LastId, lastId)
+		}
+		if len(groups) != len(stream.Groups) {
+			t.Errorf("%s: expect %d groups, actual %d", stream.Key, len(stream.Groups), len(groups))
+		}
+		for _, group := range stream.Groups {
+			g := groups[group.Name]
+			if g == nil {
+				t.Errorf("%s: group %s not created", stream.Key, group.Name)
+				continue
+			}
+			if g.lastId != group.LastId.String() {
+				t.Errorf("%s: group %s expect last id %s, actual %s", stream.Key, group.Name, group.LastId, g.lastId)
+			}
+			if stream.Version >= 2 && g.entriesRead != strconv.FormatUint(group.EntriesRead, 10) {
+				t.Errorf("%s: group %s expect entries read %d, actual %s", stream.Key, group.Name, group.EntriesRead, g.entriesRead)
+			}
+			if len(g.consumers) != len(group.Consumers) {
+				t.Errorf("%s: group %s expect %d consumers, actual %d", stream.Key, group.Name, len(group.Consumers), len(g.consumers))
+			}
+			if len(g.pending) != len(group.Pending) {
+				t.Errorf("%s: group %s expect %d pending, actual %d", stream.Key, group.Name, len(group.Pending), len(g.pending))
+			}
+			for _, nack := range group.Pending {
+				expect := nack.Consumer + " " + strconv.FormatUint(nack.DeliveryTime, 10) + " " +
+					strconv.FormatUint(nack.DeliveryCount, 10)
+				if actual := g.pending[nack.Id.String()]; actual != expect {
+					t.Errorf("%s: pending %s expect %s, actual %s", stream.Key, nack.Id, expect, actual)
+				}
+			}
+		}
+	}
+}
+
+func TestIdleFilter(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := encoder.NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 4, 0); err != nil {
+		t.Fatal(err)
+	}
+	idles := map[string]uint64{
+		"fresh": 0,
+		"warm":  59,
+		"cold":  60,
+		"stale": 86400,
+	}
+	for _, key := range []string{"fresh", "warm", "cold", "stale"} {
+		if err := enc.WriteStringObject(key, []byte(key), encoder.WithIdle(idles[key])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteStringObject("unknown", []byte("unknown")); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	parseKeys := func(options ...interface{}) string {
+		dec, err := wrapDecoder(parser.NewDecoder(bytes.NewReader(data)), options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		err = dec.Parse(func(o parser.RedisObject) bool {
+			keys = append(keys, o.GetKey())
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(keys, ",")
+	}
+	if keys := parseKeys(); keys != "fresh,warm,cold,stale,unknown" {
+		t.Errorf("unexpected keys without filter: %s", keys)
+	}
+	if keys := parseKeys(WithIdleFilter(time.Minute, true)); keys != "fresh,warm,unknown" {
+		t.Errorf("unexpected hot keys: %s", keys)
+	}
+	if keys := parseKeys(WithIdleFilter(time.Minute, false)); keys != "cold,stale" {
+		t.Errorf("unexpected cold keys: %s", keys)
+	}
+
+	err := parser.NewDecoder(bytes.NewReader(data)).Parse(func(o parser.RedisObject) bool {
+		idle := o.(*model.StringObject).Idle
+		if expect, ok := idles[o.GetKey()]; !ok {
+			if idle != nil {
+				t.Errorf("%s: expect no idle, actual %d", o.GetKey(), *idle)
+			}
+		} else if idle == nil || *idle != expect {
+			t.Errorf("%s: expect idle %d, actual %v", o.GetKey(), expect, idle)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIdleFilterLRU(t *testing.T) {
+	// cases/lru.rdb is saved by redis 7.2 under maxmemory-policy allkeys-lru
+	parseKeys := func(hot bool) string {
+		rdbFile, err := os.Open("../cases/lru.rdb")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = rdbFile.Close() }()
+		dec, err := wrapDecoder(parser.NewDecoder(rdbFile), WithIdleFilter(time.Hour, hot))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		err = dec.Parse(func(o parser.RedisObject) bool {
+			keys = append(keys, o.GetKey())
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(keys, ",")
+	}
+	if keys := parseKeys(true); keys != "session:1,user:1" {
+		t.Errorf("unexpected hot keys: %s", keys)
+	}
+	if keys := parseKeys(false); keys != "hits,page:home" {
+		t.Errorf("unexpected cold keys: %s", keys)
+	}
+}
+
+func TestToJsonsDeterministic(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := encoder.NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 2000, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2000; i++ {
+		key := "k" + strconv.Itoa(i)
+		var err error
+		if i%2 == 0 {
+			hash := make(map[string][]byte)
+			for j := 0; j < i%50; j++ {
+				hash["f"+strconv.Itoa(j)] = []byte(strconv.Itoa(i * j))
+			}
+			hash["f"] = []byte("v")
+			err = enc.WriteHashMapObject(key, hash)
+		} else {
+			err = enc.WriteStringObject(key, []byte(strings.Repeat("v", i)))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll("tmp", os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	srcRdb := filepath.Join("tmp", "deterministic.rdb")
+	if err := os.WriteFile(srcRdb, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var first []byte
+	for i := 0; i < 3; i++ {
+		output := filepath.Join("tmp", "deterministic.json")
+		if err := ToJsons(srcRdb, output, WithConcurrent(4)); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = data
+			var objects []map[string]interface{}
+			if err = json.Unmarshal(data, &objects); err != nil {
+				t.Fatal(err)
+			}
+			for j, o := range objects {
+				if o["key"] != "k"+strconv.Itoa(j) {
+					t.Fatalf("object %d is %v, expect on-disk order", j, o["key"])
+				}
+			}
+		} else if !bytes.Equal(first, data) {
+			t.Fatal("json output is not the same for the same rdb")
+		}
+	}
+}
+
+func TestMaxCommandSize(t *testing.T) {
+	type warning struct {
+		key  string
+		size int
+	}
+	var warnings []warning
+	limit := WithMaxCommandSize(1024*1024, func(key string, size int) {
+		warnings = append(warnings, warning{key, size})
+	})
+
+	huge := strings.Repeat("v", 10*1024*1024)
+	stream := &model.StreamObject{
+		BaseObject: &model.BaseObject{Key: "stream"},
+		Entries: []*model.StreamEntry{{
+			FirstMsgId: &model.StreamId{Ms: 1},
+			Fields:     []string{"payload"},
+			Msgs: []*model.StreamMessage{{
+				Id:     &model.StreamId{Ms: 1},
+				Fields: map[string]string{"payload": huge},
+			}},
+		}},
+		LastId: &model.StreamId{Ms: 1},
+	}
+	cmdLines := ObjectToCmd(stream, limit)
+	xadds := 0
+	for _, cmdLine := range cmdLines {
+		if string(cmdLine[0]) == "XADD" {
+			xadds++
+			if len(cmdLine) != 5 || string(cmdLine[4]) != huge {
+				t.Errorf("expect the whole entry in XADD")
+			}
+		}
+	}
+	if xadds != 1 {
+		t.Errorf("expect a single XADD, actual %d", xadds)
+	}
+	if len(warnings) != 1 || warnings[0].key != "stream" || warnings[0].size <= len(huge) {
+		t.Errorf("expect a size warning of stream, actual %v", warnings)
+	}
+
+	// collections are split under the limit
+	warnings = nil
+	values := make([][]byte, 100)
+	for i := range values {
+		values[i] = []byte(strings.Repeat(strconv.Itoa(i%10), 100))
+	}
+	list := &model.ListObject{BaseObject: &model.BaseObject{Key: "list"}, Values: values}
+	cmdLines = ObjectToCmd(list, WithMaxCommandSize(1000, func(key string, size int) {
+		warnings = append(warnings, warning{key, size})
+	}))
+	if len(cmdLines) < 2 {
+		t.Fatalf("expect list split into several commands, actual %d", len(cmdLines))
+	}
+	var replayed [][]byte
+	for _, cmdLine := range cmdLines {
+		if len(makeMultiBulkResp(cmdLine)) > 1000 {
+			t.Errorf("command of %d bytes exceeds limit", len(makeMultiBulkResp(cmdLine)))
+		}
+		if string(cmdLine[0]) != "RPUSH" || string(cmdLine[1]) != "list" {
+			t.Errorf("wrong command %s %s", cmdLine[0], cmdLine[1])
+		}
+		replayed = append(replayed, cmdLine[2:]...)
+	}
+	if len(replayed) != len(values) {
+		t.Fatalf("expect %d values, actual %d", len(values), len(replayed))
+	}
+	for i := range values {
+		if !bytes.Equal(replayed[i], values[i]) {
+			t.Errorf("value %d mismatch", i)
+		}
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expect no warning, actual %v", warnings)
+	}
+
+	hash := &model.HashObject{
+		BaseObject: &model.BaseObject{Key: "hash"},
+		Hash:       map[string][]byte{"a": []byte("1"), "b": []byte(strings.Repeat("x", 2000)), "c": []byte("3")},
+	}
+	cmdLines = ObjectToCmd(hash, lexOrder{}, WithMaxCommandSize(1000, func(key string, size int) {
+		warnings = append(warnings, warning{key, size})
+	}))
+	fields := 0
+	for _, cmdLine := range cmdLines {
+		if string(cmdLine[0]) != "HMSET" || len(cmdLine)%2 != 0 {
+			t.Errorf("wrong command %q", cmdLine)
+		}
+		fields += (len(cmdLine) - 2) / 2
+	}
+	if fields != 3 || len(warnings) != 1 || warnings[0].key != "hash" {
+		t.Errorf("expect 3 fields and a warning for huge field, actual %d, %v", fields, warnings)
+	}
+}
+
+func TestEmptyCollectionToCmd(t *testing.T) {
+	objects, err := ReadAll(mustOpen(t, "../cases/empty_collections.rdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range objects {
+		if cmdLines := ObjectToCmd(o); len(cmdLines) != 0 {
+			t.Errorf("expect no command for empty %s, actual %q", o.GetType(), cmdLines)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package helper
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// csvRows returns field and value of each element of object, field is index for list, member for sorted set
+// and empty for string and set. Hash fields are sorted unless their order is kept by WithOrderedFields
+func csvRows(object model.RedisObject) [][2]string {
+	var rows [][2]string
+	switch o := object.(type) {
+	case *model.StringObject:
+		rows = append(rows, [2]string{"", string(o.Value)})
+	case *model.ListObject:
+		for i, v := range o.Values {
+			rows = append(rows, [2]string{strconv.Itoa(i), string(v)})
+		}
+	case *model.SetObject:
+		for _, member := range o.Members {
+			rows = append(rows, [2]string{"", string(member)})
+		}
+	case *model.HashObject:
+		if o.Fields != nil {
+			for _, field := range o.Fields {
+				rows = append(rows, [2]string{field.Name, string(field.Value)})
+			}
+			break
+		}
+		fields := make([]string, 0, len(o.Hash))
+		for field := range o.Hash {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			rows = append(rows, [2]string{field, string(o.Hash[field])})
+		}
+	case *model.ZSetObject:
+		for _, entry := range o.Entries {
+			rows = append(rows, [2]string{entry.Member, model.FormatScore(entry.Score)})
+		}
+	}
+	return rows
+}
+
+// ToCSV reads rdb from reader and writes a row per element of strings, lists, sets, hashes and sorted sets into out
+// as csv, columns are database, key, type, field and value. Streams and module types are skipped.
+// Use WithBinaryEncoding to export binary values
+func ToCSV(reader io.Reader, out io.Writer, options ...interface{}) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	if out == nil {
+		return errors.New("output writer is required")
+	}
+	coreDec := core.NewDecoder(reader)
+	for _, opt := range options {
+		if o, ok := opt.(OrderedFieldsOption); ok && bool(o) {
+			coreDec.WithOrderedFields()
+		}
+	}
+	var dec decoder = coreDec
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	if dec, err = withBinaryEncoding(dec, options); err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, "database,key,type,field,value\n")
+	if err != nil {
+		return fmt.Errorf("write header failed: %v", err)
+	}
+	csvWriter := csv.NewWriter(out)
+	var writeErr error
+	err = dec.Parse(func(object model.RedisObject) bool {
+		db := strconv.Itoa(object.GetDBIndex())
+		for _, row := range csvRows(object) {
+			writeErr = csvWriter.Write([]string{db, object.GetKey(), object.GetType(), row[0], row[1]})
+			if writeErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return fmt.Errorf("csv write failed: %v", writeErr)
+	}
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		return fmt.Errorf("csv write failed: %v", err)
+	}
+	return nil
+}
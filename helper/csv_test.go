@@ -0,0 +1,90 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestToCSV(t *testing.T) {
+	data, err := os.ReadFile("../cases/memory.rdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.NewBuffer(nil)
+	if err = ToCSV(bytes.NewReader(data), out, WithRegexOption("^(list|zset)$")); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) < 3 {
+		t.Fatalf("expect rows of list and zset, actual %v", records)
+	}
+	listIndex := 0
+	for _, record := range records[1:] {
+		switch record[1] {
+		case "list":
+			if record[2] != "list" || record[3] != strconv.Itoa(listIndex) {
+				t.Errorf("wrong list row: %v", record)
+			}
+			listIndex++
+		case "zset":
+			if _, err := strconv.ParseFloat(record[4], 64); err != nil || record[2] != "zset" || record[3] == "" {
+				t.Errorf("wrong zset row: %v", record)
+			}
+		default:
+			t.Errorf("unexpected key: %v", record)
+		}
+	}
+
+	err = ToCSV(nil, out)
+	if err == nil || err.Error() != "src reader is required" {
+		t.Error("failed when empty reader")
+	}
+	err = ToCSV(bytes.NewReader(data), nil)
+	if err == nil || err.Error() != "output writer is required" {
+		t.Error("failed when empty writer")
+	}
+}
+
+func TestSpecialScores(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := ToCSV(mustOpen(t, "../cases/zset_special_scores.rdb"), out); err != nil {
+		t.Fatal(err)
+	}
+	expect := "database,key,type,field,value\n" +
+		"0,scores,zset,pos-inf,inf\n" +
+		"0,scores,zset,neg-inf,-inf\n" +
+		"0,scores,zset,nan,nan\n" +
+		"0,scores,zset,finite,1.5\n" +
+		"0,scores,zset,huge,1e+300\n" +
+		"0,scores,zset,ms,1700000000000\n"
+	if out.String() != expect {
+		t.Errorf("expect %q, actual %q", expect, out.String())
+	}
+
+	objects, err := ReadAll(mustOpen(t, "../cases/zset_special_scores.rdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdLines := ObjectToCmd(objects[0])
+	// redis rejects nan score, so nan is kept only in json and csv
+	expectCmd := "ZADD scores inf pos-inf -inf neg-inf 1.5 finite 1e+300 huge 1700000000000 ms"
+	if len(cmdLines) != 1 || string(bytes.Join(cmdLines[0], []byte(" "))) != expectCmd {
+		t.Errorf("expect %s, actual %q", expectCmd, cmdLines)
+	}
+	nanOnly := &model.ZSetObject{
+		BaseObject: &model.BaseObject{Key: "nan"},
+		Entries:    []*model.ZSetEntry{{Member: "nan", Score: math.NaN()}},
+	}
+	if cmdLines = ObjectToCmd(nanOnly); len(cmdLines) != 0 {
+		t.Errorf("expect no command for zset of nan scores, actual %q", cmdLines)
+	}
+}
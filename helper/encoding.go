@@ -0,0 +1,157 @@
+package helper
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// EncodingThresholds are redis configs deciding whether a collection is kept in a compact encoding.
+// Zero fields take defaults of redis 7.2
+type EncodingThresholds struct {
+	SetMaxIntsetEntries    int // set-max-intset-entries, default 512
+	SetMaxListpackEntries  int // set-max-listpack-entries, default 128
+	SetMaxListpackValue    int // set-max-listpack-value, default 64
+	HashMaxListpackEntries int // hash-max-listpack-entries, default 128
+	HashMaxListpackValue   int // hash-max-listpack-value, default 64
+	ZSetMaxListpackEntries int // zset-max-listpack-entries, default 128
+	ZSetMaxListpackValue   int // zset-max-listpack-value, default 64
+	// Margin is how far over a threshold a collection could be to be reported, as a fraction of the threshold,
+	// default 0.1
+	Margin float64
+}
+
+func orDefault(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+func (t EncodingThresholds) withDefaults() EncodingThresholds {
+	t.SetMaxIntsetEntries = orDefault(t.SetMaxIntsetEntries, 512)
+	t.SetMaxListpackEntries = orDefault(t.SetMaxListpackEntries, 128)
+	t.SetMaxListpackValue = orDefault(t.SetMaxListpackValue, 64)
+	t.HashMaxListpackEntries = orDefault(t.HashMaxListpackEntries, 128)
+	t.HashMaxListpackValue = orDefault(t.HashMaxListpackValue, 64)
+	t.ZSetMaxListpackEntries = orDefault(t.ZSetMaxListpackEntries, 128)
+	t.ZSetMaxListpackValue = orDefault(t.ZSetMaxListpackValue, 64)
+	if t.Margin <= 0 {
+		t.Margin = 0.1
+	}
+	return t
+}
+
+// isIntsetMember returns whether redis could store member in intset, i.e. it is a canonical 64 bit integer
+func isIntsetMember(member []byte) bool {
+	v, err := strconv.ParseInt(string(member), 10, 64)
+	return err == nil && strconv.FormatInt(v, 10) == string(member)
+}
+
+// compactThreshold returns the config whose threshold object exceeded, so that it was converted from compact encoding
+// to hashtable or skiplist. ok is false if object is compact, or a value is too long to be compact at all
+func compactThreshold(object model.RedisObject, t EncodingThresholds) (config string, threshold int, ok bool) {
+	switch o := object.(type) {
+	case *model.SetObject:
+		if o.GetEncoding() != model.SetEncoding {
+			return "", 0, false
+		}
+		allInts := true
+		for _, member := range o.Members {
+			if !isIntsetMember(member) {
+				allInts = false
+			}
+			if !allInts && len(member) > t.SetMaxListpackValue {
+				return "", 0, false
+			}
+		}
+		if allInts {
+			return "set-max-intset-entries", t.SetMaxIntsetEntries, true
+		}
+		return "set-max-listpack-entries", t.SetMaxListpackEntries, true
+	case *model.HashObject:
+		if o.GetEncoding() != model.HashEncoding && o.GetEncoding() != model.HashExEncoding {
+			return "", 0, false
+		}
+		for field, v := range o.Hash {
+			if len(field) > t.HashMaxListpackValue || len(v) > t.HashMaxListpackValue {
+				return "", 0, false
+			}
+		}
+		return "hash-max-listpack-entries", t.HashMaxListpackEntries, true
+	case *model.ZSetObject:
+		if o.GetEncoding() != model.ZSetEncoding && o.GetEncoding() != model.ZSet2Encoding {
+			return "", 0, false
+		}
+		for _, entry := range o.Entries {
+			if len(entry.Member) > t.ZSetMaxListpackValue {
+				return "", 0, false
+			}
+		}
+		return "zset-max-listpack-entries", t.ZSetMaxListpackEntries, true
+	}
+	return "", 0, false
+}
+
+// EncodingOptimizationReport reads rdb and writes sets, hashes and sorted sets which are just over the entries
+// threshold of their compact encoding (intset or listpack) into out as csv, so that raising the threshold to
+// the threshold column would keep them compact. Collections having a value longer than max value config, or more
+// elements than threshold * (1 + Margin), are not reported. Columns are database, key, type, encoding, count,
+// config and threshold
+func EncodingOptimizationReport(reader io.Reader, thresholds EncodingThresholds, out io.Writer,
+	options ...interface{}) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	if out == nil {
+		return errors.New("output writer is required")
+	}
+	thresholds = thresholds.withDefaults()
+	var dec decoder = core.NewDecoder(reader)
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, "database,key,type,encoding,count,config,threshold\n")
+	if err != nil {
+		return fmt.Errorf("write header failed: %v", err)
+	}
+	csvWriter := csv.NewWriter(out)
+	var writeErr error
+	err = dec.Parse(func(object model.RedisObject) bool {
+		config, threshold, ok := compactThreshold(object, thresholds)
+		if !ok {
+			return true
+		}
+		count := object.GetElemCount()
+		if count <= threshold || float64(count) > float64(threshold)*(1+thresholds.Margin) {
+			return true
+		}
+		writeErr = csvWriter.Write([]string{
+			strconv.Itoa(object.GetDBIndex()),
+			object.GetKey(),
+			object.GetType(),
+			object.GetEncoding(),
+			strconv.Itoa(count),
+			config,
+			strconv.Itoa(count),
+		})
+		return writeErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return fmt.Errorf("csv write failed: %v", writeErr)
+	}
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		return fmt.Errorf("csv write failed: %v", err)
+	}
+	return nil
+}
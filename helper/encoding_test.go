@@ -0,0 +1,85 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+)
+
+// hashtableSetRDB returns rdb of sets in hashtable encoding, encoder writes integer sets as intset regardless of size
+func hashtableSetRDB(sets map[string]int) []byte {
+	rdb := []byte("REDIS0009")
+	rdb = append(rdb, 0xfe, 0x00)
+	for key, n := range sets {
+		rdb = append(rdb, 0x02, byte(len(key)))
+		rdb = append(rdb, key...)
+		rdb = append(rdb, 0x40|byte(n>>8), byte(n)) // 14 bit length
+		for i := 0; i < n; i++ {
+			member := strconv.Itoa(i)
+			rdb = append(rdb, byte(len(member)))
+			rdb = append(rdb, member...)
+		}
+	}
+	rdb = append(rdb, 0xff)
+	return append(rdb, make([]byte, 8)...)
+}
+
+func readEncodingReport(t *testing.T, data []byte, thresholds EncodingThresholds) [][]string {
+	out := bytes.NewBuffer(nil)
+	if err := EncodingOptimizationReport(bytes.NewReader(data), thresholds, out); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(records[0], ",") != "database,key,type,encoding,count,config,threshold" {
+		t.Fatalf("wrong header: %v", records[0])
+	}
+	return records[1:]
+}
+
+func TestEncodingOptimizationReport(t *testing.T) {
+	data := hashtableSetRDB(map[string]int{"marginal": 513, "far": 600})
+	rows := readEncodingReport(t, data, EncodingThresholds{})
+	if len(rows) != 1 {
+		t.Fatalf("expect 1 row, actual %v", rows)
+	}
+	expected := "0,marginal,set,set,513,set-max-intset-entries,513"
+	if strings.Join(rows[0], ",") != expected {
+		t.Errorf("expect %s, actual %v", expected, rows[0])
+	}
+	if rows = readEncodingReport(t, data, EncodingThresholds{Margin: 0.2}); len(rows) != 2 {
+		t.Errorf("expect both sets with larger margin, actual %v", rows)
+	}
+	if rows = readEncodingReport(t, data, EncodingThresholds{SetMaxIntsetEntries: 1024}); len(rows) != 0 {
+		t.Errorf("expect no row under threshold, actual %v", rows)
+	}
+
+	short := make(map[string][]byte)
+	long := make(map[string][]byte)
+	for i := 0; i < 130; i++ {
+		short["f"+strconv.Itoa(i)] = []byte("v")
+		long["f"+strconv.Itoa(i)] = []byte("v")
+	}
+	long["f0"] = []byte(strings.Repeat("v", 65))
+	data = buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		enc.SetHashZipListOpt(64, 128)
+		check(enc.WriteDBHeader(0, 2, 0))
+		check(enc.WriteHashMapObject("short", short))
+		check(enc.WriteHashMapObject("long", long))
+	})
+	rows = readEncodingReport(t, data, EncodingThresholds{})
+	expected = "0,short,hash,hash,130,hash-max-listpack-entries,130"
+	if len(rows) != 1 || strings.Join(rows[0], ",") != expected {
+		t.Errorf("expect %s, actual %v", expected, rows)
+	}
+
+	if err := EncodingOptimizationReport(nil, EncodingThresholds{}, bytes.NewBuffer(nil)); err == nil {
+		t.Error("expect error for nil reader")
+	}
+}
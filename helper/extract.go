@@ -0,0 +1,139 @@
+package helper
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// globMatch reports whether key matches pattern like KEYS and SCAN MATCH of redis: * matches any sequence,
+// ? matches a single byte, [abc], [^abc] and [a-z] match a set of bytes, and \ escapes the next byte
+func globMatch(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if globMatch(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			key = key[1:]
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			pattern = pattern[1:]
+			not := len(pattern) > 0 && pattern[0] == '^'
+			if not {
+				pattern = pattern[1:]
+			}
+			match := false
+			for len(pattern) > 0 && pattern[0] != ']' {
+				if pattern[0] == '\\' && len(pattern) > 1 {
+					pattern = pattern[1:]
+					if pattern[0] == key[0] {
+						match = true
+					}
+				} else if len(pattern) > 2 && pattern[1] == '-' {
+					start, end := pattern[0], pattern[2]
+					if start > end {
+						start, end = end, start
+					}
+					if key[0] >= start && key[0] <= end {
+						match = true
+					}
+					pattern = pattern[2:]
+				} else if pattern[0] == key[0] {
+					match = true
+				}
+				pattern = pattern[1:]
+			}
+			if match == not {
+				return false
+			}
+			if len(pattern) == 0 {
+				// unclosed bracket matches like redis, which treats the end of pattern as ]
+				return len(key) == 1
+			}
+			key = key[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(key) == 0 || pattern[0] != key[0] {
+				return false
+			}
+			key = key[1:]
+		}
+		pattern = pattern[1:]
+	}
+	return len(key) == 0
+}
+
+// ExtractKeys reads rdb from in and writes keys matching glob pattern into out as a new rdb, keys stay in their
+// databases with their expirations. Values of other keys are skipped without decoding, and output is written
+// while parsing, so it could pull a few keys out of a large dump for local testing.
+// Pass encoder.WithTargetVersion to load the output into an older redis
+func ExtractKeys(in io.Reader, out io.Writer, pattern string, options ...interface{}) error {
+	if in == nil {
+		return errors.New("src reader is required")
+	}
+	if out == nil {
+		return errors.New("output writer is required")
+	}
+	var dec decoder = core.NewDecoder(in).WithKeyFilter(func(key string) bool {
+		return globMatch(pattern, key)
+	})
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(out)
+	enc := core.NewEncoder(writer, options...)
+	if err = enc.WriteHeader(); err != nil {
+		return err
+	}
+	currentDB := -1
+	var writeErr error
+	err = dec.Parse(func(object model.RedisObject) bool {
+		if object.GetDBIndex() != currentDB {
+			currentDB = object.GetDBIndex()
+			// key count is unknown before parsing finished, resize db is just a hint
+			if writeErr = enc.WriteDBHeader(uint(currentDB), 0, 0); writeErr != nil {
+				return false
+			}
+		}
+		writeErr = writeObject(enc, object)
+		return writeErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if err = enc.WriteEnd(); err != nil {
+		return err
+	}
+	if err = writer.Flush(); err != nil {
+		return fmt.Errorf("write rdb failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package helper
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		key     string
+		match   bool
+	}{
+		{"*", "", true},
+		{"session:*", "session:1", true},
+		{"session:*", "sessions:1", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hallo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{"a*b*c", "aXbYc", true},
+		{"a*b*c", "aXbY", false},
+		{"[abc", "a", true},
+	}
+	for _, c := range cases {
+		if globMatch(c.pattern, c.key) != c.match {
+			t.Errorf("%s matching %s: expect %v", c.pattern, c.key, c.match)
+		}
+	}
+}
+
+func TestExtractKeys(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).UnixMilli()
+	buf := bytes.NewBuffer(nil)
+	enc := encoder.NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 3, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteStringObject("session:1", []byte("a"), encoder.WithTTL(uint64(expiration))); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteStringObject("user:1", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteHashMapObject("session:2", map[string][]byte{"f": []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(3, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteListObject("session:3", [][]byte{[]byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteSetObject("cache:1", [][]byte{[]byte("y")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := ExtractKeys(bytes.NewReader(buf.Bytes()), out, "session:*"); err != nil {
+		t.Fatal(err)
+	}
+	actual := make(map[string]model.RedisObject)
+	err := core.NewDecoder(bytes.NewReader(out.Bytes())).Parse(func(o model.RedisObject) bool {
+		actual[o.GetKey()] = o
+		return true
+	})
+	if err != nil {
+		t.Fatalf("extracted rdb is not loadable: %v", err)
+	}
+	expectDB := map[string]int{"session:1": 0, "session:2": 0, "session:3": 3}
+	if len(actual) != len(expectDB) {
+		t.Fatalf("expect %d keys, actual %d", len(expectDB), len(actual))
+	}
+	for key, db := range expectDB {
+		o := actual[key]
+		if o == nil || o.GetDBIndex() != db {
+			t.Errorf("expect %s in db %d", key, db)
+		}
+	}
+	if e := actual["session:1"].GetExpiration(); e == nil || e.UnixMilli() != expiration {
+		t.Error("expiration should be kept")
+	}
+	if h, ok := actual["session:2"].(*model.HashObject); !ok || string(h.Hash["f"]) != "v" {
+		t.Error("wrong value of session:2")
+	}
+
+	// options apply after the pattern
+	out.Reset()
+	if err = ExtractKeys(bytes.NewReader(buf.Bytes()), out, "session:*", WithRegexOption("^session:[12]$")); err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	err = core.NewDecoder(bytes.NewReader(out.Bytes())).Parse(func(o model.RedisObject) bool {
+		count++
+		return true
+	})
+	if err != nil || count != 2 {
+		t.Errorf("expect 2 keys, actual %d, %v", count, err)
+	}
+
+	// hashtag filter is another key filter of core decoder, keys must pass both
+	out.Reset()
+	if err = ExtractKeys(bytes.NewReader(buf.Bytes()), out, "session:*", WithHashtagFilter("user:1")); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ReadAll(bytes.NewReader(out.Bytes()))
+	if err != nil || len(keys) != 0 {
+		t.Errorf("expect no keys, actual %v, %v", keys, err)
+	}
+
+	if err = ExtractKeys(nil, out, "*"); err == nil || err.Error() != "src reader is required" {
+		t.Error("failed when empty reader")
+	}
+}
+
+func TestExtractKeysTargetVersion(t *testing.T) {
+	data, err := os.ReadFile("../cases/listpack.rdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := ReadAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.NewBuffer(nil)
+	if err = ExtractKeys(bytes.NewReader(data), out, "*", encoder.WithTargetVersion(9)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out.Bytes(), []byte("REDIS0009")) {
+		t.Fatalf("wrong header: %q", out.Bytes()[:9])
+	}
+	// encodings redis 6 is able to load
+	loadable := map[string]bool{
+		model.StringRawEncoding:    true,
+		model.StringIntEncoding:    true,
+		model.StringEmbStrEncoding: true,
+		model.QuickListEncoding:    true,
+		model.ZipListEncoding:      true,
+		model.HashEncoding:         true,
+		model.SetEncoding:          true,
+		model.IntSetEncoding:       true,
+		model.ZSet2Encoding:        true,
+	}
+	actual, err := ReadAll(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual) != len(expect) {
+		t.Fatalf("expect %d keys, actual %d", len(expect), len(actual))
+	}
+	for i, o := range actual {
+		if !loadable[o.GetEncoding()] {
+			t.Errorf("%s is encoded as %s", o.GetKey(), o.GetEncoding())
+		}
+		sum1, _ := ObjectHash(o)
+		sum2, _ := ObjectHash(expect[i])
+		if !bytes.Equal(sum1, sum2) {
+			t.Errorf("value of %s changed", o.GetKey())
+		}
+	}
+
+	hfe, err := os.ReadFile("../cases/hash_with_hfe.rdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ExtractKeys(bytes.NewReader(hfe), out, "*", encoder.WithTargetVersion(9))
+	if err == nil || !strings.Contains(err.Error(), "requires rdb version 12") {
+		t.Errorf("expect error of hash field expiration, actual %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package helper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+)
+
+// buildRDB returns an rdb whose content between header and end is written by write,
+// check fails the test if a write returns error
+func buildRDB(t testing.TB, write func(enc *encoder.Encoder, check func(error)), options ...interface{}) []byte {
+	t.Helper()
+	buf := bytes.NewBuffer(nil)
+	enc := encoder.NewEncoder(buf, options...)
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(enc.WriteHeader())
+	write(enc, check)
+	check(enc.WriteEnd())
+	return buf.Bytes()
+}
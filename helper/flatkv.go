@@ -0,0 +1,70 @@
+package helper
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// jsonValue is the default serializer of ToFlatKV, it marshals value of ToMap as json
+func jsonValue(object model.RedisObject) []byte {
+	data, err := json.Marshal(ToMap(object)["value"])
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// ToFlatKV reads rdb from reader and writes a RESP `SET key value` command per key into out, so the dump could be
+// loaded into a store which only knows GET and SET. Strings are written as is, other types are serialized into
+// a single value by serializer, which returns nil to skip the key. serializer is json of value of ToMap if it is nil,
+// e.g. array for list and set, object for hash. Expirations are kept by PXAT. Keys of all databases are written
+// without SELECT, use FindCrossDBCollisions to check whether they clash
+func ToFlatKV(reader io.Reader, out io.Writer, serializer func(model.RedisObject) []byte, options ...interface{}) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	if out == nil {
+		return errors.New("output writer is required")
+	}
+	if serializer == nil {
+		serializer = jsonValue
+	}
+	var dec decoder = core.NewDecoder(reader)
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(out)
+	var writeErr error
+	err = dec.Parse(func(object model.RedisObject) bool {
+		var value []byte
+		if str, ok := object.(*model.StringObject); ok {
+			value = str.Value
+		} else if value = serializer(object); value == nil {
+			return true
+		}
+		cmdLine := CmdLine{setCmd, []byte(object.GetKey()), value}
+		if expiration := object.GetExpiration(); expiration != nil {
+			cmdLine = append(cmdLine, []byte("PXAT"), []byte(strconv.FormatInt(expiration.UnixMilli(), 10)))
+		}
+		_, writeErr = writer.Write(makeMultiBulkResp(cmdLine))
+		return writeErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return fmt.Errorf("write command failed: %v", writeErr)
+	}
+	if err = writer.Flush(); err != nil {
+		return fmt.Errorf("write command failed: %v", err)
+	}
+	return nil
+}
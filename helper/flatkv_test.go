@@ -0,0 +1,85 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestToFlatKV(t *testing.T) {
+	expireAt := time.Now().Add(time.Hour).UnixMilli()
+	data := buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 3, 1))
+		check(enc.WriteStringObject("s", []byte("v"), encoder.WithTTL(uint64(expireAt))))
+		check(enc.WriteHashMapObject("h", map[string][]byte{"a": []byte("1"), "b": []byte("2")}))
+		check(enc.WriteListObject("l", [][]byte{[]byte("x"), []byte("y")}))
+	})
+
+	readCommands := func(out *bytes.Buffer) map[string][]string {
+		commands := make(map[string][]string)
+		err := ParseAOFCommands(out, func(cmdLine CmdLine) bool {
+			args := make([]string, len(cmdLine))
+			for i, arg := range cmdLine {
+				args[i] = string(arg)
+			}
+			if args[0] != "SET" {
+				t.Errorf("expect SET only, actual %v", args)
+			}
+			commands[args[1]] = args
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return commands
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := ToFlatKV(bytes.NewReader(data), out, nil); err != nil {
+		t.Fatal(err)
+	}
+	commands := readCommands(out)
+	if len(commands) != 3 {
+		t.Fatalf("expect 3 commands, actual %v", commands)
+	}
+	if s := commands["s"]; len(s) != 5 || s[2] != "v" || s[3] != "PXAT" || s[4] != strconv.FormatInt(expireAt, 10) {
+		t.Errorf("wrong command of string: %v", s)
+	}
+	h := commands["h"]
+	if len(h) != 3 {
+		t.Fatalf("expect hash in a single SET, actual %v", h)
+	}
+	hash := make(map[string]string)
+	if err := json.Unmarshal([]byte(h[2]), &hash); err != nil || len(hash) != 2 || hash["a"] != "1" || hash["b"] != "2" {
+		t.Errorf("wrong value of hash: %s, %v", h[2], err)
+	}
+	if l := commands["l"]; len(l) != 3 || l[2] != `["x","y"]` {
+		t.Errorf("wrong command of list: %v", l)
+	}
+
+	// custom serializer, nil skips the key
+	out = bytes.NewBuffer(nil)
+	err := ToFlatKV(bytes.NewReader(data), out, func(object model.RedisObject) []byte {
+		if list, ok := object.(*model.ListObject); ok {
+			return bytes.Join(list.Values, []byte(","))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands = readCommands(out)
+	if len(commands) != 2 || commands["l"][2] != "x,y" || commands["h"] != nil {
+		t.Errorf("wrong commands of custom serializer: %v", commands)
+	}
+
+	if err = ToFlatKV(nil, out, nil); err == nil || !strings.Contains(err.Error(), "reader") {
+		t.Errorf("expect error for nil reader, actual %v", err)
+	}
+}
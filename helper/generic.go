@@ -0,0 +1,42 @@
+package helper
+
+import (
+	"errors"
+	"io"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// ParseType reads rdb from reader and calls cb only with objects of type T, e.g. *model.StreamObject.
+// cb returns true to continue, returns false to stop the iteration
+func ParseType[T model.RedisObject](reader io.Reader, cb func(T) bool, options ...interface{}) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	var dec decoder = core.NewDecoder(reader)
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	return dec.Parse(func(object model.RedisObject) bool {
+		if obj, ok := model.As[T](object); ok {
+			return cb(obj)
+		}
+		return true
+	})
+}
+
+// ReadAll reads all objects in rdb into a slice, it is handy for small dumps and tests.
+// It is inappropriate for large files since all objects are kept in memory, use Parse with callback instead
+func ReadAll(reader io.Reader, options ...interface{}) ([]model.RedisObject, error) {
+	var objects []model.RedisObject
+	err := ParseType(reader, func(object model.RedisObject) bool {
+		objects = append(objects, object)
+		return true
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
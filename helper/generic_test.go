@@ -0,0 +1,145 @@
+package helper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+func buildAllTypesRDB(t *testing.T) []byte {
+	return buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 6, 0))
+		check(enc.WriteStringObject("string", []byte("value")))
+		check(enc.WriteListObject("list", [][]byte{[]byte("a"), []byte("b")}))
+		check(enc.WriteSetObject("set", [][]byte{[]byte("a"), []byte("b")}))
+		check(enc.WriteHashMapObject("hash", map[string][]byte{"a": []byte("1")}))
+		check(enc.WriteZSetObject("zset", []*model.ZSetEntry{{Member: "a", Score: 1}}))
+		check(enc.WriteStreamObject("stream", &model.StreamObject{
+			Version: 1,
+			LastId:  &model.StreamId{},
+		}))
+	})
+}
+
+func TestAs(t *testing.T) {
+	var obj model.RedisObject = &model.HashObject{
+		BaseObject: &model.BaseObject{Key: "hash"},
+		Hash:       map[string][]byte{"a": []byte("1")},
+	}
+	hash, ok := model.As[*model.HashObject](obj)
+	if !ok || string(hash.Hash["a"]) != "1" {
+		t.Error("expect hash object")
+	}
+	if _, ok := model.As[*model.StringObject](obj); ok {
+		t.Error("hash should not be converted to string object")
+	}
+}
+
+func TestParseType(t *testing.T) {
+	data := buildAllTypesRDB(t)
+	collect := func(keys *[]string) func(model.RedisObject) {
+		return func(obj model.RedisObject) {
+			*keys = append(*keys, obj.GetKey())
+		}
+	}
+
+	var keys []string
+	add := collect(&keys)
+	err := ParseType(bytes.NewReader(data), func(obj *model.StringObject) bool {
+		if string(obj.Value) != "value" {
+			t.Errorf("wrong value: %s", obj.Value)
+		}
+		add(obj)
+		return true
+	})
+	if err == nil {
+		err = ParseType(bytes.NewReader(data), func(obj *model.ListObject) bool {
+			add(obj)
+			return len(obj.Values) == 2
+		})
+	}
+	if err == nil {
+		err = ParseType(bytes.NewReader(data), func(obj *model.SetObject) bool {
+			add(obj)
+			return len(obj.Members) == 2
+		})
+	}
+	if err == nil {
+		err = ParseType(bytes.NewReader(data), func(obj *model.HashObject) bool {
+			add(obj)
+			return string(obj.Hash["a"]) == "1"
+		})
+	}
+	if err == nil {
+		err = ParseType(bytes.NewReader(data), func(obj *model.ZSetObject) bool {
+			add(obj)
+			return obj.Entries[0].Score == 1
+		})
+	}
+	if err == nil {
+		err = ParseType(bytes.NewReader(data), func(obj *model.StreamObject) bool {
+			add(obj)
+			return obj.Version == 1
+		})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"string", "list", "set", "hash", "zset", "stream"}
+	if len(keys) != len(expect) {
+		t.Fatalf("expect keys %v, actual %v", expect, keys)
+	}
+	for i := range expect {
+		if keys[i] != expect[i] {
+			t.Errorf("expect keys %v, actual %v", expect, keys)
+			break
+		}
+	}
+
+	// options of decoder are supported
+	count := 0
+	err = ParseType(bytes.NewReader(data), func(obj model.RedisObject) bool {
+		count++
+		return true
+	}, WithRegexOption("^(list|set)$"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expect 2 objects, actual %d", count)
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	data := buildAllTypesRDB(t)
+	cases := []struct {
+		name    string
+		options []interface{}
+		keys    []string
+	}{
+		{name: "all", keys: []string{"string", "list", "set", "hash", "zset", "stream"}},
+		{name: "regex", options: []interface{}{WithRegexOption("^s")}, keys: []string{"string", "set", "stream"}},
+		{name: "hashtag", options: []interface{}{WithHashtagFilter("hash")}, keys: []string{"hash"}},
+	}
+	for _, c := range cases {
+		objects, err := ReadAll(bytes.NewReader(data), c.options...)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if len(objects) != len(c.keys) {
+			t.Errorf("%s: expect %d objects, actual %d", c.name, len(c.keys), len(objects))
+			continue
+		}
+		for i, obj := range objects {
+			if obj.GetKey() != c.keys[i] {
+				t.Errorf("%s: expect key %s, actual %s", c.name, c.keys[i], obj.GetKey())
+			}
+		}
+	}
+	if _, err := ReadAll(bytes.NewReader(nil)); err == nil {
+		t.Error("expect error for empty file")
+	}
+}
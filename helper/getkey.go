@@ -0,0 +1,63 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// GetKey reads rdb from reader and returns the object of key in db, or core.ErrKeyNotFound.
+// Values of other keys are skipped without decoding and it stops once the key is found.
+// reader is rewound to the beginning, so the same reader can be used for several lookups
+func GetKey(reader io.ReadSeeker, db int, key string, options ...interface{}) (model.RedisObject, error) {
+	if reader == nil {
+		return nil, errors.New("src reader is required")
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var dec decoder = core.NewDecoder(reader).WithKeyFilter(func(k string) bool {
+		return k == key
+	})
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return nil, err
+	}
+	var result model.RedisObject
+	err = dec.Parse(func(object model.RedisObject) bool {
+		if object.GetDBIndex() == db && object.GetKey() == key {
+			result = object
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, core.ErrKeyNotFound
+	}
+	return result, nil
+}
+
+// DecodeObjectAt decodes the object starting at startOffset of reader, which is BaseObject.StartOffset
+// recorded in a previous parse with core.Decoder.WithByteRanges. db is the database of the object.
+// It allows random access to keys of a large rdb with an external index
+func DecodeObjectAt(reader io.ReaderAt, startOffset int64, db int) (model.RedisObject, error) {
+	if reader == nil {
+		return nil, errors.New("src reader is required")
+	}
+	if startOffset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+	section := io.NewSectionReader(reader, startOffset, math.MaxInt64-startOffset)
+	obj, err := core.NewDecoder(section).ParseObject(db)
+	if err != nil {
+		return nil, fmt.Errorf("decode object at %d failed: %w", startOffset, err)
+	}
+	return obj, nil
+}
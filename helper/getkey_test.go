@@ -0,0 +1,141 @@
+package helper
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestGetKey(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	keyCount := 10000
+	enc := encoder.NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Error(err)
+		return
+	}
+	for db := 0; db < 2; db++ {
+		if err := enc.WriteDBHeader(uint(db), uint64(keyCount), 0); err != nil {
+			t.Error(err)
+			return
+		}
+		for i := 0; i < keyCount; i++ {
+			value := "value:" + strconv.Itoa(db) + ":" + strconv.Itoa(i)
+			if err := enc.WriteStringObject("key:"+strconv.Itoa(i), []byte(value)); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Error(err)
+		return
+	}
+	reader := bytes.NewReader(buf.Bytes())
+
+	obj, err := GetKey(reader, 0, "key:5000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	str, ok := obj.(*model.StringObject)
+	if !ok || str.Key != "key:5000" || string(str.Value) != "value:0:5000" {
+		t.Errorf("wrong object: %v", obj)
+	}
+	obj, err = GetKey(reader, 1, "key:5000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if str, ok := obj.(*model.StringObject); !ok || string(str.Value) != "value:1:5000" {
+		t.Errorf("wrong object in db 1: %v", obj)
+	}
+	if _, err = GetKey(reader, 0, "key:10000"); !errors.Is(err, core.ErrKeyNotFound) {
+		t.Errorf("expect ErrKeyNotFound, actual %v", err)
+	}
+	if _, err = GetKey(reader, 2, "key:1"); !errors.Is(err, core.ErrKeyNotFound) {
+		t.Errorf("expect ErrKeyNotFound for missing db, actual %v", err)
+	}
+}
+
+func TestDecodeObjectAt(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := encoder.NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	expireAt := uint64(time.Now().Add(time.Hour).UnixNano() / 1e6)
+	for db := 0; db < 2; db++ {
+		if err := enc.WriteDBHeader(uint(db), 100, 50); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			key := "key:" + strconv.Itoa(i)
+			var err error
+			if i%2 == 0 {
+				err = enc.WriteStringObject(key, []byte("value:"+strconv.Itoa(db)), encoder.WithTTL(expireAt))
+			} else {
+				err = enc.WriteListObject(key, [][]byte{[]byte("a"), []byte(key)})
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.NewReader(buf.Bytes())
+
+	// first pass builds an index of offsets
+	type location struct {
+		offset int64
+		db     int
+	}
+	index := make(map[string]location)
+	expect := make(map[string]model.RedisObject)
+	err := core.NewDecoder(bytes.NewReader(buf.Bytes())).WithByteRanges().Parse(func(object model.RedisObject) bool {
+		name := strconv.Itoa(object.GetDBIndex()) + "/" + object.GetKey()
+		var offset int64
+		switch o := object.(type) {
+		case *model.StringObject:
+			offset = o.StartOffset
+		case *model.ListObject:
+			offset = o.StartOffset
+		}
+		index[name] = location{offset: offset, db: object.GetDBIndex()}
+		expect[name] = object
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"0/key:0", "0/key:51", "1/key:98", "1/key:99"} {
+		loc := index[name]
+		obj, err := DecodeObjectAt(data, int64(loc.offset), loc.db)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		e := expect[name]
+		if obj.GetKey() != e.GetKey() || obj.GetDBIndex() != e.GetDBIndex() || obj.GetType() != e.GetType() {
+			t.Errorf("%s: expect %s %s in db %d, actual %s %s in db %d", name,
+				e.GetType(), e.GetKey(), e.GetDBIndex(), obj.GetType(), obj.GetKey(), obj.GetDBIndex())
+		}
+		if (e.GetExpiration() == nil) != (obj.GetExpiration() == nil) {
+			t.Errorf("%s: wrong expiration", name)
+		}
+		if str, ok := obj.(*model.StringObject); ok && string(str.Value) != "value:"+strconv.Itoa(loc.db) {
+			t.Errorf("%s: wrong value %s", name, str.Value)
+		}
+	}
+	if _, err := DecodeObjectAt(data, int64(buf.Len()-9), 0); err == nil {
+		t.Error("expect error at EOF opcode")
+	}
+}
@@ -0,0 +1,160 @@
+package helper
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+// GrowthPercentOption makes PrefixGrowth rank prefixes by percentage growth instead of absolute growth
+type GrowthPercentOption bool
+
+// WithGrowthPercent makes PrefixGrowth rank prefixes by percentage growth, prefixes absent in old rdb rank first
+func WithGrowthPercent() GrowthPercentOption {
+	return GrowthPercentOption(true)
+}
+
+type prefixGrowth struct {
+	db          int
+	prefix      string
+	oldSize     int
+	newSize     int
+	oldKeyCount int
+	newKeyCount int
+}
+
+func (g *prefixGrowth) growth() int {
+	return g.newSize - g.oldSize
+}
+
+// growthPercent returns +Inf if prefix is absent in old rdb
+func (g *prefixGrowth) growthPercent() float64 {
+	if g.oldSize == 0 {
+		if g.newSize == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return float64(g.growth()) * 100 / float64(g.oldSize)
+}
+
+// keyPrefix returns the first segment of key with separator, or the whole key if it has no separator
+func keyPrefix(key, separator string) string {
+	if separator == "" {
+		return key
+	}
+	if i := strings.Index(key, separator); i >= 0 {
+		return key[:i+len(separator)]
+	}
+	return key
+}
+
+// PrefixGrowth computes total size of keys of each prefix in oldRDB and newRDB, then writes top N prefixes by growth
+// to out as csv. Prefix is the first segment of key split by separator, e.g. `user:` of `user:1:name`.
+// topN == 0 means all prefixes, which are ranked by absolute growth unless WithGrowthPercent
+func PrefixGrowth(oldRDB, newRDB string, separator string, topN int, out io.Writer, options ...interface{}) error {
+	if oldRDB == "" || newRDB == "" {
+		return errors.New("src file path is required")
+	}
+	if out == nil {
+		return errors.New("output writer is required")
+	}
+	if topN < 0 {
+		return errors.New("n must greater than 0")
+	}
+	byPercent := false
+	for _, opt := range options {
+		switch o := opt.(type) {
+		case GrowthPercentOption:
+			byPercent = bool(o)
+		}
+	}
+	growths := make(map[string]*prefixGrowth)
+	getGrowth := func(object model.RedisObject) *prefixGrowth {
+		prefix := keyPrefix(object.GetKey(), separator)
+		key := genKey(object.GetDBIndex(), prefix)
+		g := growths[key]
+		if g == nil {
+			g = &prefixGrowth{
+				db:     object.GetDBIndex(),
+				prefix: prefix,
+			}
+			growths[key] = g
+		}
+		return g
+	}
+	err := parseRDBFile(oldRDB, func(object model.RedisObject) bool {
+		g := getGrowth(object)
+		g.oldSize += object.GetSize()
+		g.oldKeyCount++
+		return true
+	}, options...)
+	if err != nil {
+		return err
+	}
+	err = parseRDBFile(newRDB, func(object model.RedisObject) bool {
+		g := getGrowth(object)
+		g.newSize += object.GetSize()
+		g.newKeyCount++
+		return true
+	}, options...)
+	if err != nil {
+		return err
+	}
+
+	list := make([]*prefixGrowth, 0, len(growths))
+	for _, g := range growths {
+		list = append(list, g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if byPercent {
+			if pi, pj := list[i].growthPercent(), list[j].growthPercent(); pi != pj {
+				return pi > pj
+			}
+		}
+		if gi, gj := list[i].growth(), list[j].growth(); gi != gj {
+			return gi > gj
+		}
+		if list[i].db != list[j].db {
+			return list[i].db < list[j].db
+		}
+		return list[i].prefix < list[j].prefix
+	})
+	if topN > 0 && len(list) > topN {
+		list = list[:topN]
+	}
+
+	_, err = io.WriteString(out, "database,prefix,old_size,new_size,growth,growth_percent,old_key_count,new_key_count\n")
+	if err != nil {
+		return fmt.Errorf("write header failed: %v", err)
+	}
+	csvWriter := csv.NewWriter(out)
+	for _, g := range list {
+		percent := "inf"
+		if p := g.growthPercent(); !math.IsInf(p, 1) {
+			percent = strconv.FormatFloat(p, 'f', 2, 64)
+		}
+		err = csvWriter.Write([]string{
+			strconv.Itoa(g.db),
+			g.prefix,
+			strconv.Itoa(g.oldSize),
+			strconv.Itoa(g.newSize),
+			strconv.Itoa(g.growth()),
+			percent,
+			strconv.Itoa(g.oldKeyCount),
+			strconv.Itoa(g.newKeyCount),
+		})
+		if err != nil {
+			return fmt.Errorf("csv write failed: %v", err)
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
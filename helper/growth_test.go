@@ -0,0 +1,106 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+)
+
+func writeStringsRDB(t *testing.T, filename string, keys []string) {
+	rdbFile, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	enc := encoder.NewEncoder(rdbFile)
+	if err = enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err = enc.WriteDBHeader(0, uint64(len(keys)), 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if err = enc.WriteStringObject(key, []byte(strings.Repeat("v", 100))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrefixGrowth(t *testing.T) {
+	err := os.MkdirAll("tmp", os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	var oldKeys, newKeys []string
+	// ids have the same width, so that all keys of a prefix have the same size
+	for i := 10; i < 20; i++ {
+		oldKeys = append(oldKeys, "user:"+strconv.Itoa(i), "order:"+strconv.Itoa(i))
+		newKeys = append(newKeys, "order:"+strconv.Itoa(i))
+	}
+	for i := 10; i < 30; i++ {
+		newKeys = append(newKeys, "user:"+strconv.Itoa(i))
+	}
+	newKeys = append(newKeys, "cart:1")
+	oldRDB := filepath.Join("tmp", "growth_old.rdb")
+	newRDB := filepath.Join("tmp", "growth_new.rdb")
+	writeStringsRDB(t, oldRDB, oldKeys)
+	writeStringsRDB(t, newRDB, newKeys)
+
+	out := bytes.NewBuffer(nil)
+	if err = PrefixGrowth(oldRDB, newRDB, ":", 2, out); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expect top 2 prefixes, actual %v", records)
+	}
+	user := records[1]
+	if user[1] != "user:" || user[5] != "100.00" || user[6] != "10" || user[7] != "20" {
+		t.Errorf("user: should have doubled, actual %v", user)
+	}
+	oldSize, _ := strconv.Atoi(user[2])
+	newSize, _ := strconv.Atoi(user[3])
+	growth, _ := strconv.Atoi(user[4])
+	if newSize != 2*oldSize || growth != oldSize {
+		t.Errorf("wrong sizes of user: %v", user)
+	}
+	if records[2][1] != "cart:" || records[2][5] != "inf" {
+		t.Errorf("cart: should be the second, actual %v", records[2])
+	}
+
+	out.Reset()
+	if err = PrefixGrowth(oldRDB, newRDB, ":", 0, out, WithGrowthPercent()); err != nil {
+		t.Fatal(err)
+	}
+	records, err = csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prefixes []string
+	for _, record := range records[1:] {
+		prefixes = append(prefixes, record[1])
+	}
+	if strings.Join(prefixes, ",") != "cart:,user:,order:" {
+		t.Errorf("wrong order by percentage: %v", prefixes)
+	}
+}
@@ -0,0 +1,56 @@
+package helper
+
+import (
+	"strings"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// HashtagOption filters keys by hash tag
+type HashtagOption *string
+
+// WithHashtagFilter only returns keys whose hash tag equals tag, values of other keys are skipped without decoding.
+// Same as redis cluster, hash tag is the content between the first '{' and the first '}' after it,
+// the whole key is used if there is no such non-empty content
+func WithHashtagFilter(tag string) HashtagOption {
+	return &tag
+}
+
+// extractHashtag returns the part of key used to compute hash slot in redis cluster
+func extractHashtag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 { // no '}' or empty braces
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
+
+type keyFilterDecoder struct {
+	dec    decoder
+	filter func(key string) bool
+}
+
+func (d *keyFilterDecoder) Parse(cb func(object model.RedisObject) bool) error {
+	return d.dec.Parse(func(object model.RedisObject) bool {
+		if d.filter(object.GetKey()) {
+			return cb(object)
+		}
+		return true
+	})
+}
+
+// withKeyFilter skips values in core decoder if possible, otherwise filters objects after decoding
+func withKeyFilter(dec decoder, filter func(key string) bool) decoder {
+	if coreDec, ok := dec.(*core.Decoder); ok {
+		return coreDec.WithKeyFilter(filter)
+	}
+	return &keyFilterDecoder{
+		dec:    dec,
+		filter: filter,
+	}
+}
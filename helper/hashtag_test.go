@@ -0,0 +1,73 @@
+package helper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestExtractHashtag(t *testing.T) {
+	cases := map[string]string{
+		"{user:42}:profile": "user:42",
+		"cart:{user:42}":    "user:42",
+		"{user:42}{other}":  "user:42",
+		"user:42":           "user:42",
+		"{}user:42":         "{}user:42",
+		"{user:42":          "{user:42",
+		"}{user:42}":        "user:42",
+		"a{{b}}":            "{b",
+		"":                  "",
+	}
+	for key, expect := range cases {
+		if actual := extractHashtag(key); actual != expect {
+			t.Errorf("hashtag of %s expect %s, actual %s", key, expect, actual)
+		}
+	}
+}
+
+func TestHashtagFilter(t *testing.T) {
+	data := buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 7, 0))
+		check(enc.WriteStringObject("{user:42}:name", []byte("tom")))
+		check(enc.WriteListObject("{user:1}:list", [][]byte{[]byte("a")}))
+		check(enc.WriteHashMapObject("{user:42}:hash", map[string][]byte{"a": []byte("1")}))
+		check(enc.WriteSetObject("user:42", [][]byte{[]byte("a"), []byte("b")}))
+		check(enc.WriteZSetObject("{}user:42", []*model.ZSetEntry{{Member: "a", Score: 1}}))
+		check(enc.WriteStringObject("profile:{user:42}", []byte("x")))
+		check(enc.WriteStringObject("tail", []byte("end")))
+	})
+
+	var keys []string
+	err := ParseType(bytes.NewReader(data), func(obj model.RedisObject) bool {
+		keys = append(keys, obj.GetKey())
+		return true
+	}, WithHashtagFilter("user:42"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"{user:42}:name", "{user:42}:hash", "user:42", "profile:{user:42}"}
+	if len(keys) != len(expect) {
+		t.Fatalf("expect %v, actual %v", expect, keys)
+	}
+	for i := range expect {
+		if keys[i] != expect[i] {
+			t.Errorf("expect %v, actual %v", expect, keys)
+			break
+		}
+	}
+
+	// empty braces use the whole key
+	keys = nil
+	err = ParseType(bytes.NewReader(data), func(obj model.RedisObject) bool {
+		keys = append(keys, obj.GetKey())
+		return true
+	}, WithHashtagFilter("{}user:42"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "{}user:42" {
+		t.Errorf("wrong keys: %v", keys)
+	}
+}
@@ -0,0 +1,80 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// keyspaceInfo is statistics of a database in INFO keyspace
+type keyspaceInfo struct {
+	keys    int64
+	expires int64
+	ttlSum  int64 // sum of remaining ttl of expiring keys in milliseconds
+}
+
+// InfoKeyspace reads rdb from reader and writes keyspace section of INFO as a redis loading the rdb would reply,
+// e.g. "db0:keys=1000,expires=50,avg_ttl=12345". avg_ttl is the average remaining ttl in milliseconds of expiring
+// keys. Keys already expired are not counted because redis drops them while loading
+func InfoKeyspace(reader io.Reader, out io.Writer, options ...interface{}) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	if out == nil {
+		return errors.New("output writer is required")
+	}
+	var dec decoder = core.NewDecoder(reader)
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	now := nowFunc(options)()
+	infos := make(map[int]*keyspaceInfo)
+	err = dec.Parse(func(object model.RedisObject) bool {
+		var ttl int64
+		if expiration := object.GetExpiration(); expiration != nil {
+			ttl = expiration.Sub(now).Milliseconds()
+			if ttl <= 0 {
+				return true
+			}
+		}
+		info := infos[object.GetDBIndex()]
+		if info == nil {
+			info = &keyspaceInfo{}
+			infos[object.GetDBIndex()] = info
+		}
+		info.keys++
+		if object.GetExpiration() != nil {
+			info.expires++
+			info.ttlSum += ttl
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	dbs := make([]int, 0, len(infos))
+	for db := range infos {
+		dbs = append(dbs, db)
+	}
+	sort.Ints(dbs)
+	if _, err = io.WriteString(out, "# Keyspace\r\n"); err != nil {
+		return fmt.Errorf("write info failed: %v", err)
+	}
+	for _, db := range dbs {
+		info := infos[db]
+		var avgTTL int64
+		if info.expires > 0 {
+			avgTTL = info.ttlSum / info.expires
+		}
+		_, err = fmt.Fprintf(out, "db%d:keys=%d,expires=%d,avg_ttl=%d\r\n", db, info.keys, info.expires, avgTTL)
+		if err != nil {
+			return fmt.Errorf("write info failed: %v", err)
+		}
+	}
+	return nil
+}
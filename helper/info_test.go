@@ -0,0 +1,40 @@
+package helper
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/hdt3213/rdb/encoder"
+)
+
+func TestInfoKeyspace(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	nowMs := uint64(now.UnixNano() / int64(time.Millisecond))
+	data := buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 4, 3))
+		check(enc.WriteStringObject("persistent", []byte("1")))
+		check(enc.WriteStringObject("ttl1", []byte("1"), encoder.WithTTL(nowMs+1000)))
+		check(enc.WriteStringObject("ttl3", []byte("1"), encoder.WithTTL(nowMs+3001)))
+		check(enc.WriteStringObject("expired", []byte("1"), encoder.WithTTL(nowMs-1)))
+		check(enc.WriteDBHeader(2, 2, 0))
+		check(enc.WriteListObject("list", [][]byte{[]byte("a")}))
+		check(enc.WriteSetObject("set", [][]byte{[]byte("a")}))
+		check(enc.WriteDBHeader(15, 1, 1))
+		check(enc.WriteStringObject("expired", []byte("1"), encoder.WithTTL(nowMs)))
+	})
+	out := bytes.NewBuffer(nil)
+	if err := InfoKeyspace(bytes.NewReader(data), out, WithClock(func() time.Time { return now })); err != nil {
+		t.Fatal(err)
+	}
+	expect := "# Keyspace\r\n" +
+		"db0:keys=3,expires=2,avg_ttl=2000\r\n" +
+		"db2:keys=2,expires=0,avg_ttl=0\r\n"
+	if out.String() != expect {
+		t.Errorf("expect %q, actual %q", expect, out.String())
+	}
+
+	if err := InfoKeyspace(nil, out); err == nil || err.Error() != "src reader is required" {
+		t.Error("failed when empty reader")
+	}
+}
@@ -1,18 +1,21 @@
 package helper
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 
 	"github.com/bytedance/sonic"
 	"github.com/hdt3213/rdb/core"
 	"github.com/hdt3213/rdb/model"
 )
 
-var jsonEncoder = sonic.ConfigDefault
+// jsonEncoder sorts keys of maps, e.g. hash fields, so that the same rdb is always converted into the same json
+var jsonEncoder = sonic.Config{SortMapKeys: true}.Froze()
 
 // ConcurrentOption sets the number of goroutines for json converter
 type ConcurrentOption int
@@ -22,6 +25,89 @@ func WithConcurrent(c int) ConcurrentOption {
 	return ConcurrentOption(c)
 }
 
+// ListRendererOption renders values of list as a single blob in json
+type ListRendererOption func(values [][]byte) []byte
+
+// WithListRenderer makes json converter output list as `"value": render(values)` instead of an array,
+// e.g. joins append-only log lines with newline
+func WithListRenderer(render func(values [][]byte) []byte) ListRendererOption {
+	return render
+}
+
+// HashRendererOption renders hash as a single blob in json
+type HashRendererOption func(hash map[string][]byte) []byte
+
+// WithHashRenderer makes json converter output hash as `"value": render(hash)` instead of an object
+func WithHashRenderer(render func(hash map[string][]byte) []byte) HashRendererOption {
+	return render
+}
+
+// OrderedFieldsOption makes json converter keep on-disk order of hash fields
+type OrderedFieldsOption bool
+
+// WithOrderedFields makes json converter output fields of listpack, ziplist and zipmap hashes in on-disk order,
+// fields of hashtable encoded hashes are sorted by name
+func WithOrderedFields() OrderedFieldsOption {
+	return OrderedFieldsOption(true)
+}
+
+// StreamIDRangeOption makes decoder keep only stream messages with id in the range
+type StreamIDRangeOption [2]model.StreamId
+
+// WithStreamIDRange makes ToJsons and ToAOF keep only stream messages with id in [min, max],
+// pending entries of consumer groups are filtered by the same range. DUMP payload for RESTORE is not filtered
+func WithStreamIDRange(min, max model.StreamId) StreamIDRangeOption {
+	return StreamIDRangeOption{min, max}
+}
+
+// OrderedMembersOption makes json converter output set members in a stable order
+type OrderedMembersOption bool
+
+// WithOrderedMembers makes json converter output members of hashtable encoded sets sorted,
+// members of intset and listpack encoded sets are in stored order
+func WithOrderedMembers() OrderedMembersOption {
+	return OrderedMembersOption(true)
+}
+
+// KeyLengthOption makes json converter output byte length and character count of keys
+type KeyLengthOption bool
+
+// WithKeyLength makes json converter output key_bytes and key_runes of every object like the memory report,
+// key_runes equals key_bytes if key is not valid utf-8
+func WithKeyLength() KeyLengthOption {
+	return KeyLengthOption(true)
+}
+
+// setKeyLength sets KeyBytes and KeyRunes of object
+func setKeyLength(object model.RedisObject) {
+	base := baseObject(object)
+	if base == nil {
+		return
+	}
+	keyBytes, runes := len(base.Key), keyRunes(base.Key)
+	base.KeyBytes, base.KeyRunes = &keyBytes, &runes
+}
+
+type renderedObject struct {
+	*model.BaseObject
+	Value string `json:"value"`
+}
+
+// renderObject returns object to marshal, which is rendered if there is a renderer for its type
+func renderObject(object model.RedisObject, listRenderer ListRendererOption, hashRenderer HashRendererOption) interface{} {
+	switch o := object.(type) {
+	case *model.ListObject:
+		if listRenderer != nil {
+			return &renderedObject{BaseObject: o.BaseObject, Value: string(listRenderer(o.Values))}
+		}
+	case *model.HashObject:
+		if hashRenderer != nil {
+			return &renderedObject{BaseObject: o.BaseObject, Value: string(hashRenderer(o.Hash))}
+		}
+	}
+	return object
+}
+
 // ToJsons read rdb file and convert to json file
 func ToJsons(rdbFilename string, jsonFilename string, options ...interface{}) error {
 	if rdbFilename == "" {
@@ -46,15 +132,31 @@ func ToJsons(rdbFilename string, jsonFilename string, options ...interface{}) er
 		_ = jsonFile.Close()
 	}()
 	// create decoder
-	var dec decoder = core.NewDecoder(rdbFile)
+	coreDec := core.NewDecoder(rdbFile)
+	for _, opt := range options {
+		switch o := opt.(type) {
+		case OrderedFieldsOption:
+			if o {
+				coreDec.WithOrderedFields()
+			}
+		case OrderedMembersOption:
+			if o {
+				coreDec.WithOrderedMembers()
+			}
+		case StreamIDRangeOption:
+			coreDec.WithStreamIDRange(o[0], o[1])
+		}
+	}
+	var dec decoder = coreDec
 	if dec, err = wrapDecoder(dec, options...); err != nil {
 		return err
 	}
-	// parse rdb
-	_, err = jsonFile.WriteString("[\n")
-	if err != nil {
-		return fmt.Errorf("write json  failed, %v", err)
+	if dec, err = withBinaryEncoding(dec, options); err != nil {
+		return err
 	}
+	// parse rdb, write error of the opening bracket is returned by the first Write of write goroutine
+	writer := bufio.NewWriter(jsonFile)
+	_, _ = writer.WriteString("[\n")
 
 	// parse options
 	concurrent := 1
@@ -62,22 +164,56 @@ func ToJsons(rdbFilename string, jsonFilename string, options ...interface{}) er
 	if cpuNum > 1 {
 		concurrent = cpuNum - 1 // leave one core for parser
 	}
+	var listRenderer ListRendererOption
+	var hashRenderer HashRendererOption
+	typed := false
+	keyLength := false
 	for _, opt := range options {
 		switch o := opt.(type) {
 		case ConcurrentOption:
 			concurrent = int(o)
+		case ListRendererOption:
+			listRenderer = o
+		case HashRendererOption:
+			hashRenderer = o
+		case TypedJSONOption:
+			typed = bool(o)
+		case KeyLengthOption:
+			keyLength = bool(o)
 		}
 	}
 
-	redisObjectBuffer := make(chan model.RedisObject, 1000)
-	jsonStringBuffer := make(chan []byte, 1000)
+	// seq is the position of object in rdb, objects are written in the same order whatever concurrent is
+	type sequenced struct {
+		seq    int
+		object model.RedisObject
+		data   []byte
+	}
+	redisObjectBuffer := make(chan *sequenced, 1000)
+	jsonStringBuffer := make(chan *sequenced, 1000)
+
+	// exportErr is the first marshal or write error, parser stops once it is set
+	var exportErr error
+	var errOnce sync.Once
+	var failed atomic.Bool
+	setErr := func(err error) {
+		errOnce.Do(func() {
+			exportErr = err
+			failed.Store(true)
+		})
+	}
 
 	// parser goroutine
-	empty := true
+	var parseErr error
 	go func() {
-		err = dec.Parse(func(object model.RedisObject) bool {
-			redisObjectBuffer <- object
-			return true
+		seq := 0
+		parseErr = dec.Parse(func(object model.RedisObject) bool {
+			if keyLength {
+				setKeyLength(object)
+			}
+			redisObjectBuffer <- &sequenced{seq: seq, object: object}
+			seq++
+			return !failed.Load()
 		})
 		close(redisObjectBuffer)
 	}()
@@ -86,29 +222,56 @@ func ToJsons(rdbFilename string, jsonFilename string, options ...interface{}) er
 	wg.Add(concurrent)
 	for i := 0; i < concurrent; i++ {
 		go func() {
-			for object := range redisObjectBuffer {
-				data, err := jsonEncoder.Marshal(object) // enable SortMapKeys to ensure same result
+			for item := range redisObjectBuffer {
+				object := item.object
+				var rendered interface{}
+				if typed {
+					rendered = toTypedObject(object)
+				} else {
+					rendered = renderObject(object, listRenderer, hashRenderer)
+				}
+				data, err := jsonEncoder.Marshal(rendered)
 				if err != nil {
-					fmt.Printf("json marshal failed: %v", err)
-					continue
+					setErr(fmt.Errorf("json marshal %s failed: %v", object.GetKey(), err))
+					data = nil
 				}
-				jsonStringBuffer <- data
+				// failed object is sent with nil data, so that the writer does not wait for it
+				jsonStringBuffer <- &sequenced{seq: item.seq, data: data}
 			}
 			wg.Done()
 		}()
 	}
 	// write goroutine
+	// objects are written as soon as they are marshalled, separator is written before each object except the first one,
+	// so nothing is retained and there is no need to seek back for the trailing comma
 	wg2 := &sync.WaitGroup{}
 	wg2.Add(1)
 	go func() {
-		for data := range jsonStringBuffer {
-			data = append(data, ',', '\n')
-			_, err = jsonFile.Write(data)
-			if err != nil {
-				fmt.Printf("write failed: %v", err)
-				continue
+		empty := true
+		next := 0
+		pending := make(map[int][]byte) // objects marshalled before the ones in front of them
+		for item := range jsonStringBuffer {
+			pending[item.seq] = item.data
+			for {
+				data, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				if data == nil || failed.Load() {
+					continue
+				}
+				if !empty {
+					_, _ = writer.WriteString(",\n") // error will be returned by the next Write
+				}
+				_, err := writer.Write(data)
+				if err != nil {
+					setErr(fmt.Errorf("error during write in file: %v", err))
+					continue
+				}
+				empty = false
 			}
-			empty = false
 		}
 		wg2.Done()
 	}()
@@ -116,17 +279,18 @@ func ToJsons(rdbFilename string, jsonFilename string, options ...interface{}) er
 	wg.Wait()
 	close(jsonStringBuffer)
 	wg2.Wait() // wait writing goroutine
+	if exportErr != nil {
+		return exportErr
+	}
 
 	// finish json
-	if !empty {
-		_, err = jsonFile.Seek(-2, 2)
-		if err != nil {
-			return fmt.Errorf("error during seek in file: %v", err)
-		}
+	_, err = writer.WriteString("\n]")
+	if err != nil {
+		return fmt.Errorf("error during write in file: %v", err)
 	}
-	_, err = jsonFile.WriteString("\n]")
+	err = writer.Flush()
 	if err != nil {
 		return fmt.Errorf("error during write in file: %v", err)
 	}
-	return nil
+	return parseErr
 }
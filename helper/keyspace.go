@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// Keyspace is an in-memory index of all objects in rdb
+type Keyspace struct {
+	dbs map[int]map[string]model.RedisObject
+}
+
+// LoadAll reads the whole rdb from reader and indexes objects by db and key.
+// All values are kept in memory, which may take several times the size of rdb file.
+// Please use Parse to process objects one by one if the dump is large
+func LoadAll(reader io.Reader, options ...interface{}) (*Keyspace, error) {
+	if reader == nil {
+		return nil, errors.New("src reader is required")
+	}
+	var dec decoder = core.NewDecoder(reader)
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return nil, err
+	}
+	keyspace := &Keyspace{
+		dbs: make(map[int]map[string]model.RedisObject),
+	}
+	err = dec.Parse(func(object model.RedisObject) bool {
+		db := keyspace.dbs[object.GetDBIndex()]
+		if db == nil {
+			db = make(map[string]model.RedisObject)
+			keyspace.dbs[object.GetDBIndex()] = db
+		}
+		db[object.GetKey()] = object
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keyspace, nil
+}
+
+// Get returns object of the given key in db
+func (ks *Keyspace) Get(db int, key string) (model.RedisObject, bool) {
+	obj, ok := ks.dbs[db][key]
+	return obj, ok
+}
+
+// Keys returns all keys in db in lexical order
+func (ks *Keyspace) Keys(db int) []string {
+	keys := make([]string, 0, len(ks.dbs[db]))
+	for key := range ks.dbs[db] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DBs returns index of all non-empty dbs in ascending order
+func (ks *Keyspace) DBs() []int {
+	dbs := make([]int, 0, len(ks.dbs))
+	for db := range ks.dbs {
+		dbs = append(dbs, db)
+	}
+	sort.Ints(dbs)
+	return dbs
+}
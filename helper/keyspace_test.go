@@ -0,0 +1,48 @@
+package helper
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestLoadAll(t *testing.T) {
+	rdbFile, err := os.Open("../cases/multiple_databases.rdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	keyspace, err := LoadAll(rdbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbs := keyspace.DBs(); !reflect.DeepEqual(dbs, []int{0, 2}) {
+		t.Errorf("wrong dbs: %v", dbs)
+	}
+	if keys := keyspace.Keys(0); !reflect.DeepEqual(keys, []string{"key_in_zeroth_database"}) {
+		t.Errorf("wrong keys: %v", keys)
+	}
+	obj, ok := keyspace.Get(2, "key_in_second_database")
+	if !ok {
+		t.Fatal("key not found")
+	}
+	str, ok := obj.(*model.StringObject)
+	if !ok || string(str.Value) != "second" {
+		t.Errorf("wrong object: %+v", obj)
+	}
+	if _, ok := keyspace.Get(0, "key_in_second_database"); ok {
+		t.Error("key should not be found in db 0")
+	}
+	if keys := keyspace.Keys(1); len(keys) != 0 {
+		t.Errorf("db 1 should be empty: %v", keys)
+	}
+
+	_, err = LoadAll(nil)
+	if err == nil {
+		t.Error("expect error")
+	}
+}
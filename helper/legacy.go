@@ -0,0 +1,78 @@
+package helper
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// legacyEncodings maps type and legacy encoding to the encoding a recent redis (7.0+) would store it with.
+// intset is not legacy, redis still uses it for small sets of integers
+var legacyEncodings = map[string]map[string]string{
+	model.ListType: {
+		model.ListEncoding:      model.QuickList2Encoding,
+		model.ZipListEncoding:   model.QuickList2Encoding,
+		model.QuickListEncoding: model.QuickList2Encoding,
+	},
+	model.HashType: {
+		model.ZipMapEncoding:  model.ListPackEncoding,
+		model.ZipListEncoding: model.ListPackEncoding,
+	},
+	model.ZSetType: {
+		model.ZSetEncoding:    model.ZSet2Encoding,
+		model.ZipListEncoding: model.ListPackEncoding,
+	},
+}
+
+// LegacyEncodingReport reads rdb from reader and writes keys stored in legacy encodings (e.g. ziplist, zipmap) to out
+// as csv, with the encoding a recent redis would use. Sum of size shows how much re-saving the dump matters
+func LegacyEncodingReport(reader io.Reader, out io.Writer, options ...interface{}) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	if out == nil {
+		return errors.New("output writer is required")
+	}
+	var dec decoder = core.NewDecoder(reader)
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, "database,key,type,encoding,suggested_encoding,size\n")
+	if err != nil {
+		return fmt.Errorf("write header failed: %v", err)
+	}
+	csvWriter := csv.NewWriter(out)
+	var writeErr error
+	err = dec.Parse(func(object model.RedisObject) bool {
+		suggested, ok := legacyEncodings[object.GetType()][object.GetEncoding()]
+		if !ok {
+			return true
+		}
+		writeErr = csvWriter.Write([]string{
+			strconv.Itoa(object.GetDBIndex()),
+			object.GetKey(),
+			object.GetType(),
+			object.GetEncoding(),
+			suggested,
+			strconv.Itoa(object.GetSize()),
+		})
+		return writeErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return fmt.Errorf("csv write failed: %v", writeErr)
+	}
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		return fmt.Errorf("csv write failed: %v", err)
+	}
+	return nil
+}
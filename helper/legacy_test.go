@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"testing"
+)
+
+func TestLegacyEncodingReport(t *testing.T) {
+	rdbFile, err := os.Open("../cases/memory.rdb")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	out := bytes.NewBuffer(nil)
+	if err = LegacyEncodingReport(rdbFile, out); err != nil {
+		t.Error(err)
+		return
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(records) < 2 {
+		t.Errorf("expect legacy keys in memory.rdb, actual %v", records)
+		return
+	}
+	suggested := make(map[string]string)
+	for _, record := range records[1:] {
+		if legacyEncodings[record[2]][record[3]] != record[4] {
+			t.Errorf("wrong suggestion: %v", record)
+		}
+		suggested[record[1]] = record[3] + "->" + record[4]
+	}
+	expected := map[string]string{
+		"hash": "ziplist->listpack",
+		"list": "quicklist->quicklist2",
+		"zset": "ziplist->listpack",
+	}
+	for key, s := range expected {
+		if suggested[key] != s {
+			t.Errorf("key %s: expect %s, actual %s", key, s, suggested[key])
+		}
+	}
+	if _, ok := suggested["set"]; ok {
+		t.Error("hashtable set is not legacy")
+	}
+
+	// listpack encoded dump has nothing to modernize
+	rdbFile2, err := os.Open("../cases/listpack.rdb")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = rdbFile2.Close()
+	}()
+	out.Reset()
+	if err = LegacyEncodingReport(rdbFile2, out); err != nil {
+		t.Error(err)
+		return
+	}
+	if out.String() != "database,key,type,encoding,suggested_encoding,size\n" {
+		t.Errorf("expect no legacy keys, actual %s", out.String())
+	}
+}
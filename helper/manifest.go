@@ -0,0 +1,73 @@
+package helper
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// Manifest is an overview of rdb which is collected without decoding values
+type Manifest struct {
+	// DBCount is number of databases in rdb
+	DBCount int
+	// MaxDBIndex is the highest database index, or -1 if rdb has no database
+	MaxDBIndex int
+	// KeyCount is number of keys in all databases
+	KeyCount int
+	// AOFBase is true if aof-base aux field is set, which means rdb is the base of a multi part aof
+	AOFBase bool
+	// ExpireCount is number of keys with expiration
+	ExpireCount int
+	// SoonestExpireKey is the key which expires first, SoonestExpireAt is its expiration. They are empty and nil
+	// if no key has expiration
+	SoonestExpireKey string
+	SoonestExpireAt  *time.Time
+}
+
+// Inspect scans rdb from reader and returns its Manifest.
+// Values of all keys are skipped, so it is much faster than a full parse
+func Inspect(reader io.Reader) (*Manifest, error) {
+	if reader == nil {
+		return nil, errors.New("src reader is required")
+	}
+	dec := core.NewDecoder(reader).WithKeyFilter(func(key string) bool {
+		return false
+	})
+	err := dec.Parse(func(object model.RedisObject) bool {
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats := dec.Stats()
+	manifest := &Manifest{
+		MaxDBIndex:  -1,
+		KeyCount:    stats.SkippedKeys,
+		ExpireCount: stats.ExpireCount,
+	}
+	if stats.ExpireCount > 0 {
+		expireAt := time.Unix(0, stats.MinExpireMs*int64(time.Millisecond))
+		manifest.SoonestExpireKey = stats.MinExpireKey
+		manifest.SoonestExpireAt = &expireAt
+	}
+	for _, aux := range dec.GetAuxFields() {
+		if aux.Key == "aof-base" {
+			manifest.AOFBase = aux.Value == "1"
+		}
+	}
+	seen := make(map[int]struct{})
+	for _, db := range stats.DBs {
+		if _, ok := seen[db]; ok {
+			continue
+		}
+		seen[db] = struct{}{}
+		manifest.DBCount++
+		if db > manifest.MaxDBIndex {
+			manifest.MaxDBIndex = db
+		}
+	}
+	return manifest, nil
+}
@@ -0,0 +1,131 @@
+package helper
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+)
+
+func TestInspect(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := encoder.NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	for _, db := range []int{0, 3, 9} {
+		if err := enc.WriteDBHeader(uint(db), 2, 0); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := enc.WriteStringObject("k"+strconv.Itoa(i), []byte("v")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := enc.WriteHashMapObject("h", map[string][]byte{"f": []byte("v")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := Inspect(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.DBCount != 3 {
+		t.Errorf("expect 3 dbs, actual %d", manifest.DBCount)
+	}
+	if manifest.MaxDBIndex != 9 {
+		t.Errorf("expect max db index 9, actual %d", manifest.MaxDBIndex)
+	}
+	if manifest.KeyCount != 9 {
+		t.Errorf("expect 9 keys, actual %d", manifest.KeyCount)
+	}
+
+	if manifest.AOFBase {
+		t.Error("expect not aof base")
+	}
+
+	buf.Reset()
+	enc = encoder.NewEncoder(buf).SetAuxField("aof-base", "1")
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err = Inspect(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !manifest.AOFBase {
+		t.Error("expect aof base")
+	}
+	if manifest.DBCount != 0 || manifest.MaxDBIndex != -1 {
+		t.Errorf("expect no db, actual %d dbs, max index %d", manifest.DBCount, manifest.MaxDBIndex)
+	}
+
+	_, err = Inspect(nil)
+	if err == nil {
+		t.Error("expect error")
+	}
+}
+
+func TestInspectExpirations(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := encoder.NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	expirations := map[string]uint64{
+		"a": 1700000300000,
+		"b": 1700000100000,
+		"c": 1700000200000,
+	}
+	for db, keys := range [][]string{{"a", "persistent"}, {"b", "c"}} {
+		if err := enc.WriteDBHeader(uint(db), uint64(len(keys)), 0); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			var options []interface{}
+			if expireAt, ok := expirations[key]; ok {
+				options = append(options, encoder.WithTTL(expireAt))
+			}
+			if err := enc.WriteStringObject(key, []byte("v"), options...); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := Inspect(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.ExpireCount != 3 {
+		t.Errorf("expect 3 keys with expiration, actual %d", manifest.ExpireCount)
+	}
+	if manifest.SoonestExpireKey != "b" || manifest.SoonestExpireAt == nil ||
+		manifest.SoonestExpireAt.UnixNano()/1e6 != 1700000100000 {
+		t.Errorf("unexpected soonest expiration %s %v", manifest.SoonestExpireKey, manifest.SoonestExpireAt)
+	}
+
+	buf.Reset()
+	enc = encoder.NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err = Inspect(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.ExpireCount != 0 || manifest.SoonestExpireAt != nil {
+		t.Errorf("expect no expiration, actual %+v", manifest)
+	}
+}
@@ -10,8 +10,31 @@ import (
 	"os"
 	"strconv"
 	"time"
+	"unicode/utf8"
 )
 
+// ArchBitsOption sets architecture of redis which memory usage is evaluated for
+type ArchBitsOption int
+
+// WithArchBits makes memory reports evaluate size of keys as if redis is built for 32 or 64 bit architecture,
+// instead of following redis-bits aux field of rdb
+func WithArchBits(bits int) ArchBitsOption {
+	return ArchBitsOption(bits)
+}
+
+// applyArchBits sets architecture of dec according to ArchBitsOption
+func applyArchBits(dec *core.Decoder, options []interface{}) error {
+	for _, opt := range options {
+		if bits, ok := opt.(ArchBitsOption); ok {
+			if bits != 32 && bits != 64 {
+				return fmt.Errorf("unsupported arch bits: %d", bits)
+			}
+			dec.WithArchBits(int(bits))
+		}
+	}
+	return nil
+}
+
 // MemoryProfile read rdb file and analysis memory usage then write result to csv file
 func MemoryProfile(rdbFilename string, csvFilename string, options ...interface{}) error {
 	if rdbFilename == "" {
@@ -35,12 +58,16 @@ func MemoryProfile(rdbFilename string, csvFilename string, options ...interface{
 		_ = csvFile.Close()
 	}()
 
-	var dec decoder = core.NewDecoder(rdbFile)
+	coreDec := core.NewDecoder(rdbFile)
+	if err = applyArchBits(coreDec, options); err != nil {
+		return err
+	}
+	var dec decoder = coreDec
 	if dec, err = wrapDecoder(dec, options...); err != nil {
 		return err
 	}
 
-	_, err = csvFile.WriteString("database,key,type,size,size_readable,element_count,encoding,expiration\n")
+	_, err = csvFile.WriteString("database,key,type,size,size_readable,element_count,encoding,expiration,key_bytes,key_runes\n")
 	if err != nil {
 		return fmt.Errorf("write csv failed: %v", err)
 	}
@@ -63,6 +90,8 @@ func MemoryProfile(rdbFilename string, csvFilename string, options ...interface{
 			strconv.Itoa(object.GetElemCount()),
 			object.GetEncoding(),
 			formatExpiration(object),
+			strconv.Itoa(len(object.GetKey())),
+			strconv.Itoa(keyRunes(object.GetKey())),
 		})
 		if err != nil {
 			fmt.Printf("csv write failed: %v", err)
@@ -71,3 +100,11 @@ func MemoryProfile(rdbFilename string, csvFilename string, options ...interface{
 		return true
 	})
 }
+
+// keyRunes returns number of characters in key, it equals number of bytes if key is not valid utf-8
+func keyRunes(key string) int {
+	if !utf8.ValidString(key) {
+		return len(key)
+	}
+	return utf8.RuneCountInString(key)
+}
@@ -1,9 +1,16 @@
 package helper
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
 )
 
 func TestMemoryProfile(t *testing.T) {
@@ -117,3 +124,168 @@ func TestMemoryNoExpired(t *testing.T) {
 		return
 	}
 }
+
+func TestMemoryProfileKeyLength(t *testing.T) {
+	err := os.MkdirAll("tmp", os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	keys := []string{"ascii", "用户:1", "bin\xff\xfe"}
+	srcRdb := filepath.Join("tmp", "key_length.rdb")
+	rdbFile, err := os.Create(srcRdb)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	enc := encoder.NewEncoder(rdbFile)
+	if err = enc.WriteHeader(); err != nil {
+		t.Error(err)
+		return
+	}
+	if err = enc.WriteDBHeader(0, uint64(len(keys)), 0); err != nil {
+		t.Error(err)
+		return
+	}
+	for _, key := range keys {
+		if err = enc.WriteStringObject(key, []byte("1")); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if err = enc.WriteEnd(); err != nil {
+		t.Error(err)
+		return
+	}
+	_ = rdbFile.Close()
+
+	actualFile := filepath.Join("tmp", "key_length.csv")
+	err = MemoryProfile(srcRdb, actualFile)
+	if err != nil {
+		t.Errorf("error occurs during parse, err: %v", err)
+		return
+	}
+	csvFile, err := os.Open(actualFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = csvFile.Close()
+	}()
+	records, err := csv.NewReader(csvFile).ReadAll()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expect := map[string][2]string{
+		"ascii":       {"5", "5"},
+		"用户:1":        {"8", "4"},
+		"bin\xff\xfe": {"5", "5"}, // not valid utf-8
+	}
+	if len(records) != len(keys)+1 {
+		t.Errorf("expect %d lines, actual %d", len(keys)+1, len(records))
+		return
+	}
+	for _, record := range records[1:] {
+		lengths := expect[record[1]]
+		if record[8] != lengths[0] || record[9] != lengths[1] {
+			t.Errorf("key %q: expect %v, actual %v", record[1], lengths, record[8:])
+		}
+	}
+
+	// json objects are in the order of keys, since invalid utf-8 of binary key is replaced in json
+	for _, typed := range []bool{false, true} {
+		options := []interface{}{WithKeyLength()}
+		bytesField, runesField := "key_bytes", "key_runes"
+		if typed {
+			options = append(options, WithTypedJSON())
+			bytesField, runesField = "keyBytes", "keyRunes"
+		}
+		jsonFile := filepath.Join("tmp", "key_length.json")
+		if err = ToJsons(srcRdb, jsonFile, options...); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(jsonFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var objects []map[string]interface{}
+		if err = json.Unmarshal(data, &objects); err != nil {
+			t.Fatal(err)
+		}
+		if len(objects) != len(keys) {
+			t.Fatalf("expect %d objects, actual %d", len(keys), len(objects))
+		}
+		for i, object := range objects {
+			lengths := expect[keys[i]]
+			actual := [2]string{fmt.Sprint(object[bytesField]), fmt.Sprint(object[runesField])}
+			if actual != lengths {
+				t.Errorf("key %q (typed %v): expect %v, actual %v", keys[i], typed, lengths, actual)
+			}
+		}
+	}
+	if err = ToJsons(srcRdb, filepath.Join("tmp", "key_length.json")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join("tmp", "key_length.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("key_bytes")) {
+		t.Error("key length should be output only WithKeyLength")
+	}
+}
+
+func TestMemoryProfileArchBits(t *testing.T) {
+	err := os.MkdirAll("tmp", os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	readSizes := func(options ...interface{}) map[string]int {
+		actualFile := filepath.Join("tmp", "arch_bits.csv")
+		if err := MemoryProfile("../cases/memory.rdb", actualFile, options...); err != nil {
+			t.Fatal(err)
+		}
+		csvFile, err := os.Open(actualFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = csvFile.Close()
+		}()
+		records, err := csv.NewReader(csvFile).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes := make(map[string]int)
+		for _, record := range records[1:] {
+			sizes[record[1]], _ = strconv.Atoi(record[3])
+		}
+		return sizes
+	}
+	sizes64 := readSizes(WithArchBits(64))
+	sizes32 := readSizes(WithArchBits(32))
+	if len(sizes64) == 0 || len(sizes64) != len(sizes32) {
+		t.Fatalf("expect same keys, actual %d and %d", len(sizes64), len(sizes32))
+	}
+	for key, size := range sizes64 {
+		if sizes32[key] >= size {
+			t.Errorf("key %s: expect 32 bit size %d less than 64 bit size %d", key, sizes32[key], size)
+		}
+	}
+	if err = MemoryProfile("../cases/memory.rdb", filepath.Join("tmp", "arch_bits.csv"), WithArchBits(16)); err == nil {
+		t.Error("expect error for unsupported arch bits")
+	}
+}
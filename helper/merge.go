@@ -0,0 +1,115 @@
+package helper
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// MergeConflictFunc chooses the object to keep when the same key of db appears in more than one input,
+// existing is from an earlier input. Returning nil removes the key from output
+type MergeConflictFunc func(db int, key string, existing, incoming model.RedisObject) model.RedisObject
+
+// mergeDB keeps objects of a db and the order in which keys first appeared
+type mergeDB struct {
+	keys    []string
+	objects map[string]model.RedisObject
+}
+
+// Merge parses all inputs in order and writes their keys into a single rdb to out.
+// onConflict resolves keys appearing in several inputs, the incoming object wins if onConflict is nil.
+// All objects are kept in memory until the last input is parsed. encoder.WithTargetVersion sets version of output
+func Merge(inputs []io.Reader, out io.Writer, onConflict MergeConflictFunc, options ...interface{}) error {
+	if len(inputs) == 0 {
+		return errors.New("src readers are required")
+	}
+	if out == nil {
+		return errors.New("output writer is required")
+	}
+	dbs := make(map[int]*mergeDB)
+	for i, input := range inputs {
+		if input == nil {
+			return fmt.Errorf("src reader %d is nil", i)
+		}
+		var dec decoder = core.NewDecoder(input)
+		var err error
+		if dec, err = wrapDecoder(dec, options...); err != nil {
+			return err
+		}
+		err = dec.Parse(func(object model.RedisObject) bool {
+			db := dbs[object.GetDBIndex()]
+			if db == nil {
+				db = &mergeDB{
+					objects: make(map[string]model.RedisObject),
+				}
+				dbs[object.GetDBIndex()] = db
+			}
+			key := object.GetKey()
+			existing, ok := db.objects[key]
+			if !ok {
+				db.keys = append(db.keys, key)
+				db.objects[key] = object
+				return true
+			}
+			if existing == nil || onConflict == nil {
+				db.objects[key] = object
+				return true
+			}
+			db.objects[key] = onConflict(object.GetDBIndex(), key, existing, object)
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("parse input %d failed: %v", i, err)
+		}
+	}
+
+	dbIndexes := make([]int, 0, len(dbs))
+	for index := range dbs {
+		dbIndexes = append(dbIndexes, index)
+	}
+	sort.Ints(dbIndexes)
+	writer := bufio.NewWriter(out)
+	enc := core.NewEncoder(writer, options...)
+	if err := enc.WriteHeader(); err != nil {
+		return err
+	}
+	for _, index := range dbIndexes {
+		db := dbs[index]
+		var keyCount, ttlCount uint64
+		for _, key := range db.keys {
+			if obj := db.objects[key]; obj != nil {
+				keyCount++
+				if obj.GetExpiration() != nil {
+					ttlCount++
+				}
+			}
+		}
+		if keyCount == 0 {
+			continue
+		}
+		if err := enc.WriteDBHeader(uint(index), keyCount, ttlCount); err != nil {
+			return err
+		}
+		for _, key := range db.keys {
+			obj := db.objects[key]
+			if obj == nil {
+				continue
+			}
+			if err := writeObject(enc, obj); err != nil {
+				return err
+			}
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("write rdb failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package helper
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestMerge(t *testing.T) {
+	buildRDB := func(db uint, values map[string]string) []byte {
+		buf := bytes.NewBuffer(nil)
+		enc := encoder.NewEncoder(buf)
+		if err := enc.WriteHeader(); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteDBHeader(db, uint64(len(values)), 0); err != nil {
+			t.Fatal(err)
+		}
+		for key, value := range values {
+			if err := enc.WriteStringObject(key, []byte(value)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := enc.WriteEnd(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	first := buildRDB(0, map[string]string{"a": "1", "shared": "old"})
+	second := buildRDB(0, map[string]string{"b": "2", "shared": "new"})
+	third := buildRDB(2, map[string]string{"shared": "db2"})
+
+	merge := func(onConflict MergeConflictFunc) map[int]map[string]string {
+		out := bytes.NewBuffer(nil)
+		inputs := []io.Reader{bytes.NewReader(first), bytes.NewReader(second), bytes.NewReader(third)}
+		if err := Merge(inputs, out, onConflict); err != nil {
+			t.Fatal(err)
+		}
+		objects, err := ReadAll(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := make(map[int]map[string]string)
+		for _, obj := range objects {
+			if result[obj.GetDBIndex()] == nil {
+				result[obj.GetDBIndex()] = make(map[string]string)
+			}
+			result[obj.GetDBIndex()][obj.GetKey()] = string(obj.(*model.StringObject).Value)
+		}
+		return result
+	}
+
+	result := merge(nil)
+	if len(result[0]) != 3 || result[0]["shared"] != "new" || result[0]["a"] != "1" || result[0]["b"] != "2" {
+		t.Errorf("unexpected db 0: %v", result[0])
+	}
+	if result[2]["shared"] != "db2" {
+		t.Errorf("unexpected db 2: %v", result[2])
+	}
+
+	calls := 0
+	result = merge(func(db int, key string, existing, incoming model.RedisObject) model.RedisObject {
+		calls++
+		if db != 0 || key != "shared" {
+			t.Errorf("unexpected conflict %d %s", db, key)
+		}
+		return existing
+	})
+	if calls != 1 {
+		t.Errorf("expect 1 conflict, actual %d", calls)
+	}
+	if result[0]["shared"] != "old" {
+		t.Errorf("expect existing value, actual %s", result[0]["shared"])
+	}
+
+	result = merge(func(db int, key string, existing, incoming model.RedisObject) model.RedisObject {
+		return nil
+	})
+	if _, ok := result[0]["shared"]; ok || len(result[0]) != 2 {
+		t.Errorf("expect shared key to be removed: %v", result[0])
+	}
+}
+
+func TestMergeKeepsTTL(t *testing.T) {
+	expireAt := time.Now().Add(time.Hour).UnixNano() / 1e6
+	buf := bytes.NewBuffer(nil)
+	enc := encoder.NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteStringObject("a", []byte("1"), encoder.WithTTL(uint64(expireAt))); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.NewBuffer(nil)
+	if err := Merge([]io.Reader{bytes.NewReader(buf.Bytes())}, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	objects, err := ReadAll(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].GetExpiration() == nil || objects[0].GetExpiration().UnixNano()/1e6 != expireAt {
+		t.Errorf("expect ttl to be kept")
+	}
+	if err := Merge(nil, out, nil); err == nil {
+		t.Error("expect error")
+	}
+}
+
+func TestMergeStripExpirations(t *testing.T) {
+	var inputs []io.Reader
+	for _, name := range []string{"keys_with_expiry.rdb", "hash_with_hfe.rdb"} {
+		data, err := os.ReadFile(filepath.Join("../cases", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, bytes.NewReader(data))
+	}
+	out := bytes.NewBuffer(nil)
+	if err := Merge(inputs, out, nil, WithStripExpirations()); err != nil {
+		t.Fatal(err)
+	}
+	objects, err := ReadAll(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) == 0 {
+		t.Fatal("expect objects")
+	}
+	for _, obj := range objects {
+		if obj.GetExpiration() != nil {
+			t.Errorf("expect %s to be persistent", obj.GetKey())
+		}
+		if hash, ok := obj.(*model.HashObject); ok && len(hash.FieldExpirations) > 0 {
+			t.Errorf("expect fields of %s to be persistent", obj.GetKey())
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package helper
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// parquet file is written without third-party dependency: every column chunk is a single uncompressed data page
+// in PLAIN encoding, and metadata is encoded in thrift compact protocol as described in parquet-format
+
+var parquetMagic = []byte("PAR1")
+
+// parquetRowGroupRows and parquetRowGroupBytes are max number of rows and bytes of values buffered in memory
+// before they are flushed as a row group, so a few large values do not make a page larger than int32
+const (
+	parquetRowGroupRows  = 65536
+	parquetRowGroupBytes = 64 * 1024 * 1024
+)
+
+// parquet physical types, repetition types, converted types and encodings
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetNoConvertedType = -1
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+	parquetJSON            = 19
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+// thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs in thrift compact protocol
+type thriftWriter struct {
+	buf       []byte
+	lastField int16
+	stack     []int16
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	if delta := id - w.lastField; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.zigzag(int64(id))
+	}
+	w.lastField = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.zigzag(v)
+}
+
+func (w *thriftWriter) binary(id int16, v []byte) {
+	w.field(id, thriftBinary)
+	w.varint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *thriftWriter) list(id int16, elemType byte, size int) {
+	w.field(id, thriftList)
+	if size < 15 {
+		w.buf = append(w.buf, byte(size)<<4|elemType)
+	} else {
+		w.buf = append(w.buf, 0xf0|elemType)
+		w.varint(uint64(size))
+	}
+}
+
+// structBegin starts a struct, field id is ignored for elements of list
+func (w *thriftWriter) structBegin(id int16, inList bool) {
+	if !inList {
+		w.field(id, thriftStruct)
+	}
+	w.stack = append(w.stack, w.lastField)
+	w.lastField = 0
+}
+
+func (w *thriftWriter) structEnd() {
+	w.buf = append(w.buf, 0)
+	w.lastField = w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+}
+
+// parquetColumn buffers values of a column in current row group
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32
+	optional  bool
+	values    []byte
+	defined   []bool // definition levels of optional column
+	count     int
+}
+
+func (c *parquetColumn) appendInt32(v int32) {
+	c.values = binary.LittleEndian.AppendUint32(c.values, uint32(v))
+	c.appendDefined(true)
+}
+
+func (c *parquetColumn) appendInt64(v int64) {
+	c.values = binary.LittleEndian.AppendUint64(c.values, uint64(v))
+	c.appendDefined(true)
+}
+
+func (c *parquetColumn) appendBytes(v []byte) {
+	c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(v)))
+	c.values = append(c.values, v...)
+	c.appendDefined(true)
+}
+
+func (c *parquetColumn) appendNull() {
+	c.appendDefined(false)
+}
+
+func (c *parquetColumn) appendDefined(defined bool) {
+	if c.optional {
+		c.defined = append(c.defined, defined)
+	}
+	c.count++
+}
+
+// page returns data page of buffered values, definition levels of optional column are prefixed in RLE
+func (c *parquetColumn) page() []byte {
+	if !c.optional {
+		return c.values
+	}
+	levels := make([]byte, 0)
+	for i := 0; i < len(c.defined); {
+		j := i
+		for j < len(c.defined) && c.defined[j] == c.defined[i] {
+			j++
+		}
+		levels = binary.AppendUvarint(levels, uint64(j-i)<<1)
+		if c.defined[i] {
+			levels = append(levels, 1)
+		} else {
+			levels = append(levels, 0)
+		}
+		i = j
+	}
+	page := binary.LittleEndian.AppendUint32(make([]byte, 0, 4+len(levels)+len(c.values)), uint32(len(levels)))
+	page = append(page, levels...)
+	return append(page, c.values...)
+}
+
+func (c *parquetColumn) reset() {
+	c.values = c.values[:0]
+	c.defined = c.defined[:0]
+	c.count = 0
+}
+
+type parquetChunk struct {
+	offset int64
+	size   int64
+	count  int
+}
+
+type parquetRowGroup struct {
+	chunks []parquetChunk
+	rows   int
+	size   int64
+}
+
+// parquetWriter writes rows into row groups and metadata at Close
+type parquetWriter struct {
+	out       io.Writer
+	offset    int64
+	columns   []*parquetColumn
+	maxRows   int
+	maxBytes  int
+	rows      int
+	totalRows int64
+	groups    []*parquetRowGroup
+}
+
+func newParquetWriter(out io.Writer, columns []*parquetColumn, maxRows, maxBytes int) (*parquetWriter, error) {
+	w := &parquetWriter{
+		out:      out,
+		columns:  columns,
+		maxRows:  maxRows,
+		maxBytes: maxBytes,
+	}
+	if err := w.write(parquetMagic); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *parquetWriter) write(p []byte) error {
+	n, err := w.out.Write(p)
+	w.offset += int64(n)
+	if err != nil {
+		return fmt.Errorf("write parquet failed: %v", err)
+	}
+	return nil
+}
+
+// endRow flushes row group if it has maxRows rows or maxBytes bytes of values,
+// values of the row should have been appended to every column
+func (w *parquetWriter) endRow() error {
+	w.rows++
+	size := 0
+	for _, c := range w.columns {
+		size += len(c.values)
+	}
+	if w.rows >= w.maxRows || size >= w.maxBytes {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *parquetWriter) flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	group := &parquetRowGroup{rows: w.rows}
+	for _, c := range w.columns {
+		page := c.page()
+		if len(page) > math.MaxInt32 {
+			return fmt.Errorf("page of column %s has %d bytes, exceeds limit of parquet", c.name, len(page))
+		}
+		header := &thriftWriter{}
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.structBegin(5, false)
+		header.i32(1, int32(c.count))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.structEnd()
+		header.buf = append(header.buf, 0)
+		chunk := parquetChunk{
+			offset: w.offset,
+			size:   int64(len(header.buf) + len(page)),
+			count:  c.count,
+		}
+		if err := w.write(header.buf); err != nil {
+			return err
+		}
+		if err := w.write(page); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.size
+		c.reset()
+	}
+	w.groups = append(w.groups, group)
+	w.totalRows += int64(w.rows)
+	w.rows = 0
+	return nil
+}
+
+// Close flushes buffered rows and writes file metadata, it does not close out
+func (w *parquetWriter) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	meta := &thriftWriter{}
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(w.columns)+1)
+	meta.structBegin(0, true)
+	meta.binary(4, []byte("schema"))
+	meta.i32(5, int32(len(w.columns)))
+	meta.structEnd()
+	for _, c := range w.columns {
+		meta.structBegin(0, true)
+		meta.i32(1, c.typ)
+		if c.optional {
+			meta.i32(3, parquetOptional)
+		} else {
+			meta.i32(3, parquetRequired)
+		}
+		meta.binary(4, []byte(c.name))
+		if c.converted != parquetNoConvertedType {
+			meta.i32(6, c.converted)
+		}
+		meta.structEnd()
+	}
+	meta.i64(3, w.totalRows)
+	meta.list(4, thriftStruct, len(w.groups))
+	for _, group := range w.groups {
+		meta.structBegin(0, true)
+		meta.list(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			c := w.columns[i]
+			meta.structBegin(0, true)
+			meta.i64(2, chunk.offset)
+			meta.structBegin(3, false)
+			meta.i32(1, c.typ)
+			if c.optional {
+				meta.list(2, thriftI32, 2)
+				meta.zigzag(parquetPlain)
+				meta.zigzag(parquetRLE)
+			} else {
+				meta.list(2, thriftI32, 1)
+				meta.zigzag(parquetPlain)
+			}
+			meta.list(3, thriftBinary, 1)
+			meta.varint(uint64(len(c.name)))
+			meta.buf = append(meta.buf, c.name...)
+			meta.i32(4, 0) // UNCOMPRESSED
+			meta.i64(5, int64(chunk.count))
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.structEnd()
+			meta.structEnd()
+		}
+		meta.i64(2, group.size)
+		meta.i64(3, int64(group.rows))
+		meta.structEnd()
+	}
+	meta.binary(6, []byte("github.com/hdt3213/rdb"))
+	meta.buf = append(meta.buf, 0)
+	if err := w.write(meta.buf); err != nil {
+		return err
+	}
+	if err := w.write(binary.LittleEndian.AppendUint32(nil, uint32(len(meta.buf)))); err != nil {
+		return err
+	}
+	return w.write(parquetMagic)
+}
+
+// ToParquet reads rdb from reader and writes a row per key into out as parquet file, columns are db, key (binary), type,
+// encoding, size, element_count, expire_at (unix milliseconds, null if key is persistent) and value (json string
+// in the value schema of WithTypedJSON, type of module values is module as well).
+// Rows are flushed in row groups of at most 65536 rows or 64MB of values, so memory usage is bounded
+func ToParquet(reader io.Reader, out io.Writer, options ...interface{}) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	if out == nil {
+		return errors.New("output writer is required")
+	}
+	var dec decoder = core.NewDecoder(reader)
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	if dec, err = withBinaryEncoding(dec, options); err != nil {
+		return err
+	}
+	db := &parquetColumn{name: "db", typ: parquetInt32, converted: parquetNoConvertedType}
+	// keys are binary-safe and not encoded by WithBinaryEncoding, readers reject invalid UTF8 in a UTF8 column
+	key := &parquetColumn{name: "key", typ: parquetByteArray, converted: parquetNoConvertedType}
+	typ := &parquetColumn{name: "type", typ: parquetByteArray, converted: parquetUTF8}
+	encoding := &parquetColumn{name: "encoding", typ: parquetByteArray, converted: parquetUTF8}
+	size := &parquetColumn{name: "size", typ: parquetInt64, converted: parquetNoConvertedType}
+	elemCount := &parquetColumn{name: "element_count", typ: parquetInt64, converted: parquetNoConvertedType}
+	expireAt := &parquetColumn{name: "expire_at", typ: parquetInt64, converted: parquetTimestampMillis, optional: true}
+	value := &parquetColumn{name: "value", typ: parquetByteArray, converted: parquetJSON}
+	writer, err := newParquetWriter(out, []*parquetColumn{db, key, typ, encoding, size, elemCount, expireAt, value},
+		parquetRowGroupRows, parquetRowGroupBytes)
+	if err != nil {
+		return err
+	}
+	var writeErr error
+	err = dec.Parse(func(object model.RedisObject) bool {
+		typed := toTypedObject(object)
+		var data []byte
+		if data, writeErr = json.Marshal(typed.Value); writeErr != nil {
+			writeErr = fmt.Errorf("marshal value of %s failed: %v", object.GetKey(), writeErr)
+			return false
+		}
+		db.appendInt32(int32(object.GetDBIndex()))
+		key.appendBytes([]byte(object.GetKey()))
+		typ.appendBytes([]byte(typed.Type))
+		encoding.appendBytes([]byte(object.GetEncoding()))
+		size.appendInt64(int64(object.GetSize()))
+		elemCount.appendInt64(int64(object.GetElemCount()))
+		if typed.ExpireAt != nil {
+			expireAt.appendInt64(*typed.ExpireAt)
+		} else {
+			expireAt.appendNull()
+		}
+		value.appendBytes(data)
+		writeErr = writer.endRow()
+		return writeErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	return writer.Close()
+}
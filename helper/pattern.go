@@ -0,0 +1,220 @@
+package helper
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hdt3213/rdb/bytefmt"
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// PatternSeparatorsOption sets separators used to split key into segments, ":" by default
+type PatternSeparatorsOption []string
+
+// WithPatternSeparators sets separators used to split key into segments.
+// All separators are normalized to the first one in inferred patterns
+func WithPatternSeparators(separators ...string) PatternSeparatorsOption {
+	return separators
+}
+
+// PlaceholderRuleOption replaces segments fully matching Expr with Placeholder
+type PlaceholderRuleOption struct {
+	Placeholder string
+	Expr        string
+}
+
+// WithPlaceholderRule replaces segments fully matching expr with placeholder.
+// Custom rules are checked in the order they are given and before the default rules
+func WithPlaceholderRule(placeholder string, expr string) PlaceholderRuleOption {
+	return PlaceholderRuleOption{
+		Placeholder: placeholder,
+		Expr:        expr,
+	}
+}
+
+// MaxPatternsOption limits the number of distinct patterns
+type MaxPatternsOption int
+
+// WithMaxPatterns limits the number of distinct patterns of all databases in total, keys beyond the limit are
+// counted in OtherPattern of their database, which is not counted in the limit
+func WithMaxPatterns(n int) MaxPatternsOption {
+	return MaxPatternsOption(n)
+}
+
+// OtherPattern collects keys whose pattern cannot be added because of MaxPatternsOption
+const OtherPattern = "<other>"
+
+const defaultMaxPatterns = 10000
+
+type placeholderRule struct {
+	placeholder string
+	reg         *regexp.Regexp
+}
+
+var defaultPlaceholderRules = []*placeholderRule{
+	{
+		placeholder: "<uuid>",
+		reg:         regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`),
+	},
+	{
+		placeholder: "<int>",
+		reg:         regexp.MustCompile(`^-?[0-9]+$`),
+	},
+	{
+		placeholder: "<hex>",
+		reg:         regexp.MustCompile(`^[0-9a-fA-F]{16,}$`),
+	},
+}
+
+type patternInferrer struct {
+	separators  []string
+	rules       []*placeholderRule
+	maxPatterns int
+	// buckets is the set of genKey(db, pattern) returned by bucket, except OtherPattern
+	buckets map[string]struct{}
+}
+
+func newPatternInferrer(options ...interface{}) (*patternInferrer, error) {
+	inferrer := &patternInferrer{
+		maxPatterns: defaultMaxPatterns,
+		buckets:     make(map[string]struct{}),
+	}
+	for _, opt := range options {
+		switch o := opt.(type) {
+		case PatternSeparatorsOption:
+			inferrer.separators = o
+		case PlaceholderRuleOption:
+			reg, err := regexp.Compile("^(?:" + o.Expr + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("illegal placeholder rule: %v", o.Expr)
+			}
+			inferrer.rules = append(inferrer.rules, &placeholderRule{
+				placeholder: o.Placeholder,
+				reg:         reg,
+			})
+		case MaxPatternsOption:
+			inferrer.maxPatterns = int(o)
+		}
+	}
+	inferrer.rules = append(inferrer.rules, defaultPlaceholderRules...)
+	return inferrer, nil
+}
+
+// infer returns pattern of the given key
+func (inferrer *patternInferrer) infer(key string) string {
+	segments := split(key, inferrer.separators)
+	for i, segment := range segments {
+		for _, rule := range inferrer.rules {
+			if rule.reg.MatchString(segment) {
+				segments[i] = rule.placeholder
+				break
+			}
+		}
+	}
+	sep := ":"
+	if len(inferrer.separators) > 0 {
+		sep = inferrer.separators[0]
+	}
+	return strings.Join(segments, sep)
+}
+
+// bucket returns pattern of the given key in db, or OtherPattern if the pattern is new
+// and maxPatterns patterns of all databases have been returned
+func (inferrer *patternInferrer) bucket(db int, key string) string {
+	pattern := inferrer.infer(key)
+	id := genKey(db, pattern)
+	if _, ok := inferrer.buckets[id]; ok {
+		return pattern
+	}
+	if len(inferrer.buckets) >= inferrer.maxPatterns {
+		return OtherPattern
+	}
+	inferrer.buckets[id] = struct{}{}
+	return pattern
+}
+
+type patternStat struct {
+	db        int
+	pattern   string
+	totalSize int
+	keyCount  int
+}
+
+// InferredKeyPatterns reads rdb from reader, infers key patterns by replacing numeric and uuid-looking segments
+// with placeholders, then writes memory usage of each pattern to out as csv in descending order of size
+func InferredKeyPatterns(reader io.Reader, out io.Writer, options ...interface{}) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	if out == nil {
+		return errors.New("output writer is required")
+	}
+	inferrer, err := newPatternInferrer(options...)
+	if err != nil {
+		return err
+	}
+	var dec decoder = core.NewDecoder(reader)
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	stats := make(map[string]*patternStat)
+	err = dec.Parse(func(object model.RedisObject) bool {
+		pattern := inferrer.bucket(object.GetDBIndex(), object.GetKey())
+		key := genKey(object.GetDBIndex(), pattern)
+		stat := stats[key]
+		if stat == nil {
+			stat = &patternStat{
+				db:      object.GetDBIndex(),
+				pattern: pattern,
+			}
+			stats[key] = stat
+		}
+		stat.totalSize += object.GetSize()
+		stat.keyCount++
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	list := make([]*patternStat, 0, len(stats))
+	for _, stat := range stats {
+		list = append(list, stat)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].totalSize != list[j].totalSize {
+			return list[i].totalSize > list[j].totalSize
+		}
+		if list[i].db != list[j].db {
+			return list[i].db < list[j].db
+		}
+		return list[i].pattern < list[j].pattern
+	})
+
+	_, err = io.WriteString(out, "database,pattern,size,size_readable,key_count\n")
+	if err != nil {
+		return fmt.Errorf("write header failed: %v", err)
+	}
+	csvWriter := csv.NewWriter(out)
+	for _, stat := range list {
+		err = csvWriter.Write([]string{
+			strconv.Itoa(stat.db),
+			stat.pattern,
+			strconv.Itoa(stat.totalSize),
+			bytefmt.FormatSize(uint64(stat.totalSize)),
+			strconv.Itoa(stat.keyCount),
+		})
+		if err != nil {
+			return fmt.Errorf("csv write failed: %v", err)
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
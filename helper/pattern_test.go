@@ -0,0 +1,134 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+)
+
+func buildPatternRDB(t *testing.T, keys []string) []byte {
+	buf := bytes.NewBuffer(nil)
+	enc := encoder.NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, uint64(len(keys)), 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if err := enc.WriteStringObject(key, []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func inferPatterns(t *testing.T, keys []string, options ...interface{}) map[string]int {
+	out := bytes.NewBuffer(nil)
+	err := InferredKeyPatterns(bytes.NewReader(buildPatternRDB(t, keys)), out, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(map[string]int)
+	for _, record := range records[1:] {
+		count, _ := strconv.Atoi(record[4])
+		result[record[1]] = count
+	}
+	return result
+}
+
+func TestInferredKeyPatterns(t *testing.T) {
+	keys := []string{
+		"user:12345:cart",
+		"user:67890:cart",
+		"user:1:profile",
+		"session:1b4e28ba-2fa1-11d2-883f-0016d3cca427",
+		"session:6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"trace:00000000deadbeef",
+		"config",
+	}
+	result := inferPatterns(t, keys)
+	expect := map[string]int{
+		"user:<int>:cart":    2,
+		"user:<int>:profile": 1,
+		"session:<uuid>":     2,
+		"trace:<hex>":        1,
+		"config":             1,
+	}
+	if len(result) != len(expect) {
+		t.Errorf("expect %d patterns, actual %+v", len(expect), result)
+	}
+	for pattern, count := range expect {
+		if result[pattern] != count {
+			t.Errorf("pattern %s expect %d keys, actual %d", pattern, count, result[pattern])
+		}
+	}
+
+	// custom separators and rules
+	keys = []string{
+		"order.2024-01-02.a",
+		"order.2024-03-04.b",
+		"order/5.a",
+	}
+	result = inferPatterns(t, keys,
+		WithPatternSeparators(".", "/"),
+		WithPlaceholderRule("<date>", `\d{4}-\d{2}-\d{2}`),
+	)
+	if result["order.<date>.a"] != 1 || result["order.<date>.b"] != 1 || result["order.<int>.a"] != 1 {
+		t.Errorf("wrong result: %+v", result)
+	}
+
+	// bounded patterns
+	keys = []string{"a:1", "b:1", "c:1", "d:1"}
+	result = inferPatterns(t, keys, WithMaxPatterns(2))
+	if len(result) != 3 || result[OtherPattern] != 2 {
+		t.Errorf("wrong result: %+v", result)
+	}
+
+	// the limit applies to patterns of all databases, overflow keys are counted in <other> of their database
+	data := buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 2, 0))
+		check(enc.WriteStringObject("a:1", []byte("value")))
+		check(enc.WriteStringObject("b:1", []byte("value")))
+		check(enc.WriteDBHeader(1, 3, 0))
+		check(enc.WriteStringObject("a:2", []byte("value")))
+		check(enc.WriteStringObject("c:1", []byte("value")))
+		check(enc.WriteStringObject("b:2", []byte("value")))
+	})
+	out := bytes.NewBuffer(nil)
+	if err := InferredKeyPatterns(bytes.NewReader(data), out, WithMaxPatterns(2)); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make(map[string]string)
+	for _, record := range records[1:] {
+		rows[record[0]+" "+record[1]] = record[4]
+	}
+	expectRows := map[string]string{"0 a:<int>": "1", "0 b:<int>": "1", "1 " + OtherPattern: "3"}
+	if len(rows) != len(expectRows) {
+		t.Errorf("expect %v, actual %v", expectRows, rows)
+	}
+	for row, count := range expectRows {
+		if rows[row] != count {
+			t.Errorf("row %s: expect %s keys, actual %s", row, count, rows[row])
+		}
+	}
+
+	err = InferredKeyPatterns(bytes.NewReader(nil), bytes.NewBuffer(nil), WithPlaceholderRule("<x>", `(`))
+	if err == nil {
+		t.Error("expect error")
+	}
+}
@@ -38,7 +38,11 @@ func PrefixAnalyse(rdbFilename string, topN int, maxDepth int, output *os.File,
 	defer func() {
 		_ = rdbFile.Close()
 	}()
-	var dec decoder = core.NewDecoder(rdbFile)
+	coreDec := core.NewDecoder(rdbFile)
+	if err = applyArchBits(coreDec, options); err != nil {
+		return err
+	}
+	var dec decoder = coreDec
 	if dec, err = wrapDecoder(dec, options...); err != nil {
 		return err
 	}
@@ -53,7 +57,7 @@ func PrefixAnalyse(rdbFilename string, topN int, maxDepth int, output *os.File,
 	if err != nil {
 		return err
 	}
-	
+
 	// get top list
 	toplist := newToplist(topN)
 	tree.traverse(func(node *radixNode, depth int) bool {
@@ -53,10 +53,11 @@ func WithRegexOption(expr string) RegexOption {
 // noExpiredDecoder filter all expired keys
 type noExpiredDecoder struct {
 	dec decoder
+	now func() time.Time
 }
 
 func (d *noExpiredDecoder) Parse(cb func(object model.RedisObject) bool) error {
-	now := time.Now()
+	now := d.now()
 	return d.dec.Parse(func(object model.RedisObject) bool {
 		expiration := object.GetExpiration()
 		if expiration == nil || expiration.After(now) {
@@ -116,10 +117,99 @@ func (d *noExpirationDecoder) Parse(cb func(object model.RedisObject) bool) erro
 	})
 }
 
-func parseExpireExpr(s string) ([]int64, error) {
+// IdleFilterOption filters keys by LRU idle time
+type IdleFilterOption struct {
+	max time.Duration
+	hot bool
+}
+
+// WithIdleFilter keeps hot keys whose idle time is less than max if hot is true, otherwise keeps cold keys
+// whose idle time is not less than max. Keys without idle metadata, e.g. rdb is not saved under LRU maxmemory-policy,
+// are regarded as hot, so the hot and cold tiers together hold every key
+func WithIdleFilter(max time.Duration, hot bool) IdleFilterOption {
+	return IdleFilterOption{
+		max: max,
+		hot: hot,
+	}
+}
+
+// idleDecoder filters keys by idle time
+type idleDecoder struct {
+	dec decoder
+	max time.Duration
+	hot bool
+}
+
+func (d *idleDecoder) Parse(cb func(object model.RedisObject) bool) error {
+	return d.dec.Parse(func(object model.RedisObject) bool {
+		idle := getIdle(object)
+		if (idle == nil || time.Duration(*idle)*time.Second < d.max) == d.hot {
+			return cb(object)
+		}
+		return true
+	})
+}
+
+// getIdle returns idle time in seconds of object, or nil if it is unknown
+func getIdle(object model.RedisObject) *uint64 {
+	base := baseObject(object)
+	if base == nil {
+		return nil
+	}
+	return base.Idle
+}
+
+// baseObject returns BaseObject embedded in object, or nil if object is not a known type
+func baseObject(object model.RedisObject) *model.BaseObject {
+	switch o := object.(type) {
+	case *model.StringObject:
+		return o.BaseObject
+	case *model.ListObject:
+		return o.BaseObject
+	case *model.SetObject:
+		return o.BaseObject
+	case *model.HashObject:
+		return o.BaseObject
+	case *model.ZSetObject:
+		return o.BaseObject
+	case *model.StreamObject:
+		return o.BaseObject
+	case *model.ModuleTypeObject:
+		return o.BaseObject
+	}
+	return nil
+}
+
+// StripExpirationsOption removes expirations of all keys and hash fields, so exported keys never expire
+type StripExpirationsOption bool
+
+// WithStripExpirations removes expirations of all keys and hash fields after filters,
+// so that keys exported to aof, RESTORE commands or re-encoded rdb are persistent
+func WithStripExpirations() StripExpirationsOption {
+	return StripExpirationsOption(true)
+}
+
+// stripExpirationsDecoder removes expirations of all objects
+type stripExpirationsDecoder struct {
+	dec decoder
+}
+
+func (d *stripExpirationsDecoder) Parse(cb func(object model.RedisObject) bool) error {
+	return d.dec.Parse(func(object model.RedisObject) bool {
+		if hash, ok := object.(*model.HashObject); ok {
+			hash.FieldExpirations = nil
+		}
+		if base := baseObject(object); base != nil {
+			base.Expiration = nil
+		}
+		return cb(object)
+	})
+}
+
+func parseExpireExpr(s string, now func() time.Time) ([]int64, error) {
 	parseValue := func(s string) (int64, error) {
 		if s == "now" {
-			return time.Now().Unix(), nil
+			return now().Unix(), nil
 		}
 		if s == "inf" {
 			return math.MaxInt64, nil
@@ -147,16 +237,34 @@ func wrapDecoder(dec decoder, options ...interface{}) (decoder, error) {
 	var regexOpt RegexOption
 	var noExpiredOpt NoExpiredOption
 	var expirationOpt ExpirationOption
+	var hashtagOpt HashtagOption
+	var transformOpt ValueTransformOption
+	var stripOpt StripExpirationsOption
+	var idleOpt *IdleFilterOption
 	for _, opt := range options {
 		switch o := opt.(type) {
+		case ValueTransformOption:
+			transformOpt = o
+		case HashtagOption:
+			hashtagOpt = o
 		case RegexOption:
 			regexOpt = o
 		case NoExpiredOption:
 			noExpiredOpt = o
 		case ExpirationOption:
 			expirationOpt = o
+		case StripExpirationsOption:
+			stripOpt = o
+		case IdleFilterOption:
+			idleOpt = &o
 		}
 	}
+	if hashtagOpt != nil {
+		tag := *hashtagOpt
+		dec = withKeyFilter(dec, func(key string) bool {
+			return extractHashtag(key) == tag
+		})
+	}
 	if regexOpt != nil {
 		var err error
 		dec, err = regexWrapper(dec, *regexOpt)
@@ -167,6 +275,7 @@ func wrapDecoder(dec decoder, options ...interface{}) (decoder, error) {
 	if noExpiredOpt {
 		dec = &noExpiredDecoder{
 			dec: dec,
+			now: nowFunc(options),
 		}
 	}
 	if expirationOpt != "" {
@@ -180,7 +289,7 @@ func wrapDecoder(dec decoder, options ...interface{}) (decoder, error) {
 				expirationRange: []int64{0, math.MaxInt64},
 			}
 		} else {
-			rng, err := parseExpireExpr(string(expirationOpt))
+			rng, err := parseExpireExpr(string(expirationOpt), nowFunc(options))
 			if err != nil {
 				return nil, err
 			}
@@ -190,5 +299,23 @@ func wrapDecoder(dec decoder, options ...interface{}) (decoder, error) {
 			}
 		}
 	}
+	if idleOpt != nil {
+		dec = &idleDecoder{
+			dec: dec,
+			max: idleOpt.max,
+			hot: idleOpt.hot,
+		}
+	}
+	if stripOpt {
+		dec = &stripExpirationsDecoder{
+			dec: dec,
+		}
+	}
+	if transformOpt != nil {
+		dec = &transformDecoder{
+			dec:       dec,
+			transform: transformOpt,
+		}
+	}
 	return dec, nil
 }
@@ -0,0 +1,73 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// ReplicationInfo is replication metadata stored in aux fields of rdb,
+// it tells where in the replication stream the snapshot was taken
+type ReplicationInfo struct {
+	// Present is true if any replication metadata exists
+	Present bool
+	// ReplID is replication id of master
+	ReplID        string
+	ReplIDPresent bool
+	// ReplOffset is replication offset of master
+	ReplOffset        int64
+	ReplOffsetPresent bool
+	// ReplStreamDB is the selected db in replication stream
+	ReplStreamDB        int
+	ReplStreamDBPresent bool
+}
+
+// InspectReplication reads replication metadata from aux fields of rdb.
+// It stops at the first database, so the keyspace will not be parsed
+func InspectReplication(reader io.Reader) (*ReplicationInfo, error) {
+	if reader == nil {
+		return nil, errors.New("src reader is required")
+	}
+	info := &ReplicationInfo{}
+	var err error
+	dec := core.NewDecoder(reader).WithSpecialOpCode()
+	parseErr := dec.Parse(func(object model.RedisObject) bool {
+		aux, ok := object.(*model.AuxObject)
+		if !ok {
+			// aux fields are all in front of databases
+			return false
+		}
+		switch aux.Key {
+		case "repl-id":
+			info.ReplID = aux.Value
+			info.ReplIDPresent = true
+		case "repl-offset":
+			info.ReplOffset, err = strconv.ParseInt(aux.Value, 10, 64)
+			if err != nil {
+				err = fmt.Errorf("illegal repl-offset: %s", aux.Value)
+				return false
+			}
+			info.ReplOffsetPresent = true
+		case "repl-stream-db":
+			info.ReplStreamDB, err = strconv.Atoi(aux.Value)
+			if err != nil {
+				err = fmt.Errorf("illegal repl-stream-db: %s", aux.Value)
+				return false
+			}
+			info.ReplStreamDBPresent = true
+		}
+		return true
+	})
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.Present = info.ReplIDPresent || info.ReplOffsetPresent || info.ReplStreamDBPresent
+	return info, nil
+}
@@ -0,0 +1,75 @@
+package helper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+)
+
+func TestInspectReplication(t *testing.T) {
+	buildRDB := func(aux map[string]string) []byte {
+		buf := bytes.NewBuffer(nil)
+		enc := encoder.NewEncoder(buf)
+		if err := enc.WriteHeader(); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{"redis-ver", "repl-stream-db", "repl-id", "repl-offset"} {
+			if value, ok := aux[key]; ok {
+				if err := enc.WriteAux(key, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := enc.WriteDBHeader(0, 1, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteStringObject("a", []byte("b")); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteEnd(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	data := buildRDB(map[string]string{
+		"redis-ver":      "7.0.0",
+		"repl-stream-db": "2",
+		"repl-id":        "8c2b1e6c5d8c1a0e1b1f2a3c4d5e6f7a8b9c0d1e",
+		"repl-offset":    "123456",
+	})
+	info, err := InspectReplication(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Present || !info.ReplIDPresent || !info.ReplOffsetPresent || !info.ReplStreamDBPresent {
+		t.Errorf("expect all fields present: %+v", info)
+	}
+	if info.ReplID != "8c2b1e6c5d8c1a0e1b1f2a3c4d5e6f7a8b9c0d1e" || info.ReplOffset != 123456 || info.ReplStreamDB != 2 {
+		t.Errorf("wrong replication info: %+v", info)
+	}
+
+	// the keyspace should not be parsed, so a truncated body doesn't matter
+	info, err = InspectReplication(bytes.NewReader(data[:len(data)-12]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ReplOffset != 123456 {
+		t.Errorf("wrong replication info: %+v", info)
+	}
+
+	// standalone dump
+	info, err = InspectReplication(bytes.NewReader(buildRDB(map[string]string{"redis-ver": "7.0.0"})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *info != (ReplicationInfo{}) {
+		t.Errorf("expect zero value: %+v", info)
+	}
+
+	_, err = InspectReplication(bytes.NewReader(buildRDB(map[string]string{"repl-offset": "abc"})))
+	if err == nil {
+		t.Error("expect error")
+	}
+}
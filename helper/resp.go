@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/hdt3213/rdb/model"
 )
@@ -18,6 +21,156 @@ type CmdLine = [][]byte
 // lexOrder traversal map in lex order to create
 type lexOrder struct{}
 
+// ExpireShiftOption shifts all absolute expirations by a fixed duration during export
+type ExpireShiftOption time.Duration
+
+// WithExpireShift shifts all absolute expirations (including hash field expirations) by delta during export.
+// Keys whose shifted expiration is not in the future will be dropped, unless WithDropExpired(false)
+func WithExpireShift(delta time.Duration) ExpireShiftOption {
+	return ExpireShiftOption(delta)
+}
+
+// DropExpiredOption controls whether keys already expired at export time are emitted
+type DropExpiredOption bool
+
+// WithDropExpired controls whether keys already expired at export time are emitted.
+// Expired keys are emitted by default and will be deleted by redis on loading, unless WithExpireShift is used
+func WithDropExpired(drop bool) DropExpiredOption {
+	return DropExpiredOption(drop)
+}
+
+// dropExpired returns whether expired keys should not be exported
+func dropExpired(opts []interface{}) bool {
+	drop := false
+	for _, o := range opts {
+		if _, ok := o.(ExpireShiftOption); ok {
+			drop = true
+		}
+	}
+	for _, o := range opts {
+		if o, ok := o.(DropExpiredOption); ok {
+			drop = bool(o)
+		}
+	}
+	return drop
+}
+
+// ProtocolVersionOption is RESP version of the server which generated commands are replayed to
+type ProtocolVersionOption int
+
+// WithProtocolVersion sets RESP version of the target server, 2 or 3. Requests are arrays of bulk strings in both
+// versions and RESP3 only changes replies, so none of the generated commands (SELECT, SET, RPUSH, SADD, HMSET,
+// HPEXPIREAT, HPERSIST, ZADD, XADD, PEXPIREAT and RESTORE) differ for now. Other values are rejected
+func WithProtocolVersion(version int) ProtocolVersionOption {
+	return ProtocolVersionOption(version)
+}
+
+// protocolVersion returns RESP version given by WithProtocolVersion, default is 2
+func protocolVersion(opts []interface{}) (int, error) {
+	version := 2
+	for _, o := range opts {
+		if o, ok := o.(ProtocolVersionOption); ok {
+			version = int(o)
+		}
+	}
+	if version != 2 && version != 3 {
+		return 0, fmt.Errorf("unsupported protocol version: %d", version)
+	}
+	return version, nil
+}
+
+// SkeletonOnlyOption tells aof exporter to emit a sentinel command for every key instead of its value
+type SkeletonOnlyOption bool
+
+// WithSkeletonOnly tells aof exporter to emit `SET key ""` for every key instead of its value,
+// databases and expirations are preserved
+func WithSkeletonOnly() SkeletonOnlyOption {
+	return SkeletonOnlyOption(true)
+}
+
+// MaxCommandSizeOption limits size of commands generated by aof exporter
+type MaxCommandSizeOption struct {
+	size int
+	warn func(key string, size int)
+}
+
+// WithMaxCommandSize splits RPUSH, SADD, HMSET and ZADD of large collections into commands no larger than size bytes
+// in RESP, size should be below proto-max-bulk-len and client-query-buffer-limit of the target. Commands which could
+// not be split, e.g. XADD of a huge stream entry or SET of a huge string, are still emitted as a whole and reported
+// to warn with key and size of the command, warn is log.Printf if it is nil
+func WithMaxCommandSize(size int, warn func(key string, size int)) MaxCommandSizeOption {
+	return MaxCommandSizeOption{size: size, warn: warn}
+}
+
+// bulkSize returns length of arg as a bulk string in RESP
+func bulkSize(arg []byte) int {
+	return len(strconv.Itoa(len(arg))) + len(arg) + 5
+}
+
+// respSize returns length of command line of argc arguments in RESP, whose bulk strings take argsSize bytes
+func respSize(argc int, argsSize int) int {
+	return len(strconv.Itoa(argc)) + 3 + argsSize
+}
+
+// splittableCmds are commands whose arguments after key could be split into several commands,
+// values are number of arguments of an element
+var splittableCmds = map[string]int{
+	"RPUSH": 1,
+	"SADD":  1,
+	"HMSET": 2,
+	"ZADD":  2,
+}
+
+// limitCmdSize splits commands larger than limit and reports the ones which could not be split
+func limitCmdSize(key string, cmdLines []CmdLine, limit MaxCommandSizeOption) []CmdLine {
+	warn := limit.warn
+	if warn == nil {
+		warn = func(key string, size int) {
+			log.Printf("command of %s is %d bytes, larger than max command size %d", key, size, limit.size)
+		}
+	}
+	result := make([]CmdLine, 0, len(cmdLines))
+	for _, cmdLine := range cmdLines {
+		argsSize := 0
+		for _, arg := range cmdLine {
+			argsSize += bulkSize(arg)
+		}
+		if respSize(len(cmdLine), argsSize) <= limit.size {
+			result = append(result, cmdLine)
+			continue
+		}
+		step, ok := splittableCmds[string(cmdLine[0])]
+		if !ok {
+			warn(key, respSize(len(cmdLine), argsSize))
+			result = append(result, cmdLine)
+			continue
+		}
+		header := cmdLine[:2]
+		headerSize := bulkSize(header[0]) + bulkSize(header[1])
+		chunk := append(CmdLine{}, header...)
+		chunkSize := headerSize
+		for i := 2; i+step <= len(cmdLine); i += step {
+			elementSize := 0
+			for _, arg := range cmdLine[i : i+step] {
+				elementSize += bulkSize(arg)
+			}
+			if len(chunk) > 2 && respSize(len(chunk)+step, chunkSize+elementSize) > limit.size {
+				result = append(result, chunk)
+				chunk = append(CmdLine{}, header...)
+				chunkSize = headerSize
+			}
+			chunk = append(chunk, cmdLine[i:i+step]...)
+			chunkSize += elementSize
+			if len(chunk) == 2+step && respSize(len(chunk), chunkSize) > limit.size {
+				// a single element exceeds the limit
+				warn(key, respSize(len(chunk), chunkSize))
+			}
+		}
+		result = append(result, chunk)
+	}
+	return result
+}
+
 func makeMultiBulkResp(args [][]byte) []byte {
 	argLen := len(args)
 	var buf bytes.Buffer
@@ -42,6 +195,16 @@ func stringToCmd(obj *model.StringObject) CmdLine {
 	return cmdLine
 }
 
+var selectCmd = []byte("SELECT")
+
+func makeSelectCmd(db int) CmdLine {
+	return CmdLine{selectCmd, []byte(strconv.Itoa(db))}
+}
+
+func skeletonToCmd(obj model.RedisObject) CmdLine {
+	return CmdLine{setCmd, []byte(obj.GetKey()), []byte{}}
+}
+
 var rPushAllCmd = []byte("RPUSH")
 
 func listToCmd(obj *model.ListObject) CmdLine {
@@ -70,7 +233,7 @@ var hMSetCmd = []byte("HMSET")
 var hPExpireAtCmd = []byte("HPEXPIREAT") // redis 7.4.0+
 var hPersistCmd = []byte("HPERSIST")     // redis 7.4.0+
 
-func hashToCmd(obj *model.HashObject, useLexOrder bool) []CmdLine {
+func hashToCmd(obj *model.HashObject, useLexOrder bool, expireShift time.Duration) []CmdLine {
 	cmdLine := make([][]byte, 2+obj.GetElemCount()*2)
 	cmdLine[0] = hMSetCmd
 	cmdLine[1] = []byte(obj.GetKey())
@@ -115,7 +278,7 @@ func hashToCmd(obj *model.HashObject, useLexOrder bool) []CmdLine {
 				// HPEXPIRE key seconds FIELDS num FIELD...
 				hpexp[0] = hPExpireAtCmd
 				hpexp[1] = []byte(obj.Key)
-				hpexp[2] = []byte(fmt.Sprintf("%d", expire))
+				hpexp[2] = []byte(fmt.Sprintf("%d", expire+int64(expireShift/time.Millisecond)))
 				hpexp[3] = []byte("FIELDS")
 				hpexp[4] = []byte("1")
 				hpexp[5] = []byte(field)
@@ -129,14 +292,21 @@ func hashToCmd(obj *model.HashObject, useLexOrder bool) []CmdLine {
 
 var zAddCmd = []byte("ZADD")
 
+// zSetToCmd converts zset to ZADD, members with nan score are skipped with a log since ZADD rejects nan.
+// It returns nil if no member is left
 func zSetToCmd(obj *model.ZSetObject) CmdLine {
-	cmdLine := make([][]byte, 2+obj.GetElemCount()*2)
+	cmdLine := make([][]byte, 2, 2+obj.GetElemCount()*2)
 	cmdLine[0] = zAddCmd
 	cmdLine[1] = []byte(obj.GetKey())
-	for i, e := range obj.Entries {
-		value := strconv.FormatFloat(e.Score, 'f', -1, 64)
-		cmdLine[2+i*2] = []byte(value)
-		cmdLine[3+i*2] = []byte(e.Member)
+	for _, e := range obj.Entries {
+		if math.IsNaN(e.Score) {
+			log.Printf("member %s of zset %s has nan score which ZADD rejects, it is skipped", e.Member, obj.GetKey())
+			continue
+		}
+		cmdLine = append(cmdLine, []byte(model.FormatScore(e.Score)), []byte(e.Member))
+	}
+	if len(cmdLine) == 2 {
+		return nil
 	}
 	return cmdLine
 }
@@ -144,7 +314,7 @@ func zSetToCmd(obj *model.ZSetObject) CmdLine {
 var pExpireAtBytes = []byte("PEXPIREAT")
 
 // MakeExpireCmd generates command line to set expiration for the given key
-func makeExpireCmd(obj model.RedisObject) CmdLine {
+func makeExpireCmd(obj model.RedisObject, expireShift time.Duration) CmdLine {
 	expireAt := obj.GetExpiration()
 	if expireAt == nil {
 		return nil
@@ -152,12 +322,15 @@ func makeExpireCmd(obj model.RedisObject) CmdLine {
 	args := make([][]byte, 3)
 	args[0] = pExpireAtBytes
 	args[1] = []byte(obj.GetKey())
-	args[2] = []byte(strconv.FormatInt(expireAt.UnixNano()/1e6, 10))
+	args[2] = []byte(strconv.FormatInt(expireAt.Add(expireShift).UnixNano()/1e6, 10))
 	return args
 }
 
 var (
-	xaddCmd = []byte("XADD")
+	xaddCmd   = []byte("XADD")
+	xsetIDCmd = []byte("XSETID")
+	xgroupCmd = []byte("XGROUP")
+	xclaimCmd = []byte("XCLAIM")
 )
 
 func formatStreamID(streamID *model.StreamId) string {
@@ -166,45 +339,164 @@ func formatStreamID(streamID *model.StreamId) string {
 	return ms + "-" + seq
 }
 
+// streamToCmd replays stream like rewriteStreamObject of redis aof.c: XADD with explicit ids, XSETID to restore
+// last id and counters, then XGROUP CREATE, XGROUP CREATECONSUMER and XCLAIM to restore consumer groups and PELs
 func streamToCmd(stream *model.StreamObject) []CmdLine {
 	commands := make([]CmdLine, 0)
+	key := []byte(stream.GetKey())
 
 	for _, entry := range stream.Entries {
 		for _, message := range entry.Msgs {
+			if message.Deleted {
+				continue
+			}
 			args := make(CmdLine, 0, 3+len(message.Fields)*2)
 			args = append(
 				args,
 				xaddCmd,
-				[]byte(stream.GetKey()),
+				key,
 				[]byte(formatStreamID(message.Id)),
 			)
-
-			for key, value := range message.Fields {
-				args = append(args, []byte(key), []byte(value))
+			for _, field := range streamMessageFields(entry, message) {
+				args = append(args, []byte(field), []byte(message.Fields[field]))
 			}
-
 			commands = append(commands, args)
+		}
+	}
+	lastId := stream.LastId
+	if lastId == nil {
+		lastId = &model.StreamId{}
+	}
+	if len(commands) == 0 {
+		// create an empty stream like rewriteStreamObject of redis, the placeholder id is 0-1 since XADD
+		// rejects 0-0 which is last id of streams created by XGROUP CREATE MKSTREAM, XSETID below restores the last id
+		commands = append(commands, CmdLine{
+			xaddCmd, key, []byte("MAXLEN"), []byte("0"), []byte("0-1"), []byte("x"), []byte("y"),
+		})
+	}
 
-			// TODO: groups, consumers, pending
+	setID := CmdLine{xsetIDCmd, key, []byte(formatStreamID(lastId))}
+	if stream.Version >= 2 {
+		maxDeletedId := stream.MaxDeletedId
+		if maxDeletedId == nil {
+			maxDeletedId = &model.StreamId{}
+		}
+		setID = append(setID,
+			[]byte("ENTRIESADDED"), []byte(strconv.FormatUint(stream.AddedEntriesCount, 10)),
+			[]byte("MAXDELETEDID"), []byte(formatStreamID(maxDeletedId)),
+		)
+	}
+	commands = append(commands, setID)
+
+	for _, group := range stream.Groups {
+		groupName := []byte(group.Name)
+		groupLastId := group.LastId
+		if groupLastId == nil {
+			groupLastId = &model.StreamId{}
+		}
+		create := CmdLine{xgroupCmd, []byte("CREATE"), key, groupName, []byte(formatStreamID(groupLastId))}
+		if stream.Version >= 2 {
+			entriesRead := "-1" // SCG_INVALID_ENTRIES_READ
+			if group.EntriesRead != ^uint64(0) {
+				entriesRead = strconv.FormatUint(group.EntriesRead, 10)
+			}
+			create = append(create, []byte("ENTRIESREAD"), []byte(entriesRead))
+		}
+		commands = append(commands, create)
+
+		nacks := make(map[model.StreamId]*model.StreamNAck, len(group.Pending))
+		for _, nack := range group.Pending {
+			nacks[*nack.Id] = nack
+		}
+		for _, consumer := range group.Consumers {
+			consumerName := []byte(consumer.Name)
+			commands = append(commands, CmdLine{xgroupCmd, []byte("CREATECONSUMER"), key, groupName, consumerName})
+			for _, id := range consumer.Pending {
+				nack := nacks[*id]
+				if nack == nil {
+					continue
+				}
+				commands = append(commands, CmdLine{
+					xclaimCmd, key, groupName, consumerName, []byte("0"), []byte(formatStreamID(id)),
+					[]byte("TIME"), []byte(strconv.FormatUint(nack.DeliveryTime, 10)),
+					[]byte("RETRYCOUNT"), []byte(strconv.FormatUint(nack.DeliveryCount, 10)),
+					[]byte("JUSTID"), []byte("FORCE"),
+				})
+			}
 		}
 	}
 
 	return commands
 }
 
+// streamMessageFields returns fields of message, fields shared with master entry keep their order in listpack
+// and the others are sorted, so that output is stable
+func streamMessageFields(entry *model.StreamEntry, message *model.StreamMessage) []string {
+	fields := make([]string, 0, len(message.Fields))
+	seen := make(map[string]struct{}, len(message.Fields))
+	for _, field := range entry.Fields {
+		if _, ok := message.Fields[field]; ok {
+			if _, dup := seen[field]; !dup {
+				seen[field] = struct{}{}
+				fields = append(fields, field)
+			}
+		}
+	}
+	if len(fields) == len(message.Fields) {
+		return fields
+	}
+	others := make([]string, 0, len(message.Fields)-len(fields))
+	for field := range message.Fields {
+		if _, ok := seen[field]; !ok {
+			others = append(others, field)
+		}
+	}
+	sort.Strings(others)
+	return append(fields, others...)
+}
+
 // ObjectToCmd convert redis object to redis command line
 func ObjectToCmd(obj model.RedisObject, opts ...interface{}) []CmdLine {
 	if obj == nil {
 		return nil
 	}
 	useLexOrder := false
+	var expireShift time.Duration
+	skeletonOnly := false
+	var maxCmdSize *MaxCommandSizeOption
 	for _, o := range opts {
-		switch o.(type) {
+		switch o := o.(type) {
+		case MaxCommandSizeOption:
+			maxCmdSize = &o
 		case lexOrder:
 			useLexOrder = true
+		case SkeletonOnlyOption:
+			skeletonOnly = bool(o)
+		case ExpireShiftOption:
+			expireShift = time.Duration(o)
+		}
+	}
+	if obj.GetExpiration() != nil && dropExpired(opts) {
+		// drop keys which will be expired immediately after loading
+		if !obj.GetExpiration().Add(expireShift).After(nowFunc(opts)()) {
+			return nil
 		}
 	}
 	cmdLines := make([]CmdLine, 0)
+	if skeletonOnly {
+		cmdLines = append(cmdLines, skeletonToCmd(obj))
+		if obj.GetExpiration() != nil {
+			cmdLines = append(cmdLines, makeExpireCmd(obj, expireShift))
+		}
+		return cmdLines
+	}
+	switch obj.GetType() {
+	case model.ListType, model.SetType, model.HashType, model.ZSetType:
+		if obj.GetElemCount() == 0 {
+			// redis has no empty collections and rejects RPUSH, SADD, HMSET or ZADD without elements
+			return nil
+		}
+	}
 	switch obj.GetType() {
 	case model.StringType:
 		strObj := obj.(*model.StringObject)
@@ -214,19 +506,26 @@ func ObjectToCmd(obj model.RedisObject, opts ...interface{}) []CmdLine {
 		cmdLines = append(cmdLines, listToCmd(listObj))
 	case model.HashType:
 		hashObj := obj.(*model.HashObject)
-		cmdLines = append(cmdLines, hashToCmd(hashObj, useLexOrder)...)
+		cmdLines = append(cmdLines, hashToCmd(hashObj, useLexOrder, expireShift)...)
 	case model.SetType:
 		setObj := obj.(*model.SetObject)
 		cmdLines = append(cmdLines, setToCmd(setObj))
 	case model.ZSetType:
 		zsetObj := obj.(*model.ZSetObject)
-		cmdLines = append(cmdLines, zSetToCmd(zsetObj))
+		cmdLine := zSetToCmd(zsetObj)
+		if cmdLine == nil {
+			return nil
+		}
+		cmdLines = append(cmdLines, cmdLine)
 	case model.StreamType:
 		streamObj := obj.(*model.StreamObject)
 		cmdLines = append(cmdLines, streamToCmd(streamObj)...)
 	}
 	if obj.GetExpiration() != nil {
-		cmdLines = append(cmdLines, makeExpireCmd(obj))
+		cmdLines = append(cmdLines, makeExpireCmd(obj, expireShift))
+	}
+	if maxCmdSize != nil {
+		cmdLines = limitCmdSize(obj.GetKey(), cmdLines, *maxCmdSize)
 	}
 	return cmdLines
 }
@@ -0,0 +1,216 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// RestoreReplaceOption adds REPLACE to RESTORE command, so existing keys will be overwritten instead of BUSYKEY error
+type RestoreReplaceOption bool
+
+// WithRestoreReplace adds REPLACE to RESTORE command, so replay into a non-empty redis is idempotent
+func WithRestoreReplace() RestoreReplaceOption {
+	return RestoreReplaceOption(true)
+}
+
+// RestoreAbsTTLOption adds ABSTTL to RESTORE command, so ttl is the absolute unix time in milliseconds
+type RestoreAbsTTLOption bool
+
+// WithRestoreAbsTTL adds ABSTTL to RESTORE command, so the original absolute expiration is preserved
+func WithRestoreAbsTTL() RestoreAbsTTLOption {
+	return RestoreAbsTTLOption(true)
+}
+
+// RestoreModeOption tells aof exporter to emit RESTORE commands with DUMP payload instead of commands like SET, RPUSH
+type RestoreModeOption bool
+
+// WithRestoreMode tells aof exporter to emit RESTORE commands with DUMP payload
+func WithRestoreMode() RestoreModeOption {
+	return RestoreModeOption(true)
+}
+
+// PipelineOption sets max number of commands RestoreTo sends before reading their replies
+type PipelineOption int
+
+// WithPipeline makes RestoreTo send up to depth commands before reading their replies if conn is a PipelineConn,
+// which improves throughput over latency-bound links
+func WithPipeline(depth int) PipelineOption {
+	return PipelineOption(depth)
+}
+
+// RedisConn sends a command to redis and waits for its reply, error reply should be returned as error
+type RedisConn interface {
+	Do(cmdLine CmdLine) error
+}
+
+// PipelineConn is a RedisConn which is able to send several commands before reading their replies
+type PipelineConn interface {
+	RedisConn
+	// Pipeline sends all cmdLines then reads their replies. It returns an error for each command, nil means success,
+	// or a non-nil error if the connection failed
+	Pipeline(cmdLines []CmdLine) ([]error, error)
+}
+
+// restorePipeline buffers commands and names of them, which are used in error message
+type restorePipeline struct {
+	conn     PipelineConn
+	depth    int
+	cmdLines []CmdLine
+	names    []string
+}
+
+func (p *restorePipeline) send(name string, cmdLine CmdLine) error {
+	p.cmdLines = append(p.cmdLines, cmdLine)
+	p.names = append(p.names, name)
+	if len(p.cmdLines) >= p.depth {
+		return p.flush()
+	}
+	return nil
+}
+
+// flush sends buffered commands and returns the first error
+func (p *restorePipeline) flush() error {
+	if len(p.cmdLines) == 0 {
+		return nil
+	}
+	errs, err := p.conn.Pipeline(p.cmdLines)
+	if err != nil {
+		return fmt.Errorf("pipeline failed: %v", err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("%s failed: %v", p.names[i], err)
+		}
+	}
+	p.cmdLines = p.cmdLines[:0]
+	p.names = p.names[:0]
+	return nil
+}
+
+var (
+	restoreCmd     = []byte("RESTORE")
+	replaceKeyword = []byte("REPLACE")
+	absTTLKeyword  = []byte("ABSTTL")
+)
+
+// ObjectToRestoreCmd converts object to RESTORE command, the object must be decoded WithDumpPayload.
+// It returns nil if object has no dump payload, or it is expired and WithDropExpired(true).
+// TTL of expired keys is clamped to 1ms, because 0 means the key is persistent for RESTORE
+func ObjectToRestoreCmd(obj model.RedisObject, opts ...interface{}) CmdLine {
+	payload := obj.GetDumpPayload()
+	if payload == nil {
+		return nil
+	}
+	replace := false
+	absTTL := false
+	var expireShift time.Duration
+	for _, o := range opts {
+		switch o := o.(type) {
+		case RestoreReplaceOption:
+			replace = bool(o)
+		case RestoreAbsTTLOption:
+			absTTL = bool(o)
+		case ExpireShiftOption:
+			expireShift = time.Duration(o)
+		}
+	}
+	var ttl int64
+	if expiration := obj.GetExpiration(); expiration != nil {
+		expireAt := expiration.Add(expireShift)
+		now := nowFunc(opts)()
+		if !expireAt.After(now) && dropExpired(opts) {
+			return nil
+		}
+		if absTTL {
+			ttl = expireAt.UnixNano() / 1e6
+		} else {
+			ttl = int64(expireAt.Sub(now) / time.Millisecond)
+		}
+		if ttl < 1 {
+			ttl = 1
+		}
+	}
+	cmdLine := CmdLine{
+		restoreCmd,
+		[]byte(obj.GetKey()),
+		[]byte(strconv.FormatInt(ttl, 10)),
+		payload,
+	}
+	if replace {
+		cmdLine = append(cmdLine, replaceKeyword)
+	}
+	if absTTL && ttl > 0 {
+		cmdLine = append(cmdLine, absTTLKeyword)
+	}
+	return cmdLine
+}
+
+// RestoreTo reads rdb from reader and restores all keys into redis through conn using RESTORE command.
+// It stops and returns the first error. WithPipeline sends commands in batches if conn is a PipelineConn,
+// then commands after the failed one in the same batch have been sent when it returns
+func RestoreTo(reader io.Reader, conn RedisConn, options ...interface{}) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	if conn == nil {
+		return errors.New("redis connection is required")
+	}
+	if _, err := protocolVersion(options); err != nil {
+		return err
+	}
+	if err := checkRestoreTransform(options); err != nil {
+		return err
+	}
+	var dec decoder = core.NewDecoder(reader).WithDumpPayload()
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	send := func(name string, cmdLine CmdLine) error {
+		if err := conn.Do(cmdLine); err != nil {
+			return fmt.Errorf("%s failed: %v", name, err)
+		}
+		return nil
+	}
+	var pipeline *restorePipeline
+	for _, opt := range options {
+		if depth, ok := opt.(PipelineOption); ok && depth > 1 {
+			if pipelineConn, ok := conn.(PipelineConn); ok {
+				pipeline = &restorePipeline{
+					conn:  pipelineConn,
+					depth: int(depth),
+				}
+				send = pipeline.send
+			}
+		}
+	}
+	currentDB := 0
+	var restoreErr error
+	err = dec.Parse(func(object model.RedisObject) bool {
+		cmdLine := ObjectToRestoreCmd(object, options...)
+		if cmdLine == nil {
+			return true
+		}
+		if object.GetDBIndex() != currentDB {
+			currentDB = object.GetDBIndex()
+			if restoreErr = send("select db "+strconv.Itoa(currentDB), makeSelectCmd(currentDB)); restoreErr != nil {
+				return false
+			}
+		}
+		restoreErr = send("restore "+object.GetKey(), cmdLine)
+		return restoreErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if restoreErr == nil && pipeline != nil {
+		restoreErr = pipeline.flush()
+	}
+	return restoreErr
+}
@@ -0,0 +1,290 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/crc64jones"
+	"github.com/hdt3213/rdb/encoder"
+	"github.com/hdt3213/rdb/model"
+)
+
+type fakeRedisConn struct {
+	cmdLines []CmdLine
+	failAt   int
+}
+
+func (c *fakeRedisConn) Do(cmdLine CmdLine) error {
+	c.cmdLines = append(c.cmdLines, cmdLine)
+	if c.failAt > 0 && len(c.cmdLines) == c.failAt {
+		return errors.New("BUSYKEY Target key name already exists")
+	}
+	return nil
+}
+
+func buildRestoreRDB(t *testing.T, expireAt uint64) []byte {
+	return buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 2, 1))
+		check(enc.WriteStringObject("str", []byte("value"), encoder.WithTTL(expireAt)))
+		check(enc.WriteHashMapObject("hash", map[string][]byte{"a": []byte("1"), "b": []byte("2")}))
+		check(enc.WriteDBHeader(1, 1, 0))
+		check(enc.WriteListObject("list", [][]byte{[]byte("a"), []byte("b")}))
+	})
+}
+
+// decodePayload wraps dump payload into a rdb file and decodes it
+func decodePayload(t *testing.T, key string, payload []byte) model.RedisObject {
+	if len(payload) < 11 {
+		t.Fatalf("payload too short")
+	}
+	crc := crc64jones.New()
+	_, _ = crc.Write(payload[:len(payload)-8])
+	if crc.Sum64() != binary.LittleEndian.Uint64(payload[len(payload)-8:]) {
+		t.Fatalf("wrong crc of payload")
+	}
+	rdb := []byte("REDIS0009")
+	rdb = append(rdb, 0xfe, 0x00, payload[0], byte(len(key)))
+	rdb = append(rdb, key...)
+	rdb = append(rdb, payload[1:len(payload)-10]...)
+	rdb = append(rdb, 0xff)
+	rdb = append(rdb, make([]byte, 8)...)
+	var result model.RedisObject
+	err := core.NewDecoder(bytes.NewReader(rdb)).Parse(func(o model.RedisObject) bool {
+		result = o
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestObjectToRestoreCmd(t *testing.T) {
+	expireAt := uint64(time.Now().Add(time.Hour).UnixNano() / 1e6)
+	data := buildRestoreRDB(t, expireAt)
+	objects := make(map[string]model.RedisObject)
+	err := core.NewDecoder(bytes.NewReader(data)).WithDumpPayload().Parse(func(o model.RedisObject) bool {
+		objects[o.GetKey()] = o
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := objects["str"]
+	cmdLine := ObjectToRestoreCmd(str, WithRestoreReplace(), WithRestoreAbsTTL())
+	if len(cmdLine) != 6 || string(cmdLine[0]) != "RESTORE" || string(cmdLine[1]) != "str" ||
+		string(cmdLine[2]) != strconv.FormatUint(expireAt, 10) ||
+		string(cmdLine[4]) != "REPLACE" || string(cmdLine[5]) != "ABSTTL" {
+		t.Errorf("wrong restore command: %q", cmdLine)
+	}
+	decoded := decodePayload(t, "str", cmdLine[3]).(*model.StringObject)
+	if string(decoded.Value) != "value" {
+		t.Errorf("wrong decoded value: %s", decoded.Value)
+	}
+
+	cmdLine = ObjectToRestoreCmd(str)
+	if len(cmdLine) != 4 {
+		t.Errorf("wrong restore command: %q", cmdLine)
+	}
+	ttl, _ := strconv.ParseInt(string(cmdLine[2]), 10, 64)
+	if ttl <= 0 || ttl > int64(time.Hour/time.Millisecond) {
+		t.Errorf("wrong relative ttl: %d", ttl)
+	}
+
+	// persistent key has 0 ttl and no ABSTTL
+	cmdLine = ObjectToRestoreCmd(objects["hash"], WithRestoreAbsTTL())
+	if len(cmdLine) != 4 || string(cmdLine[2]) != "0" {
+		t.Errorf("wrong restore command: %q", cmdLine)
+	}
+	hash := decodePayload(t, "hash", cmdLine[3]).(*model.HashObject)
+	if len(hash.Hash) != 2 || string(hash.Hash["b"]) != "2" {
+		t.Errorf("wrong decoded hash: %+v", hash.Hash)
+	}
+
+	if ObjectToRestoreCmd(&model.StringObject{BaseObject: &model.BaseObject{Key: "a"}}) != nil {
+		t.Error("object without payload should be ignored")
+	}
+}
+
+func TestRestoreTo(t *testing.T) {
+	expireAt := uint64(time.Now().Add(time.Hour).UnixNano() / 1e6)
+	data := buildRestoreRDB(t, expireAt)
+	conn := &fakeRedisConn{}
+	err := RestoreTo(bytes.NewReader(data), conn, WithRestoreReplace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"RESTORE str", "RESTORE hash", "SELECT 1", "RESTORE list"}
+	if len(conn.cmdLines) != len(expect) {
+		t.Fatalf("expect %d commands, actual %d", len(expect), len(conn.cmdLines))
+	}
+	for i, cmdLine := range conn.cmdLines {
+		if string(cmdLine[0])+" "+string(cmdLine[1]) != expect[i] {
+			t.Errorf("expect %s, actual %q", expect[i], cmdLine)
+		}
+		if string(cmdLine[0]) == "RESTORE" && string(cmdLine[len(cmdLine)-1]) != "REPLACE" {
+			t.Errorf("expect REPLACE flag: %q", cmdLine)
+		}
+	}
+
+	// stop at the first error
+	conn = &fakeRedisConn{failAt: 2}
+	err = RestoreTo(bytes.NewReader(data), conn)
+	if err == nil {
+		t.Error("expect error")
+	}
+	if len(conn.cmdLines) != 2 {
+		t.Errorf("expect to stop after the first error, actual %d commands", len(conn.cmdLines))
+	}
+}
+
+func TestExportExpiredKeys(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	zero := time.Unix(0, 0)
+	obj := &model.StringObject{
+		BaseObject: &model.BaseObject{
+			Key:         "a",
+			Expiration:  &past,
+			DumpPayload: []byte("payload"),
+		},
+		Value: []byte("b"),
+	}
+
+	// expired keys are emitted by default and redis will delete them on loading
+	cmdLines := ObjectToCmd(obj)
+	if len(cmdLines) != 2 || string(cmdLines[1][2]) != strconv.FormatInt(past.UnixNano()/1e6, 10) {
+		t.Errorf("wrong command lines: %q", cmdLines)
+	}
+	if cmdLines = ObjectToCmd(obj, WithDropExpired(true)); len(cmdLines) != 0 {
+		t.Errorf("expired key should be dropped: %q", cmdLines)
+	}
+	if cmdLines = ObjectToCmd(obj, WithExpireShift(time.Minute)); len(cmdLines) != 0 {
+		t.Errorf("expired key should be dropped: %q", cmdLines)
+	}
+	if cmdLines = ObjectToCmd(obj, WithDropExpired(false), WithExpireShift(time.Minute)); len(cmdLines) != 2 {
+		t.Errorf("expired key should be emitted: %q", cmdLines)
+	}
+
+	// relative ttl is clamped to 1ms, 0 means persistent for RESTORE
+	cmdLine := ObjectToRestoreCmd(obj)
+	if len(cmdLine) != 4 || string(cmdLine[2]) != "1" {
+		t.Errorf("wrong restore command: %q", cmdLine)
+	}
+	cmdLine = ObjectToRestoreCmd(obj, WithRestoreAbsTTL())
+	if len(cmdLine) != 5 || string(cmdLine[2]) != strconv.FormatInt(past.UnixNano()/1e6, 10) {
+		t.Errorf("wrong restore command: %q", cmdLine)
+	}
+	if cmdLine = ObjectToRestoreCmd(obj, WithDropExpired(true)); cmdLine != nil {
+		t.Errorf("expired key should be dropped: %q", cmdLine)
+	}
+
+	obj.Expiration = &zero
+	cmdLine = ObjectToRestoreCmd(obj, WithRestoreAbsTTL())
+	if len(cmdLine) != 5 || string(cmdLine[2]) != "1" || string(cmdLine[4]) != "ABSTTL" {
+		t.Errorf("wrong restore command: %q", cmdLine)
+	}
+	cmdLines = ObjectToCmd(obj)
+	if len(cmdLines) != 2 || string(cmdLines[1][2]) != "0" {
+		t.Errorf("wrong command lines: %q", cmdLines)
+	}
+}
+
+// fakePipelineConn simulates a redis connection with latency for each round trip
+type fakePipelineConn struct {
+	fakeRedisConn
+	latency    time.Duration
+	roundTrips int
+}
+
+func (c *fakePipelineConn) Do(cmdLine CmdLine) error {
+	c.roundTrips++
+	time.Sleep(c.latency)
+	return c.fakeRedisConn.Do(cmdLine)
+}
+
+func (c *fakePipelineConn) Pipeline(cmdLines []CmdLine) ([]error, error) {
+	c.roundTrips++
+	time.Sleep(c.latency)
+	errs := make([]error, len(cmdLines))
+	for i, cmdLine := range cmdLines {
+		errs[i] = c.fakeRedisConn.Do(cmdLine)
+	}
+	return errs, nil
+}
+
+func TestRestoreToPipeline(t *testing.T) {
+	expireAt := uint64(time.Now().Add(time.Hour).UnixNano() / 1e6)
+	data := buildRestoreRDB(t, expireAt)
+	conn := &fakePipelineConn{}
+	err := RestoreTo(bytes.NewReader(data), conn, WithPipeline(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"RESTORE str", "RESTORE hash", "SELECT 1", "RESTORE list"}
+	if len(conn.cmdLines) != len(expect) {
+		t.Fatalf("expect %d commands, actual %d", len(expect), len(conn.cmdLines))
+	}
+	for i, cmdLine := range conn.cmdLines {
+		if string(cmdLine[0])+" "+string(cmdLine[1]) != expect[i] {
+			t.Errorf("expect %s, actual %q", expect[i], cmdLine)
+		}
+	}
+	if conn.roundTrips != 2 {
+		t.Errorf("expect 2 round trips, actual %d", conn.roundTrips)
+	}
+
+	// stop at the first error
+	conn = &fakePipelineConn{fakeRedisConn: fakeRedisConn{failAt: 2}}
+	err = RestoreTo(bytes.NewReader(data), conn, WithPipeline(2))
+	if err == nil {
+		t.Error("expect error")
+	}
+	if conn.roundTrips != 1 {
+		t.Errorf("expect to stop after the first batch, actual %d round trips", conn.roundTrips)
+	}
+}
+
+func buildManyKeysRDB(b *testing.B, keyCount int) []byte {
+	buf := bytes.NewBuffer(nil)
+	enc := encoder.NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		b.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, uint64(keyCount), 0); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < keyCount; i++ {
+		if err := enc.WriteStringObject("key:"+strconv.Itoa(i), []byte("value")); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func benchmarkRestoreTo(b *testing.B, options ...interface{}) {
+	data := buildManyKeysRDB(b, 200)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn := &fakePipelineConn{latency: 10 * time.Microsecond}
+		if err := RestoreTo(bytes.NewReader(data), conn, options...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRestoreTo(b *testing.B) {
+	benchmarkRestoreTo(b)
+}
+
+func BenchmarkRestoreToPipeline(b *testing.B) {
+	benchmarkRestoreTo(b, WithPipeline(100))
+}
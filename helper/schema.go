@@ -0,0 +1,136 @@
+package helper
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// MaxSchemaFieldsOption limits the number of distinct field names of each pattern in HashSchemas
+type MaxSchemaFieldsOption int
+
+// WithMaxSchemaFields limits the number of distinct field names of each pattern,
+// fields beyond the limit are counted in OtherPattern
+func WithMaxSchemaFields(n int) MaxSchemaFieldsOption {
+	return MaxSchemaFieldsOption(n)
+}
+
+const defaultMaxSchemaFields = 1000
+
+type hashSchema struct {
+	db       int
+	pattern  string
+	keyCount int
+	fields   map[string]int // field -> number of keys containing it
+}
+
+// HashSchemas reads rdb from reader, groups hashes by inferred key pattern (see InferredKeyPatterns)
+// and writes the union of field names of each pattern to out as csv, values are not exported.
+// Each row has the number of keys containing the field, which is less than key_count if schema drifts
+func HashSchemas(reader io.Reader, out io.Writer, options ...interface{}) error {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	if out == nil {
+		return errors.New("output writer is required")
+	}
+	inferrer, err := newPatternInferrer(options...)
+	if err != nil {
+		return err
+	}
+	maxFields := defaultMaxSchemaFields
+	for _, opt := range options {
+		switch o := opt.(type) {
+		case MaxSchemaFieldsOption:
+			maxFields = int(o)
+		}
+	}
+	var dec decoder = core.NewDecoder(reader)
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	schemas := make(map[string]*hashSchema)
+	err = dec.Parse(func(object model.RedisObject) bool {
+		hash, ok := object.(*model.HashObject)
+		if !ok {
+			return true
+		}
+		pattern := inferrer.bucket(hash.GetDBIndex(), hash.GetKey())
+		key := genKey(hash.GetDBIndex(), pattern)
+		schema := schemas[key]
+		if schema == nil {
+			schema = &hashSchema{
+				db:      hash.GetDBIndex(),
+				pattern: pattern,
+				fields:  make(map[string]int),
+			}
+			schemas[key] = schema
+		}
+		schema.keyCount++
+		countedOther := false
+		for field := range hash.Hash {
+			if _, ok := schema.fields[field]; !ok && len(schema.fields) >= maxFields {
+				if !countedOther { // count each key once
+					schema.fields[OtherPattern]++
+					countedOther = true
+				}
+				continue
+			}
+			schema.fields[field]++
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	list := make([]*hashSchema, 0, len(schemas))
+	for _, schema := range schemas {
+		list = append(list, schema)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].db != list[j].db {
+			return list[i].db < list[j].db
+		}
+		return list[i].pattern < list[j].pattern
+	})
+
+	_, err = io.WriteString(out, "database,pattern,key_count,field,field_key_count\n")
+	if err != nil {
+		return fmt.Errorf("write header failed: %v", err)
+	}
+	csvWriter := csv.NewWriter(out)
+	for _, schema := range list {
+		fields := make([]string, 0, len(schema.fields))
+		for field := range schema.fields {
+			fields = append(fields, field)
+		}
+		sort.Slice(fields, func(i, j int) bool {
+			ci, cj := schema.fields[fields[i]], schema.fields[fields[j]]
+			if ci != cj {
+				return ci > cj
+			}
+			return fields[i] < fields[j]
+		})
+		for _, field := range fields {
+			err = csvWriter.Write([]string{
+				strconv.Itoa(schema.db),
+				schema.pattern,
+				strconv.Itoa(schema.keyCount),
+				field,
+				strconv.Itoa(schema.fields[field]),
+			})
+			if err != nil {
+				return fmt.Errorf("csv write failed: %v", err)
+			}
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,72 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+)
+
+func buildSchemaRDB(t *testing.T) []byte {
+	return buildRDB(t, func(enc *encoder.Encoder, check func(error)) {
+		check(enc.WriteDBHeader(0, 5, 0))
+		check(enc.WriteHashMapObject("user:1", map[string][]byte{"name": []byte("a"), "age": []byte("1")}))
+		check(enc.WriteHashMapObject("user:2", map[string][]byte{"name": []byte("b"), "age": []byte("2")}))
+		check(enc.WriteHashMapObject("user:3", map[string][]byte{
+			"name": []byte("c"), "age": []byte("3"), "legacy_flag": []byte("1"),
+		}))
+		check(enc.WriteHashMapObject("config", map[string][]byte{"mode": []byte("x")}))
+		check(enc.WriteStringObject("user:4", []byte("not a hash")))
+	})
+}
+
+func TestHashSchemas(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	err := HashSchemas(bytes.NewReader(buildSchemaRDB(t)), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := [][]string{
+		{"database", "pattern", "key_count", "field", "field_key_count"},
+		{"0", "config", "1", "mode", "1"},
+		{"0", "user:<int>", "3", "age", "3"},
+		{"0", "user:<int>", "3", "name", "3"},
+		{"0", "user:<int>", "3", "legacy_flag", "1"},
+	}
+	if len(records) != len(expect) {
+		t.Fatalf("expect %v, actual %v", expect, records)
+	}
+	for i := range expect {
+		for j := range expect[i] {
+			if records[i][j] != expect[i][j] {
+				t.Errorf("line %d: expect %v, actual %v", i, expect[i], records[i])
+				break
+			}
+		}
+	}
+}
+
+func TestHashSchemasMaxFields(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	err := HashSchemas(bytes.NewReader(buildSchemaRDB(t)), out, WithMaxSchemaFields(2), WithRegexOption("^user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header, 2 fields and other
+	if len(records) != 4 {
+		t.Fatalf("expect 4 lines, actual %v", records)
+	}
+	last := records[3]
+	if last[1] != "user:<int>" || last[3] != OtherPattern || last[4] != "1" {
+		t.Errorf("wrong other fields: %v", last)
+	}
+}
@@ -0,0 +1,186 @@
+package helper
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/crc16"
+	"github.com/hdt3213/rdb/model"
+)
+
+// SlotCount is number of hash slots in redis cluster
+const SlotCount = 16384
+
+// KeySlot returns hash slot of key in redis cluster, same as CLUSTER KEYSLOT
+func KeySlot(key string) int {
+	return int(crc16.Checksum([]byte(extractHashtag(key)))) % SlotCount
+}
+
+// evenSlotRanges divides all slots into nodeCount ranges, just like clusterManagerCommandCreate of redis-cli
+// which rounds the end of each range by lround
+func evenSlotRanges(nodeCount int) [][2]int {
+	ranges := make([][2]int, nodeCount)
+	per := float64(SlotCount) / float64(nodeCount)
+	first := 0
+	cursor := 0.0
+	for i := 0; i < nodeCount; i++ {
+		last := int(math.Round(cursor + per - 1))
+		if last > SlotCount-1 || i == nodeCount-1 {
+			last = SlotCount - 1
+		}
+		if last < first {
+			last = first
+		}
+		ranges[i] = [2]int{first, last}
+		first = last + 1
+		cursor += per
+	}
+	return ranges
+}
+
+type slotShard struct {
+	file      *os.File
+	writer    *bufio.Writer
+	enc       *core.Encoder
+	currentDB int
+}
+
+// writeObject writes object into rdb, db header must have been written
+func writeObject(enc *core.Encoder, obj model.RedisObject) error {
+	var options []interface{}
+	if expiration := obj.GetExpiration(); expiration != nil {
+		expireMs := expiration.UnixNano() / 1e6
+		if expireMs < 0 {
+			expireMs = 0
+		}
+		options = append(options, core.WithTTL(uint64(expireMs)))
+	}
+	key := obj.GetKey()
+	switch o := obj.(type) {
+	case *model.StringObject:
+		return enc.WriteStringObject(key, o.Value, options...)
+	case *model.ListObject:
+		return enc.WriteListObject(key, o.Values, options...)
+	case *model.SetObject:
+		return enc.WriteSetObject(key, o.Members, options...)
+	case *model.HashObject:
+		if len(o.FieldExpirations) > 0 {
+			return enc.WriteHashMapObjectEx(key, o.Hash, o.FieldExpirations, options...)
+		}
+		return enc.WriteHashMapObject(key, o.Hash, options...)
+	case *model.ZSetObject:
+		return enc.WriteZSetObject(key, o.Entries, options...)
+	case *model.StreamObject:
+		return enc.WriteStreamObject(key, o, options...)
+	}
+	return fmt.Errorf("cannot write %s object: %s", obj.GetType(), key)
+}
+
+// SplitBySlot splits rdb into nodeCount standalone rdb files named node-<i>.rdb in outDir,
+// each one contains keys whose hash slots fall in slotRanges[i]. Slot ranges are inclusive.
+// If slotRanges is nil, slots are evenly divided among nodes like redis-cli --cluster create.
+// encoder.WithTargetVersion sets version of output
+func SplitBySlot(reader io.Reader, nodeCount int, slotRanges [][2]int, outDir string, options ...interface{}) (err error) {
+	if reader == nil {
+		return errors.New("src reader is required")
+	}
+	if nodeCount <= 0 {
+		return errors.New("node count must be positive")
+	}
+	if slotRanges == nil {
+		slotRanges = evenSlotRanges(nodeCount)
+	} else if len(slotRanges) != nodeCount {
+		return fmt.Errorf("expect %d slot ranges, actual %d", nodeCount, len(slotRanges))
+	}
+	slotNodes := make([]int, SlotCount)
+	for i := range slotNodes {
+		slotNodes[i] = -1
+	}
+	for node, r := range slotRanges {
+		if r[0] < 0 || r[1] >= SlotCount || r[0] > r[1] {
+			return fmt.Errorf("invalid slot range: %d-%d", r[0], r[1])
+		}
+		for slot := r[0]; slot <= r[1]; slot++ {
+			if slotNodes[slot] >= 0 {
+				return fmt.Errorf("slot %d is assigned to node %d and %d", slot, slotNodes[slot], node)
+			}
+			slotNodes[slot] = node
+		}
+	}
+
+	if err = os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return fmt.Errorf("create output dir failed: %v", err)
+	}
+	shards := make([]*slotShard, nodeCount)
+	defer func() {
+		for _, shard := range shards {
+			if shard != nil {
+				_ = shard.file.Close()
+			}
+		}
+	}()
+	for i := range shards {
+		filename := filepath.Join(outDir, fmt.Sprintf("node-%d.rdb", i))
+		file, err := os.Create(filename)
+		if err != nil {
+			return fmt.Errorf("create rdb %s failed, %v", filename, err)
+		}
+		writer := bufio.NewWriter(file)
+		shard := &slotShard{
+			file:      file,
+			writer:    writer,
+			enc:       core.NewEncoder(writer, options...),
+			currentDB: -1,
+		}
+		shards[i] = shard
+		if err := shard.enc.WriteHeader(); err != nil {
+			return err
+		}
+	}
+
+	var dec decoder = core.NewDecoder(reader)
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	var writeErr error
+	err = dec.Parse(func(object model.RedisObject) bool {
+		slot := KeySlot(object.GetKey())
+		node := slotNodes[slot]
+		if node < 0 {
+			writeErr = fmt.Errorf("slot %d of key %s is not assigned to any node", slot, object.GetKey())
+			return false
+		}
+		shard := shards[node]
+		if shard.currentDB != object.GetDBIndex() {
+			shard.currentDB = object.GetDBIndex()
+			// key count is unknown before parsing finished, resize db is just a hint
+			writeErr = shard.enc.WriteDBHeader(uint(shard.currentDB), 0, 0)
+			if writeErr != nil {
+				return false
+			}
+		}
+		writeErr = writeObject(shard.enc, object)
+		return writeErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	for _, shard := range shards {
+		if err := shard.enc.WriteEnd(); err != nil {
+			return err
+		}
+		if err := shard.writer.Flush(); err != nil {
+			return fmt.Errorf("write rdb failed: %v", err)
+		}
+	}
+	return nil
+}
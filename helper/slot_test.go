@@ -0,0 +1,124 @@
+package helper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+)
+
+func TestKeySlot(t *testing.T) {
+	if slot := KeySlot("foo"); slot != 12182 {
+		t.Errorf("expect slot 12182, actual %d", slot)
+	}
+	if KeySlot("{user1000}.following") != KeySlot("user1000") {
+		t.Error("keys with same hash tag should be in same slot")
+	}
+}
+
+func TestEvenSlotRanges(t *testing.T) {
+	// slots assigned by redis-cli --cluster create
+	cases := map[int]string{
+		1: "[[0 16383]]",
+		3: "[[0 5460] [5461 10922] [10923 16383]]",
+		6: "[[0 2730] [2731 5460] [5461 8191] [8192 10922] [10923 13652] [13653 16383]]",
+	}
+	for nodeCount, expect := range cases {
+		if actual := fmt.Sprint(evenSlotRanges(nodeCount)); actual != expect {
+			t.Errorf("%d nodes: expect %s, actual %s", nodeCount, expect, actual)
+		}
+	}
+}
+
+func TestSplitBySlot(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	srcFiles := []string{"memory.rdb", "multiple_databases.rdb", "hash_with_hfe.rdb", "stream_listpacks_1.rdb"}
+	for _, srcFile := range srcFiles {
+		srcRdb := filepath.Join("../cases", srcFile)
+		rdbFile, err := os.Open(srcRdb)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect, err := ReadAll(rdbFile)
+		_ = rdbFile.Close()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		nodeCount := 3
+		outDir := filepath.Join("tmp", srcFile)
+		rdbFile, _ = os.Open(srcRdb)
+		// hash_with_hfe.rdb has field expirations of rdb version 12
+		err = SplitBySlot(rdbFile, nodeCount, nil, outDir, encoder.WithTargetVersion(12))
+		_ = rdbFile.Close()
+		if err != nil {
+			t.Errorf("split %s failed: %v", srcFile, err)
+			continue
+		}
+		ranges := evenSlotRanges(nodeCount)
+		actual := make(map[string]string)
+		for i := 0; i < nodeCount; i++ {
+			shardFile, err := os.Open(filepath.Join(outDir, "node-"+strconv.Itoa(i)+".rdb"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			objects, err := ReadAll(shardFile)
+			_ = shardFile.Close()
+			if err != nil {
+				t.Errorf("read shard %d of %s failed: %v", i, srcFile, err)
+				continue
+			}
+			for _, obj := range objects {
+				slot := KeySlot(obj.GetKey())
+				if slot < ranges[i][0] || slot > ranges[i][1] {
+					t.Errorf("key %s of slot %d should not be in node %d", obj.GetKey(), slot, i)
+				}
+				actual[strconv.Itoa(obj.GetDBIndex())+" "+obj.GetKey()] = obj.GetType()
+			}
+		}
+		if len(actual) != len(expect) {
+			t.Errorf("%s: expect %d keys, actual %d", srcFile, len(expect), len(actual))
+		}
+		for _, obj := range expect {
+			if actual[strconv.Itoa(obj.GetDBIndex())+" "+obj.GetKey()] != obj.GetType() {
+				t.Errorf("%s: missing key %s", srcFile, obj.GetKey())
+			}
+		}
+	}
+}
+
+func TestSplitBySlotRanges(t *testing.T) {
+	defer func() {
+		_ = os.RemoveAll("tmp")
+	}()
+	srcRdb := filepath.Join("../cases", "memory.rdb")
+	rdbFile, err := os.Open(srcRdb)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() { _ = rdbFile.Close() }()
+	err = SplitBySlot(rdbFile, 2, [][2]int{{0, 100}, {50, 16383}}, "tmp")
+	if err == nil {
+		t.Error("expect error for overlapped ranges")
+	}
+	err = SplitBySlot(rdbFile, 2, [][2]int{{0, 100}}, "tmp")
+	if err == nil {
+		t.Error("expect error for wrong range count")
+	}
+	err = SplitBySlot(rdbFile, 1, [][2]int{{0, 100}}, "tmp")
+	if err == nil {
+		t.Error("expect error for unassigned slots")
+	}
+}
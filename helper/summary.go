@@ -0,0 +1,84 @@
+package helper
+
+import (
+	"errors"
+	"io"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// Summary is overall statistics of keyspace
+type Summary struct {
+	KeyCount int
+	// OnDiskBytes is total bytes of objects in rdb file, including type flags and keys
+	OnDiskBytes int64
+	// LogicalBytes is total bytes of decoded keys and values without compression or encoding
+	LogicalBytes int64
+}
+
+// CompressionRatio returns LogicalBytes / OnDiskBytes
+func (s *Summary) CompressionRatio() float64 {
+	if s.OnDiskBytes == 0 {
+		return 0
+	}
+	return float64(s.LogicalBytes) / float64(s.OnDiskBytes)
+}
+
+// logicalSize returns bytes of decoded key and values, scores and stream ids are counted as binary numbers
+func logicalSize(obj model.RedisObject) int64 {
+	size := int64(len(obj.GetKey()))
+	switch o := obj.(type) {
+	case *model.StringObject:
+		size += int64(len(o.Value))
+	case *model.ListObject:
+		for _, v := range o.Values {
+			size += int64(len(v))
+		}
+	case *model.SetObject:
+		for _, v := range o.Members {
+			size += int64(len(v))
+		}
+	case *model.HashObject:
+		for field, v := range o.Hash {
+			size += int64(len(field) + len(v))
+		}
+	case *model.ZSetObject:
+		for _, e := range o.Entries {
+			size += int64(len(e.Member)) + 8
+		}
+	case *model.StreamObject:
+		for _, entry := range o.Entries {
+			for _, msg := range entry.Msgs {
+				size += 16
+				for field, v := range msg.Fields {
+					size += int64(len(field) + len(v))
+				}
+			}
+		}
+	}
+	return size
+}
+
+// Summarize reads rdb from reader and returns overall statistics of keyspace
+func Summarize(reader io.Reader, options ...interface{}) (*Summary, error) {
+	if reader == nil {
+		return nil, errors.New("src reader is required")
+	}
+	var dec decoder = core.NewDecoder(reader)
+	var err error
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return nil, err
+	}
+	summary := &Summary{}
+	err = dec.Parse(func(object model.RedisObject) bool {
+		summary.KeyCount++
+		summary.OnDiskBytes += int64(object.GetDiskSize())
+		summary.LogicalBytes += logicalSize(object)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
@@ -0,0 +1,54 @@
+package helper
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hdt3213/rdb/encoder"
+)
+
+func TestSummarize(t *testing.T) {
+	rdbFile, err := os.Open("../cases/easily_compressible_string_key.rdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	summary, err := Summarize(rdbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.KeyCount != 1 {
+		t.Errorf("expect 1 key, actual %d", summary.KeyCount)
+	}
+	if summary.OnDiskBytes <= 0 || summary.CompressionRatio() <= 1 {
+		t.Errorf("compressible key should take less bytes on disk: %+v", summary)
+	}
+
+	// plain string: type flag + key length + key + value length + value
+	buf := bytes.NewBuffer(nil)
+	enc := encoder.NewEncoder(buf)
+	value := strings.Repeat("a", 10)
+	if err = enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err = enc.WriteDBHeader(0, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err = enc.WriteStringObject("k", []byte(value)); err != nil {
+		t.Fatal(err)
+	}
+	if err = enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	summary, err = Summarize(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.OnDiskBytes != 1+1+1+1+10 || summary.LogicalBytes != 11 {
+		t.Errorf("wrong summary: %+v", summary)
+	}
+}
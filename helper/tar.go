@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+)
+
+// tarMemberReader reads a member of tar archive and closes underlying readers
+type tarMemberReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *tarMemberReader) Close() error {
+	var err error
+	for _, c := range r.closers {
+		if cErr := c.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// OpenFromTar opens tar archive and returns a reader positioned at memberName, e.g. dump.rdb in a backup bundle.
+// Gzip compressed archive is detected by magic number. memberName matches member of the same path or base name.
+// Caller should close the returned reader
+func OpenFromTar(tarPath, memberName string) (io.ReadCloser, error) {
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return nil, fmt.Errorf("open tar %s failed, %v", tarPath, err)
+	}
+	r := &tarMemberReader{
+		closers: []io.Closer{file},
+	}
+	br := bufio.NewReader(file)
+	var src io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzReader, err := gzip.NewReader(br)
+		if err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("open gzip %s failed, %v", tarPath, err)
+		}
+		r.closers = append([]io.Closer{gzReader}, r.closers...)
+		src = gzReader
+	}
+	tarReader := tar.NewReader(src)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			_ = r.Close()
+			return nil, fmt.Errorf("member %s not found in %s", memberName, tarPath)
+		}
+		if err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("read tar %s failed, %v", tarPath, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		if name == path.Clean(memberName) || path.Base(name) == memberName {
+			r.Reader = tarReader
+			return r, nil
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package helper
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+func writeTestTar(t *testing.T, filename string, compressed bool, members map[string][]byte) {
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	var w io.Writer = file
+	var gzWriter *gzip.Writer
+	if compressed {
+		gzWriter = gzip.NewWriter(file)
+		w = gzWriter
+	}
+	tarWriter := tar.NewWriter(w)
+	for _, name := range []string{"meta.json", "backup/dump.rdb"} {
+		data := members[name]
+		err = tarWriter.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = tarWriter.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gzWriter != nil {
+		if err = gzWriter.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOpenFromTar(t *testing.T) {
+	err := os.MkdirAll("tmp", os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	rdbData, err := os.ReadFile("../cases/memory.rdb")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected, err := ReadAll(mustOpen(t, "../cases/memory.rdb"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	members := map[string][]byte{
+		"meta.json":       []byte(`{"host":"127.0.0.1"}`),
+		"backup/dump.rdb": rdbData,
+	}
+	for _, compressed := range []bool{false, true} {
+		tarPath := filepath.Join("tmp", "backup.tar")
+		if compressed {
+			tarPath = filepath.Join("tmp", "backup.tar.gz")
+		}
+		writeTestTar(t, tarPath, compressed, members)
+		for _, member := range []string{"dump.rdb", "backup/dump.rdb"} {
+			reader, err := OpenFromTar(tarPath, member)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var keys []string
+			err = ParseType(reader, func(object model.RedisObject) bool {
+				keys = append(keys, object.GetKey())
+				return true
+			})
+			_ = reader.Close()
+			if err != nil {
+				t.Errorf("parse %s in %s failed: %v", member, tarPath, err)
+				continue
+			}
+			if len(keys) != len(expected) {
+				t.Errorf("%s: expect %d keys, actual %d", tarPath, len(expected), len(keys))
+			}
+		}
+		if _, err = OpenFromTar(tarPath, "missing.rdb"); err == nil {
+			t.Error("expect error for missing member")
+		}
+	}
+}
+
+func mustOpen(t *testing.T, filename string) *os.File {
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = file.Close()
+	})
+	return file
+}
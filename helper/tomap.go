@@ -0,0 +1,69 @@
+package helper
+
+import (
+	"github.com/hdt3213/rdb/model"
+)
+
+func bytesToStrings(values [][]byte) []string {
+	result := make([]string, len(values))
+	for i, v := range values {
+		result[i] = string(v)
+	}
+	return result
+}
+
+// ToMap converts object to a map in uniform shape: type, key, db, expireAt and value, so consumers like template
+// engines do not depend on concrete model types. expireAt is nil for persistent keys. value is string for string,
+// []string for list and set, map[string]string for hash, []model.ZSetEntry for sorted set,
+// []*model.StreamEntry for stream, and the decoded value for module types and metadata.
+// WithBinaryEncoding is the only option, values are left as is if its encoding is unsupported
+func ToMap(obj model.RedisObject, options ...interface{}) map[string]interface{} {
+	if obj == nil {
+		return nil
+	}
+	if encode, err := binaryEncoder(options); err == nil && encode != nil {
+		obj = encodeBinary(obj, encode)
+	}
+	var value interface{}
+	switch o := obj.(type) {
+	case *model.StringObject:
+		value = string(o.Value)
+	case *model.ListObject:
+		value = bytesToStrings(o.Values)
+	case *model.SetObject:
+		value = bytesToStrings(o.Members)
+	case *model.HashObject:
+		hash := make(map[string]string, len(o.Hash))
+		for field, v := range o.Hash {
+			hash[field] = string(v)
+		}
+		value = hash
+	case *model.ZSetObject:
+		entries := make([]model.ZSetEntry, len(o.Entries))
+		for i, entry := range o.Entries {
+			entries[i] = *entry
+		}
+		value = entries
+	case *model.StreamObject:
+		value = o.Entries
+	case *model.ModuleTypeObject:
+		value = o.Value
+	case *model.AuxObject:
+		value = o.Value
+	case *model.ScriptObject:
+		value = o.Body
+	case *model.FunctionLibrary:
+		value = o.Code
+	}
+	var expireAt interface{}
+	if expiration := obj.GetExpiration(); expiration != nil {
+		expireAt = *expiration
+	}
+	return map[string]interface{}{
+		"type":     obj.GetType(),
+		"key":      obj.GetKey(),
+		"db":       obj.GetDBIndex(),
+		"expireAt": expireAt,
+		"value":    value,
+	}
+}
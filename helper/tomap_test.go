@@ -0,0 +1,67 @@
+package helper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestToMap(t *testing.T) {
+	expiration := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	base := func(key string) *model.BaseObject {
+		return &model.BaseObject{DB: 1, Key: key}
+	}
+	entries := []*model.StreamEntry{{FirstMsgId: &model.StreamId{Ms: 1, Sequence: 0}}}
+	cases := []struct {
+		obj   model.RedisObject
+		value interface{}
+	}{
+		{
+			obj:   &model.StringObject{BaseObject: &model.BaseObject{DB: 1, Key: "str", Expiration: &expiration}, Value: []byte("v")},
+			value: "v",
+		},
+		{
+			obj:   &model.ListObject{BaseObject: base("list"), Values: [][]byte{[]byte("a"), []byte("b")}},
+			value: []string{"a", "b"},
+		},
+		{
+			obj:   &model.SetObject{BaseObject: base("set"), Members: [][]byte{[]byte("m")}},
+			value: []string{"m"},
+		},
+		{
+			obj:   &model.HashObject{BaseObject: base("hash"), Hash: map[string][]byte{"f": []byte("v")}},
+			value: map[string]string{"f": "v"},
+		},
+		{
+			obj:   &model.ZSetObject{BaseObject: base("zset"), Entries: []*model.ZSetEntry{{Member: "m", Score: 1.5}}},
+			value: []model.ZSetEntry{{Member: "m", Score: 1.5}},
+		},
+		{
+			obj:   &model.StreamObject{BaseObject: base("stream"), Entries: entries},
+			value: entries,
+		},
+	}
+	for _, c := range cases {
+		m := ToMap(c.obj)
+		if m["type"] != c.obj.GetType() || m["key"] != c.obj.GetKey() || m["db"] != 1 {
+			t.Errorf("%s: wrong meta %v", c.obj.GetKey(), m)
+		}
+		if !reflect.DeepEqual(m["value"], c.value) {
+			t.Errorf("%s: expect value %v, actual %v", c.obj.GetKey(), c.value, m["value"])
+		}
+		if len(m) != 5 {
+			t.Errorf("%s: wrong fields %v", c.obj.GetKey(), m)
+		}
+	}
+	if m := ToMap(cases[0].obj); m["expireAt"] != expiration {
+		t.Errorf("wrong expireAt: %v", m["expireAt"])
+	}
+	if m := ToMap(cases[1].obj); m["expireAt"] != nil {
+		t.Errorf("expireAt of persistent key should be nil: %v", m["expireAt"])
+	}
+	if ToMap(nil) != nil {
+		t.Error("expect nil for nil object")
+	}
+}
@@ -0,0 +1,44 @@
+package helper
+
+import (
+	"errors"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+// ValueTransformOption transforms objects before they are passed to callback or exported
+type ValueTransformOption func(obj model.RedisObject) model.RedisObject
+
+// WithValueTransform applies transform to every object after all filters, right before callback or export.
+// It may modify the object in place or return another one, e.g. redact sensitive values.
+// Returning nil drops the object. It cannot be used with RestoreTo or WithRestoreMode,
+// since DUMP payload of RESTORE command is the original value in rdb
+func WithValueTransform(transform func(obj model.RedisObject) model.RedisObject) ValueTransformOption {
+	return transform
+}
+
+type transformDecoder struct {
+	dec       decoder
+	transform ValueTransformOption
+}
+
+func (d *transformDecoder) Parse(cb func(object model.RedisObject) bool) error {
+	return d.dec.Parse(func(object model.RedisObject) bool {
+		object = d.transform(object)
+		if object == nil {
+			return true
+		}
+		return cb(object)
+	})
+}
+
+// checkRestoreTransform returns error if options contain a value transform,
+// which would be lost in RESTORE commands sending the original DUMP payload
+func checkRestoreTransform(options []interface{}) error {
+	for _, opt := range options {
+		if _, ok := opt.(ValueTransformOption); ok {
+			return errors.New("value transform cannot be applied to DUMP payload of RESTORE command")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+func TestValueTransform(t *testing.T) {
+	upper := WithValueTransform(func(obj model.RedisObject) model.RedisObject {
+		if str, ok := obj.(*model.StringObject); ok {
+			str.Value = bytes.ToUpper(str.Value)
+		}
+		if obj.GetKey() == "list" {
+			return nil
+		}
+		return obj
+	})
+	data := buildAllTypesRDB(t)
+	objects, err := ReadAll(bytes.NewReader(data), upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 5 {
+		t.Errorf("expect 5 objects, actual %d", len(objects))
+	}
+	for _, obj := range objects {
+		if obj.GetKey() == "list" {
+			t.Error("list should be dropped")
+		}
+		if str, ok := obj.(*model.StringObject); ok && string(str.Value) != "VALUE" {
+			t.Errorf("expect VALUE, actual %s", str.Value)
+		}
+	}
+
+	err = os.MkdirAll("tmp", os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	srcRdb := filepath.Join("tmp", "transform.rdb")
+	if err := os.WriteFile(srcRdb, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	actualJSON := filepath.Join("tmp", "transform.json")
+	if err := ToJsons(srcRdb, actualJSON, upper); err != nil {
+		t.Fatal(err)
+	}
+	jsonData, err := os.ReadFile(actualJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jsons []map[string]interface{}
+	if err := json.Unmarshal(jsonData, &jsons); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, obj := range jsons {
+		if obj["key"] == "string" {
+			found = true
+			if obj["value"] != "VALUE" {
+				t.Errorf("expect VALUE in json, actual %v", obj["value"])
+			}
+		}
+	}
+	if !found {
+		t.Error("string key not found in json")
+	}
+}
+
+func TestValueTransformRestore(t *testing.T) {
+	identity := WithValueTransform(func(obj model.RedisObject) model.RedisObject {
+		return obj
+	})
+	data := buildRestoreRDB(t, 0)
+	conn := &fakeRedisConn{}
+	err := RestoreTo(bytes.NewReader(data), conn, identity)
+	if err == nil || !strings.Contains(err.Error(), "DUMP payload") {
+		t.Errorf("expect error of transform with RESTORE, actual %v", err)
+	}
+	if len(conn.cmdLines) != 0 {
+		t.Errorf("expect no command sent, actual %d", len(conn.cmdLines))
+	}
+
+	err = os.MkdirAll("tmp", os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	srcRdb := filepath.Join("tmp", "transform_restore.rdb")
+	if err := os.WriteFile(srcRdb, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	actualAOF := filepath.Join("tmp", "transform_restore.aof")
+	err = ToAOF(srcRdb, actualAOF, WithRestoreMode(), identity)
+	if err == nil || !strings.Contains(err.Error(), "DUMP payload") {
+		t.Errorf("expect error of transform in restore mode, actual %v", err)
+	}
+	if _, err := os.Stat(actualAOF); !os.IsNotExist(err) {
+		t.Errorf("aof should not be created, stat error: %v", err)
+	}
+	// transform works with commands of aof
+	if err := ToAOF(srcRdb, actualAOF, identity); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,122 @@
+package helper
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hdt3213/rdb/core"
+	"github.com/hdt3213/rdb/model"
+)
+
+// TTLToleranceOption sets max difference of expirations which is not reported by TTLDiff
+type TTLToleranceOption time.Duration
+
+// WithTTLTolerance makes TTLDiff ignore keys whose expirations differ by no more than d
+func WithTTLTolerance(d time.Duration) TTLToleranceOption {
+	return TTLToleranceOption(d)
+}
+
+const (
+	ttlChanged = "changed"
+	ttlGained  = "gained"
+	ttlLost    = "lost"
+)
+
+func parseRDBFile(filename string, cb func(object model.RedisObject) bool, options ...interface{}) error {
+	rdbFile, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("open rdb %s failed, %v", filename, err)
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	var dec decoder = core.NewDecoder(rdbFile)
+	if dec, err = wrapDecoder(dec, options...); err != nil {
+		return err
+	}
+	return dec.Parse(cb)
+}
+
+// TTLDiff compares expirations of keys present in both oldRDB and newRDB, then writes keys whose expirations
+// differ by more than tolerance, gained or lost expiration to out as csv. Keys present in only one file are ignored.
+// Only expirations of oldRDB are kept in memory
+func TTLDiff(oldRDB, newRDB string, out io.Writer, options ...interface{}) error {
+	if oldRDB == "" || newRDB == "" {
+		return errors.New("src file path is required")
+	}
+	if out == nil {
+		return errors.New("output writer is required")
+	}
+	var tolerance time.Duration
+	for _, opt := range options {
+		switch o := opt.(type) {
+		case TTLToleranceOption:
+			tolerance = time.Duration(o)
+		}
+	}
+	oldExpirations := make(map[string]*time.Time) // nil means the key has no expiration
+	err := parseRDBFile(oldRDB, func(object model.RedisObject) bool {
+		oldExpirations[genKey(object.GetDBIndex(), object.GetKey())] = object.GetExpiration()
+		return true
+	}, options...)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(out, "database,key,change,old_expiration,new_expiration,delta\n")
+	if err != nil {
+		return fmt.Errorf("write header failed: %v", err)
+	}
+	csvWriter := csv.NewWriter(out)
+	formatExpiration := func(expiration *time.Time) string {
+		if expiration == nil {
+			return ""
+		}
+		return expiration.Format(time.RFC3339)
+	}
+	var writeErr error
+	err = parseRDBFile(newRDB, func(object model.RedisObject) bool {
+		oldExpiration, ok := oldExpirations[genKey(object.GetDBIndex(), object.GetKey())]
+		if !ok {
+			return true
+		}
+		newExpiration := object.GetExpiration()
+		var change, delta string
+		if oldExpiration == nil && newExpiration == nil {
+			return true
+		} else if oldExpiration == nil {
+			change = ttlGained
+		} else if newExpiration == nil {
+			change = ttlLost
+		} else {
+			d := newExpiration.Sub(*oldExpiration)
+			if d <= tolerance && d >= -tolerance {
+				return true
+			}
+			change = ttlChanged
+			delta = d.String()
+		}
+		writeErr = csvWriter.Write([]string{
+			strconv.Itoa(object.GetDBIndex()),
+			object.GetKey(),
+			change,
+			formatExpiration(oldExpiration),
+			formatExpiration(newExpiration),
+			delta,
+		})
+		return writeErr == nil
+	}, options...)
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return fmt.Errorf("csv write failed: %v", writeErr)
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
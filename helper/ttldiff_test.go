@@ -0,0 +1,113 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hdt3213/rdb/encoder"
+)
+
+type ttlKey struct {
+	key      string
+	expireAt time.Time // zero means no expiration
+}
+
+func writeTTLRDB(t *testing.T, filename string, keys []ttlKey) {
+	rdbFile, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rdbFile.Close()
+	}()
+	enc := encoder.NewEncoder(rdbFile)
+	if err = enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err = enc.WriteDBHeader(0, uint64(len(keys)), 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		var options []interface{}
+		if !k.expireAt.IsZero() {
+			options = append(options, encoder.WithTTL(uint64(k.expireAt.UnixNano()/1e6)))
+		}
+		if err = enc.WriteStringObject(k.key, []byte("value"), options...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTTLDiff(t *testing.T) {
+	err := os.MkdirAll("tmp", os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	base := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	oldRDB := filepath.Join("tmp", "ttl_old.rdb")
+	newRDB := filepath.Join("tmp", "ttl_new.rdb")
+	writeTTLRDB(t, oldRDB, []ttlKey{
+		{key: "same", expireAt: base},
+		{key: "shifted", expireAt: base},
+		{key: "jitter", expireAt: base},
+		{key: "lost", expireAt: base},
+		{key: "gained"},
+		{key: "persistent"},
+		{key: "removed", expireAt: base},
+	})
+	writeTTLRDB(t, newRDB, []ttlKey{
+		{key: "same", expireAt: base},
+		{key: "shifted", expireAt: base.Add(time.Hour)},
+		{key: "jitter", expireAt: base.Add(time.Second)},
+		{key: "lost"},
+		{key: "gained", expireAt: base},
+		{key: "persistent"},
+		{key: "added", expireAt: base},
+	})
+
+	out := bytes.NewBuffer(nil)
+	err = TTLDiff(oldRDB, newRDB, out, WithTTLTolerance(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string][]string{
+		"shifted": {ttlChanged, "1h0m0s"},
+		"lost":    {ttlLost, ""},
+		"gained":  {ttlGained, ""},
+	}
+	if len(records) != len(expect)+1 {
+		t.Fatalf("expect %d lines, actual %v", len(expect)+1, records)
+	}
+	for _, record := range records[1:] {
+		e, ok := expect[record[1]]
+		if !ok {
+			t.Errorf("unexpected key %s", record[1])
+			continue
+		}
+		if record[2] != e[0] || record[5] != e[1] {
+			t.Errorf("key %s: expect %v, actual %v", record[1], e, record)
+		}
+	}
+	for _, record := range records[1:] {
+		if record[1] == "lost" && (record[3] == "" || record[4] != "") {
+			t.Errorf("lost key should only have old expiration: %v", record)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package helper
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/hdt3213/rdb/model"
+)
+
+// TypedJSONOption makes json converter output objects in the typed schema
+type TypedJSONOption bool
+
+// WithTypedJSON makes json converter output every object as {"type", "db", "key", "expireAt", "value"},
+// the shape of value depends only on type, so that it could be deserialized into tagged unions of statically typed
+// languages. expireAt is unix time in milliseconds or null, see README for the schema
+func WithTypedJSON() TypedJSONOption {
+	return TypedJSONOption(true)
+}
+
+type typedObject struct {
+	Type     string      `json:"type"`
+	DB       int         `json:"db"`
+	Key      string      `json:"key"`
+	ExpireAt *int64      `json:"expireAt"`
+	Value    interface{} `json:"value"`
+	// FieldExpireAt is expiration of hash fields in unix milliseconds, only hash with field expirations has it
+	FieldExpireAt map[string]int64 `json:"fieldExpireAt,omitempty"`
+	// KeyBytes and KeyRunes are length of key, only objects converted WithKeyLength have them
+	KeyBytes *int `json:"keyBytes,omitempty"`
+	KeyRunes *int `json:"keyRunes,omitempty"`
+}
+
+// typedScore is score of sorted set, it is a json number unless it is inf or nan, which is "inf", "-inf" or "nan"
+type typedScore float64
+
+func (s typedScore) MarshalJSON() ([]byte, error) {
+	if math.IsInf(float64(s), 0) || math.IsNaN(float64(s)) {
+		return json.Marshal(model.FormatScore(float64(s)))
+	}
+	return json.Marshal(float64(s))
+}
+
+type typedZSetEntry struct {
+	Member string      `json:"member"`
+	Score  interface{} `json:"score"` // json.Number of model.ZSetEntry.ScoreNumber, or typedScore if it is inf or nan
+}
+
+type typedStream struct {
+	Length       uint64              `json:"length"`
+	LastId       string              `json:"lastId"`
+	FirstId      *string             `json:"firstId"`
+	MaxDeletedId *string             `json:"maxDeletedId"`
+	EntriesAdded *uint64             `json:"entriesAdded"`
+	Entries      []*typedStreamEntry `json:"entries"`
+	Groups       []*typedStreamGroup `json:"groups"`
+}
+
+type typedStreamEntry struct {
+	Id     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+}
+
+type typedStreamGroup struct {
+	Name        string                `json:"name"`
+	LastId      string                `json:"lastId"`
+	EntriesRead *uint64               `json:"entriesRead"`
+	Lag         *int64                `json:"lag"`
+	Pending     []*typedStreamPending `json:"pending"`
+	Consumers   []*typedConsumer      `json:"consumers"`
+}
+
+type typedStreamPending struct {
+	Id            string `json:"id"`
+	Consumer      string `json:"consumer"`
+	DeliveryTime  uint64 `json:"deliveryTime"`
+	DeliveryCount uint64 `json:"deliveryCount"`
+}
+
+type typedConsumer struct {
+	Name       string `json:"name"`
+	SeenTime   uint64 `json:"seenTime"`
+	ActiveTime uint64 `json:"activeTime"`
+}
+
+// typedModuleType is type of module objects in typed json
+const typedModuleType = "module"
+
+type typedModule struct {
+	ModuleType string      `json:"moduleType"`
+	Data       interface{} `json:"data"`
+}
+
+func typedStreamIDPtr(id *model.StreamId) *string {
+	if id == nil {
+		return nil
+	}
+	s := id.String()
+	return &s
+}
+
+func toTypedStream(stream *model.StreamObject) *typedStream {
+	result := &typedStream{
+		Length:  stream.Length,
+		Entries: make([]*typedStreamEntry, 0),
+		Groups:  make([]*typedStreamGroup, 0, len(stream.Groups)),
+	}
+	if stream.LastId != nil {
+		result.LastId = stream.LastId.String()
+	} else {
+		result.LastId = "0-0"
+	}
+	if stream.Version >= 2 {
+		result.FirstId = typedStreamIDPtr(stream.FirstId)
+		result.MaxDeletedId = typedStreamIDPtr(stream.MaxDeletedId)
+		entriesAdded := stream.AddedEntriesCount
+		result.EntriesAdded = &entriesAdded
+	}
+	for _, entry := range stream.Entries {
+		for _, msg := range entry.Msgs {
+			if msg.Deleted {
+				continue
+			}
+			result.Entries = append(result.Entries, &typedStreamEntry{
+				Id:     msg.Id.String(),
+				Fields: msg.Fields,
+			})
+		}
+	}
+	for _, group := range stream.Groups {
+		g := &typedStreamGroup{
+			Name:      group.Name,
+			LastId:    group.LastId.String(),
+			Pending:   make([]*typedStreamPending, 0, len(group.Pending)),
+			Consumers: make([]*typedConsumer, 0, len(group.Consumers)),
+		}
+		if stream.Version >= 2 {
+			entriesRead := group.EntriesRead
+			g.EntriesRead = &entriesRead
+		}
+		if group.Lag != model.StreamLagUnknown {
+			lag := group.Lag
+			g.Lag = &lag
+		}
+		for _, nack := range group.Pending {
+			g.Pending = append(g.Pending, &typedStreamPending{
+				Id:            nack.Id.String(),
+				Consumer:      nack.Consumer,
+				DeliveryTime:  nack.DeliveryTime,
+				DeliveryCount: nack.DeliveryCount,
+			})
+		}
+		for _, consumer := range group.Consumers {
+			g.Consumers = append(g.Consumers, &typedConsumer{
+				Name:       consumer.Name,
+				SeenTime:   consumer.SeenTime,
+				ActiveTime: consumer.ActiveTime,
+			})
+		}
+		result.Groups = append(result.Groups, g)
+	}
+	return result
+}
+
+// toTypedObject converts object to the typed schema
+func toTypedObject(object model.RedisObject) *typedObject {
+	result := &typedObject{
+		Type: object.GetType(),
+		DB:   object.GetDBIndex(),
+		Key:  object.GetKey(),
+	}
+	if expiration := object.GetExpiration(); expiration != nil {
+		expireAt := expiration.UnixNano() / 1e6
+		result.ExpireAt = &expireAt
+	}
+	if base := baseObject(object); base != nil {
+		result.KeyBytes, result.KeyRunes = base.KeyBytes, base.KeyRunes
+	}
+	switch o := object.(type) {
+	case *model.StringObject:
+		result.Value = string(o.Value)
+	case *model.ListObject:
+		result.Value = bytesToStrings(o.Values)
+	case *model.SetObject:
+		result.Value = bytesToStrings(o.Members)
+	case *model.HashObject:
+		hash := make(map[string]string, len(o.Hash))
+		for field, value := range o.Hash {
+			hash[field] = string(value)
+		}
+		result.Value = hash
+		for field, expire := range o.FieldExpirations {
+			if expire == 0 {
+				continue
+			}
+			if result.FieldExpireAt == nil {
+				result.FieldExpireAt = make(map[string]int64)
+			}
+			result.FieldExpireAt[field] = expire
+		}
+	case *model.ZSetObject:
+		entries := make([]typedZSetEntry, len(o.Entries))
+		for i, e := range o.Entries {
+			entries[i] = typedZSetEntry{Member: e.Member, Score: typedScore(e.Score)}
+			if number, ok := e.ScoreNumber(); ok {
+				entries[i].Score = number
+			}
+		}
+		result.Value = entries
+	case *model.StreamObject:
+		result.Value = toTypedStream(o)
+	case *model.ModuleTypeObject:
+		// type of module object is name of module type, which is moved into value to keep type a fixed set
+		result.Type = typedModuleType
+		result.Value = &typedModule{ModuleType: o.ModuleType, Data: o.Value}
+	default:
+		result.Value = object
+	}
+	return result
+}
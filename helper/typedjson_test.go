@@ -0,0 +1,140 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// typedJSONRecord is a statically typed consumer of typed json
+type typedJSONRecord struct {
+	Type          string           `json:"type"`
+	DB            int              `json:"db"`
+	Key           string           `json:"key"`
+	ExpireAt      *int64           `json:"expireAt"`
+	Value         json.RawMessage  `json:"value"`
+	FieldExpireAt map[string]int64 `json:"fieldExpireAt"`
+}
+
+type typedJSONZSetEntry struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+type typedJSONStream struct {
+	Length       uint64  `json:"length"`
+	LastId       string  `json:"lastId"`
+	FirstId      *string `json:"firstId"`
+	MaxDeletedId *string `json:"maxDeletedId"`
+	EntriesAdded *uint64 `json:"entriesAdded"`
+	Entries      []struct {
+		Id     string            `json:"id"`
+		Fields map[string]string `json:"fields"`
+	} `json:"entries"`
+	Groups []struct {
+		Name        string  `json:"name"`
+		LastId      string  `json:"lastId"`
+		EntriesRead *uint64 `json:"entriesRead"`
+		Lag         *int64  `json:"lag"`
+		Pending     []struct {
+			Id            string `json:"id"`
+			Consumer      string `json:"consumer"`
+			DeliveryTime  uint64 `json:"deliveryTime"`
+			DeliveryCount uint64 `json:"deliveryCount"`
+		} `json:"pending"`
+		Consumers []struct {
+			Name       string `json:"name"`
+			SeenTime   uint64 `json:"seenTime"`
+			ActiveTime uint64 `json:"activeTime"`
+		} `json:"consumers"`
+	} `json:"groups"`
+}
+
+func decodeStrict(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func TestTypedJSON(t *testing.T) {
+	err := os.MkdirAll("tmp", os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("tmp")
+		if err != nil {
+			t.Logf("remove tmp directory failed: %v", err)
+		}
+	}()
+	types := make(map[string]int)
+	fieldExpirations := 0
+	for _, name := range []string{"memory.rdb", "keys_with_expiry.rdb", "hash_with_hfe.rdb", "stream_listoacks_3.rdb"} {
+		srcRdb := filepath.Join("../cases", name)
+		actualFile := filepath.Join("tmp", name+".json")
+		if err := ToJsons(srcRdb, actualFile, WithTypedJSON()); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(actualFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var records []*typedJSONRecord
+		if err := decodeStrict(data, &records); err != nil {
+			t.Fatalf("%s: decode typed json failed: %v", name, err)
+		}
+		if len(records) == 0 {
+			t.Fatalf("%s: no objects", name)
+		}
+		for _, record := range records {
+			types[record.Type]++
+			if record.FieldExpireAt != nil && record.Type != "hash" {
+				t.Errorf("%s: unexpected fieldExpireAt", record.Key)
+			}
+			switch record.Type {
+			case "string":
+				var value string
+				err = decodeStrict(record.Value, &value)
+			case "list", "set":
+				var value []string
+				err = decodeStrict(record.Value, &value)
+				if err == nil && len(value) == 0 {
+					t.Errorf("%s: empty %s", record.Key, record.Type)
+				}
+			case "hash":
+				var value map[string]string
+				err = decodeStrict(record.Value, &value)
+				fieldExpirations += len(record.FieldExpireAt)
+				for field := range record.FieldExpireAt {
+					if _, ok := value[field]; !ok {
+						t.Errorf("%s: expiration of unknown field %s", record.Key, field)
+					}
+				}
+			case "zset":
+				var value []typedJSONZSetEntry
+				err = decodeStrict(record.Value, &value)
+			case "stream":
+				var value typedJSONStream
+				err = decodeStrict(record.Value, &value)
+				if err == nil && (value.Length != uint64(len(value.Entries)) || value.EntriesAdded == nil || len(value.Groups) != 1) {
+					t.Errorf("%s: unexpected stream %+v", record.Key, value)
+				}
+			default:
+				t.Errorf("%s: unexpected type %s", record.Key, record.Type)
+			}
+			if err != nil {
+				t.Errorf("%s: decode %s value failed: %v", record.Key, record.Type, err)
+			}
+		}
+	}
+	for _, typ := range []string{"string", "list", "set", "hash", "zset", "stream"} {
+		if types[typ] == 0 {
+			t.Errorf("expect %s objects", typ)
+		}
+	}
+	if fieldExpirations == 0 {
+		t.Error("expect field expirations of hash")
+	}
+}
@@ -8,16 +8,29 @@ const (
 	maxLit          = 1 << 5
 	maxOff          = 1 << 13
 	maxRef          = (1 << 8) + (1 << 3)
+
+	// maxExpansion is the max ratio of output length to input length,
+	// the longest back reference takes 3 input bytes and produces maxRef output bytes
+	maxExpansion = maxRef / 3
 )
 
 var (
 	errInsufficientBuffer = errors.New("insufficient buffer")
 	errDataCorruption     = errors.New("data corruption")
+	// ErrOverflow means compressed data claims or tries to write more than the declared output length
+	ErrOverflow = errors.New("lzf output overflow")
 )
 
 // using https://github.com/zhuyie/golzf according to MIT license
 // Decompress decompress lzf compressed data
 func Decompress(input []byte, inLen int, outLen int) ([]byte, error) {
+	if inLen > len(input) || inLen < 0 || outLen < 0 {
+		return nil, errDataCorruption
+	}
+	if outLen > inLen*maxExpansion {
+		// check before allocating, a tiny input can never be expanded to such a long output
+		return nil, ErrOverflow
+	}
 	input = input[:inLen]
 	output := make([]byte, outLen)
 	var inputIndex, outputIndex int
@@ -36,7 +49,7 @@ func Decompress(input []byte, inLen int, outLen int) ([]byte, error) {
 			ctrl++
 
 			if outputIndex+ctrl > outputLength {
-				return nil, errInsufficientBuffer
+				return nil, ErrOverflow
 			}
 
 			if inputIndex+ctrl > inputLength {
@@ -68,7 +81,7 @@ func Decompress(input []byte, inLen int, outLen int) ([]byte, error) {
 			inputIndex++
 
 			if outputIndex+length+2 > outputLength {
-				return nil, errDataCorruption
+				return nil, ErrOverflow
 			}
 
 			if ref < 0 {
@@ -36,3 +36,63 @@ func TestLzf(t *testing.T) {
 		}
 	}
 }
+
+func TestDecompressOverflow(t *testing.T) {
+	compressed, err := Compress([]byte(strings.Repeat("a", 1024)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// claims a huge output
+	_, err = Decompress(compressed, len(compressed), 1<<30)
+	if err != ErrOverflow {
+		t.Errorf("expect ErrOverflow, actual %v", err)
+	}
+	// declared output is shorter than actual
+	_, err = Decompress(compressed, len(compressed), 512)
+	if err != ErrOverflow {
+		t.Errorf("expect ErrOverflow, actual %v", err)
+	}
+	_, err = Decompress(compressed, len(compressed)+1, 1024)
+	if err == nil {
+		t.Error("expect error")
+	}
+}
+
+// TestDecompressOverlap checks back references whose distance is less than their length,
+// which have to be copied byte by byte to repeat the bytes produced by themselves
+func TestDecompressOverlap(t *testing.T) {
+	cases := []struct {
+		input  []byte
+		expect string
+	}{
+		// literal "x", then 100 bytes at distance 1
+		{input: []byte{0x00, 'x', 0xE0, 91, 0}, expect: strings.Repeat("x", 101)},
+		// literal "ab", then 100 bytes at distance 2
+		{input: []byte{0x01, 'a', 'b', 0xE0, 91, 1}, expect: strings.Repeat("ab", 51)},
+		// literal "abc", 5 bytes at distance 3, then literal "d"
+		{input: []byte{0x02, 'a', 'b', 'c', 0x60, 2, 0x00, 'd'}, expect: "abcabcabd"},
+	}
+	for _, c := range cases {
+		actual, err := Decompress(c.input, len(c.input), len(c.expect))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != c.expect {
+			t.Errorf("expect %s, actual %s", c.expect, actual)
+		}
+	}
+
+	// compressor emits overlapping references for long runs
+	str := strings.Repeat("a", 1000) + strings.Repeat("xyz", 300)
+	compressed, err := Compress([]byte(str))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decompressed, err := Decompress(compressed, len(compressed), len(str))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decompressed) != str {
+		t.Error("wrong decompressed run")
+	}
+}
@@ -1,12 +1,14 @@
 package memprofiler
 
 import (
+	"math"
 	"sort"
 	"strconv"
 	"unsafe"
 )
 
-var jemallocClasses = []int{
+// jemallocClasses are int64 since the large classes overflow int on 32-bit platforms
+var jemallocClasses = []int64{
 	8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 448, 512, 640, 768, 896, 1024,
 	1280, 1536, 1792, 2048, 2560, 3072, 3584, 4096, 5120, 6144, 7168, 8192, 10240, 12288, 14336, 16384, 20480, 24576,
 	28672, 32768, 40960, 49152, 57344, 65536, 81920, 98304, 114688, 131072, 163840, 196608, 229376, 262144, 327680,
@@ -39,9 +41,12 @@ var jemallocClasses = []int{
 
 func getJemallocSize(req int) int {
 	i := sort.Search(len(jemallocClasses), func(i int) bool {
-		return jemallocClasses[i] >= req
+		return jemallocClasses[i] >= int64(req)
 	})
-	return jemallocClasses[i]
+	if i == len(jemallocClasses) || jemallocClasses[i] > math.MaxInt {
+		return req
+	}
+	return int(jemallocClasses[i])
 }
 
 func sizeOfString(str string) int {
@@ -58,40 +63,51 @@ func sizeOfString(str string) int {
 		return getJemallocSize(size + 2 + 1)
 	} else if size < 25536 { // 2^16
 		return getJemallocSize(size + 1 + 4 + 1)
-	} else if size < 4294967296 { // 2^32
+	} else if uint64(size) < 4294967296 { // 2^32
 		return getJemallocSize(size + 1 + 8 + 1)
 	}
 	return getJemallocSize(size + 1 + 16 + 1)
 }
 
-func sizeOfPointer() int {
+func (meta *RedisMeta) sizeOfPointer() int {
+	if meta.is32Bit() {
+		return 4
+	}
 	return 8
 }
 
-func sizeOfLong() int {
+func (meta *RedisMeta) sizeOfLong() int {
+	if meta.is32Bit() {
+		return 4
+	}
 	return 8
 }
 
+// is32Bit returns true if redis is built for 32 bit architecture, nil meta means 64 bit
+func (meta *RedisMeta) is32Bit() bool {
+	return meta != nil && meta.Bits == 32
+}
+
 func unsafeBytes2Str(b []byte) string {
 	return *(*string)(unsafe.Pointer(&b))
 }
 
-func redisObjOverhead() int {
-	return sizeOfPointer() + 8
+func (meta *RedisMeta) redisObjOverhead() int {
+	return meta.sizeOfPointer() + 8
 }
 
-func topLevelObjectOverhead(key string, hasTTl bool) int {
-	size := hashTableEntryOverhead() + sizeOfString(key) + redisObjOverhead()
+func (meta *RedisMeta) topLevelObjectOverhead(key string, hasTTl bool) int {
+	size := meta.hashTableEntryOverhead() + sizeOfString(key) + meta.redisObjOverhead()
 	if !hasTTl {
 		return size
 	}
-	return size + expiryOverhead()
+	return size + meta.expiryOverhead()
 }
 
-func expiryOverhead() int {
+func (meta *RedisMeta) expiryOverhead() int {
 	// Key expiry is stored in a hashtable, so we have to pay for the cost of a hashtable entry
 	// The timestamp itself is stored as an int64, which is a 8 bytes
-	return hashTableEntryOverhead() + 8
+	return meta.hashTableEntryOverhead() + 8
 }
 
 func nextPower(size int) int {
@@ -2,13 +2,13 @@ package memprofiler
 
 import "github.com/hdt3213/rdb/model"
 
-func hashTableEntryOverhead() int {
+func (meta *RedisMeta) hashTableEntryOverhead() int {
 	// See  https://github.com/antirez/redis/blob/unstable/src/dict.h
 	// Each dictEntry has 2 pointers + int64
-	return 2*sizeOfPointer() + 8
+	return 2*meta.sizeOfPointer() + 8
 }
 
-func hashtableOverhead(size int) int {
+func (meta *RedisMeta) hashtableOverhead(size int) int {
 	// See  https://github.com/antirez/redis/blob/unstable/src/dict.h
 	// See the structures dict and dictht
 	// 2 * (3 unsigned longs + 1 pointer) + int + long + 2 pointers
@@ -19,10 +19,10 @@ func hashtableOverhead(size int) int {
 	// Due to the possibility of rehashing during loading, we calculate the worse
 	// case in which both tables are allocated, and so multiply
 	// the size of **table by 1.5
-	return 4 + 7*sizeOfLong() + 4*sizeOfPointer() + nextPower(size)*sizeOfPointer()*3/2
+	return 4 + 7*meta.sizeOfLong() + 4*meta.sizeOfPointer() + nextPower(size)*meta.sizeOfPointer()*3/2
 }
 
-func sizeOfHashObject(obj *model.HashObject) int {
+func (meta *RedisMeta) sizeOfHashObject(obj *model.HashObject) int {
 	if obj.GetEncoding() == model.ZipListEncoding {
 		extra := obj.Extra.(*model.ZiplistDetail)
 		return extra.RawStringSize
@@ -30,16 +30,16 @@ func sizeOfHashObject(obj *model.HashObject) int {
 		extra := obj.Extra.(*model.ListpackDetail)
 		return extra.RawStringSize
 	}
-	size := hashtableOverhead(len(obj.Hash))
+	size := meta.hashtableOverhead(len(obj.Hash))
 	for k, v := range obj.Hash {
-		size += hashTableEntryOverhead()
+		size += meta.hashTableEntryOverhead()
 		size += sizeOfString(k)
 		size += sizeOfString(unsafeBytes2Str(v))
 	}
 	return size
 }
 
-func sizeOfSetObject(obj *model.SetObject) int {
+func (meta *RedisMeta) sizeOfSetObject(obj *model.SetObject) int {
 	if obj.GetEncoding() == model.IntSetEncoding {
 		extra := obj.Extra.(*model.IntsetDetail)
 		return extra.RawStringSize
@@ -47,9 +47,9 @@ func sizeOfSetObject(obj *model.SetObject) int {
 		extra := obj.Extra.(*model.ListpackDetail)
 		return extra.RawStringSize
 	}
-	size := hashtableOverhead(len(obj.Members))
+	size := meta.hashtableOverhead(len(obj.Members))
 	for _, v := range obj.Members {
-		size += hashTableEntryOverhead() + sizeOfString(unsafeBytes2Str(v))
+		size += meta.hashTableEntryOverhead() + sizeOfString(unsafeBytes2Str(v))
 	}
 	return size
 }
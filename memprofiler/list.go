@@ -6,18 +6,18 @@ import (
 	"strconv"
 )
 
-func sizeOfListObject(obj *model.ListObject) int {
+func (meta *RedisMeta) sizeOfListObject(obj *model.ListObject) int {
 	switch obj.GetEncoding() {
 	case model.QuickListEncoding:
 		detail := obj.Extra.(*model.QuicklistDetail)
-		return sizeOfQuicklist(detail)
+		return meta.sizeOfQuicklist(detail)
 	case model.ListEncoding:
-		return sizeOfList(obj.Values)
+		return meta.sizeOfList(obj.Values)
 	case model.ZipListEncoding:
 		return sizeOfZiplist(obj.Values)
 	case model.QuickList2Encoding:
 		detail := obj.Extra.(*model.Quicklist2Detail)
-		return sizeOfQuicklist2(obj.Values, detail)
+		return meta.sizeOfQuicklist2(obj.Values, detail)
 	}
 	return 0
 }
@@ -78,9 +78,9 @@ func sizeOfZiplist(values [][]byte) int {
 	return size
 }
 
-func sizeOfQuicklist(detail *model.QuicklistDetail) int {
-	size := 2*sizeOfPointer() + sizeOfLong() + 2*4
-	nodeOverhead := 4*sizeOfPointer() + sizeOfLong() + 2*4
+func (meta *RedisMeta) sizeOfQuicklist(detail *model.QuicklistDetail) int {
+	size := 2*meta.sizeOfPointer() + meta.sizeOfLong() + 2*4
+	nodeOverhead := 4*meta.sizeOfPointer() + meta.sizeOfLong() + 2*4
 	size += len(detail.ZiplistStruct) * nodeOverhead
 	for _, ziplist := range detail.ZiplistStruct {
 		size += sizeOfZiplist(ziplist)
@@ -88,10 +88,10 @@ func sizeOfQuicklist(detail *model.QuicklistDetail) int {
 	return size
 }
 
-func sizeOfQuicklist2(values [][]byte, detail *model.Quicklist2Detail) int {
-	size := 2*sizeOfPointer() + 2*sizeOfLong() + 2*4
+func (meta *RedisMeta) sizeOfQuicklist2(values [][]byte, detail *model.Quicklist2Detail) int {
+	size := 2*meta.sizeOfPointer() + 2*meta.sizeOfLong() + 2*4
 	// https://github.com/CN-annotation-team/redis7.0-chinese-annotated/blob/7.0-cn-annotated/src/quicklist.h#L60
-	nodeOverhead := 3*sizeOfPointer() + sizeOfLong() + 4
+	nodeOverhead := 3*meta.sizeOfPointer() + meta.sizeOfLong() + 4
 	size += nodeOverhead * len(detail.NodeEncodings)
 	for i, enc := range detail.NodeEncodings {
 		if enc == model.QuicklistNodeContainerPlain {
@@ -107,12 +107,12 @@ func sizeOfQuicklist2(values [][]byte, detail *model.Quicklist2Detail) int {
 	return size
 }
 
-func sizeOfList(values [][]byte) int {
+func (meta *RedisMeta) sizeOfList(values [][]byte) int {
 	// See https://github.com/antirez/redis/blob/unstable/src/adlist.h
 	// A list has 5 pointers + an unsigned long
-	size := 5*sizeOfPointer() + sizeOfLong()
+	size := 5*meta.sizeOfPointer() + meta.sizeOfLong()
 	// A node has 3 pointers
-	entryHeadSize := 3 * sizeOfPointer()
+	entryHeadSize := 3 * meta.sizeOfPointer()
 	size += len(values) * entryHeadSize
 	// fixme: since redis 4.0, make it compatible with older version
 	for _, v := range values {
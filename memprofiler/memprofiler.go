@@ -12,23 +12,29 @@ type RedisMeta struct {
 	Bits    int // 32/64
 }
 
-// SizeOfObject evaluates memory usage of obj
+// SizeOfObject evaluates memory usage of obj in 64 bit redis
 func SizeOfObject(obj model.RedisObject) int {
-	// todo: memory profile by redis version and architecture
-	size := topLevelObjectOverhead(obj.GetKey(), obj.GetExpiration() != nil)
+	return SizeOfObjectWithMeta(obj, nil)
+}
+
+// SizeOfObjectWithMeta evaluates memory usage of obj in redis described by meta, nil meta means 64 bit redis.
+// Pointers and longs take 4 bytes in 32 bit redis
+func SizeOfObjectWithMeta(obj model.RedisObject, meta *RedisMeta) int {
+	// todo: memory profile by redis version
+	size := meta.topLevelObjectOverhead(obj.GetKey(), obj.GetExpiration() != nil)
 	switch o := obj.(type) {
 	case *model.StringObject:
 		size += sizeOfString(unsafeBytes2Str(o.Value))
 	case *model.ListObject:
-		size += sizeOfListObject(o)
+		size += meta.sizeOfListObject(o)
 	case *model.SetObject:
-		size += sizeOfSetObject(o)
+		size += meta.sizeOfSetObject(o)
 	case *model.HashObject:
-		size += sizeOfHashObject(o)
+		size += meta.sizeOfHashObject(o)
 	case *model.ZSetObject:
-		size += sizeOfZSetObject(o)
+		size += meta.sizeOfZSetObject(o)
 	case *model.StreamObject:
-		size += sizeOfStreamObject(o)
+		size += meta.sizeOfStreamObject(o)
 	}
 	return size
 }
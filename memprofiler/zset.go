@@ -6,12 +6,12 @@ import (
 	"math/rand"
 )
 
-func skipListOverhead(size int) int {
-	return 2*sizeOfPointer() + hashtableOverhead(size) + (2*sizeOfPointer() + 16)
+func (meta *RedisMeta) skipListOverhead(size int) int {
+	return 2*meta.sizeOfPointer() + meta.hashtableOverhead(size) + (2*meta.sizeOfPointer() + 16)
 }
 
-func skipListEntryOverhead() int {
-	return hashTableEntryOverhead() + 2*sizeOfPointer() + 8 + int(math.Round(float64(sizeOfPointer()+8)*MathExpectationOfRandomLevel))
+func (meta *RedisMeta) skipListEntryOverhead() int {
+	return meta.hashTableEntryOverhead() + 2*meta.sizeOfPointer() + 8 + int(math.Round(float64(meta.sizeOfPointer()+8)*MathExpectationOfRandomLevel))
 }
 
 // MathExpectationOfRandomLevel is mathematical expectation of zsetRandomLevel(), used to guarantee the stable results
@@ -32,7 +32,7 @@ func zsetRandomLevel() int {
 	return i
 }
 
-func sizeOfZSetObject(o *model.ZSetObject) int {
+func (meta *RedisMeta) sizeOfZSetObject(o *model.ZSetObject) int {
 	if o.GetEncoding() == model.ZipListEncoding {
 		extra := o.Extra.(*model.ZiplistDetail)
 		return extra.RawStringSize
@@ -40,9 +40,9 @@ func sizeOfZSetObject(o *model.ZSetObject) int {
 		extra := o.Extra.(*model.ListpackDetail)
 		return extra.RawStringSize
 	}
-	size := skipListOverhead(len(o.Entries))
+	size := meta.skipListOverhead(len(o.Entries))
 	for _, entry := range o.Entries {
-		size += sizeOfString(entry.Member) + 8 + skipListEntryOverhead() // size of score is 8 (double)
+		size += sizeOfString(entry.Member) + 8 + meta.skipListEntryOverhead() // size of score is 8 (double)
 	}
 	return size
 }
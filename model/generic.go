@@ -0,0 +1,7 @@
+package model
+
+// As converts obj to the given concrete type, e.g. `hash, ok := model.As[*model.HashObject](obj)`
+func As[T RedisObject](obj RedisObject) (T, bool) {
+	t, ok := obj.(T)
+	return t, ok
+}
@@ -1,7 +1,11 @@
 package model
 
 import (
+	"bytes"
 	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -22,11 +26,21 @@ const (
 	DBSizeType = "dbsize"
 	// StreamType is a redis stream
 	StreamType = "stream"
+	// ScriptType is lua script cached by redis 4-6, which is stored as aux field `lua`
+	ScriptType = "script"
+	// FunctionType is redis function library
+	FunctionType = "function"
 )
 
 const (
 	// StringEncoding for string
 	StringEncoding = "string"
+	// StringIntEncoding is string which can be represented as a 64 bit signed integer
+	StringIntEncoding = "int"
+	// StringEmbStrEncoding is string no longer than 44 bytes, allocated together with redis object
+	StringEmbStrEncoding = "embstr"
+	// StringRawEncoding is string longer than 44 bytes
+	StringRawEncoding = "raw"
 	// ListEncoding is formed by a length encoding and some string
 	ListEncoding = "list"
 	// SetEncoding is formed by a length encoding and some string
@@ -74,6 +88,12 @@ type RedisObject interface {
 	GetElemCount() int
 	// GetEncoding returns encoding of object
 	GetEncoding() string
+	// GetDiskSize returns number of bytes the object takes in rdb file
+	GetDiskSize() int
+	// GetDumpPayload returns value in the format of DUMP command, only available if decoder WithDumpPayload
+	GetDumpPayload() []byte
+	// GetRawContainer returns ziplist, listpack, intset or zipmap of value, only available if decoder WithRawContainer
+	GetRawContainer() []byte
 }
 
 // BaseObject is basement of redis object
@@ -85,6 +105,22 @@ type BaseObject struct {
 	Type       string      `json:"type"`                 // Type is one of string/list/set/hash/zset
 	Encoding   string      `json:"encoding"`             // Encoding is the exact encoding method
 	Extra      interface{} `json:"-"`                    // Extra stores more detail of encoding for memory profiler and other usages
+	Truncated  bool        `json:"truncated,omitempty"`  // Truncated is true if some elements were skipped because of element cap
+	DiskSize   int         `json:"-"`                    // DiskSize is number of bytes the object takes in rdb file, including type and key
+	// Idle is LRU idle time in seconds, it is nil if rdb has no idle metadata for the key
+	Idle *uint64 `json:"idle,omitempty"`
+	// KeyBytes and KeyRunes are byte length and character count of key, they are nil unless json converter WithKeyLength
+	KeyBytes *int `json:"key_bytes,omitempty"`
+	KeyRunes *int `json:"key_runes,omitempty"`
+	// StartOffset and EndOffset delimit bytes of object in rdb file, including its expire, freq and idle opcodes.
+	// EndOffset is exclusive. Only available if decoder WithByteRanges
+	StartOffset int64 `json:"-"`
+	EndOffset   int64 `json:"-"`
+	// DumpPayload is serialized value in the format of DUMP command, only available if decoder WithDumpPayload
+	DumpPayload []byte `json:"-"`
+	// RawContainer is decompressed ziplist, listpack, intset or zipmap the value is stored in, it is nil for values
+	// stored in other encodings, including quicklists of several containers. Only available if decoder WithRawContainer
+	RawContainer []byte `json:"-"`
 }
 
 // GetKey returns key of object
@@ -117,6 +153,21 @@ func (o *BaseObject) GetElemCount() int {
 	return 0
 }
 
+// GetDiskSize returns number of bytes the object takes in rdb file, including type and key
+func (o *BaseObject) GetDiskSize() int {
+	return o.DiskSize
+}
+
+// GetDumpPayload returns value in the format of DUMP command, only available if decoder WithDumpPayload
+func (o *BaseObject) GetDumpPayload() []byte {
+	return o.DumpPayload
+}
+
+// GetRawContainer returns ziplist, listpack, intset or zipmap of value, only available if decoder WithRawContainer
+func (o *BaseObject) GetRawContainer() []byte {
+	return o.RawContainer
+}
+
 // StringObject stores a string object
 type StringObject struct {
 	*BaseObject
@@ -177,6 +228,39 @@ type HashObject struct {
 	*BaseObject
 	Hash             map[string][]byte
 	FieldExpirations map[string]int64
+	// Fields is only set if decoder WithOrderedFields, it keeps on-disk order of listpack, ziplist and zipmap hashes.
+	// Fields of hashtable encoding have no meaningful order, they are sorted by name
+	Fields []HashField
+}
+
+// HashField is a field-value pair of hash
+type HashField struct {
+	Name  string
+	Value []byte
+}
+
+// marshalOrderedHash encodes fields as a json object in the order of fields
+func marshalOrderedHash(fields []HashField) (json.RawMessage, error) {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(field.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(string(field.Value))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
 }
 
 // GetType returns redis object type
@@ -189,31 +273,41 @@ func (o *HashObject) GetElemCount() int {
 	return len(o.Hash)
 }
 
-// MarshalJSON marshal []byte as string
+// MarshalJSON marshal []byte as string, fields are in the order of Fields if it is set
 func (o *HashObject) MarshalJSON() ([]byte, error) {
-	m := make(map[string]string)
-	for k, v := range o.Hash {
-		m[k] = string(v)
+	var hash interface{}
+	if o.Fields != nil {
+		ordered, err := marshalOrderedHash(o.Fields)
+		if err != nil {
+			return nil, err
+		}
+		hash = ordered
+	} else {
+		m := make(map[string]string)
+		for k, v := range o.Hash {
+			m[k] = string(v)
+		}
+		hash = m
 	}
-	if len(o.FieldExpirations) == len(o.Hash) {
+	if o.FieldExpirations != nil && len(o.FieldExpirations) == len(o.Hash) {
 		// hash/listpack with HFE
 		o2 := struct {
 			*BaseObject
-			Hash             map[string]string `json:"hash"`
-			FieldExpirations map[string]int64  `json:"expire"`
+			Hash             interface{}      `json:"hash"`
+			FieldExpirations map[string]int64 `json:"expire"`
 		}{
 			BaseObject:       o.BaseObject,
-			Hash:             m,
+			Hash:             hash,
 			FieldExpirations: o.FieldExpirations,
 		}
 		return json.Marshal(o2)
 	} else {
 		o2 := struct {
 			*BaseObject
-			Hash map[string]string `json:"hash"`
+			Hash interface{} `json:"hash"`
 		}{
 			BaseObject: o.BaseObject,
-			Hash:       m,
+			Hash:       hash,
 		}
 		return json.Marshal(o2)
 	}
@@ -255,6 +349,58 @@ func (o *SetObject) MarshalJSON() ([]byte, error) {
 type ZSetEntry struct {
 	Member string  `json:"member"`
 	Score  float64 `json:"score"`
+	// ScoreText is score as stored in rdb if it is stored as text, i.e. zset, ziplist and listpack encodings.
+	// It is empty for binary doubles of zset2
+	ScoreText string `json:"-"`
+}
+
+// ScoreNumber returns ScoreText if it is a json number of Score, so that score is written as it was stored,
+// without being rounded or padded. Otherwise it returns the shortest text which parses back to Score exactly
+func (e ZSetEntry) ScoreNumber() (json.Number, bool) {
+	if e.ScoreText != "" && json.Valid([]byte(e.ScoreText)) {
+		if score, err := strconv.ParseFloat(e.ScoreText, 64); err == nil && score == e.Score {
+			return json.Number(e.ScoreText), true
+		}
+	}
+	if math.IsInf(e.Score, 0) || math.IsNaN(e.Score) {
+		return "", false
+	}
+	data, _ := json.Marshal(e.Score)
+	return json.Number(data), true
+}
+
+// FormatScore formats score like ZSCORE of redis, i.e. the shortest digits in layout of %.17g such as 1e+300,
+// and inf, -inf and nan rather than +Inf, -Inf and NaN of Go
+func FormatScore(score float64) string {
+	if math.IsInf(score, 1) {
+		return "inf"
+	} else if math.IsInf(score, -1) {
+		return "-inf"
+	} else if math.IsNaN(score) {
+		return "nan"
+	}
+	// %g uses exponent form if the exponent is less than -4 or not less than the precision
+	s := strconv.FormatFloat(score, 'e', -1, 64)
+	if exp, _ := strconv.Atoi(s[strings.IndexByte(s, 'e')+1:]); exp < -4 || exp >= 17 {
+		return s
+	}
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+// MarshalJSON writes score as ScoreNumber, or as a FormatScore string if it is inf or nan
+func (e ZSetEntry) MarshalJSON() ([]byte, error) {
+	var score interface{} = FormatScore(e.Score)
+	if number, ok := e.ScoreNumber(); ok {
+		score = number
+	}
+	o2 := struct {
+		Member string      `json:"member"`
+		Score  interface{} `json:"score"`
+	}{
+		Member: e.Member,
+		Score:  score,
+	}
+	return json.Marshal(o2)
 }
 
 // ZSetObject stores a sorted set object
@@ -277,6 +423,8 @@ func (o *ZSetObject) GetElemCount() int {
 type AuxObject struct {
 	*BaseObject
 	Value string
+	// Bytes is value as read from file, it is binary safe, e.g. vendor fields may embed binary metadata
+	Bytes []byte `json:"-"`
 }
 
 // GetType returns redis object type
@@ -296,6 +444,34 @@ func (o *AuxObject) MarshalJSON() ([]byte, error) {
 	return json.Marshal(o2)
 }
 
+// ScriptObject stores a lua script cached by redis 4-6
+type ScriptObject struct {
+	*BaseObject
+	// SHA is sha1 hex digest of body, which is the name of script in EVALSHA
+	SHA  string `json:"sha"`
+	Body string `json:"body"`
+}
+
+// GetType returns redis object type
+func (o *ScriptObject) GetType() string {
+	return ScriptType
+}
+
+// FunctionLibrary stores a redis function library
+type FunctionLibrary struct {
+	*BaseObject
+	Name   string `json:"name"`
+	Engine string `json:"engine"`
+	// Functions are names of functions registered by library, which is extracted from code in the best effort
+	Functions []string `json:"functions"`
+	Code      string   `json:"code"`
+}
+
+// GetType returns redis object type
+func (o *FunctionLibrary) GetType() string {
+	return FunctionType
+}
+
 // DBSizeObject stores db size metadata
 type DBSizeObject struct {
 	*BaseObject
@@ -48,9 +48,42 @@ type StreamId struct {
 	Sequence uint64 `json:"sequence"`
 }
 
+// StreamID is alias of StreamId
+type StreamID = StreamId
+
+// String returns id in the form of ms-seq
+func (id *StreamId) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Sequence, 10)
+}
+
+// Compare returns -1 if id is less than other, 0 if they are equal and 1 if id is greater.
+// nil is less than any id
+func (id *StreamId) Compare(other *StreamId) int {
+	if id == nil || other == nil {
+		if id == other {
+			return 0
+		} else if id == nil {
+			return -1
+		}
+		return 1
+	}
+	if id.Ms != other.Ms {
+		if id.Ms < other.Ms {
+			return -1
+		}
+		return 1
+	}
+	if id.Sequence != other.Sequence {
+		if id.Sequence < other.Sequence {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
 func (id *StreamId) MarshalText() (text []byte, err error) {
-	txt := strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Sequence, 10)
-	return []byte(txt), nil
+	return []byte(id.String()), nil
 }
 
 // StreamGroup is a consumer group
@@ -64,15 +97,26 @@ type StreamGroup struct {
 	group reads. In the real world, the reasoning behind this value is detailed at the top comment of
 	streamEstimateDistanceFromFirstEverEntry(). */
 	EntriesRead uint64 `json:"entriesRead,omitempty"`
+	// Lag is number of entries added to stream but not read by group yet, which is AddedEntriesCount - EntriesRead.
+	// It is StreamLagUnknown if rdb predates entries-added (V1 streams) or entries read by group is unknown
+	Lag int64 `json:"lag"`
 }
 
+// StreamLagUnknown means lag of consumer group can not be computed
+const StreamLagUnknown int64 = -1
+
 // StreamNAck points a pending message
 type StreamNAck struct {
 	Id            *StreamId `json:"id"`
 	DeliveryTime  uint64    `json:"deliveryTime"`
 	DeliveryCount uint64    `json:"deliveryCount"`
+	// Consumer is name of the consumer who owns the pending message
+	Consumer string `json:"consumer,omitempty"`
 }
 
+// PendingEntry is a pending message of consumer group
+type PendingEntry = StreamNAck
+
 // StreamConsumer is a consumer
 type StreamConsumer struct {
 	Name       string      `json:"name"`
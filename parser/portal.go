@@ -23,6 +23,10 @@ const (
 	DBSizeType = model.DBSizeType
 	// StreamType is for redis stream
 	StreamType = model.StreamType
+	// ScriptType is for lua script cached by redis 4-6
+	ScriptType = model.ScriptType
+	// FunctionType is for redis function library
+	FunctionType = model.FunctionType
 )
 
 type (
@@ -44,9 +48,15 @@ type (
 	AuxObject = model.AuxObject
 	// DBSizeObject stores db size metadata
 	DBSizeObject = model.DBSizeObject
+	// ScriptObject stores a lua script cached by redis 4-6
+	ScriptObject = model.ScriptObject
+	// FunctionLibrary stores a redis function library
+	FunctionLibrary = model.FunctionLibrary
 )
 
 var (
 	// NewDecoder creates a new RDB decoder
 	NewDecoder = core.NewDecoder
+	// NewDecoderSize creates a new RDB decoder with a read buffer of at least bufSize bytes
+	NewDecoderSize = core.NewDecoderSize
 )